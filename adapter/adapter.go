@@ -0,0 +1,138 @@
+// Package adapter bridges dataloaden loaders and github.com/graph-gophers/dataloader loaders, so both APIs
+// can be used side by side while a service migrates from one to the other.
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/UnAfraid/dataloaden/v3"
+	"github.com/graph-gophers/dataloader"
+)
+
+// ErrValueType is returned when a graph-gophers loader produces a value that is neither a V nor a *V
+var ErrValueType = errors.New("adapter: unexpected value type")
+
+// ToGraphGophers exposes a dataloaden loader through the graph-gophers dataloader.Interface. fromKey
+// converts their keys into keys of the loader, a conversion error fails the load of that key. Thunks
+// resolve to the *V the loader returns, or to a nil interface for keys that were not found.
+func ToGraphGophers[K comparable, V any](loader *dataloaden.Loader[K, V], fromKey func(dataloader.Key) (K, error)) dataloader.Interface {
+	return &graphGophersLoader[K, V]{loader: loader, fromKey: fromKey}
+}
+
+type graphGophersLoader[K comparable, V any] struct {
+	loader  *dataloaden.Loader[K, V]
+	fromKey func(dataloader.Key) (K, error)
+}
+
+func (g *graphGophersLoader[K, V]) Load(ctx context.Context, key dataloader.Key) dataloader.Thunk {
+	k, err := g.fromKey(key)
+	if err != nil {
+		return func() (interface{}, error) {
+			return nil, err
+		}
+	}
+
+	thunk := g.loader.LoadThunkCtx(ctx, k)
+	return func() (interface{}, error) {
+		v, err := thunk()
+		if v == nil {
+			// a nil *V in the interface would not compare equal to nil
+			return nil, err
+		}
+		return v, err
+	}
+}
+
+func (g *graphGophersLoader[K, V]) LoadMany(ctx context.Context, keys dataloader.Keys) dataloader.ThunkMany {
+	thunks := make([]dataloader.Thunk, len(keys))
+	for i, key := range keys {
+		thunks[i] = g.Load(ctx, key)
+	}
+
+	return func() ([]interface{}, []error) {
+		data := make([]interface{}, len(keys))
+		errs := make([]error, len(keys))
+		failed := false
+		for i, thunk := range thunks {
+			data[i], errs[i] = thunk()
+			failed = failed || errs[i] != nil
+		}
+		// like graph-gophers, the errors are nil unless one of the keys failed
+		if !failed {
+			errs = nil
+		}
+		return data, errs
+	}
+}
+
+func (g *graphGophersLoader[K, V]) Clear(_ context.Context, key dataloader.Key) dataloader.Interface {
+	if k, err := g.fromKey(key); err == nil {
+		g.loader.Clear(k)
+	}
+	return g
+}
+
+func (g *graphGophersLoader[K, V]) ClearAll() dataloader.Interface {
+	g.loader.ClearAll()
+	return g
+}
+
+// Prime accepts a V or a *V, keys that fail to convert and values of other types are ignored
+func (g *graphGophersLoader[K, V]) Prime(_ context.Context, key dataloader.Key, value interface{}) dataloader.Interface {
+	k, err := g.fromKey(key)
+	if err != nil {
+		return g
+	}
+	if v, err := toValue[V](value); err == nil {
+		g.loader.Prime(k, v)
+	}
+	return g
+}
+
+// FromGraphGophers puts a graph-gophers loader behind a dataloaden loader. The returned
+// loader batches keys as configured by wait, maxBatch and opts and fetches every batch through LoadMany,
+// toKey converts its keys into graph-gophers keys. The values of the graph-gophers loader must be V or *V.
+// LoadMany gets the context of the fetch, see dataloaden.NewDataLoaderCtx: it carries the values of the
+// waiters and is canceled once all of them gave up.
+//
+// Both loaders batch and cache, so the graph-gophers loader is best created with a short wait and without
+// a cache of its own.
+func FromGraphGophers[K comparable, V any](loader dataloader.Interface, toKey func(K) dataloader.Key, wait time.Duration, maxBatch int, opts ...dataloaden.Option[K, V]) *dataloaden.Loader[K, V] {
+	fetch := func(ctx context.Context, keys []K) ([]*V, []error) {
+		ggKeys := make(dataloader.Keys, len(keys))
+		for i, key := range keys {
+			ggKeys[i] = toKey(key)
+		}
+
+		data, errs := loader.LoadMany(ctx, ggKeys)()
+		values := make([]*V, len(keys))
+		valueErrs := make([]error, len(keys))
+		for i := range keys {
+			if i < len(errs) && errs[i] != nil {
+				valueErrs[i] = errs[i]
+				continue
+			}
+			if i < len(data) {
+				values[i], valueErrs[i] = toValue[V](data[i])
+			}
+		}
+		return values, valueErrs
+	}
+	return dataloaden.NewDataLoaderCtx(fetch, wait, maxBatch, opts...)
+}
+
+func toValue[V any](value interface{}) (*V, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case *V:
+		return v, nil
+	case V:
+		return &v, nil
+	default:
+		return nil, fmt.Errorf("%w %T", ErrValueType, value)
+	}
+}
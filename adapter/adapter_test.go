@@ -0,0 +1,180 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/UnAfraid/dataloaden/v3"
+	"github.com/graph-gophers/dataloader"
+)
+
+// recordingFetch returns values of the form "v<key>", fails negative keys, finds no keys from 100 on and
+// records every batch it was called with
+type recordingFetch struct {
+	mu    sync.Mutex
+	calls [][]int
+}
+
+func (r *recordingFetch) fetch(keys []int) ([]*string, []error) {
+	r.mu.Lock()
+	r.calls = append(r.calls, append([]int(nil), keys...))
+	r.mu.Unlock()
+
+	results := make([]*string, len(keys))
+	errs := make([]error, len(keys))
+	for i, k := range keys {
+		if k < 0 {
+			errs[i] = errors.New("negative key " + strconv.Itoa(k))
+			continue
+		}
+		if k >= 100 {
+			continue
+		}
+		v := "v" + strconv.Itoa(k)
+		results[i] = &v
+	}
+	return results, errs
+}
+
+func (r *recordingFetch) batchFn(_ context.Context, keys dataloader.Keys) []*dataloader.Result {
+	intKeys := make([]int, len(keys))
+	for i, key := range keys {
+		intKeys[i], _ = fromKey(key)
+	}
+	values, errs := r.fetch(intKeys)
+
+	results := make([]*dataloader.Result, len(keys))
+	for i := range keys {
+		results[i] = &dataloader.Result{Error: errs[i]}
+		if values[i] != nil {
+			results[i].Data = *values[i]
+		}
+	}
+	return results
+}
+
+func toKey(k int) dataloader.Key {
+	return dataloader.StringKey(strconv.Itoa(k))
+}
+
+func fromKey(key dataloader.Key) (int, error) {
+	return strconv.Atoi(key.String())
+}
+
+func TestToGraphGophers(t *testing.T) {
+	rec := &recordingFetch{}
+	loader := ToGraphGophers(dataloaden.NewDataLoader(rec.fetch, time.Millisecond, 0), fromKey)
+	ctx := context.Background()
+
+	data, errs := loader.LoadMany(ctx, dataloader.Keys{toKey(1), toKey(2)})()
+	if errs != nil {
+		t.Fatalf("expected nil errors when every key loaded, got %v", errs)
+	}
+	if v := data[1].(*string); *v != "v2" {
+		t.Errorf("unexpected value %q", *v)
+	}
+	if len(rec.calls) != 1 {
+		t.Errorf("expected one batch, got %v", rec.calls)
+	}
+
+	if _, errs := loader.LoadMany(ctx, dataloader.Keys{toKey(1), toKey(-3)})(); len(errs) != 2 || errs[1] == nil {
+		t.Errorf("expected the error of the failed key, got %v", errs)
+	}
+
+	if v, err := loader.Load(ctx, toKey(100))(); v != nil || err != nil {
+		t.Errorf("expected a nil value for a key that was not found, got %#v, %v", v, err)
+	}
+
+	if _, err := loader.Load(ctx, dataloader.StringKey("nope"))(); err == nil {
+		t.Error("expected the key conversion error")
+	}
+
+	loader.Prime(ctx, toKey(5), "primed")
+	if v, err := loader.Load(ctx, toKey(5))(); err != nil || *v.(*string) != "primed" {
+		t.Errorf("unexpected primed value %v, %v", v, err)
+	}
+}
+
+func TestFromGraphGophers(t *testing.T) {
+	rec := &recordingFetch{}
+	gg := dataloader.NewBatchedLoader(rec.batchFn, dataloader.WithWait(time.Millisecond))
+	loader := FromGraphGophers[int, string](gg, toKey, time.Millisecond, 0)
+
+	values, errs := loader.LoadAll([]int{1, 2, 3})
+	if errs[0] != nil || errs[1] != nil || errs[2] != nil {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	if *values[0] != "v1" || *values[1] != "v2" {
+		t.Errorf("unexpected values %v", values)
+	}
+	if len(rec.calls) != 1 || len(rec.calls[0]) != 3 {
+		t.Errorf("expected one batch of 3 keys, got %v", rec.calls)
+	}
+
+	if _, err := loader.Load(-3); err == nil {
+		t.Error("expected the error of the graph-gophers loader")
+	}
+}
+
+func TestFromGraphGophersContext(t *testing.T) {
+	type ctxKey struct{}
+	seen := make(chan any, 1)
+	gg := dataloader.NewBatchedLoader(func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+		seen <- ctx.Value(ctxKey{})
+		results := make([]*dataloader.Result, len(keys))
+		for i := range results {
+			results[i] = &dataloader.Result{Data: "v"}
+		}
+		return results
+	}, dataloader.WithWait(time.Millisecond))
+	loader := FromGraphGophers[int, string](gg, toKey, time.Millisecond, 0)
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "trace")
+	if _, err := loader.LoadCtx(ctx, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v := <-seen; v != "trace" {
+		t.Errorf("expected the context of the load to reach the graph-gophers loader, got %v", v)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := loader.LoadCtx(canceled, 2); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the canceled load to give up, got %v", err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	rec := &recordingFetch{}
+	inner := dataloaden.NewDataLoader(rec.fetch, time.Millisecond, 0)
+	loader := FromGraphGophers[int, string](ToGraphGophers(inner, fromKey), toKey, time.Millisecond, 0)
+
+	values, errs := loader.LoadAll([]int{4, 5, 4})
+	for i, want := range []string{"v4", "v5", "v4"} {
+		if errs[i] != nil || *values[i] != want {
+			t.Errorf("key %d: unexpected result %v, %v", i, values[i], errs[i])
+		}
+	}
+	if len(rec.calls) != 1 || len(rec.calls[0]) != 2 {
+		t.Errorf("expected one batch of 2 keys, got %v", rec.calls)
+	}
+	if v, err := loader.Load(100); v != nil || err != nil {
+		t.Errorf("expected a key that was not found to stay missing, got %v, %v", v, err)
+	}
+
+	// values of another type are reported instead of silently dropped
+	gg := dataloader.NewBatchedLoader(func(_ context.Context, keys dataloader.Keys) []*dataloader.Result {
+		results := make([]*dataloader.Result, len(keys))
+		for i := range results {
+			results[i] = &dataloader.Result{Data: 42}
+		}
+		return results
+	}, dataloader.WithWait(time.Millisecond))
+	if _, err := FromGraphGophers[int, string](gg, toKey, time.Millisecond, 0).Load(1); !errors.Is(err, ErrValueType) {
+		t.Errorf("expected ErrValueType, got %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+module github.com/UnAfraid/dataloaden/v3/adapter
+
+go 1.25
+
+require (
+	github.com/UnAfraid/dataloaden/v3 v3.0.0
+	github.com/graph-gophers/dataloader v5.0.0+incompatible
+)
+
+require github.com/opentracing/opentracing-go v1.2.0 // indirect
+
+// the adapter is developed and tested against the loader in the same repository
+replace github.com/UnAfraid/dataloaden/v3 => ../
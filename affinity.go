@@ -0,0 +1,78 @@
+package dataloaden
+
+import (
+	"context"
+	"sync"
+)
+
+// affinityGroup is the keys of a batch that share an affinity label, with their positions in the batch
+type affinityGroup[K comparable] struct {
+	keys      []K
+	positions []int
+}
+
+// fetchGrouped splits the keys by their affinity label and fetches every group on its own, up to
+// affinityParallelism groups at a time. The results are routed back to the positions of the keys and the
+// errors are always aligned with them: an error a group's fetch returned for all of its keys fails only
+// that group.
+func (l *Loader[K, V]) fetchGrouped(ctx context.Context, keys []K) ([]*V, []error, error) {
+	var groups []*affinityGroup[K]
+	byLabel := map[string]*affinityGroup[K]{}
+	for pos, key := range keys {
+		label := l.affinity(key)
+		g, ok := byLabel[label]
+		if !ok {
+			g = &affinityGroup[K]{}
+			byLabel[label] = g
+			groups = append(groups, g)
+		}
+		g.keys = append(g.keys, key)
+		g.positions = append(g.positions, pos)
+	}
+	l.count(&l.stats.affinityGroups, uint64(len(groups)))
+
+	data := make([]*V, len(keys))
+	errs := make([]error, len(keys))
+	fetchGroup := func(g *affinityGroup[K]) {
+		groupData, groupErrs, groupErr := l.fetchPlain(ctx, g.keys)
+		for i, pos := range g.positions {
+			if i < len(groupData) {
+				data[pos] = groupData[i]
+			}
+			switch {
+			case len(groupErrs) == len(g.keys):
+				errs[pos] = groupErrs[i]
+			default:
+				errs[pos] = groupErr
+			}
+		}
+	}
+
+	if l.affinityParallelism <= 1 || len(groups) == 1 {
+		for _, g := range groups {
+			fetchGroup(g)
+		}
+		return data, errs, joinBatchErrors(errs)
+	}
+	// the groups write to disjoint positions
+	sem := make(chan struct{}, l.affinityParallelism)
+	var wg sync.WaitGroup
+	for _, g := range groups {
+		sem <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			fetchGroup(g)
+		})
+	}
+	wg.Wait()
+	return data, errs, joinBatchErrors(errs)
+}
+
+// keyError returns the error the waiters of pos get: the aggregate of the whole batch, unless the batch
+// isolates the errors of its keys and they are aligned with them
+func (b *genericLoaderBatch[K, V]) keyError(pos int) error {
+	if b.isolated && len(b.error) == len(b.keys) {
+		return b.error[pos]
+	}
+	return b.err
+}
@@ -0,0 +1,78 @@
+package dataloaden
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func shardOf(key int) string {
+	return "shard-" + strconv.Itoa(key%3)
+}
+
+func TestAffinity(t *testing.T) {
+	t.Run("routes results and isolates errors", func(t *testing.T) {
+		errShard := errors.New("shard-1 down")
+		var mu sync.Mutex
+		var calls [][]int
+		loader := NewDataLoader(func(keys []int) ([]*string, []error) {
+			mu.Lock()
+			calls = append(calls, slices.Clone(keys))
+			mu.Unlock()
+			if keys[0]%3 == 1 {
+				return nil, []error{errShard}
+			}
+			return (&recordingFetch{}).fetch(keys)
+		}, time.Millisecond, 0, WithAffinity[int, string](shardOf, 1))
+
+		keys := []int{0, 1, 2, 3, 4, 5, 6}
+		values, errs := loader.LoadAll(keys)
+		for i, key := range keys {
+			switch {
+			case key%3 == 1:
+				if !errors.Is(errs[i], errShard) || values[i] != nil {
+					t.Errorf("expected key %d to fail with its shard, got %v, %v", key, values[i], errs[i])
+				}
+			case errs[i] != nil || *values[i] != "v"+strconv.Itoa(key):
+				t.Errorf("expected v%d, got %v, %v", key, values[i], errs[i])
+			}
+		}
+		if !slices.EqualFunc(calls, [][]int{{0, 3, 6}, {1, 4}, {2, 5}}, slices.Equal) {
+			t.Errorf("expected one fetch per shard, got %v", calls)
+		}
+		if stats := loader.Stats(); stats.Batches != 1 || stats.AffinityGroups != 3 {
+			t.Errorf("expected 1 batch in 3 groups, got %d and %d", stats.Batches, stats.AffinityGroups)
+		}
+
+		if !loader.PeekInto(3, new(string)) || loader.PeekInto(4, new(string)) {
+			t.Error("expected only the keys of the shards that succeeded to be cached")
+		}
+	})
+
+	t.Run("parallel groups", func(t *testing.T) {
+		var started sync.WaitGroup
+		started.Add(3)
+		loader := NewDataLoader(func(keys []int) ([]*string, []error) {
+			started.Done()
+			started.Wait()
+			return (&recordingFetch{}).fetch(keys)
+		}, time.Millisecond, 0, WithAffinity[int, string](shardOf, 3))
+
+		done := make(chan []error)
+		go func() {
+			_, errs := loader.LoadAll([]int{0, 1, 2, 3})
+			done <- errs
+		}()
+		select {
+		case errs := <-done:
+			if err := errors.Join(errs...); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the groups to be fetched at the same time")
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package dataloaden
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// ErrAliasCycle fails the keys whose aliases lead back to themselves, see WithAliasResolver
+var ErrAliasCycle = errors.New("dataloaden: alias cycle")
+
+// aliasTable remembers the canonical key of every alias the resolver reported, so later loads of an alias
+// are answered under the canonical key without resolving it again
+type aliasTable[K comparable] struct {
+	mu        sync.RWMutex
+	canonical map[K]K
+}
+
+func (t *aliasTable[K]) get(key K) (K, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	canonical, ok := t.canonical[key]
+	return canonical, ok
+}
+
+func (t *aliasTable[K]) remember(learned map[K]K) {
+	if len(learned) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.canonical == nil {
+		t.canonical = make(map[K]K, len(learned))
+	}
+	for alias, canonical := range learned {
+		t.canonical[alias] = canonical
+	}
+}
+
+func (t *aliasTable[K]) clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.canonical = nil
+}
+
+// canonicalKey returns the canonical key of a key that is a known alias, the key itself otherwise
+func (l *Loader[K, V]) canonicalKey(key K) K {
+	if l.aliases == nil {
+		return key
+	}
+	if canonical, ok := l.aliases.get(key); ok {
+		return canonical
+	}
+	return key
+}
+
+// resolveAlias follows key through the aliases the resolver returned and the ones already known to its
+// canonical key
+func (l *Loader[K, V]) resolveAlias(key K, resolved map[K]K) (K, error) {
+	current := key
+	for range len(resolved) + 1 {
+		next, ok := resolved[current]
+		if !ok {
+			next, ok = l.aliases.get(current)
+		}
+		if !ok || next == current {
+			return current, nil
+		}
+		if next == key {
+			break
+		}
+		current = next
+	}
+	return key, l.namedError(fmt.Errorf("%w: key %v", ErrAliasCycle, key))
+}
+
+// fetchResolved rewrites the aliases among the keys to their canonical keys, fetches every canonical key
+// once and routes the results back to the positions of the original keys. A resolver that failed fails
+// the keys it returned no alias for, keys in an alias cycle fail with ErrAliasCycle.
+func (l *Loader[K, V]) fetchResolved(ctx context.Context, keys []K) ([]*V, []error, error) {
+	// the resolver gets a copy, so it cannot rewrite the keys the batch reports elsewhere
+	resolved, resolveErr := l.resolveAliases(slices.Clone(keys))
+	if resolveErr != nil {
+		resolveErr = l.namedError(resolveErr)
+	}
+
+	failed := make([]error, len(keys))
+	unique := make([]K, 0, len(keys))
+	index := make([]int, len(keys))
+	positions := make(map[K]int, len(keys))
+	learned := map[K]K{}
+	for i, key := range keys {
+		index[i] = -1
+		if _, ok := resolved[key]; !ok && resolveErr != nil {
+			failed[i] = resolveErr
+			continue
+		}
+		canonical, err := l.resolveAlias(key, resolved)
+		if err != nil {
+			failed[i] = err
+			continue
+		}
+		if canonical != key {
+			learned[key] = canonical
+		}
+		pos, ok := positions[canonical]
+		if !ok {
+			pos = len(unique)
+			positions[canonical] = pos
+			unique = append(unique, canonical)
+		}
+		index[i] = pos
+	}
+	l.aliases.remember(learned)
+
+	var data []*V
+	var errs []error
+	var err error
+	if len(unique) > 0 {
+		data, errs, err = l.fetchAffine(ctx, unique)
+	}
+
+	routedData := make([]*V, len(keys))
+	for i, pos := range index {
+		if pos >= 0 && pos < len(data) {
+			routedData[i] = data[pos]
+		}
+	}
+
+	// errors aligned with the fetched keys are routed like the data, any other shape applies to the whole
+	// batch, as do the errors of the keys the resolver failed
+	if len(errs) != 0 && len(errs) != len(unique) {
+		resolved := len(errs)
+		errs = slices.Clip(errs)
+		for _, failure := range failed {
+			if failure != nil {
+				errs = append(errs, failure)
+			}
+		}
+		if len(errs) == resolved {
+			return routedData, errs, err
+		}
+		return routedData, errs, joinBatchErrors(errs)
+	}
+	routedErrs := make([]error, len(keys))
+	for i, pos := range index {
+		switch {
+		case pos < 0:
+			routedErrs[i] = failed[i]
+		case len(errs) > 0:
+			routedErrs[i] = errs[pos]
+		}
+	}
+	return routedData, routedErrs, joinBatchErrors(routedErrs)
+}
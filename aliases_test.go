@@ -0,0 +1,113 @@
+package dataloaden
+
+import (
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mergedAccounts resolves the aliases left behind by merged accounts: 1 was merged into 2, which was
+// later merged into 3, and 5 and 6 were merged into each other both ways by mistake
+func mergedAccounts(calls *atomic.Int32) func([]int) (map[int]int, error) {
+	return func(keys []int) (map[int]int, error) {
+		calls.Add(1)
+		aliases := map[int]int{}
+		for _, key := range keys {
+			switch key {
+			case 1, 2:
+				aliases[1], aliases[2] = 2, 3
+			case 5, 6:
+				aliases[5], aliases[6] = 6, 5
+			}
+		}
+		return aliases, nil
+	}
+}
+
+func TestAliasResolver(t *testing.T) {
+	t.Run("chains and pairs in one batch", func(t *testing.T) {
+		var resolves atomic.Int32
+		rec := &recordingFetch{}
+		loader := NewDataLoader(rec.fetch, time.Millisecond, 0, WithAliasResolver[int, string](mergedAccounts(&resolves)))
+
+		values, errs := loader.LoadAll([]int{1, 3, 2, 4})
+		for i, want := range []string{"v3", "v3", "v3", "v4"} {
+			if errs[i] != nil || *values[i] != want {
+				t.Errorf("expected %s at %d, got %v, %v", want, i, values[i], errs[i])
+			}
+		}
+		if rec.callCount() != 1 || !slices.Equal(rec.calls[0], []int{3, 4}) {
+			t.Fatalf("expected one fetch of the canonical keys, got %v", rec.calls)
+		}
+
+		var cached string
+		if loader.PeekInto(1, &cached); cached != "v3" {
+			t.Errorf("expected the alias to read the canonical entry, got %q", cached)
+		}
+		loader.Clear(3)
+		if loader.PeekInto(2, &cached) {
+			t.Error("expected the result to be cached under the canonical key only")
+		}
+
+		_, _ = loader.Load(1)
+		_, _ = loader.Load(2)
+		if rec.callCount() != 2 || !slices.Equal(rec.calls[1], []int{3}) {
+			t.Errorf("expected the aliases to join the canonical key, got %v", rec.calls)
+		}
+		if n := resolves.Load(); n != 2 {
+			t.Errorf("expected known aliases not to be resolved again, got %d resolves", n)
+		}
+
+		loader.ClearAll()
+		_, _ = loader.Load(1)
+		if n := resolves.Load(); n != 3 || rec.callCount() != 3 {
+			t.Errorf("expected ClearAll to forget the aliases, got %d resolves", n)
+		}
+	})
+
+	t.Run("cycles", func(t *testing.T) {
+		var resolves atomic.Int32
+		rec := &recordingFetch{}
+		loader := NewDataLoader(rec.fetch, 0, 0, WithAliasResolver[int, string](mergedAccounts(&resolves)))
+		if _, err := loader.Load(5); !errors.Is(err, ErrAliasCycle) {
+			t.Errorf("expected ErrAliasCycle, got %v", err)
+		}
+		if n := rec.callCount(); n != 0 {
+			t.Errorf("expected a batch of only failed keys not to be fetched, got %d fetches", n)
+		}
+	})
+
+	t.Run("resolver failures", func(t *testing.T) {
+		errDirectory := errors.New("directory unavailable")
+		rec := &recordingFetch{}
+		loader := NewDataLoader(rec.fetch, time.Hour, 0, WithAliasResolver[int, string](func(keys []int) (map[int]int, error) {
+			if slices.Contains(keys, 7) {
+				return map[int]int{1: 3}, errDirectory
+			}
+			return nil, nil
+		}))
+
+		thunks := []func() (*string, error){loader.LoadThunk(1), loader.LoadThunk(7), loader.LoadThunk(8)}
+		loader.Flush()
+		for i, thunk := range thunks {
+			if _, err := thunk(); !errors.Is(err, errDirectory) {
+				t.Errorf("expected the resolver error to fail the batch at %d, got %v", i, err)
+			}
+		}
+		if stats := loader.Stats(); stats.FailedBatches != 1 || !slices.Equal(rec.calls[0], []int{3}) {
+			t.Errorf("expected only the alias the resolver reported to be fetched, got %v", rec.calls)
+		}
+
+		// the alias the failed resolver did report is kept
+		thunk := loader.LoadThunk(1)
+		loader.Flush()
+		if v, err := thunk(); err != nil || *v != "v3" {
+			t.Errorf("expected the canonical value, got %v, %v", v, err)
+		}
+		if !slices.Equal(rec.calls[1], []int{3}) {
+			t.Errorf("expected the canonical key to be fetched, got %v", rec.calls)
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package dataloaden
+
+import (
+	"container/list"
+	"context"
+	"hash/maphash"
+	"slices"
+	"sync"
+	"time"
+)
+
+// BatchMemo remembers the results of fetches by the set of keys they were called with, for replays that
+// send identical batches over and over, see WithBatchMemo. One memo can be shared by several loaders
+// fetching from the same source, e.g. a loader per replayed request. It is safe for concurrent use.
+type BatchMemo[K comparable, V any] struct {
+	max int
+	ttl time.Duration
+
+	mu      sync.Mutex
+	seed    maphash.Seed
+	hashKey func(key K) uint64
+	entries map[uint64]*list.Element
+	lru     list.List
+
+	// bumped by every invalidation, fetches that started before one do not store their results
+	epoch uint64
+}
+
+type batchMemoEntry[K comparable, V any] struct {
+	hash     uint64
+	keys     []K
+	data     []*V
+	errs     []error
+	storedAt time.Time
+}
+
+// NewBatchMemo creates a memo for the results of up to maxBatches key sets, each remembered for ttl,
+// 0 = until invalidated
+func NewBatchMemo[K comparable, V any](maxBatches int, ttl time.Duration) *BatchMemo[K, V] {
+	m := &BatchMemo[K, V]{max: maxBatches, ttl: ttl, seed: maphash.MakeSeed(), entries: map[uint64]*list.Element{}}
+	m.hashKey = func(key K) uint64 {
+		return maphash.Comparable(m.seed, key)
+	}
+	return m
+}
+
+// hash returns the same value for the same keys in any order, the keys of a batch are distinct
+func (m *BatchMemo[K, V]) hash(keys []K) uint64 {
+	var h uint64
+	for _, key := range keys {
+		h += m.hashKey(key)
+	}
+	return h
+}
+
+// get returns the remembered results for keys aligned with keys, and the epoch to store fresh results with
+func (m *BatchMemo[K, V]) get(keys []K, now time.Time) (data []*V, errs []error, epoch uint64, ok bool) {
+	hash := m.hash(keys)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	elem, found := m.entries[hash]
+	if !found {
+		return nil, nil, m.epoch, false
+	}
+	entry := elem.Value.(*batchMemoEntry[K, V])
+	if m.ttl > 0 && now.Sub(entry.storedAt) >= m.ttl {
+		m.unsafeRemove(elem)
+		return nil, nil, m.epoch, false
+	}
+	// a different key set with the same hash
+	data, errs, ok = entry.aligned(keys)
+	if !ok {
+		return nil, nil, m.epoch, false
+	}
+	m.lru.MoveToFront(elem)
+	return data, errs, m.epoch, true
+}
+
+// aligned returns the results of the entry in the order of keys, ok is false when keys is a different set
+func (e *batchMemoEntry[K, V]) aligned(keys []K) ([]*V, []error, bool) {
+	if len(keys) != len(e.keys) {
+		return nil, nil, false
+	}
+	if slices.Equal(keys, e.keys) {
+		return slices.Clone(e.data), slices.Clone(e.errs), true
+	}
+
+	index := make(map[K]int, len(e.keys))
+	for i, key := range e.keys {
+		index[key] = i
+	}
+	var data []*V
+	if e.data != nil {
+		data = make([]*V, len(keys))
+	}
+	// errors that are not aligned with the keys apply to all of them in any order
+	errs := slices.Clone(e.errs)
+	alignedErrs := len(e.errs) == len(keys)
+	for i, key := range keys {
+		j, ok := index[key]
+		if !ok {
+			return nil, nil, false
+		}
+		if data != nil && j < len(e.data) {
+			data[i] = e.data[j]
+		}
+		if alignedErrs {
+			errs[i] = e.errs[j]
+		}
+	}
+	return data, errs, true
+}
+
+// put remembers the results of a fetch that started at epoch, unless the memo was invalidated since
+func (m *BatchMemo[K, V]) put(keys []K, data []*V, errs []error, epoch uint64, now time.Time) {
+	entry := &batchMemoEntry[K, V]{
+		hash:     m.hash(keys),
+		keys:     slices.Clone(keys),
+		data:     slices.Clone(data),
+		errs:     slices.Clone(errs),
+		storedAt: now,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if epoch != m.epoch || m.max <= 0 {
+		return
+	}
+	if elem, ok := m.entries[entry.hash]; ok {
+		m.unsafeRemove(elem)
+	}
+	m.entries[entry.hash] = m.lru.PushFront(entry)
+	for len(m.entries) > m.max {
+		m.unsafeRemove(m.lru.Back())
+	}
+}
+
+func (m *BatchMemo[K, V]) unsafeRemove(elem *list.Element) {
+	m.lru.Remove(elem)
+	delete(m.entries, elem.Value.(*batchMemoEntry[K, V]).hash)
+}
+
+// Invalidate forgets every remembered result, including those of fetches still running
+func (m *BatchMemo[K, V]) Invalidate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.epoch++
+	clear(m.entries)
+	m.lru.Init()
+}
+
+// InvalidateBatchMemo forgets every result remembered by the memo of a loader created WithBatchMemo.
+// Prime, Clear, ClearMany, ClearAll, ClearWhere, ClearFunc, ReplaceCache, BumpEpoch and Detach
+// invalidate it as well.
+func (l *Loader[K, V]) InvalidateBatchMemo() {
+	if l.batchMemo != nil {
+		l.batchMemo.Invalidate()
+	}
+}
+
+// fetchMemoized answers the keys from the batch memo, or fetches them and remembers the results unless
+// their error is Transient
+func (l *Loader[K, V]) fetchMemoized(ctx context.Context, keys []K) ([]*V, []error, error) {
+	data, errs, epoch, ok := l.batchMemo.get(keys, l.clock.Now())
+	if ok {
+		l.count(&l.stats.batchMemoHits, 1)
+		return data, errs, joinBatchErrors(errs)
+	}
+
+	data, errs, err := l.fetchFresh(ctx, keys)
+	if l.classify(err) != Transient {
+		l.batchMemo.put(keys, data, errs, epoch, l.clock.Now())
+	}
+	return data, errs, err
+}
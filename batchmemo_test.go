@@ -0,0 +1,155 @@
+package dataloaden
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBatchMemo(t *testing.T) {
+	newLoader := func(memo *BatchMemo[int, string], rec *recordingFetch, opts ...Option[int, string]) *Loader[int, string] {
+		return NewDataLoader(rec.fetch, time.Millisecond, 0, append(opts, WithBatchMemo(memo))...)
+	}
+
+	t.Run("replayed batches", func(t *testing.T) {
+		memo := NewBatchMemo[int, string](10, 0)
+		rec := &recordingFetch{}
+		_, _ = newLoader(memo, rec).LoadAll([]int{1, 2, 3})
+
+		replay := newLoader(memo, rec)
+		values, errs := replay.LoadAll([]int{3, 1, 2})
+		for i, key := range []int{3, 1, 2} {
+			if errs[i] != nil || *values[i] != "v"+strconv.Itoa(key) {
+				t.Errorf("expected v%d at position %d, got %v, %v", key, i, values[i], errs[i])
+			}
+		}
+		if rec.callCount() != 1 || replay.Stats().BatchMemoHits != 1 {
+			t.Errorf("expected the replay to be answered from the memo, got %d fetches and %+v", rec.callCount(), replay.Stats())
+		}
+
+		// a different key set is fetched
+		_, _ = newLoader(memo, rec).LoadAll([]int{1, 2})
+		if rec.callCount() != 2 {
+			t.Errorf("expected a fetch for a new key set, got %d fetches", rec.callCount())
+		}
+	})
+
+	t.Run("hash collisions", func(t *testing.T) {
+		memo := NewBatchMemo[int, string](10, 0)
+		memo.hashKey = func(int) uint64 { return 0 }
+		rec := &recordingFetch{}
+		_, _ = newLoader(memo, rec).LoadAll([]int{1, 2})
+
+		values, _ := newLoader(memo, rec).LoadAll([]int{3, 4})
+		if rec.callCount() != 2 || *values[0] != "v3" || *values[1] != "v4" {
+			t.Errorf("expected a colliding key set to be fetched, got %d fetches and %v", rec.callCount(), values)
+		}
+	})
+
+	t.Run("invalidation", func(t *testing.T) {
+		memo := NewBatchMemo[int, string](10, 0)
+		rec := &recordingFetch{}
+		writes := map[string]func(*Loader[int, string]){
+			"InvalidateBatchMemo": func(l *Loader[int, string]) { l.InvalidateBatchMemo() },
+			"Prime":               func(l *Loader[int, string]) { l.Prime(9, nil) },
+			"Clear":               func(l *Loader[int, string]) { l.Clear(9) },
+			"ClearAll":            func(l *Loader[int, string]) { l.ClearAll() },
+			"ReplaceCache":        func(l *Loader[int, string]) { l.ReplaceCache(nil) },
+			"Detach":              func(l *Loader[int, string]) { l.Detach() },
+		}
+		for name, write := range writes {
+			calls := rec.callCount()
+			_, _ = newLoader(memo, rec).LoadAll([]int{1, 2})
+			write(newLoader(memo, rec))
+			_, _ = newLoader(memo, rec).LoadAll([]int{1, 2})
+			if rec.callCount() != calls+2 {
+				t.Errorf("expected %s to invalidate the memo, got %d fetches", name, rec.callCount()-calls)
+			}
+			memo.Invalidate()
+		}
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		clock := newFakeClock()
+		memo := NewBatchMemo[int, string](10, time.Minute)
+		rec := &recordingFetch{}
+		load := func() {
+			loader := newLoader(memo, rec, WithClock[int, string](clock), WithEagerSingle[int, string]())
+			_, _ = loader.Load(1)
+		}
+
+		load()
+		clock.Advance(30 * time.Second)
+		load()
+		clock.Advance(30 * time.Second)
+		load()
+		if rec.callCount() != 2 {
+			t.Errorf("expected the memo to expire after a minute, got %d fetches", rec.callCount())
+		}
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		memo := NewBatchMemo[int, string](2, 0)
+		rec := &recordingFetch{}
+		for _, key := range []int{1, 2, 1, 3, 1, 2} {
+			_, _ = newLoader(memo, rec).LoadAll([]int{key})
+		}
+		// 1 was used more recently than 2, so 3 evicts 2
+		if rec.callCount() != 4 {
+			t.Errorf("expected 4 fetches, got %d", rec.callCount())
+		}
+	})
+}
+
+func TestBatchMemoErrors(t *testing.T) {
+	var calls int
+	errOdd := errors.New("odd")
+	errDown := errors.New("down")
+	transient := true
+	fetchFn := func(keys []int) ([]*string, []error) {
+		calls++
+		if transient {
+			return nil, []error{errDown}
+		}
+		values := make([]*string, len(keys))
+		errs := make([]error, len(keys))
+		for i, k := range keys {
+			if k%2 != 0 {
+				errs[i] = errOdd
+				continue
+			}
+			v := strconv.Itoa(k)
+			values[i] = &v
+		}
+		return values, errs
+	}
+	memo := NewBatchMemo[int, string](10, 0)
+	newLoader := func() *Loader[int, string] {
+		return NewDataLoader(fetchFn, time.Millisecond, 0, WithBatchMemo(memo),
+			WithClassifyError[int, string](func(err error) ErrorClass {
+				if errors.Is(err, errDown) {
+					return Transient
+				}
+				return Unknown
+			}))
+	}
+
+	// transient failures are not remembered
+	_, _ = newLoader().LoadAll([]int{1, 2})
+	transient = false
+	_, _ = newLoader().LoadAll([]int{1, 2})
+	if calls != 2 {
+		t.Fatalf("expected the transient failure to be fetched again, got %d fetches", calls)
+	}
+
+	results := newLoader().LoadAllResult([]int{2, 1})
+	if calls != 2 {
+		t.Fatalf("expected the replay to be answered from the memo, got %d fetches", calls)
+	}
+	for _, r := range results {
+		if !errors.Is(r.Err, errOdd) {
+			t.Errorf("expected the remembered error of the batch, got %+v", r)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package dataloaden
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the fetch while the circuit breaker is open
+var ErrCircuitOpen = errors.New("dataloaden: circuit breaker open")
+
+// circuitBreaker stops calling the fetch for a cooldown after threshold consecutive fatal failures. Once the
+// cooldown has passed batches are let through again, and the first fatal failure reopens it right away.
+// A nil breaker never opens.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	halfOpen  bool
+}
+
+func (b *circuitBreaker) allow(now time.Time) error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.Before(b.openUntil) {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// record feeds the outcome of a fetch to the breaker, failures that are not fatal leave it as it is
+func (b *circuitBreaker) record(now time.Time, success, fatal bool) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case success:
+		b.failures = 0
+		b.halfOpen = false
+	case fatal:
+		b.failures++
+		if b.halfOpen || b.failures >= b.threshold {
+			b.openUntil = now.Add(b.cooldown)
+			b.failures = 0
+			b.halfOpen = true
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package dataloaden
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrBudgetExceeded is delivered to a waiter whose load did not produce a result within the budget set
+// WithLoadBudget
+var ErrBudgetExceeded = errors.New("dataloaden: load budget exceeded")
+
+// await blocks until done is closed, or gives up once the context of the request is done, returning its
+// error, or once the budget of the request is spent, returning ErrBudgetExceeded. A result that is already
+// available is delivered even when the waiter gave up in the meantime. It gives up right away when the batch
+// is throttled until after the budget ends, see RetryAfterError.
+func (r loadRequest[K, V]) await(l *Loader[K, V], done <-chan struct{}) error {
+	var canceled <-chan struct{}
+	if r.ctx != nil {
+		canceled = r.ctx.Done()
+	}
+	select {
+	case <-done:
+		return nil
+	default:
+	}
+	if r.deadline.IsZero() {
+		select {
+		case <-done:
+			return nil
+		case <-canceled:
+			return r.ctx.Err()
+		}
+	}
+
+	remaining := r.deadline.Sub(l.clock.Now())
+	if remaining <= 0 {
+		return ErrBudgetExceeded
+	}
+	expired := make(chan struct{})
+	t := l.clock.AfterFunc(remaining, func() { close(expired) })
+	defer t.Stop()
+
+	for {
+		var throttled <-chan struct{}
+		if notice := r.batch.throttled.Load(); notice != nil {
+			if notice.until.After(r.deadline) {
+				return ErrBudgetExceeded
+			}
+			throttled = notice.changed
+		}
+		select {
+		case <-done:
+			return nil
+		case <-canceled:
+			return r.ctx.Err()
+		case <-expired:
+			return ErrBudgetExceeded
+		case <-throttled:
+		}
+	}
+}
+
+// abandoned is the result of a request that gave up waiting with err, its batch carries on and still caches
+// the value for later loads
+func (l *Loader[K, V]) abandoned(err error) Result[*V] {
+	if err == ErrBudgetExceeded {
+		return l.budgetExceeded()
+	}
+	return Result[*V]{Err: err}
+}
+
+// budgetExceeded is the result of a request that ran out of budget
+func (l *Loader[K, V]) budgetExceeded() Result[*V] {
+	l.count(&l.stats.budgetExceeded, 1)
+	return Result[*V]{Err: l.namedError(ErrBudgetExceeded)}
+}
+
+// deadline is when a request made now runs out of budget, zero without a budget
+func (l *Loader[K, V]) deadline() time.Time {
+	if l.loadBudget <= 0 {
+		return time.Time{}
+	}
+	return l.clock.Now().Add(l.loadBudget)
+}
@@ -0,0 +1,139 @@
+package dataloaden
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// awaitClock waits until the loader's goroutines caught up with the fake clock: the fetch was called calls
+// times and live timers are pending
+func awaitClock(t *testing.T, clock *fakeClock, f *scriptedFetch, calls, live int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for int(f.calls.Load()) != calls || clock.live() != live {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d calls and %d timers, got %d and %d", calls, live, f.calls.Load(), clock.live())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLoadBudget(t *testing.T) {
+	t.Run("shared across retries", func(t *testing.T) {
+		clock := newFakeClock()
+		f := &scriptedFetch{script: []error{errTimeout, errTimeout}}
+		loader := NewDataLoader(f.fetch, time.Millisecond, 0,
+			WithClock[int, string](clock),
+			WithClassifyError[int, string](classifyTestError),
+			WithRetry[int, string](2, 20*time.Millisecond),
+			WithLoadBudget[int, string](30*time.Millisecond),
+		)
+
+		done := make(chan error)
+		go func() {
+			_, err := loader.Load(1)
+			done <- err
+		}()
+
+		// window and budget, then budget and backoff after each failed attempt
+		awaitClock(t, clock, f, 0, 2)
+		go clock.Advance(time.Millisecond) // the window timer runs the fetch, which sleeps on the clock
+		awaitClock(t, clock, f, 1, 2)
+		clock.Advance(20 * time.Millisecond)
+		awaitClock(t, clock, f, 2, 2)
+		select {
+		case err := <-done:
+			t.Fatalf("expected the load to wait within its budget, got %v", err)
+		default:
+		}
+
+		clock.Advance(9 * time.Millisecond)
+		if err := <-done; !errors.Is(err, ErrBudgetExceeded) {
+			t.Fatalf("expected ErrBudgetExceeded once 30ms passed, got %v", err)
+		}
+
+		// the batch carries on and caches the third attempt
+		clock.Advance(11 * time.Millisecond)
+		awaitClock(t, clock, f, 3, 0)
+		var v string
+		for !loader.PeekInto(1, &v) {
+			time.Sleep(time.Millisecond)
+		}
+		if v != "v1" {
+			t.Errorf("expected v1 to be cached, got %q", v)
+		}
+		if stats := loader.Stats(); stats.BudgetExceeded != 1 || stats.Retries != 2 {
+			t.Errorf("expected 1 exceeded budget and 2 retries, got %+v", stats)
+		}
+	})
+
+	t.Run("covers the batch window", func(t *testing.T) {
+		clock := newFakeClock()
+		f := &scriptedFetch{}
+		loader := NewDataLoader(f.fetch, 50*time.Millisecond, 0,
+			WithClock[int, string](clock),
+			WithLoadBudget[int, string](10*time.Millisecond),
+		)
+
+		thunk := loader.LoadThunk(1)
+		done := make(chan error)
+		go func() {
+			_, err := thunk()
+			done <- err
+		}()
+		awaitClock(t, clock, f, 0, 2)
+		clock.Advance(10 * time.Millisecond)
+		if err := <-done; !errors.Is(err, ErrBudgetExceeded) {
+			t.Fatalf("expected ErrBudgetExceeded while the batch was queued, got %v", err)
+		}
+
+		clock.Advance(40 * time.Millisecond)
+		awaitClock(t, clock, f, 1, 0)
+		if v, err := loader.Load(1); err != nil || *v != "v1" {
+			t.Errorf("expected the queued batch to cache v1, got %v, %v", v, err)
+		}
+	})
+
+	t.Run("applies to eager singles", func(t *testing.T) {
+		clock := newFakeClock()
+		f := &scriptedFetch{script: []error{errTimeout}}
+		loader := NewDataLoader(f.fetch, time.Millisecond, 0,
+			WithClock[int, string](clock),
+			WithEagerSingle[int, string](),
+			WithClassifyError[int, string](classifyTestError),
+			WithRetry[int, string](1, 20*time.Millisecond),
+			WithLoadBudget[int, string](10*time.Millisecond),
+		)
+
+		done := make(chan error)
+		go func() {
+			_, err := loader.Load(1)
+			done <- err
+		}()
+		awaitClock(t, clock, f, 1, 2)
+		clock.Advance(10 * time.Millisecond)
+		if err := <-done; !errors.Is(err, ErrBudgetExceeded) {
+			t.Fatalf("expected ErrBudgetExceeded during the backoff, got %v", err)
+		}
+		clock.Advance(10 * time.Millisecond)
+		awaitClock(t, clock, f, 2, 0)
+	})
+
+	t.Run("delivers results that are ready", func(t *testing.T) {
+		clock := newFakeClock()
+		f := &scriptedFetch{}
+		loader := NewDataLoader(f.fetch, time.Millisecond, 0,
+			WithClock[int, string](clock),
+			WithLoadBudget[int, string](10*time.Millisecond),
+		)
+
+		thunk := loader.LoadThunk(1)
+		clock.Advance(time.Millisecond)
+		awaitClock(t, clock, f, 1, 0)
+		clock.Advance(time.Hour)
+		if v, err := thunk(); err != nil || *v != "v1" {
+			t.Errorf("expected the fetched value even though the thunk was called late, got %v, %v", v, err)
+		}
+	})
+}
@@ -0,0 +1,232 @@
+package dataloaden
+
+import (
+	"container/list"
+	"iter"
+)
+
+// entryCache stores the cached entries of a loader. Unbounded it is a plain map, bounded by maxBytes it
+// tracks the approximate size of its entries and evicts the least recently used ones to stay under the limit,
+// bounded by maxEntries it does the same for the number of entries.
+// It is not safe for concurrent use, the loader guards it with its mutex.
+type entryCache[K comparable, V any] struct {
+	// allocated on the first write by newStore, a map unless set by WithCompactCache
+	entries  entryStore[K, V]
+	newStore func() entryStore[K, V]
+
+	// bumped by every write, lets readers tell whether anything changed since they last looked
+	gen uint64
+
+	// bumped by every ReplaceCache, batches started before a replace do not overwrite its entries
+	replaced uint64
+
+	// the byte limit, 0 = unbounded, and the estimate of the size of an entry
+	maxBytes int
+	sizeOf   func(K, *V) int
+
+	// the entry limit, 0 = unbounded, see WithMaxCacheSize
+	maxEntries int
+
+	// asked before an entry is evicted to stay within maxBytes, see WithOnEvict
+	onEvict   func(K, *V) bool
+	maxVetoes int
+
+	// the tracked total and the keys of a bounded cache ordered from most to least recently used
+	bytes int
+	lru   *list.List
+	elems map[K]*list.Element
+}
+
+// entryStore holds the entries of a cache
+type entryStore[K comparable, V any] interface {
+	get(key K) (cacheEntry[V], bool)
+	set(key K, entry cacheEntry[V])
+	delete(key K)
+	len() int
+	all() iter.Seq2[K, cacheEntry[V]]
+}
+
+type mapStore[K comparable, V any] map[K]cacheEntry[V]
+
+func (m mapStore[K, V]) get(key K) (cacheEntry[V], bool) {
+	it, ok := m[key]
+	return it, ok
+}
+
+func (m mapStore[K, V]) set(key K, entry cacheEntry[V]) { m[key] = entry }
+func (m mapStore[K, V]) delete(key K)                   { delete(m, key) }
+func (m mapStore[K, V]) len() int                       { return len(m) }
+
+func (m mapStore[K, V]) all() iter.Seq2[K, cacheEntry[V]] {
+	return func(yield func(K, cacheEntry[V]) bool) {
+		for key, it := range m {
+			if !yield(key, it) {
+				return
+			}
+		}
+	}
+}
+
+// sizedKey is the value of an lru element
+type sizedKey[K comparable] struct {
+	key    K
+	size   int
+	vetoes int
+}
+
+func (c *entryCache[K, V]) bounded() bool {
+	return c.maxBytes > 0 || c.maxEntries > 0
+}
+
+// over reports whether the cache exceeds one of its limits
+func (c *entryCache[K, V]) over() bool {
+	return c.maxBytes > 0 && c.bytes > c.maxBytes || c.maxEntries > 0 && len(c.elems) > c.maxEntries
+}
+
+// empty returns an empty cache with the same limits and store
+func (c *entryCache[K, V]) empty() entryCache[K, V] {
+	return entryCache[K, V]{newStore: c.newStore, maxBytes: c.maxBytes, sizeOf: c.sizeOf, maxEntries: c.maxEntries, onEvict: c.onEvict, maxVetoes: c.maxVetoes}
+}
+
+func (c *entryCache[K, V]) len() int {
+	if c.entries == nil {
+		return 0
+	}
+	return c.entries.len()
+}
+
+// all iterates over the entries in no particular order
+func (c *entryCache[K, V]) all() iter.Seq2[K, cacheEntry[V]] {
+	if c.entries == nil {
+		return func(func(K, cacheEntry[V]) bool) {}
+	}
+	return c.entries.all()
+}
+
+func (c *entryCache[K, V]) store(key K, entry cacheEntry[V]) {
+	if c.entries == nil {
+		if c.newStore != nil {
+			c.entries = c.newStore()
+		} else {
+			c.entries = mapStore[K, V]{}
+		}
+	}
+	c.entries.set(key, entry)
+}
+
+// get returns the entry for key and marks it as recently used
+func (c *entryCache[K, V]) get(key K) (cacheEntry[V], bool) {
+	if c.entries == nil {
+		return cacheEntry[V]{}, false
+	}
+	it, ok := c.entries.get(key)
+	if ok && c.bounded() {
+		c.lru.MoveToFront(c.elems[key])
+	}
+	return it, ok
+}
+
+// peek returns the entry for key without marking it as used
+func (c *entryCache[K, V]) peek(key K) (cacheEntry[V], bool) {
+	if c.entries == nil {
+		return cacheEntry[V]{}, false
+	}
+	return c.entries.get(key)
+}
+
+// set stores the entry for key and returns how many other entries were evicted to make room for it.
+// An entry larger than the whole limit is not stored.
+func (c *entryCache[K, V]) set(key K, entry cacheEntry[V]) (evicted int) {
+	c.gen++
+	if !c.bounded() {
+		c.store(key, entry)
+		return 0
+	}
+
+	size := 0
+	if c.maxBytes > 0 {
+		size = c.sizeOf(key, entry.value)
+		if size > c.maxBytes {
+			c.delete(key)
+			return 0
+		}
+	}
+	if el, ok := c.elems[key]; ok {
+		sk := el.Value.(*sizedKey[K])
+		c.bytes += size - sk.size
+		sk.size, sk.vetoes = size, 0
+		c.lru.MoveToFront(el)
+	} else {
+		if c.elems == nil {
+			c.elems = map[K]*list.Element{}
+			c.lru = list.New()
+		}
+		c.bytes += size
+		c.elems[key] = c.lru.PushFront(&sizedKey[K]{key: key, size: size})
+	}
+	c.store(key, entry)
+
+	// the new entry fits on its own, so the others are evicted from the back until it fits alongside them.
+	// Vetoed entries and the new one move to the front, every entry is vetoed a bounded number of times.
+	newest := c.elems[key]
+	for c.over() {
+		el := c.lru.Back()
+		if el == newest || c.vetoed(el) {
+			c.lru.MoveToFront(el)
+			continue
+		}
+		c.remove(el)
+		evicted++
+	}
+	return evicted
+}
+
+// vetoed asks onEvict whether the entry of el is kept, once it was kept maxVetoes times it is evicted
+// without asking
+func (c *entryCache[K, V]) vetoed(el *list.Element) bool {
+	sk := el.Value.(*sizedKey[K])
+	if c.onEvict == nil || sk.vetoes >= c.maxVetoes {
+		return false
+	}
+	it, _ := c.entries.get(sk.key)
+	if !c.onEvict(sk.key, it.value) {
+		return false
+	}
+	sk.vetoes++
+	return true
+}
+
+func (c *entryCache[K, V]) delete(key K) {
+	c.gen++
+	if el, ok := c.elems[key]; ok {
+		c.remove(el)
+		return
+	}
+	if c.entries != nil {
+		c.entries.delete(key)
+	}
+}
+
+func (c *entryCache[K, V]) remove(el *list.Element) {
+	sk := el.Value.(*sizedKey[K])
+	c.bytes -= sk.size
+	c.lru.Remove(el)
+	delete(c.elems, sk.key)
+	c.entries.delete(sk.key)
+}
+
+// clear removes every entry and keeps the limits
+func (c *entryCache[K, V]) clear() {
+	c.gen++
+	c.entries = nil
+	c.elems = nil
+	c.bytes = 0
+	c.lru = nil
+}
+
+// replace swaps in the entries of next, which was filled off to the side with the same limits
+func (c *entryCache[K, V]) replace(next entryCache[K, V]) {
+	next.gen = c.gen + 1
+	next.replaced = c.replaced + 1
+	*c = next
+}
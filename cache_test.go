@@ -0,0 +1,193 @@
+package dataloaden
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func stringSize(_ int, v *string) int {
+	if v == nil {
+		return 1
+	}
+	return len(*v)
+}
+
+func TestEntryCacheEviction(t *testing.T) {
+	c := entryCache[int, string]{maxBytes: 10, sizeOf: stringSize}
+	set := func(key int, v string) int {
+		return c.set(key, cacheEntry[string]{value: &v, found: true})
+	}
+
+	set(1, "aaaa")
+	set(2, "bbbb")
+	if c.bytes != 8 {
+		t.Fatalf("expected 8 bytes, got %d", c.bytes)
+	}
+
+	// re-inserting adjusts the total by the delta
+	set(1, "aa")
+	if c.bytes != 6 {
+		t.Fatalf("expected 6 bytes after shrinking key 1, got %d", c.bytes)
+	}
+
+	// key 2 is now the least recently used
+	if evicted := set(3, "ccccc"); evicted != 1 {
+		t.Fatalf("expected one eviction, got %d", evicted)
+	}
+	if _, ok := c.peek(2); ok {
+		t.Error("expected key 2 to be evicted")
+	}
+	if c.bytes != 7 {
+		t.Errorf("expected 7 bytes, got %d", c.bytes)
+	}
+
+	// reading key 1 makes key 3 the eviction candidate
+	c.get(1)
+	set(4, "dddd")
+	if _, ok := c.peek(3); ok {
+		t.Error("expected key 3 to be evicted")
+	}
+	if _, ok := c.peek(1); !ok {
+		t.Error("expected recently used key 1 to be kept")
+	}
+
+	// entries larger than the limit are not stored and replace nothing
+	set(1, strings.Repeat("x", 11))
+	if _, ok := c.peek(1); ok {
+		t.Error("expected oversized entry to be dropped")
+	}
+	if c.bytes != 4 || c.len() != 1 || c.lru.Len() != 1 {
+		t.Errorf("unexpected state: %d bytes, %d entries, %d lru", c.bytes, c.len(), c.lru.Len())
+	}
+
+	c.delete(4)
+	if c.bytes != 0 || c.len() != 0 || c.lru.Len() != 0 {
+		t.Errorf("unexpected state after delete: %d bytes, %d entries, %d lru", c.bytes, c.len(), c.lru.Len())
+	}
+}
+
+func TestMaxCacheBytes(t *testing.T) {
+	const maxBytes = 100
+	loader, rec := newStringLoader(t, time.Millisecond, WithMaxCacheBytes[int, string](maxBytes, stringSize))
+
+	for round := range 3 {
+		keys := make([]int, 20)
+		for i := range keys {
+			keys[i] = round*20 + i
+		}
+		if _, errs := loader.LoadAll(keys); errs[0] != nil {
+			t.Fatal(errs[0])
+		}
+		if stats := loader.Stats(); stats.CacheBytes > maxBytes {
+			t.Fatalf("round %d: cache grew to %d bytes, over the %d limit", round, stats.CacheBytes, maxBytes)
+		}
+	}
+
+	stats := loader.Stats()
+	if stats.Evictions == 0 {
+		t.Error("expected evictions")
+	}
+
+	// the most recent keys are still cached, the oldest were evicted
+	calls := rec.callCount()
+	_, _ = loader.Load(59)
+	if rec.callCount() != calls {
+		t.Error("expected the most recent key to be cached")
+	}
+	_, _ = loader.Load(0)
+	if rec.callCount() != calls+1 {
+		t.Error("expected the oldest key to be fetched again")
+	}
+
+	loader.ClearAll()
+	if stats := loader.Stats(); stats.CacheBytes != 0 {
+		t.Errorf("expected an empty cache, got %d bytes", stats.CacheBytes)
+	}
+}
+
+func TestMaxCacheSize(t *testing.T) {
+	loader, rec := newStringLoader(t, 0, WithMaxCacheSize[int, string](3))
+	cached := func() []int {
+		var keys []int
+		for key := range 7 {
+			if _, ok := loader.cache.peek(key); ok {
+				keys = append(keys, key)
+			}
+		}
+		return keys
+	}
+
+	_, _ = loader.Load(1)
+	_, _ = loader.Load(2)
+	_, _ = loader.Load(3)
+	// a hit makes 1 the most recently used, 2 is evicted next
+	_, _ = loader.Load(1)
+	_, _ = loader.Load(4)
+	if got := cached(); !slices.Equal(got, []int{1, 3, 4}) {
+		t.Fatalf("expected the least recently used key to be evicted, got %v", got)
+	}
+
+	// a prime of a cached key is a use as well, and a prime of a new key is a write
+	v := "p5"
+	loader.Prime(3, &v)
+	loader.Prime(5, &v)
+	if got := cached(); !slices.Equal(got, []int{3, 4, 5}) {
+		t.Fatalf("expected the prime to count as a use, got %v", got)
+	}
+	if n := rec.callCount(); n != 4 {
+		t.Errorf("expected 4 fetches, got %d", n)
+	}
+	if stats := loader.Stats(); stats.Evictions != 2 {
+		t.Errorf("expected 2 evictions, got %d", stats.Evictions)
+	}
+
+	unbounded, _ := newStringLoader(t, 0, WithMaxCacheSize[int, string](0))
+	_, _ = unbounded.LoadAll([]int{1, 2, 3, 4, 5})
+	if n := unbounded.cache.len(); n != 5 {
+		t.Errorf("expected a size of 0 to leave the cache unbounded, got %d entries", n)
+	}
+}
+
+func TestOnEvictVeto(t *testing.T) {
+	t.Run("bounded vetoes", func(t *testing.T) {
+		var asked []int
+		loader, _ := newStringLoader(t, time.Millisecond, WithMaxCacheBytes[int, string](8, stringSize),
+			WithOnEvict(func(key int, _ *string) bool {
+				asked = append(asked, key)
+				return key == 1
+			}, 2))
+
+		// every value is 4 bytes, so the cache holds 2 of them
+		for key := 1; key <= 7; key++ {
+			v := "p" + strconv.Itoa(key) + "__"
+			loader.Prime(key, &v)
+		}
+		// 1 is kept twice, then evicted without asking to make room for 7
+		if !slices.Equal(asked, []int{1, 2, 3, 1, 4, 5}) {
+			t.Errorf("expected onEvict to be asked for 1, 2, 3, 1, 4, 5, got %v", asked)
+		}
+		for key := 1; key <= 7; key++ {
+			if cached := loader.PeekInto(key, new(string)); cached != (key >= 6) {
+				t.Errorf("expected only 6 and 7 to be cached, got %v for %d", cached, key)
+			}
+		}
+	})
+
+	t.Run("always veto", func(t *testing.T) {
+		loader, _ := newStringLoader(t, time.Millisecond, WithMaxCacheBytes[int, string](8, stringSize),
+			WithOnEvict(func(int, *string) bool { return true }, 3))
+		for key := range 50 {
+			v := "p" + strconv.Itoa(key%10) + "__"
+			loader.Prime(key, &v)
+			if stats := loader.Stats(); stats.CacheBytes > 8 {
+				t.Fatalf("cache grew to %d bytes writing %d", stats.CacheBytes, key)
+			}
+			if !loader.PeekInto(key, new(string)) {
+				t.Fatalf("expected the written entry %d to be cached", key)
+			}
+		}
+	})
+}
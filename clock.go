@@ -0,0 +1,38 @@
+package dataloaden
+
+import "time"
+
+// Clock is the source of time of a loader: its batch windows, cache TTL and watchdog all run on it.
+// Tests can control time deterministically by passing their own implementation to WithClock.
+type Clock interface {
+	Now() time.Time
+
+	// AfterFunc calls f in its own goroutine once d has elapsed, unless the returned Timer is stopped first
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a pending call created by Clock.AfterFunc
+type Timer interface {
+	// Stop prevents the call from happening, it returns false if it already happened or was stopped
+	Stop() bool
+}
+
+// realClock is the default clock, backed by the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// sleep blocks until d has elapsed on clock
+func sleep(clock Clock, d time.Duration) {
+	done := make(chan struct{})
+	clock.AfterFunc(d, func() {
+		close(done)
+	})
+	<-done
+}
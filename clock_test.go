@@ -0,0 +1,313 @@
+package dataloaden
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock only moves when advanced, due timers run synchronously inside Advance
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	at    time.Time
+	f     func()
+	done  bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1_700_000_000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	stopped := !t.done
+	t.done = true
+	return stopped
+}
+
+// Advance moves the clock forward by d and runs the timers that became due, in order
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var due []*fakeTimer
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		switch {
+		case t.done:
+		case !t.at.After(c.now):
+			t.done = true
+			due = append(due, t)
+		default:
+			pending = append(pending, t)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+
+	slices.SortStableFunc(due, func(a, b *fakeTimer) int {
+		return a.at.Compare(b.at)
+	})
+	for _, t := range due {
+		t.f()
+	}
+}
+
+// live returns the number of timers that have neither fired nor been stopped
+func (c *fakeClock) live() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, t := range c.timers {
+		if !t.done {
+			n++
+		}
+	}
+	return n
+}
+
+// deadlineContext carries a deadline on the fake clock and is never cancelled
+type deadlineContext struct {
+	context.Context
+	deadline time.Time
+}
+
+func (c deadlineContext) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func withFakeDeadline(clock *fakeClock, d time.Duration) context.Context {
+	return deadlineContext{Context: context.Background(), deadline: clock.Now().Add(d)}
+}
+
+func TestFakeClockBatchWindow(t *testing.T) {
+	clock := newFakeClock()
+	loader, rec := newStringLoader(t, 10*time.Millisecond, WithClock[int, string](clock))
+
+	thunk := loader.LoadThunk(1)
+	clock.Advance(9 * time.Millisecond)
+	if calls := rec.callCount(); calls != 0 {
+		t.Fatalf("batch dispatched before the window elapsed, %d calls", calls)
+	}
+	clock.Advance(time.Millisecond)
+	if v, err := thunk(); err != nil || *v != "v1" {
+		t.Fatalf("unexpected result %v, %v", v, err)
+	}
+	if got := loader.Stats().BatchesByTrigger; got[WaitExpired] != 1 {
+		t.Errorf("expected one batch dispatched by the window, got %v", got)
+	}
+}
+
+func TestDeadlineFlush(t *testing.T) {
+	t.Run("earliest deadline dispatches early", func(t *testing.T) {
+		clock := newFakeClock()
+		loader, rec := newStringLoader(t, 20*time.Millisecond,
+			WithClock[int, string](clock), WithDeadlineFlush[int, string](2*time.Millisecond))
+
+		far := loader.LoadThunkCtx(withFakeDeadline(clock, time.Second), 1)
+		free := loader.LoadThunk(2)
+		near := loader.LoadThunkCtx(withFakeDeadline(clock, 10*time.Millisecond), 3)
+
+		clock.Advance(7 * time.Millisecond)
+		if calls := rec.callCount(); calls != 0 {
+			t.Fatalf("batch dispatched before the deadline margin, %d calls", calls)
+		}
+		clock.Advance(time.Millisecond)
+		for _, thunk := range []func() (*string, error){far, free, near} {
+			if _, err := thunk(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if calls := rec.callCount(); calls != 1 {
+			t.Fatalf("expected one batch, got %d", calls)
+		}
+		if got := loader.Stats().BatchesByTrigger; got[DeadlineFlush] != 1 || got[WaitExpired] != 0 {
+			t.Errorf("expected one batch dispatched by the deadline, got %v", got)
+		}
+	})
+
+	t.Run("deadline free waiters wait for the window", func(t *testing.T) {
+		clock := newFakeClock()
+		loader, rec := newStringLoader(t, 20*time.Millisecond,
+			WithClock[int, string](clock), WithDeadlineFlush[int, string](2*time.Millisecond))
+
+		first := loader.LoadThunkCtx(context.Background(), 1)
+		second := loader.LoadThunk(2)
+
+		clock.Advance(19 * time.Millisecond)
+		if calls := rec.callCount(); calls != 0 {
+			t.Fatalf("batch dispatched before the window elapsed, %d calls", calls)
+		}
+		clock.Advance(time.Millisecond)
+		_, _ = first()
+		_, _ = second()
+		if got := loader.Stats().BatchesByTrigger; got[WaitExpired] != 1 || got[DeadlineFlush] != 0 {
+			t.Errorf("expected one batch dispatched by the window, got %v", got)
+		}
+	})
+
+	t.Run("deadline after the window", func(t *testing.T) {
+		clock := newFakeClock()
+		loader, _ := newStringLoader(t, 20*time.Millisecond,
+			WithClock[int, string](clock), WithDeadlineFlush[int, string](2*time.Millisecond))
+
+		thunk := loader.LoadThunkCtx(withFakeDeadline(clock, 30*time.Millisecond), 1)
+		clock.Advance(20 * time.Millisecond)
+		_, _ = thunk()
+		if got := loader.Stats().BatchesByTrigger; got[WaitExpired] != 1 {
+			t.Errorf("expected the window to dispatch the batch, got %v", got)
+		}
+	})
+
+	t.Run("deadline within the margin dispatches immediately", func(t *testing.T) {
+		clock := newFakeClock()
+		loader, _ := newStringLoader(t, 20*time.Millisecond,
+			WithClock[int, string](clock), WithDeadlineFlush[int, string](5*time.Millisecond))
+
+		free := loader.LoadThunk(1)
+		v, err := loader.LoadCtx(withFakeDeadline(clock, 3*time.Millisecond), 2)
+		if err != nil || *v != "v2" {
+			t.Fatalf("unexpected result %v, %v", v, err)
+		}
+		if v, err := free(); err != nil || *v != "v1" {
+			t.Fatalf("unexpected result %v, %v", v, err)
+		}
+		if got := loader.Stats().BatchesByTrigger; got[DeadlineFlush] != 1 {
+			t.Errorf("expected one batch dispatched by the deadline, got %v", got)
+		}
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		clock := newFakeClock()
+		loader, rec := newStringLoader(t, 20*time.Millisecond, WithClock[int, string](clock))
+
+		thunk := loader.LoadThunkCtx(withFakeDeadline(clock, time.Millisecond), 1)
+		clock.Advance(10 * time.Millisecond)
+		if calls := rec.callCount(); calls != 0 {
+			t.Fatalf("batch dispatched early without WithDeadlineFlush, %d calls", calls)
+		}
+		clock.Advance(10 * time.Millisecond)
+		_, _ = thunk()
+	})
+}
+
+func TestEagerSingle(t *testing.T) {
+	newLoader := func() (*Loader[int, string], *recordingFetch, *fakeClock) {
+		clock := newFakeClock()
+		loader, rec := newStringLoader(t, 10*time.Millisecond,
+			WithClock[int, string](clock), WithEagerSingle[int, string]())
+		return loader, rec, clock
+	}
+
+	t.Run("singleton", func(t *testing.T) {
+		loader, rec, _ := newLoader()
+
+		// no clock movement needed, the batch is dispatched as soon as its only key is waited for
+		if v, err := loader.Load(1); err != nil || *v != "v1" {
+			t.Fatalf("unexpected result %v, %v", v, err)
+		}
+		if got := loader.Stats().BatchesByTrigger; got[SingletonFlush] != 1 || rec.callCount() != 1 {
+			t.Errorf("expected one singleton batch, got %v", got)
+		}
+	})
+
+	t.Run("thunk invoked while singleton", func(t *testing.T) {
+		loader, rec, clock := newLoader()
+
+		thunk := loader.LoadThunk(1)
+		clock.Advance(time.Hour)
+		if calls := rec.callCount(); calls != 0 {
+			t.Fatalf("expected the singleton to wait for its thunk, got %d calls", calls)
+		}
+		if v, err := thunk(); err != nil || *v != "v1" {
+			t.Fatalf("unexpected result %v, %v", v, err)
+		}
+		if got := loader.Stats().BatchesByTrigger; got[SingletonFlush] != 1 {
+			t.Errorf("expected one singleton batch, got %v", got)
+		}
+	})
+
+	t.Run("pair", func(t *testing.T) {
+		loader, rec, clock := newLoader()
+
+		first := loader.LoadThunk(1)
+		clock.Advance(5 * time.Millisecond)
+		second := loader.LoadThunk(2)
+
+		// the window starts with the second key
+		clock.Advance(9 * time.Millisecond)
+		if calls := rec.callCount(); calls != 0 {
+			t.Fatalf("batch dispatched before the window elapsed, %d calls", calls)
+		}
+		clock.Advance(time.Millisecond)
+		_, _ = first()
+		_, _ = second()
+		if rec.callCount() != 1 || len(rec.calls[0]) != 2 {
+			t.Fatalf("expected both keys in one batch, got %v", rec.calls)
+		}
+		if got := loader.Stats().BatchesByTrigger; got[WaitExpired] != 1 || got[SingletonFlush] != 0 {
+			t.Errorf("expected one batch dispatched by the window, got %v", got)
+		}
+	})
+}
+
+func TestDispatchTiming(t *testing.T) {
+	clock := newFakeClock()
+	loader, rec := newStringLoader(t, 10*time.Millisecond, WithClock[int, string](clock))
+	loader.SetMaxBatch(3)
+
+	// duplicates do not count towards maxBatch, the third distinct key dispatches right away
+	thunks := []func() (*string, error){loader.LoadThunk(1), loader.LoadThunk(1), loader.LoadThunk(2), loader.LoadThunk(3)}
+	for _, thunk := range thunks {
+		_, _ = thunk()
+	}
+	if rec.callCount() != 1 || len(rec.calls[0]) != 3 {
+		t.Fatalf("expected one full batch, got %v", rec.calls)
+	}
+	if n := clock.live(); n != 0 {
+		t.Errorf("expected the window timer to be stopped, %d timers live", n)
+	}
+
+	// a batch that does not fill up waits exactly for its window, counted from its first key
+	clock.Advance(3 * time.Millisecond)
+	thunk := loader.LoadThunk(4)
+	clock.Advance(5 * time.Millisecond)
+	second := loader.LoadThunk(5)
+	clock.Advance(4 * time.Millisecond)
+	if calls := rec.callCount(); calls != 1 {
+		t.Fatalf("batch dispatched before its window elapsed, %d calls", calls)
+	}
+	clock.Advance(time.Millisecond)
+	_, _ = thunk()
+	_, _ = second()
+	if rec.callCount() != 2 || len(rec.calls[1]) != 2 {
+		t.Fatalf("expected a second batch of 2 keys, got %v", rec.calls)
+	}
+
+	want := map[TriggerReason]uint64{MaxBatchReached: 1, WaitExpired: 1}
+	if got := loader.Stats().BatchesByTrigger; got[MaxBatchReached] != want[MaxBatchReached] || got[WaitExpired] != want[WaitExpired] {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,37 @@
+package dataloaden
+
+// Cloner is implemented by values that know how to copy themselves. When the value type of a loader
+// implements it, on the value or the pointer, Prime, ReplaceCache, LoadInto and PeekInto copy values with
+// Clone instead of a shallow copy, so the cache and the caller never share what the value points to.
+// Types with a non-generic Clone() any whose result is a V or *V are recognized as well.
+type Cloner[V any] interface {
+	Clone() V
+}
+
+// cloneValue copies value, deeply when the value type is a Cloner
+func cloneValue[V any](value *V) *V {
+	if cpy, ok := cloned(value); ok {
+		return cpy
+	}
+	cpy := *value
+	return &cpy
+}
+
+// cloned copies value with its Clone method, ok is false when the value type has none
+func cloned[V any](value *V) (cpy *V, ok bool) {
+	switch c := any(value).(type) {
+	case Cloner[*V]:
+		return c.Clone(), true
+	case Cloner[V]:
+		v := c.Clone()
+		return &v, true
+	case interface{ Clone() any }:
+		switch v := c.Clone().(type) {
+		case *V:
+			return v, true
+		case V:
+			return &v, true
+		}
+	}
+	return nil, false
+}
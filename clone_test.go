@@ -0,0 +1,65 @@
+package dataloaden
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+type tagged struct {
+	tags []string
+}
+
+func (t *tagged) Clone() *tagged {
+	return &tagged{tags: slices.Clone(t.tags)}
+}
+
+type anyTagged struct {
+	tags []string
+}
+
+func (t anyTagged) Clone() any {
+	return anyTagged{tags: slices.Clone(t.tags)}
+}
+
+func TestCloner(t *testing.T) {
+	fetchFn := func(keys []int) ([]*tagged, []error) {
+		return make([]*tagged, len(keys)), nil
+	}
+	loader := NewDataLoader(fetchFn, time.Millisecond, 0)
+
+	primed := &tagged{tags: []string{"a"}}
+	loader.Prime(1, primed)
+	primed.tags[0] = "changed"
+
+	var dst tagged
+	if !loader.PeekInto(1, &dst) || dst.tags[0] != "a" {
+		t.Fatalf("expected the cache to hold a clone of the primed value, got %v", dst.tags)
+	}
+	dst.tags[0] = "changed"
+	if err := loader.LoadInto(1, &dst); err != nil || dst.tags[0] != "a" {
+		t.Fatalf("expected reads to hand out clones, got %v, %v", dst.tags, err)
+	}
+
+	replacement := &tagged{tags: []string{"b"}}
+	loader.ReplaceCache(map[int]*tagged{2: replacement})
+	replacement.tags[0] = "changed"
+	if v, err := loader.Load(2); err != nil || v.tags[0] != "b" {
+		t.Errorf("expected ReplaceCache to clone the values, got %v, %v", v, err)
+	}
+}
+
+func TestClonerAny(t *testing.T) {
+	fetchFn := func(keys []int) ([]*anyTagged, []error) {
+		return make([]*anyTagged, len(keys)), nil
+	}
+	loader := NewDataLoader(fetchFn, time.Millisecond, 0)
+
+	primed := &anyTagged{tags: []string{"a"}}
+	loader.Prime(1, primed)
+	primed.tags[0] = "changed"
+
+	if v, err := loader.Load(1); err != nil || v.tags[0] != "a" {
+		t.Errorf("expected the cache to hold a clone of the primed value, got %v, %v", v, err)
+	}
+}
@@ -0,0 +1,136 @@
+package dataloaden
+
+import (
+	"iter"
+	"math/bits"
+)
+
+const (
+	compactEmpty   uint8 = 0
+	compactDeleted uint8 = 1
+
+	// every live slot carries the low 7 bits of its hash with the high bit set, so probing only compares
+	// keys whose tags match
+	compactLive uint8 = 0x80
+)
+
+// compactStore is an open addressing hash table over keys hashed by the caller, with linear probing
+// and a load factor of up to 7/8. It is sized from a capacity hint instead of growing in powers of two
+// and keeps no per-bucket overhead besides a tag byte, which makes it smaller than a map for large
+// caches. Keys are always compared in full, colliding hashes only cost probes.
+type compactStore[K comparable, V any] struct {
+	hash    func(K) uint64
+	tags    []uint8
+	slots   []compactSlot[K, V]
+	used    int
+	deleted int
+}
+
+type compactSlot[K comparable, V any] struct {
+	key   K
+	entry cacheEntry[V]
+}
+
+func newCompactStore[K comparable, V any](hash func(K) uint64, capacityHint int) *compactStore[K, V] {
+	s := &compactStore[K, V]{hash: hash}
+	s.resize(max(capacityHint*8/7+1, 8))
+	return s
+}
+
+func (s *compactStore[K, V]) resize(capacity int) {
+	tags, slots := s.tags, s.slots
+	s.tags = make([]uint8, capacity)
+	s.slots = make([]compactSlot[K, V], capacity)
+	s.used, s.deleted = 0, 0
+	for i, tag := range tags {
+		if tag >= compactLive {
+			s.set(slots[i].key, slots[i].entry)
+		}
+	}
+}
+
+// find returns the slot holding key, or the slot to insert it at when it is not stored
+func (s *compactStore[K, V]) find(key K) (int, bool) {
+	h := s.hash(key)
+	tag := compactLive | uint8(h&0x7f)
+	i, _ := bits.Mul64(h, uint64(len(s.tags)))
+	insert := -1
+	for {
+		switch s.tags[i] {
+		case compactEmpty:
+			if insert < 0 {
+				insert = int(i)
+			}
+			return insert, false
+		case compactDeleted:
+			if insert < 0 {
+				insert = int(i)
+			}
+		case tag:
+			if s.slots[i].key == key {
+				return int(i), true
+			}
+		}
+		if i++; i == uint64(len(s.tags)) {
+			i = 0
+		}
+	}
+}
+
+func (s *compactStore[K, V]) get(key K) (cacheEntry[V], bool) {
+	i, ok := s.find(key)
+	if !ok {
+		return cacheEntry[V]{}, false
+	}
+	return s.slots[i].entry, true
+}
+
+func (s *compactStore[K, V]) set(key K, entry cacheEntry[V]) {
+	i, ok := s.find(key)
+	if ok {
+		s.slots[i].entry = entry
+		return
+	}
+	if s.tags[i] == compactEmpty && (s.used+s.deleted+1)*8 > len(s.tags)*7 {
+		// grow when live entries fill the table, otherwise clearing the deleted slots makes enough room
+		if (s.used+1)*2 > len(s.tags) {
+			s.resize(len(s.tags) * 2)
+		} else {
+			s.resize(len(s.tags))
+		}
+		s.set(key, entry)
+		return
+	}
+
+	if s.tags[i] == compactDeleted {
+		s.deleted--
+	}
+	s.tags[i] = compactLive | uint8(s.hash(key)&0x7f)
+	s.slots[i] = compactSlot[K, V]{key: key, entry: entry}
+	s.used++
+}
+
+func (s *compactStore[K, V]) delete(key K) {
+	i, ok := s.find(key)
+	if !ok {
+		return
+	}
+	s.tags[i] = compactDeleted
+	s.slots[i] = compactSlot[K, V]{}
+	s.used--
+	s.deleted++
+}
+
+func (s *compactStore[K, V]) len() int {
+	return s.used
+}
+
+func (s *compactStore[K, V]) all() iter.Seq2[K, cacheEntry[V]] {
+	return func(yield func(K, cacheEntry[V]) bool) {
+		for i, tag := range s.tags {
+			if tag >= compactLive && !yield(s.slots[i].key, s.slots[i].entry) {
+				return
+			}
+		}
+	}
+}
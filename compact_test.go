@@ -0,0 +1,157 @@
+package dataloaden
+
+import (
+	"hash/maphash"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCompactStore(t *testing.T) {
+	// a hash with many collisions exercises probing across deleted slots and full key comparison
+	s := newCompactStore[int, string](func(k int) uint64 { return uint64(k % 5) }, 4)
+	want := map[int]cacheEntry[string]{}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		key := rnd.Intn(64)
+		if rnd.Intn(3) == 0 {
+			s.delete(key)
+			delete(want, key)
+			continue
+		}
+		v := strconv.Itoa(i)
+		entry := cacheEntry[string]{value: &v, found: true}
+		s.set(key, entry)
+		want[key] = entry
+	}
+
+	if s.len() != len(want) {
+		t.Fatalf("expected %d entries, got %d", len(want), s.len())
+	}
+	for key := range 64 {
+		got, ok := s.get(key)
+		if w, wok := want[key]; ok != wok || got.value != w.value {
+			t.Errorf("key %d: expected %v %v, got %v %v", key, w.value, wok, got.value, ok)
+		}
+	}
+	seen := 0
+	for key, it := range s.all() {
+		if want[key].value != it.value {
+			t.Errorf("key %d: unexpected entry while iterating", key)
+		}
+		seen++
+	}
+	if seen != len(want) {
+		t.Errorf("expected to iterate %d entries, got %d", len(want), seen)
+	}
+}
+
+func TestCompactCache(t *testing.T) {
+	seed := maphash.MakeSeed()
+	hash := func(k int) uint64 { return maphash.Comparable(seed, k) }
+	loader, rec := newStringLoader(t, time.Millisecond, WithCompactCache[int, string](hash, 16))
+
+	keys := make([]int, 100)
+	for i := range keys {
+		keys[i] = i
+	}
+	if _, errs := loader.LoadAll(keys); errs[0] != nil {
+		t.Fatal(errs[0])
+	}
+	calls := rec.callCount()
+	values, _ := loader.LoadAll(keys)
+	for i, v := range values {
+		if *v != "v"+strconv.Itoa(i) {
+			t.Fatalf("key %d: unexpected value %s", i, *v)
+		}
+	}
+	if rec.callCount() != calls {
+		t.Error("expected every key to be cached")
+	}
+
+	if n := loader.ClearWhere(func(k int, _ *string) bool { return k%2 == 0 }); n != 50 {
+		t.Errorf("expected 50 entries cleared, got %d", n)
+	}
+	loader.ClearAll()
+	_, _ = loader.Load(1)
+	if rec.callCount() != calls+1 {
+		t.Error("expected the cleared cache to fetch again")
+	}
+}
+
+// BenchmarkCacheMemory fills a map cache, a map presized to the number of entries like the compact cache
+// is, and a compact cache with string keys and reports the heap each of them takes per entry, along with
+// the time to fill them.
+// Run it with -bench CacheMemory -benchtime 1x, the 8M cases need a few GB of memory.
+func BenchmarkCacheMemory(b *testing.B) {
+	seed := maphash.MakeSeed()
+	hash := func(k string) uint64 { return maphash.String(seed, k) }
+	stores := map[string]func(n int) entryStore[string, string]{
+		"map":          func(n int) entryStore[string, string] { return mapStore[string, string]{} },
+		"map-presized": func(n int) entryStore[string, string] { return make(mapStore[string, string], n) },
+		"compact":      func(n int) entryStore[string, string] { return newCompactStore[string, string](hash, n) },
+	}
+
+	for _, n := range []int{1_000_000, 8_000_000} {
+		keys := make([]string, n)
+		for i := range keys {
+			keys[i] = "user:" + strconv.Itoa(i)
+		}
+		v := "v"
+		entry := cacheEntry[string]{value: &v, found: true}
+
+		for _, name := range []string{"map", "map-presized", "compact"} {
+			b.Run(name+"/"+strconv.Itoa(n), func(b *testing.B) {
+				var before, after runtime.MemStats
+				for b.Loop() {
+					runtime.GC()
+					runtime.ReadMemStats(&before)
+					s := stores[name](n)
+					for _, key := range keys {
+						s.set(key, entry)
+					}
+					runtime.GC()
+					runtime.ReadMemStats(&after)
+					runtime.KeepAlive(s)
+				}
+				b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(n), "B/entry")
+			})
+		}
+	}
+}
+
+// BenchmarkCacheGet looks up cached keys in a map cache and a compact cache of 1M entries
+func BenchmarkCacheGet(b *testing.B) {
+	const n = 1_000_000
+	seed := maphash.MakeSeed()
+	hash := func(k string) uint64 { return maphash.String(seed, k) }
+	stores := map[string]entryStore[string, string]{
+		"map":     mapStore[string, string]{},
+		"compact": newCompactStore[string, string](hash, n),
+	}
+
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = "user:" + strconv.Itoa(i)
+	}
+	v := "v"
+	for _, s := range stores {
+		for _, key := range keys {
+			s.set(key, cacheEntry[string]{value: &v, found: true})
+		}
+	}
+
+	for _, name := range []string{"map", "compact"} {
+		b.Run(name, func(b *testing.B) {
+			s := stores[name]
+			for i := 0; b.Loop(); i++ {
+				if _, ok := s.get(keys[i%n]); !ok {
+					b.Fatal("missing key")
+				}
+			}
+		})
+	}
+}
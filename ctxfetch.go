@@ -0,0 +1,112 @@
+package dataloaden
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// NewDataLoaderCtx creates a loader like NewDataLoader whose fetch gets a context derived from the
+// waiters of the batch: it carries the values of the context of the first key's load, e.g. auth tokens or
+// a tracing span, and its deadline is the latest deadline among the waiters when all of them have one.
+// It is canceled once the context of every waiter is done, since the batch is needed until then, and when
+// Close gives up waiting for the loader's batches.
+//
+// Loads without a context, like Load, wait with context.Background and keep their batch from being
+// canceled. Keys the fetch returns neither a value nor an error for while its context is done fail with
+// the context's error.
+func NewDataLoaderCtx[K comparable, V any](fetchFn func(ctx context.Context, keys []K) ([]*V, []error), waitDuration time.Duration, maxBatch int, opts ...Option[K, V]) *Loader[K, V] {
+	l, err := newLoader(fetchFn, nil, waitDuration, maxBatch, opts)
+	if err != nil {
+		panic(err)
+	}
+	l.contextFetch = true
+	l.abort, l.cancelAbort = context.WithCancel(context.Background())
+	return l
+}
+
+// unsafeAddContext records the context of a waiter of a batch that has not been fetched yet, consecutive
+// loads with the same context, like those of LoadAll, are recorded once
+func (b *genericLoaderBatch[K, V]) unsafeAddContext(ctx context.Context) {
+	if n := len(b.contexts); n == 0 || b.contexts[n-1] != ctx {
+		b.contexts = append(b.contexts, ctx)
+	}
+}
+
+// fetchContext derives the context of the fetch of a batch from the contexts of its waiters, see
+// NewDataLoaderCtx. The returned cancel releases it once the fetch is done.
+func (b *genericLoaderBatch[K, V]) fetchContext(l *Loader[K, V]) (context.Context, context.CancelFunc) {
+	if !l.contextFetch {
+		return context.Background(), func() {}
+	}
+	l.mu.Lock()
+	contexts := b.contexts
+	b.contexts = nil
+	l.mu.Unlock()
+
+	base := context.Background()
+	if len(contexts) > 0 {
+		base = context.WithoutCancel(contexts[0])
+	}
+	ctx, cancel := context.WithCancel(base)
+	stops := []func() bool{context.AfterFunc(l.abort, cancel)}
+
+	var latest time.Time
+	cancelable, deadlines := len(contexts) > 0, len(contexts) > 0
+	for _, waiter := range contexts {
+		if waiter.Done() == nil {
+			cancelable = false
+		}
+		if deadline, ok := waiter.Deadline(); !ok {
+			deadlines = false
+		} else if deadline.After(latest) {
+			latest = deadline
+		}
+	}
+	if cancelable {
+		// the last waiter to give up cancels the fetch
+		var remaining atomic.Int32
+		remaining.Store(int32(len(contexts)))
+		for _, waiter := range contexts {
+			stops = append(stops, context.AfterFunc(waiter, func() {
+				if remaining.Add(-1) == 0 {
+					cancel()
+				}
+			}))
+		}
+	}
+	release := cancel
+	if deadlines {
+		var cancelDeadline context.CancelFunc
+		ctx, cancelDeadline = context.WithDeadline(ctx, latest)
+		release = func() {
+			cancelDeadline()
+			cancel()
+		}
+	}
+	return ctx, func() {
+		for _, stop := range stops {
+			stop()
+		}
+		release()
+	}
+}
+
+// canceledKeys fails the keys the fetch returned neither a value nor an error for with err, the error of
+// its context. Errors for the whole batch are kept as they are.
+func canceledKeys[K comparable, V any](keys []K, data []*V, errs []error, err error) []error {
+	if len(errs) == 1 && len(keys) != 1 && errs[0] != nil {
+		return errs
+	}
+	if len(errs) != 0 && len(errs) != len(keys) {
+		return errs
+	}
+	aligned := make([]error, len(keys))
+	copy(aligned, errs)
+	for i := range keys {
+		if aligned[i] == nil && (i >= len(data) || data[i] == nil) {
+			aligned[i] = err
+		}
+	}
+	return aligned
+}
@@ -0,0 +1,100 @@
+package dataloaden
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type traceKey struct{}
+
+// blockingCtxFetch hands the context of every call to the test and returns once it is done
+func blockingCtxFetch(calls chan<- context.Context) func(ctx context.Context, keys []int) ([]*string, []error) {
+	return func(ctx context.Context, keys []int) ([]*string, []error) {
+		calls <- ctx
+		<-ctx.Done()
+		return nil, nil
+	}
+}
+
+func TestNewDataLoaderCtx(t *testing.T) {
+	t.Run("values and deadline", func(t *testing.T) {
+		var trace any
+		var deadline time.Time
+		loader := NewDataLoaderCtx(func(ctx context.Context, keys []int) ([]*string, []error) {
+			trace = ctx.Value(traceKey{})
+			deadline, _ = ctx.Deadline()
+			return (&recordingFetch{}).fetch(keys)
+		}, time.Hour, 0)
+
+		now := time.Now()
+		first, cancelFirst := context.WithDeadline(context.WithValue(context.Background(), traceKey{}, "span-1"), now.Add(time.Minute))
+		defer cancelFirst()
+		second, cancelSecond := context.WithDeadline(context.Background(), now.Add(2*time.Minute))
+		defer cancelSecond()
+		thunks := []func() (*string, error){loader.LoadThunkCtx(first, 1), loader.LoadThunkCtx(second, 2)}
+		loader.Flush()
+		for _, thunk := range thunks {
+			if _, err := thunk(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if trace != "span-1" {
+			t.Errorf("expected the values of the first waiter, got %v", trace)
+		}
+		if !deadline.Equal(now.Add(2 * time.Minute)) {
+			t.Errorf("expected the latest deadline of the waiters, got %v", deadline)
+		}
+	})
+
+	t.Run("canceled once every waiter gave up", func(t *testing.T) {
+		calls := make(chan context.Context, 1)
+		loader := NewDataLoaderCtx(blockingCtxFetch(calls), time.Hour, 0)
+		first, cancelFirst := context.WithCancel(context.Background())
+		second, cancelSecond := context.WithCancel(context.Background())
+		thunks := []func() (*string, error){loader.LoadThunkCtx(first, 1), loader.LoadThunkCtx(second, 2)}
+		loader.Flush()
+
+		ctx := <-calls
+		cancelFirst()
+		time.Sleep(10 * time.Millisecond)
+		if err := ctx.Err(); err != nil {
+			t.Fatalf("expected the fetch to go on for the other waiter, got %v", err)
+		}
+		cancelSecond()
+		for _, thunk := range thunks {
+			if _, err := thunk(); !errors.Is(err, context.Canceled) {
+				t.Errorf("expected the keys to fail with the cancellation, got %v", err)
+			}
+		}
+	})
+
+	t.Run("waiters without a context", func(t *testing.T) {
+		calls := make(chan context.Context, 1)
+		loader := NewDataLoaderCtx(blockingCtxFetch(calls), time.Hour, 0)
+		waiter, cancel := context.WithCancel(context.Background())
+		canceled := loader.LoadThunkCtx(waiter, 1)
+		background := loader.LoadThunk(2)
+		loader.Flush()
+
+		ctx := <-calls
+		cancel()
+		time.Sleep(10 * time.Millisecond)
+		if err := ctx.Err(); err != nil {
+			t.Fatalf("expected the load without a context to keep the fetch going, got %v", err)
+		}
+
+		// Close giving up cancels every fetch
+		closeCtx, cancelClose := context.WithCancel(context.Background())
+		cancelClose()
+		if err := loader.Close(closeCtx); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected Close to give up, got %v", err)
+		}
+		for _, thunk := range []func() (*string, error){canceled, background} {
+			if _, err := thunk(); !errors.Is(err, context.Canceled) {
+				t.Errorf("expected the keys to fail with the cancellation, got %v", err)
+			}
+		}
+	})
+}
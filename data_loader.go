@@ -1,12 +1,20 @@
 package dataloaden
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// DataLoader batches and caches requests
+// ErrClosed is returned for keys that would need a fetch after the loader was closed
+var ErrClosed = errors.New("dataloaden: loader is closed")
+
+// DataLoader batches and caches requests. It is kept to the methods it was introduced with so that other
+// implementations and mocks of it keep compiling, the loaders of this package are *Loader values, which
+// offer much more.
 type DataLoader[K comparable, V any] interface {
 	// Load a User by key, batching and caching will be applied automatically
 	Load(key K) (*V, error)
@@ -34,199 +42,1344 @@ type DataLoader[K comparable, V any] interface {
 	Clear(key K)
 }
 
-// NewDataLoader creates a new data loader given a fetch, wait and maxBatch
-func NewDataLoader[K comparable, V any](fetchFn func(keys []K) ([]*V, []error), waitDuration time.Duration, maxBatch int) DataLoader[K, V] {
-	return &genericLoader[K, V]{
+// NewDataLoader creates a new data loader given a fetch, wait and maxBatch. It panics when the options
+// are invalid or conflict, see NewDataLoaderE.
+func NewDataLoader[K comparable, V any](fetchFn func(keys []K) ([]*V, []error), waitDuration time.Duration, maxBatch int, opts ...Option[K, V]) *Loader[K, V] {
+	l, err := newLoader(nil, nil, waitDuration, maxBatch, opts)
+	if err != nil {
+		panic(err)
+	}
+	l.plainFetch = fetchFn
+	return l
+}
+
+// NewDataLoaderE creates a new data loader like NewDataLoader, but returns an error wrapping
+// ErrInvalidOptions instead of panicking when an option has invalid settings or options that cannot be
+// combined are passed together. The error names the options and why they are rejected.
+func NewDataLoaderE[K comparable, V any](fetchFn func(keys []K) ([]*V, []error), waitDuration time.Duration, maxBatch int, opts ...Option[K, V]) (*Loader[K, V], error) {
+	l, err := newLoader(nil, nil, waitDuration, maxBatch, opts)
+	if err != nil {
+		return nil, err
+	}
+	l.plainFetch = fetchFn
+	return l, nil
+}
+
+// newLoader creates a loader for either a fetch or a streaming fetch and validates its options
+func newLoader[K comparable, V any](fetchFn func(ctx context.Context, keys []K) ([]*V, []error), stream func(keys []K, emit func(i int, v *V, err error)), waitDuration time.Duration, maxBatch int, opts []Option[K, V]) (*Loader[K, V], error) {
+	l := &Loader[K, V]{
 		fetch:    fetchFn,
+		stream:   stream,
 		wait:     waitDuration,
 		maxBatch: maxBatch,
+		clock:    realClock{},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if err := l.validateOptions(); err != nil {
+		return nil, err
 	}
+	if l.attached != nil {
+		l.cache, l.attached = *l.attached, nil
+		l.stats.cacheBytes.Store(uint64(l.cache.bytes))
+	}
+	return l, nil
 }
 
-type genericLoader[K comparable, V any] struct {
-	// this method provides the data for the loader
-	fetch func(keys []K) ([]*V, []error)
+// Loader is the DataLoader of this package, every constructor returns one. Beyond the methods of
+// DataLoader it offers context aware loads, cache management, stats and the operations of a Group member.
+type Loader[K comparable, V any] struct {
+	// identifies the loader in errors, watchdog reports and stats
+	name string
+
+	// this method provides the data for the loader, the context is only derived from the waiters of the
+	// batch for loaders created with NewDataLoaderCtx
+	fetch        func(ctx context.Context, keys []K) ([]*V, []error)
+	contextFetch bool
+
+	// the fetch of loaders created with NewDataLoader while fetch is nil, kept as it was passed so that
+	// creating a loader does not allocate a wrapper
+	plainFetch func(keys []K) ([]*V, []error)
+
+	// replaces fetch for loaders created with NewStreamingDataLoader
+	stream func(keys []K, emit func(i int, v *V, err error))
+
+	// which value wins when a pending key is primed
+	primePolicy PrimePolicy
+
+	// panics when the fetch breaks its contract, see WithStrict, or reports it to the handler
+	strict           bool
+	violationHandler func(loaderName, detail string)
+
+	// tells fetch errors apart for the retry, negative caching and the circuit breaker
+	classifyError func(error) ErrorClass
+	retries       int
+	retryBackoff  time.Duration
+	negativeCache bool
+	breaker       *circuitBreaker
+
+	// the longest a load may wait for its result across retries and queued batches, 0 = no limit
+	loadBudget time.Duration
 
 	// how long to done before sending a batch
 	wait time.Duration
 
+	// replace the fixed window of wait, see WithSlidingWindow
+	slide   time.Duration
+	maxWait time.Duration
+
+	// starts the window only once a batch has a second key, see WithEagerSingle
+	eagerSingle bool
+
+	// when enabled, a batch is dispatched deadlineMargin before the earliest deadline among its waiters
+	deadlineFlush  bool
+	deadlineMargin time.Duration
+
+	// the source of time for batch windows, entry ages and the watchdog
+	clock Clock
+
 	// this will limit the maximum number of keys to send in one batch, 0 = no limit
 	maxBatch int
 
+	// when set, a batch is also dispatched once the summed weight of its keys reaches maxBatchWeight
+	weight         func(key K) int
+	maxBatchWeight int
+
+	// rewrites keys to their canonical form, or rejects them, before anything else happens with them
+	normalizeKey func(key K) (K, error)
+
+	// rejects keys before they are added to a batch
+	keyFilter func(key K) error
+
+	// rewrites the keys of a batch before they are sent to the fetch
+	transformKeys func(keys []K) []K
+
+	// what loads from within the loader's own fetch do, see WithReentrantPolicy
+	reentrantPolicy ReentrantPolicy
+
+	// splits batches into one fetch per label, see WithAffinity
+	affinity            func(key K) string
+	affinityParallelism int
+
+	// the waiters of a key only get the error the fetch returned for it, see WithKeyErrors
+	keyErrors bool
+
+	// errors of batches are wrapped in a LoadError with the key formatted by loadErrorKey, see WithLoadErrors
+	loadErrors   bool
+	loadErrorKey func(key K) string
+
+	// the id of the last batch, guarded by mu
+	batchIDs uint64
+
+	// runs the fetches of dispatched batches, see WithExecutor
+	executor func(task func())
+
+	// reports the canonical keys of aliases before the fetch, see WithAliasResolver
+	resolveAliases func(keys []K) (map[K]K, error)
+
+	// the aliases the resolver reported, so later loads of them go to the canonical key right away
+	aliases *aliasTable[K]
+
+	// when set, produces the error for keys the fetch returned no value and no error for
+	missingValueError func(key K) error
+
+	// rewrites every fetched value before it is cached and delivered, see WithTransformValue
+	valueTransform func(key K, value *V) *V
+
+	// reports, and optionally fails, batches whose fetch does not return in time
+	watchdog Watchdog[K]
+
 	// lazily created cache
-	cache map[K]*V
+	cache entryCache[K, V]
+
+	// remembered LoadAll results, nil unless enabled with WithLoadAllMemo
+	memo *loadAllMemo[K, V]
+
+	// remembered fetch results, nil unless created WithBatchMemo
+	batchMemo *BatchMemo[K, V]
+
+	// the cache taken over from a CacheHandle, installed once all options are applied
+	attached *entryCache[K, V]
+
+	// the options that replaced the store of the cache, which validateOptions cannot tell from newStore
+	compactStore, prefixStore bool
+
+	// how long cached entries are fresh, 0 = forever. Expired entries are removed once a load finds them,
+	// unless they may still be served stale, see unsafeGet.
+	cacheTTL time.Duration
+
+	// the longest maxStale LoadStale was called with, expired entries are kept that much longer
+	staleWindow time.Duration
+
+	// serves the cached entry of a key whose fetch failed, see WithServeStaleOnError
+	serveStale bool
+	wrapStale  bool
+
+	// skips recording when and how entries were written, see Info
+	noEntryInfo bool
+
+	// skips counting, see WithLite
+	noStats bool
+
+	// primes fetched values into a parent loader, nil unless created WithPromote
+	promoter *promoter[K, V]
+
+	// counts loads by a label of their key, nil unless created WithStatsBy
+	statBuckets *statBuckets[K]
+
+	// receives one in sampleEvery loads, nil unless created WithLoadSampler
+	sampler     func(sample LoadSample[K])
+	sampleEvery atomic.Int64
+	sampleCount atomic.Uint64
+
+	// counts requests per key, nil unless created WithHotKeys
+	hotKeys *hotKeyTracker[K]
+
+	// reports loads after the loader's request is done, nil unless created WithLifetime
+	lifetime *lifetime
 
 	// the current batch. keys will continue to be collected until timeout is hit,
 	// then everything will be sent to the fetch method and out to the listeners
 	batch *genericLoaderBatch[K, V]
 
+	// the position of every key that is in the current batch or in a dispatched batch whose
+	// results have not reached the cache yet, so each key is only ever fetched by one batch at a time
+	pending map[K]batchPosition[K, V]
+
+	// number of batches that have been dispatched but not completed yet
+	dispatched int
+
+	// set by Close, no new batches are started afterward
+	closed bool
+
+	// tracks batches that have been started but not completed yet
+	inflight sync.WaitGroup
+
+	// canceled when Close gives up waiting for the inflight batches, only set by NewSingleFetchLoader and
+	// NewDataLoaderCtx
+	abort       context.Context
+	cancelAbort context.CancelFunc
+
+	// entries written at an older epoch are stale, see BumpEpoch
+	epoch atomic.Uint64
+
+	// the share of the loader in the dispatch slots of its Group, nil when it is not registered with one
+	slots atomic.Pointer[slotClient]
+
+	// counters reported by Stats
+	stats loaderStats
+
+	// holds loads back until the cache is warm, nil unless created WithReadyGate
+	gate *readyGate
+
+	// the most recent failed batches, nil unless created WithFailureLog
+	failures *failureLog[K]
+
 	// mutex to prevent races
-	mu sync.Mutex
+	mu loaderMutex
 }
 
+// loaderMutex is the mutex of a loader. Tests set acquisitions to count how often it is locked, it is nil
+// otherwise.
+type loaderMutex struct {
+	sync.Mutex
+	acquisitions *atomic.Uint64
+}
+
+func (m *loaderMutex) Lock() {
+	if m.acquisitions != nil {
+		m.acquisitions.Add(1)
+	}
+	m.Mutex.Lock()
+}
+
+// cacheEntry is a cached value along with whether the key was present in the fetched results
+type cacheEntry[V any] struct {
+	value *V
+	found bool
+
+	// when the entry was written in unix nanoseconds and by which write path, not recorded when the
+	// cache has no TTL and entry info is disabled
+	storedAt int64
+	source   EntrySource
+
+	// the epoch of the loader the value was fetched or primed at, see BumpEpoch
+	epoch uint64
+}
+
+func (l *Loader[K, V]) age(e cacheEntry[V]) time.Duration {
+	return l.clock.Now().Sub(time.Unix(0, e.storedAt))
+}
+
+// batchPosition locates a key within a batch
+type batchPosition[K comparable, V any] struct {
+	batch *genericLoaderBatch[K, V]
+	pos   int
+
+	// closed once the position is emitted, streaming batches only
+	ready chan struct{}
+}
+
+// the lifecycle of a batch: it collects keys until it is dispatched to the fetch. The state only changes
+// under the loader's mutex but can be read without it, which lets timers of batches that have already
+// been dispatched return without contending for the mutex.
+const (
+	batchCollecting uint32 = iota
+	batchDispatched
+)
+
 type genericLoaderBatch[K comparable, V any] struct {
+	id      uint64
+	started time.Time
 	keys    []K
+	weight  int
 	data    []*V
 	error   []error
-	closing bool
+	state   atomic.Uint32
+	trigger TriggerReason
 	done    chan struct{}
+	once    sync.Once
+
+	// dispatches the batch once the window has elapsed
+	timer Timer
+
+	// when the fetch started and how long it ran, see LoadError
+	fetchStart time.Time
+	fetchTime  time.Duration
+
+	// the contexts of the waiters, only recorded for loaders created with NewDataLoaderCtx
+	contexts []context.Context
+
+	// when the window of a sliding batch closes unless another key arrives, see WithSlidingWindow
+	slideUntil time.Time
+
+	// the earliest deadline among the waiters and the timer dispatching the batch ahead of it
+	deadline      time.Time
+	deadlineTimer Timer
+
+	// the cache's replace count when the batch started, see unsafeStore
+	replaced uint64
+
+	// the epoch of the loader when the batch was dispatched, see BumpEpoch
+	epoch uint64
+
+	// until when the fetch waits out a throttle, only set for loaders with a load budget
+	throttled atomic.Pointer[throttleNotice]
+
+	// entries primed under PreferPrimed while the batch was pending, by position. Written under the
+	// loader's mutex until the batch completes.
+	overrides map[int]cacheEntry[V]
+
+	// streaming batches release every position on its own, guarded by the loader's mutex
+	ready   []chan struct{}
+	emitted []bool
+	failed  bool
+
+	// the waiters of a batch fetched in groups by affinity or with WithKeyErrors only get the errors of
+	// their own key
+	isolated bool
+
+	// the aggregate of every error the fetch returned, computed once in end() so that
+	// every waiter of the batch shares the same immutable error value
+	err error
 }
 
-// Load a genericLoader by key, batching and caching will be applied automatically
-func (l *genericLoader[K, V]) Load(key K) (*V, error) {
-	return l.LoadThunk(key)()
+// Load a Loader by key, batching and caching will be applied automatically
+func (l *Loader[K, V]) Load(key K) (*V, error) {
+	r := l.load(context.Background(), key)
+	return r.Value, r.Err
 }
 
-// LoadThunk returns a function that when called will block waiting for a genericLoader.
+// LoadCtx loads a key like Load. The deadline of ctx lets the loader dispatch the batch early,
+// see WithDeadlineFlush, and loaders created with NewDataLoaderCtx pass ctx on to the fetch. It stops
+// waiting with ctx.Err() once ctx is done, the batch still completes and caches the value.
+func (l *Loader[K, V]) LoadCtx(ctx context.Context, key K) (*V, error) {
+	r := l.load(ctx, key)
+	return r.Value, r.Err
+}
+
+// LoadThunk returns a function that when called will block waiting for a Loader.
 // This method should be used if you want one goroutine to make requests to many
-// different data loaders without blocking until the thunk is called.
-func (l *genericLoader[K, V]) LoadThunk(key K) func() (*V, error) {
+// different data loaders without blocking until the thunk is called. Calling the thunk again
+// returns the same result without waiting or touching the cache again.
+func (l *Loader[K, V]) LoadThunk(key K) func() (*V, error) {
+	return l.LoadThunkCtx(context.Background(), key)
+}
+
+// LoadThunkCtx returns a thunk like LoadThunk. The deadline of ctx lets the loader dispatch the batch
+// early, see WithDeadlineFlush, and loaders created with NewDataLoaderCtx pass ctx on to the fetch. The
+// thunk stops waiting with ctx.Err() once ctx is done, the batch still completes and caches the value.
+func (l *Loader[K, V]) LoadThunkCtx(ctx context.Context, key K) func() (*V, error) {
+	req := l.request(ctx, key)
+	wait := sync.OnceValue(func() Result[*V] { return req.wait(l) })
+	return func() (*V, error) {
+		r := wait()
+		return r.Value, r.Err
+	}
+}
+
+// loadRequest is the claim of a single load on either an already known result or a position in a batch
+type loadRequest[K comparable, V any] struct {
+	key   K
+	batch *genericLoaderBatch[K, V]
+	pos   int
+	ready chan struct{}
+
+	// when the waiter gives up on the batch, zero without a load budget, and the context it gives up with
+	deadline time.Time
+	ctx      context.Context
+
+	// when a sampled load was requested, zero for loads that are not sampled
+	sampled time.Time
+
+	// the cached entry or the error of requests that were answered without a batch
+	entry cacheEntry[V]
+	err   error
+}
+
+// request answers the key from the cache or adds it to the current batch
+func (l *Loader[K, V]) request(ctx context.Context, key K) loadRequest[K, V] {
+	req, full := l.claim(ctx, key)
+
+	// the request filled its batch, which is handed to the fetch outside the lock
+	if full {
+		l.submit(req.batch)
+	}
+	return req
+}
+
+// load requests key and waits for its result. Loaders created with a maxBatch of 1 and no wait never
+// batch, a miss is fetched inline on the caller's goroutine instead: concurrent loads of the key join the
+// pending fetch like they would join a batch, which leaves the loader a cache with single flight.
+func (l *Loader[K, V]) load(ctx context.Context, key K) Result[*V] {
+	req, full := l.claim(ctx, key)
+	if full {
+		if l.inline(req.batch) && ctx.Done() == nil {
+			req.batch.end(l)
+		} else {
+			l.submit(req.batch)
+		}
+	}
+	return req.wait(l)
+}
+
+// inline reports whether a dispatched batch can be fetched on the goroutine that waits for it: the batch
+// holds the only key the loader would ever have put into it, and nothing needs the waiter to give up on
+// the fetch before it returns.
+func (l *Loader[K, V]) inline(b *genericLoaderBatch[K, V]) bool {
+	return l.wait == 0 && len(b.keys) == 1 && l.loadBudget == 0 && !l.watchdog.ForceComplete
+}
+
+// claim answers the key from the cache or adds it to the current batch, when the key filled the batch the
+// caller has to end it
+func (l *Loader[K, V]) claim(ctx context.Context, key K) (req loadRequest[K, V], full bool) {
+	l.checkLifetime()
+	l.count(&l.stats.loads, 1)
+	key, err := l.checkKey(key)
+	if err != nil {
+		return loadRequest[K, V]{key: key, err: err}, false
+	}
+
+	deadline, sampled := l.deadline(), l.sampleStart()
+	if err := l.passGate(ctx, deadline); err != nil {
+		return loadRequest[K, V]{key: key, err: err}, false
+	}
 	l.mu.Lock()
-	defer l.mu.Unlock()
-	if it, ok := l.cache[key]; ok {
-		return func() (*V, error) {
-			return it, nil
+	req, full = l.unsafeRequest(ctx, key)
+	l.mu.Unlock()
+	req.deadline, req.sampled, req.ctx = deadline, sampled, ctx
+	return req, full
+}
+
+func (l *Loader[K, V]) unsafeRequest(ctx context.Context, key K) (req loadRequest[K, V], full bool) {
+	l.unsafeRecordHot(key)
+	if it, ok := l.unsafeGet(key); ok {
+		l.count(&l.stats.cacheHits, 1)
+		l.unsafeCountBucket(key, true, false)
+		return loadRequest[K, V]{key: key, entry: it}, false
+	}
+	if !l.closed && l.withinFetch(ctx) {
+		// a pending batch may be the one whose fetch is loading
+		l.unsafeCountBucket(key, false, false)
+		return l.unsafeReentrant(key)
+	}
+	p, ok := l.pending[key]
+	if ok && p.batch.state.Load() == batchDispatched && p.batch.epoch != l.epoch.Load() {
+		// the batch may read data from before BumpEpoch, the key is fetched again
+		ok = false
+	}
+	l.unsafeCountBucket(key, false, ok)
+	if ok {
+		l.count(&l.stats.coalesced, 1)
+	} else {
+		if l.closed {
+			return loadRequest[K, V]{key: key, err: l.namedError(ErrClosed)}, false
+		}
+		p, full = l.unsafeEnqueue(key)
+	}
+	if l.contextFetch && (full || p.batch.state.Load() != batchDispatched) {
+		p.batch.unsafeAddContext(ctx)
+	}
+	if l.deadlineFlush && !full {
+		if deadline, ok := ctx.Deadline(); ok {
+			full = l.unsafeTrackDeadline(p.batch, deadline)
 		}
 	}
+
+	return loadRequest[K, V]{key: key, batch: p.batch, pos: p.pos, ready: p.ready}, full
+}
+
+// unsafeEnqueue adds a key that is not pending yet to the current batch, starting a new batch if needed.
+// When the key fills the batch, the batch is dispatched and full is returned: the caller has to end it
+// once it released the lock.
+func (l *Loader[K, V]) unsafeEnqueue(key K) (p batchPosition[K, V], full bool) {
 	if l.batch == nil {
-		l.batch = &genericLoaderBatch[K, V]{done: make(chan struct{})}
+		l.batch = l.newBatch()
 	}
-	batch := l.batch
-	pos := batch.keyIndex(l, key)
+	return l.batch.keyIndex(l, key)
+}
 
-	return func() (*V, error) {
-		<-batch.done
+// wait blocks until the result of the request is available, and passes sampled loads to the sampler
+func (r loadRequest[K, V]) wait(l *Loader[K, V]) Result[*V] {
+	result := r.result(l)
+	if !r.sampled.IsZero() {
+		l.sampler(LoadSample[K]{Key: r.key, Hit: r.batch == nil && r.err == nil, Latency: l.clock.Now().Sub(r.sampled)})
+	}
+	return result
+}
 
-		var data *V
-		if pos < len(batch.data) {
-			data = batch.data[pos]
+// result blocks until the result of the request is available. The batch has written its results to
+// the cache before closing done, so every waiter wakes from the same broadcast without taking any lock.
+func (r loadRequest[K, V]) result(l *Loader[K, V]) Result[*V] {
+	if r.batch != nil && l.eagerSingle {
+		if r.deadline.IsZero() {
+			l.dispatchSingleton(r.batch)
+		} else {
+			// the waiter may run out of budget, so the fetch cannot run on its goroutine
+			go l.dispatchSingleton(r.batch)
 		}
+	}
 
-		var errs error
-		for _, err := range batch.error {
-			if err == nil {
-				continue
-			}
-			errs = errors.Join(errs, err)
+	entry, err := r.entry, r.err
+	switch {
+	case r.ready != nil:
+		if err := r.await(l, r.ready); err != nil {
+			return l.abandoned(err)
 		}
-		if errs != nil {
-			return data, errs
+		entry, err = r.batch.entry(r.pos), r.batch.error[r.pos]
+	case r.batch != nil:
+		if err := r.await(l, r.batch.done); err != nil {
+			return l.abandoned(err)
 		}
+		entry, err = r.batch.entry(r.pos), r.batch.keyError(r.pos)
+		if primed, ok := r.batch.overrides[r.pos]; ok {
+			entry, err = primed, nil
+		}
+	}
 
-		l.mu.Lock()
-		defer l.mu.Unlock()
-		l.unsafeSet(key, data)
-
-		return data, nil
+	if err != nil {
+		if r.batch != nil && l.serveStale {
+			if stale, ok := l.staleFallback(r.key, err); ok {
+				return stale
+			}
+		}
+		if r.batch != nil && l.loadErrors {
+			err = r.batch.loadError(l, r.key, err)
+		}
+		return Result[*V]{Value: entry.value, Err: err}
 	}
+	result := Result[*V]{Value: entry.value, Found: entry.found}
+	if !entry.found && l.missingValueError != nil {
+		result.Err = l.missingValueError(r.key)
+	}
+	return result
 }
 
 // LoadAll fetches many keys at once. It will be broken into appropriate sized
 // sub batches depending on how the loader is configured
-func (l *genericLoader[K, V]) LoadAll(keys []K) ([]*V, []error) {
-	results := make([]func() (*V, error), len(keys))
+func (l *Loader[K, V]) LoadAll(keys []K) ([]*V, []error) {
+	return l.LoadAllCtx(context.Background(), keys)
+}
 
-	for i, key := range keys {
-		results[i] = l.LoadThunk(key)
+// LoadAllCtx loads many keys like LoadAll, with ctx applying to every key as it does for LoadCtx
+func (l *Loader[K, V]) LoadAllCtx(ctx context.Context, keys []K) ([]*V, []error) {
+	if l.memo != nil {
+		l.checkLifetime()
+		if values, ok := l.memoGet(keys); ok {
+			return values, make([]error, len(keys))
+		}
 	}
 
-	users := make([]*V, len(keys))
-	errs := make([]error, len(keys))
-	for i, thunk := range results {
-		users[i], errs[i] = thunk()
+	values, errs := l.waitAll(l.requestAll(ctx, keys))
+
+	if l.memo != nil {
+		l.memoPut(keys, values, errs)
 	}
-	return users, errs
+	return values, errs
 }
 
 // LoadAllThunk returns a function that when called will block waiting for a Generic Data.
 // This method should be used if you want one goroutine to make requests to many
-// different data loaders without blocking until the thunk is called.
-func (l *genericLoader[K, V]) LoadAllThunk(keys []K) func() ([]*V, []error) {
-	results := make([]func() (*V, error), len(keys))
+// different data loaders without blocking until the thunk is called. Calling the thunk again
+// returns the same slices.
+func (l *Loader[K, V]) LoadAllThunk(keys []K) func() ([]*V, []error) {
+	return l.LoadAllThunkCtx(context.Background(), keys)
+}
+
+// LoadAllThunkCtx returns a thunk like LoadAllThunk, with ctx applying to every key as it does for
+// LoadThunkCtx
+func (l *Loader[K, V]) LoadAllThunkCtx(ctx context.Context, keys []K) func() ([]*V, []error) {
+	reqs := l.requestAll(ctx, keys)
+	return sync.OnceValues(func() ([]*V, []error) {
+		return l.waitAll(reqs)
+	})
+}
+
+// requestAll requests every key like request does, but answers the cached keys and adds the rest to
+// batches under a single acquisition of the lock. The cached answers are as current as those of a Load
+// made at the same moment, a Prime or Clear can only happen before or after all of them.
+func (l *Loader[K, V]) requestAll(ctx context.Context, keys []K) []loadRequest[K, V] {
+	l.checkLifetime()
+	l.count(&l.stats.loads, uint64(len(keys)))
+	reqs := make([]loadRequest[K, V], len(keys))
 	for i, key := range keys {
-		results[i] = l.LoadThunk(key)
+		reqs[i].key, reqs[i].err = l.checkKey(key)
+		reqs[i].sampled = l.sampleStart()
+	}
+
+	deadline := l.deadline()
+	if err := l.passGate(ctx, deadline); err != nil {
+		for i := range reqs {
+			if reqs[i].err == nil {
+				reqs[i].err = err
+			}
+		}
+		return reqs
 	}
-	return func() ([]*V, []error) {
-		users := make([]*V, len(keys))
-		errs := make([]error, len(keys))
-		for i, thunk := range results {
-			users[i], errs[i] = thunk()
+	var full []*genericLoaderBatch[K, V]
+	l.mu.Lock()
+	for i := range reqs {
+		if reqs[i].err != nil {
+			continue
 		}
-		return users, errs
+		req, filled := l.unsafeRequest(ctx, reqs[i].key)
+		req.deadline, req.sampled, req.ctx = deadline, reqs[i].sampled, ctx
+		reqs[i] = req
+		if filled {
+			full = append(full, req.batch)
+		}
+	}
+	l.mu.Unlock()
+
+	// the batches the keys filled are handed to the fetch outside the lock
+	for _, b := range full {
+		l.submit(b)
 	}
+	return reqs
+}
+
+// waitAll waits for the result of every request, in the order of the requests
+func (l *Loader[K, V]) waitAll(reqs []loadRequest[K, V]) ([]*V, []error) {
+	values := make([]*V, len(reqs))
+	errs := make([]error, len(reqs))
+	for i, req := range reqs {
+		r := req.wait(l)
+		values[i], errs[i] = r.Value, r.Err
+	}
+	return values, errs
+}
+
+// LoadAllOrError loads many keys like LoadAll but reports the failures as a single error, nil when
+// every key loaded successfully. Values are still returned for the keys that did load.
+func (l *Loader[K, V]) LoadAllOrError(keys []K) ([]*V, error) {
+	return l.LoadAllThunkOrError(keys)()
+}
+
+// LoadAllThunkOrError returns a thunk like LoadAllThunk that reports the failures as a single error
+func (l *Loader[K, V]) LoadAllThunkOrError(keys []K) func() ([]*V, error) {
+	return orError(l.LoadAllThunk(keys))
+}
+
+// orError turns a LoadAllThunk into a LoadAllThunkOrError
+func orError[V any](thunk func() ([]*V, []error)) func() ([]*V, error) {
+	return sync.OnceValues(func() ([]*V, error) {
+		values, errs := thunk()
+		return values, combineErrors(errs)
+	})
+}
+
+// combineErrors joins the non-nil errors of a LoadAll into one, the error of a failed batch
+// is shared by all of its keys and only included once
+func combineErrors(errs []error) error {
+	var combined []error
+	seen := map[*batchError]bool{}
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		cause := err
+		if le, ok := err.(*LoadError); ok {
+			cause = le.Err
+		}
+		if be, ok := cause.(*batchError); ok {
+			if seen[be] {
+				continue
+			}
+			seen[be] = true
+		}
+		combined = append(combined, err)
+	}
+	if len(combined) == 1 {
+		return combined[0]
+	}
+	return joinBatchErrors(combined)
+}
+
+// LoadInto loads the value for key and copies it into dst, a missing value is written as the zero value.
+// Cache hits are answered without allocating, which makes it suitable for hot loops over value types.
+func (l *Loader[K, V]) LoadInto(key K, dst *V) error {
+	if l.PeekInto(key, dst) {
+		return nil
+	}
+
+	value, err := l.Load(key)
+	if err != nil {
+		return err
+	}
+	copyInto(dst, value)
+	return nil
+}
+
+// PeekInto copies the cached value for key into dst without ever triggering a fetch.
+// It returns false and leaves dst untouched when the key is not cached.
+func (l *Loader[K, V]) PeekInto(key K, dst *V) bool {
+	l.checkLifetime()
+	key, err := l.checkKey(key)
+	if err != nil {
+		return false
+	}
+
+	l.mu.Lock()
+	it, ok := l.unsafeGet(key)
+	l.mu.Unlock()
+
+	// cached values are never modified, only replaced, so the copy does not need the lock
+	if ok {
+		copyInto(dst, it.value)
+	}
+	return ok
 }
 
 // Prime the cache with the provided key and value. If the key already exists, no change is made
 // and false is returned.
 // (To forcefully prime the cache, clear the key first with loader.clear(key).prime(key, value).)
-func (l *genericLoader[K, V]) Prime(key K, value *V) bool {
+func (l *Loader[K, V]) Prime(key K, value *V) bool {
+	key, err := l.checkKey(key)
+	if err != nil {
+		return false
+	}
+
+	l.InvalidateBatchMemo()
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	var found bool
-	if _, found = l.cache[key]; !found {
-		// to make a copy when writing to the cache, it's easy to pass a pointer in from a loop var
-		// and end up with the whole cache pointing to the same value.
-		cpy := *value
-		l.unsafeSet(key, &cpy)
+	if p, pending := l.pending[key]; pending && l.primePolicy == PreferPrimed {
+		return l.unsafePrimePending(p, key, value)
+	}
+	return l.unsafePrime(key, value, SourcePrime)
+}
+
+func (l *Loader[K, V]) unsafePrime(key K, value *V, source EntrySource) bool {
+	if _, found := l.unsafeGet(key); found {
+		return false
 	}
-	return !found
+	l.unsafeSet(key, primeEntry(value, source, l.epoch.Load()))
+	return true
 }
 
 // Clear the value at key from the cache, if it exists
-func (l *genericLoader[K, V]) Clear(key K) {
+func (l *Loader[K, V]) Clear(key K) {
+	key, err := l.checkKey(key)
+	if err != nil {
+		return
+	}
+
+	l.InvalidateBatchMemo()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.unsafeDelete(key)
+}
+
+// ClearMany removes the values at keys from the cache under a single acquisition of the lock and
+// returns how many entries were removed. Duplicate keys and keys that are not cached are skipped.
+func (l *Loader[K, V]) ClearMany(keys []K) int {
+	checked := make([]K, 0, len(keys))
+	for _, key := range keys {
+		if key, err := l.checkKey(key); err == nil {
+			checked = append(checked, key)
+		}
+	}
+
+	l.InvalidateBatchMemo()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	removed := 0
+	for _, key := range checked {
+		if _, ok := l.cache.peek(key); ok {
+			l.cache.delete(key)
+			removed++
+		}
+	}
+	l.stats.cacheBytes.Store(uint64(l.cache.bytes))
+	return removed
+}
+
+func (l *Loader[K, V]) unsafeDelete(key K) {
+	l.cache.delete(key)
+	l.stats.cacheBytes.Store(uint64(l.cache.bytes))
+}
+
+// ClearAll empties the cache
+func (l *Loader[K, V]) ClearAll() {
+	l.InvalidateBatchMemo()
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	delete(l.cache, key)
+	l.cache.clear()
+	l.stats.cacheBytes.Store(0)
+	if l.aliases != nil {
+		l.aliases.clear()
+	}
+}
+
+// ReplaceCache swaps the whole cache for entries in one step, loads see either the complete old or the
+// complete new cache. Values are copied like Prime does, a nil value is cached as found.
+//
+// Batches pending during the swap still complete, but do not overwrite keys the new cache holds.
+func (l *Loader[K, V]) ReplaceCache(entries map[K]*V) {
+	// the new cache is filled without holding the lock, only the swap does
+	next := l.cache.empty()
+	var storedAt int64
+	if l.cacheTTL > 0 || !l.noEntryInfo {
+		storedAt = l.clock.Now().UnixNano()
+	}
+	epoch := l.epoch.Load()
+	evicted := 0
+	for key, value := range entries {
+		key, err := l.checkKey(key)
+		if err != nil {
+			continue
+		}
+		entry := primeEntry(value, SourceReplace, epoch)
+		entry.storedAt = storedAt
+		evicted += next.set(key, entry)
+	}
+
+	l.InvalidateBatchMemo()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cache.replace(next)
+	l.count(&l.stats.evictions, uint64(evicted))
+	l.stats.cacheBytes.Store(uint64(l.cache.bytes))
+}
+
+// ClearWhere removes the cached entries pred matches and returns how many were removed. pred may
+// be called with a nil value for keys that were not found and must not modify the value: callers
+// may be reading the same value at the same time.
+//
+// pred runs on a snapshot of the cache without holding the loader's lock, entries that were replaced
+// while it ran are kept.
+func (l *Loader[K, V]) ClearWhere(pred func(key K, value *V) bool) int {
+	type snapshotEntry struct {
+		key   K
+		value *V
+	}
+
+	l.mu.Lock()
+	snapshot := make([]snapshotEntry, 0, l.cache.len())
+	for key, it := range l.cache.all() {
+		snapshot = append(snapshot, snapshotEntry{key: key, value: it.value})
+	}
+	l.mu.Unlock()
+
+	var matched []snapshotEntry
+	for _, it := range snapshot {
+		if pred(it.key, it.value) {
+			matched = append(matched, it)
+		}
+	}
+	if len(matched) == 0 {
+		return 0
+	}
+
+	l.InvalidateBatchMemo()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	removed := 0
+	for _, it := range matched {
+		if current, ok := l.cache.peek(it.key); ok && current.value == it.value {
+			l.cache.delete(it.key)
+			removed++
+		}
+	}
+	l.stats.cacheBytes.Store(uint64(l.cache.bytes))
+	return removed
+}
+
+// ClearFunc removes the cached entries whose key pred matches and returns how many were removed, like
+// ClearWhere. pred runs without holding the loader's lock, so it may be slow or use the loader.
+func (l *Loader[K, V]) ClearFunc(pred func(key K) bool) int {
+	return l.ClearWhere(func(key K, _ *V) bool {
+		return pred(key)
+	})
+}
+
+// SetMaxBatch changes the maximum number of keys sent to the fetch in one call, 0 = no limit.
+// Batches that already hold more keys are split when they are fetched.
+func (l *Loader[K, V]) SetMaxBatch(maxBatch int) {
+	l.mu.Lock()
+	l.maxBatch = maxBatch
+	var flushed *genericLoaderBatch[K, V]
+	if l.batch != nil && maxBatch > 0 && len(l.batch.keys) >= maxBatch {
+		flushed = l.unsafeFlush(ManualFlush)
+	}
+	l.mu.Unlock()
+	l.submit(flushed)
+}
+
+// Flush dispatches the currently collected batch without waiting for the batch window to elapse
+func (l *Loader[K, V]) Flush() {
+	l.mu.Lock()
+	flushed := l.unsafeFlush(ManualFlush)
+	l.mu.Unlock()
+	l.submit(flushed)
+}
+
+// Close flushes the current batch and waits until every dispatched batch has completed or ctx is done.
+// Afterward, cached values are still returned but keys that would need a fetch fail with ErrClosed.
+func (l *Loader[K, V]) Close(ctx context.Context) error {
+	l.mu.Lock()
+	l.closed = true
+	flushed := l.unsafeFlush(ManualFlush)
+	l.mu.Unlock()
+	l.submit(flushed)
+
+	done := make(chan struct{})
+	go func() {
+		l.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		if l.cancelAbort != nil {
+			l.cancelAbort()
+		}
+		return ctx.Err()
+	}
+}
+
+// unsafeFlush dispatches the current batch, if there is one, and returns it: the caller has to submit it
+// once it released the lock
+func (l *Loader[K, V]) unsafeFlush(reason TriggerReason) *genericLoaderBatch[K, V] {
+	if b := l.batch; b != nil && l.unsafeDispatch(b, reason) {
+		return b
+	}
+	return nil
 }
 
-func (l *genericLoader[K, V]) unsafeSet(key K, value *V) {
-	if l.cache == nil {
-		l.cache = map[K]*V{}
+// dispatch sends a batch to the fetch unless it has already been dispatched, it is called by the batch timers
+func (l *Loader[K, V]) dispatch(b *genericLoaderBatch[K, V], reason TriggerReason) {
+	if b.state.Load() == batchDispatched {
+		return
+	}
+
+	l.mu.Lock()
+	ok := l.unsafeDispatch(b, reason)
+	l.mu.Unlock()
+
+	if ok {
+		l.endTimed(b)
+	}
+}
+
+// dispatchSingleton dispatches a batch that is still waiting for its second key, see WithEagerSingle
+func (l *Loader[K, V]) dispatchSingleton(b *genericLoaderBatch[K, V]) {
+	if b.state.Load() == batchDispatched {
+		return
+	}
+
+	l.mu.Lock()
+	ok := len(b.keys) == 1 && l.unsafeDispatch(b, SingletonFlush)
+	l.mu.Unlock()
+
+	if ok {
+		b.end(l)
+	}
+}
+
+// newBatch creates a batch without keys, which the loader waits for until it completes
+func (l *Loader[K, V]) newBatch() *genericLoaderBatch[K, V] {
+	l.batchIDs++
+	b := &genericLoaderBatch[K, V]{id: l.batchIDs, started: l.clock.Now(), done: make(chan struct{}), replaced: l.cache.replaced}
+	if l.loadBudget > 0 {
+		b.throttled.Store(newThrottleNotice(time.Time{}))
+	}
+	l.inflight.Add(1)
+	return b
+}
+
+// unsafeDispatch stops a batch from collecting keys so it can be sent to the fetch, it returns false when
+// the batch has already been dispatched
+func (l *Loader[K, V]) unsafeDispatch(b *genericLoaderBatch[K, V], reason TriggerReason) bool {
+	if b.state.Load() == batchDispatched {
+		return false
+	}
+	b.state.Store(batchDispatched)
+	b.trigger = reason
+	b.epoch = l.epoch.Load()
+	if l.batch == b {
+		l.batch = nil
+	}
+	l.dispatched++
+
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	if b.deadlineTimer != nil {
+		b.deadlineTimer.Stop()
+	}
+	return true
+}
+
+// unsafeTrackDeadline makes sure an open batch is dispatched deadlineMargin before deadline. It returns
+// true when the deadline is that close already and it dispatched the batch: the caller has to end it once
+// it released the lock.
+func (l *Loader[K, V]) unsafeTrackDeadline(b *genericLoaderBatch[K, V], deadline time.Time) bool {
+	if b.state.Load() == batchDispatched || !b.deadline.IsZero() && !deadline.Before(b.deadline) {
+		return false
+	}
+	b.deadline = deadline
+
+	now := l.clock.Now()
+	flushAt := deadline.Add(-l.deadlineMargin)
+	if !flushAt.After(now) {
+		return l.unsafeDispatch(b, DeadlineFlush)
+	}
+	// the window closes before the deadline needs it to
+	if !flushAt.Before(l.windowEnd(b)) {
+		return false
+	}
+
+	if b.deadlineTimer != nil {
+		b.deadlineTimer.Stop()
 	}
-	l.cache[key] = value
+	b.deadlineTimer = l.clock.AfterFunc(flushAt.Sub(now), func() {
+		l.dispatch(b, DeadlineFlush)
+	})
+	return false
 }
 
-// keyIndex will return the location of the key in the batch, if it's not found,
-// it will add the key to the batch
-func (b *genericLoaderBatch[K, V]) keyIndex(l *genericLoader[K, V], key K) int {
-	for i, existingKey := range b.keys {
-		if key == existingKey {
-			return i
+// unsafeGet returns the cached entry for key, entries that have outlived the cache TTL are treated as absent.
+// They are evicted on the way unless they may still be served stale: by WithServeStaleOnError, or by
+// LoadStale within the longest maxStale it was called with. Entries written before the last BumpEpoch
+// are absent as well, and are evicted on the way.
+func (l *Loader[K, V]) unsafeGet(key K) (cacheEntry[V], bool) {
+	it, ok := l.cache.get(key)
+	if !ok {
+		return cacheEntry[V]{}, false
+	}
+	if it.epoch != l.epoch.Load() {
+		l.unsafeDrop(key)
+		return cacheEntry[V]{}, false
+	}
+	if l.cacheTTL > 0 {
+		if age := l.age(it); age >= l.cacheTTL {
+			if !l.serveStale && age >= l.cacheTTL+l.staleWindow {
+				l.unsafeDrop(key)
+			}
+			return cacheEntry[V]{}, false
 		}
 	}
+	return it, true
+}
+
+// unsafeDrop removes the entry of key that is no longer of use
+func (l *Loader[K, V]) unsafeDrop(key K) {
+	l.cache.delete(key)
+	l.stats.cacheBytes.Store(uint64(l.cache.bytes))
+}
+
+func (l *Loader[K, V]) unsafeSet(key K, entry cacheEntry[V]) {
+	if l.cacheTTL > 0 || !l.noEntryInfo {
+		entry.storedAt = l.clock.Now().UnixNano()
+	}
+	if evicted := l.cache.set(key, entry); evicted > 0 {
+		l.count(&l.stats.evictions, uint64(evicted))
+	}
+	if l.cache.bounded() {
+		l.stats.cacheBytes.Store(uint64(l.cache.bytes))
+	}
+}
+
+// namedError prefixes errors synthesized by the loader with its name, if it has one
+func (l *Loader[K, V]) namedError(err error) error {
+	if l.name == "" {
+		return err
+	}
+	return fmt.Errorf("loader %q: %w", l.name, err)
+}
+
+func copyInto[V any](dst *V, value *V) {
+	if value == nil {
+		var zero V
+		*dst = zero
+		return
+	}
+	if cpy, ok := cloned(value); ok {
+		*dst = *cpy
+		return
+	}
+	*dst = *value
+}
 
+// keyIndex will add a key that is not pending yet to the batch and return its location, and whether the key
+// filled the batch and dispatched it
+func (b *genericLoaderBatch[K, V]) keyIndex(l *Loader[K, V], key K) (p batchPosition[K, V], full bool) {
 	pos := len(b.keys)
 	b.keys = append(b.keys, key)
-	if pos == 0 {
-		go b.startTimer(l)
+	p = batchPosition[K, V]{batch: b, pos: pos}
+	if l.stream != nil {
+		p.ready = make(chan struct{})
+		b.ready = append(b.ready, p.ready)
 	}
+	if l.pending == nil {
+		l.pending = map[K]batchPosition[K, V]{}
+	}
+	l.pending[key] = p
 
-	if l.maxBatch != 0 && pos >= l.maxBatch-1 {
-		if !b.closing {
-			b.closing = true
-			l.batch = nil
-			go b.end(l)
-		}
+	if l.weight != nil {
+		b.weight += l.weight(key)
+	}
+
+	if l.maxBatch != 0 && pos >= l.maxBatch-1 || l.weight != nil && b.weight >= l.maxBatchWeight {
+		return p, l.unsafeDispatch(b, MaxBatchReached)
+	}
+	if !l.eagerSingle || pos >= 1 {
+		b.unsafeStartWindow(l)
+	}
+	return p, false
+}
+
+// unsafeStartWindow starts the timer that dispatches the batch once the window has elapsed, if it is not running yet
+func (b *genericLoaderBatch[K, V]) unsafeStartWindow(l *Loader[K, V]) {
+	if l.slide > 0 {
+		b.unsafeSlide(l)
+		return
+	}
+	if b.timer != nil || b.state.Load() == batchDispatched {
+		return
+	}
+	b.timer = l.clock.AfterFunc(l.wait, func() {
+		l.dispatch(b, WaitExpired)
+	})
+}
+
+func (b *genericLoaderBatch[K, V]) end(l *Loader[K, V]) {
+	// a reentrant batch runs within a fetch that may hold the last slot
+	if b.trigger != ReentrantFlush {
+		slot := l.acquireSlot()
+		defer slot.release()
+	}
+
+	if l.stream != nil {
+		b.data = make([]*V, len(b.keys))
+		b.error = make([]error, len(b.keys))
+		b.emitted = make([]bool, len(b.keys))
+	}
+	if l.watchdog.After > 0 {
+		watchdog := b.watch(l)
+		defer watchdog.Stop()
+	}
+
+	l.count(&l.stats.batches, 1)
+	l.count(&l.stats.triggers[b.trigger], 1)
+
+	if l.loadErrors {
+		b.fetchStart = l.clock.Now()
+	}
+	if l.stream != nil {
+		l.count(&l.stats.fetchedKeys, uint64(len(b.keys)))
+		b.fetchStream(l)
+		return
+	}
+	ctx, cancel := b.fetchContext(l)
+	defer cancel()
+	b.isolated = l.affinity != nil || l.keyErrors
+	data, errs, err := b.fetchUnprimed(ctx, l)
+	if l.valueTransform != nil {
+		data, errs, err = l.transformValues(b.keys, data, errs, err)
 	}
+	if l.loadErrors {
+		b.fetchTime = l.clock.Now().Sub(b.fetchStart)
+	}
+	b.complete(l, data, errs, err)
+}
+
+// fetchKeys sends the keys of a batch to the fetch. It returns the results aligned with keys, the errors
+// either aligned with keys or in the shape the fetch returned them, and the aggregate of all errors.
+func (l *Loader[K, V]) fetchKeys(ctx context.Context, keys []K) ([]*V, []error, error) {
+	if l.batchMemo != nil {
+		return l.fetchMemoized(ctx, keys)
+	}
+	return l.fetchFresh(ctx, keys)
+}
+
+// fetchFresh calls the fetch for the keys, see fetchKeys
+func (l *Loader[K, V]) fetchFresh(ctx context.Context, keys []K) ([]*V, []error, error) {
+	if l.resolveAliases != nil {
+		return l.fetchResolved(ctx, keys)
+	}
+	return l.fetchAffine(ctx, keys)
+}
+
+// fetchAffine fetches the keys in groups by affinity when the loader has one, see WithAffinity
+func (l *Loader[K, V]) fetchAffine(ctx context.Context, keys []K) ([]*V, []error, error) {
+	if l.affinity != nil {
+		return l.fetchGrouped(ctx, keys)
+	}
+	return l.fetchPlain(ctx, keys)
+}
 
-	return pos
+// fetchPlain fetches the keys as rewritten by transformKeys, if any, in chunks of maxBatch keys
+func (l *Loader[K, V]) fetchPlain(ctx context.Context, keys []K) ([]*V, []error, error) {
+	if l.transformKeys != nil {
+		return l.fetchTransformed(ctx, keys)
+	}
+	return l.fetchChunks(ctx, keys)
 }
 
-func (b *genericLoaderBatch[K, V]) startTimer(l *genericLoader[K, V]) {
-	time.Sleep(l.wait)
+// fetchChunks calls the fetch once for every maxBatch keys. Batches only grow past maxBatch when it was
+// lowered while they were collecting keys, and those are split so no fetch ever exceeds the limit.
+func (l *Loader[K, V]) fetchChunks(ctx context.Context, keys []K) ([]*V, []error, error) {
 	l.mu.Lock()
-	defer l.mu.Unlock()
+	maxBatch := l.maxBatch
+	l.mu.Unlock()
+
+	if maxBatch <= 0 || len(keys) <= maxBatch {
+		data, errs := l.fetchChecked(ctx, keys)
+		return data, errs, joinBatchErrors(errs)
+	}
+
+	data := make([]*V, len(keys))
+	errs := make([]error, len(keys))
+	var all []error
+	for start := 0; start < len(keys); start += maxBatch {
+		end := min(start+maxBatch, len(keys))
+		chunkData, chunkErrs := l.fetchChecked(ctx, keys[start:end])
+		copy(data[start:end], chunkData)
+		all = append(all, chunkErrs...)
+
+		if len(chunkErrs) == end-start {
+			copy(errs[start:end], chunkErrs)
+			continue
+		}
+		// errors that are not aligned with the keys of the chunk apply to all of them
+		if err := joinBatchErrors(chunkErrs); err != nil {
+			for i := start; i < end; i++ {
+				errs[i] = err
+			}
+		}
+	}
+	return data, errs, joinBatchErrors(all)
+}
 
-	// we must have hit a batch limit and are already finalizing this batch
-	if b.closing {
+// complete publishes the results of the batch to the cache and its waiters, only the first call has any effect.
+// Streaming batches have published their results as they were emitted, the rest of their positions fail with err.
+func (b *genericLoaderBatch[K, V]) complete(l *Loader[K, V], data []*V, errs []error, err error) {
+	if b.ready != nil {
+		b.closeStream(l, err)
 		return
 	}
+	b.once.Do(func() {
+		b.data, b.error = data, errs
+		b.err = err
+		if b.err != nil {
+			l.count(&l.stats.failedBatches, 1)
+			b.recordFailure(l, errs, err)
+		}
+
+		l.mu.Lock()
+		l.dispatched--
+		for pos, key := range b.keys {
+			// keys primed under PreferPrimed keep their primed value
+			if _, primed := b.overrides[pos]; b.keyError(pos) == nil && !primed {
+				b.unsafeStore(l, pos)
+			}
+			if l.pending[key].batch == b {
+				delete(l.pending, key)
+			}
+		}
+		l.mu.Unlock()
+
+		close(b.done)
+		l.inflight.Done()
+		if b.err == nil || b.isolated {
+			b.promote(l)
+		}
+	})
+}
+
+// unsafeStore caches the result at pos, unless the cache was replaced since the batch started and
+// the replacement holds the key: the fetch may have read older data than the replacement. Likewise a
+// batch dispatched before BumpEpoch does not overwrite an entry written since.
+func (b *genericLoaderBatch[K, V]) unsafeStore(l *Loader[K, V], pos int) {
+	key := l.canonicalKey(b.keys[pos])
+	if b.replaced != l.cache.replaced {
+		if _, ok := l.cache.peek(key); ok {
+			return
+		}
+	}
+	if epoch := l.epoch.Load(); b.epoch != epoch {
+		if it, ok := l.cache.peek(key); ok && it.epoch == epoch {
+			return
+		}
+	}
+	l.unsafeSet(key, b.entry(pos))
+}
+
+// entry returns the result at pos, with a result slice nil at a position means the fetch has no value for the key
+func (b *genericLoaderBatch[K, V]) entry(pos int) cacheEntry[V] {
+	var data *V
+	if pos < len(b.data) {
+		data = b.data[pos]
+	}
+	return cacheEntry[V]{value: data, found: data != nil, source: SourceFetch, epoch: b.epoch}
+}
+
+// batchError is the aggregate of the errors a fetch returned for a batch. The message is
+// formatted once up front so waiters can share the value without any further work.
+type batchError struct {
+	errs []error
+	msg  string
+}
+
+func joinBatchErrors(errs []error) error {
+	joined, ok := errors.Join(errs...).(interface{ Unwrap() []error })
+	if !ok {
+		return nil
+	}
+	return &batchError{
+		errs: joined.Unwrap(),
+		msg:  joined.(error).Error(),
+	}
+}
 
-	l.batch = nil
-	b.end(l)
+func (e *batchError) Error() string {
+	return e.msg
 }
 
-func (b *genericLoaderBatch[K, V]) end(l *genericLoader[K, V]) {
-	b.data, b.error = l.fetch(b.keys)
-	close(b.done)
+func (e *batchError) Unwrap() []error {
+	return e.errs
 }
@@ -1,8 +1,12 @@
 package dataloaden
 
 import (
+	"context"
 	"errors"
 	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -36,64 +40,6 @@ func TestLoadSingleKey(t *testing.T) {
 	}
 }
 
-func TestBatching(t *testing.T) {
-	fetchFn := func(keys []int) ([]*string, []error) {
-		results := make([]*string, len(keys))
-		for i, k := range keys {
-			v := string(rune('A' + k))
-			results[i] = &v
-		}
-		return results, make([]error, len(keys))
-	}
-
-	loader := NewDataLoader(fetchFn, 5*time.Millisecond, 10)
-
-	thunk1 := loader.LoadThunk(0)
-	thunk2 := loader.LoadThunk(1)
-
-	val1, _ := thunk1()
-	val2, _ := thunk2()
-
-	if *val1 != "A" || *val2 != "B" {
-		t.Errorf("expected [A,B], got [%s,%s]", *val1, *val2)
-	}
-}
-
-func TestMaxBatchSize(t *testing.T) {
-	var batches [][]int
-	fetchFn := func(keys []int) ([]*string, []error) {
-		cp := make([]int, len(keys))
-		copy(cp, keys)
-		batches = append(batches, cp)
-
-		results := make([]*string, len(keys))
-		for i, k := range keys {
-			v := string(rune('A' + k))
-			results[i] = &v
-		}
-		return results, make([]error, len(keys))
-	}
-
-	loader := NewDataLoader(fetchFn, 50*time.Millisecond, 2)
-
-	thunks := []func() (*string, error){
-		loader.LoadThunk(0),
-		loader.LoadThunk(1),
-		loader.LoadThunk(2),
-	}
-
-	for _, thunk := range thunks {
-		_, _ = thunk()
-	}
-
-	if len(batches) != 2 {
-		t.Errorf("expected 2 batches, got %d", len(batches))
-	}
-	if !reflect.DeepEqual(batches[0], []int{0, 1}) || !reflect.DeepEqual(batches[1], []int{2}) {
-		t.Errorf("unexpected batching: %v", batches)
-	}
-}
-
 func TestPrimeAndClearCache(t *testing.T) {
 	fetchFn := func(keys []int) ([]*string, []error) {
 		t.Fatal("fetch should not be called when primed")
@@ -119,7 +65,7 @@ func TestPrimeAndClearCache(t *testing.T) {
 	loader.Clear(1)
 
 	// After clearing, it should trigger fetch
-	loader.(*genericLoader[int, string]).fetch = func(keys []int) ([]*string, []error) {
+	loader.fetch = func(_ context.Context, keys []int) ([]*string, []error) {
 		v := "Fetched"
 		return []*string{&v}, []error{nil}
 	}
@@ -193,3 +139,959 @@ func TestConcurrentLoads(t *testing.T) {
 		t.Errorf("fetchFn was never called")
 	}
 }
+
+func TestBatchErrorSharedByWaiters(t *testing.T) {
+	errBoom := errors.New("boom")
+	fetchFn := func(keys []int) ([]*string, []error) {
+		errs := make([]error, len(keys))
+		errs[0] = errBoom
+		return make([]*string, len(keys)), errs
+	}
+
+	loader := NewDataLoader(fetchFn, 5*time.Millisecond, 0)
+
+	const numWaiters = 500
+	thunks := make([]func() (*string, error), numWaiters)
+	for i := range thunks {
+		thunks[i] = loader.LoadThunk(i)
+	}
+
+	errs := make([]error, numWaiters)
+	var wg sync.WaitGroup
+	for i, thunk := range thunks {
+		wg.Go(func() {
+			_, errs[i] = thunk()
+			_ = errs[i].Error()
+		})
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if !errors.Is(err, errBoom) {
+			t.Fatalf("waiter %d: expected boom, got %v", i, err)
+		}
+		if err != errs[0] {
+			t.Fatalf("waiter %d: expected the batch error to be shared", i)
+		}
+	}
+	if errs[0].Error() != "boom" {
+		t.Errorf("expected boom, got %q", errs[0].Error())
+	}
+}
+
+func TestKeyErrors(t *testing.T) {
+	errBoom := errors.New("boom")
+	t.Run("a failing key does not fail its batch-mates", func(t *testing.T) {
+		var calls atomic.Int32
+		loader := NewDataLoader(func(keys []int) ([]*string, []error) {
+			calls.Add(1)
+			data := make([]*string, len(keys))
+			errs := make([]error, len(keys))
+			for i, key := range keys {
+				if key == 3 {
+					errs[i] = errBoom
+					continue
+				}
+				v := "v" + strconv.Itoa(key)
+				data[i] = &v
+			}
+			return data, errs
+		}, time.Millisecond, 0, WithKeyErrors[int, string]())
+
+		values, errs := loader.LoadAll([]int{1, 2, 3, 4})
+		for i, key := range []int{1, 2, 3, 4} {
+			if key == 3 {
+				if errs[i] != errBoom {
+					t.Errorf("expected only the error of key 3, got %v", errs[i])
+				}
+				continue
+			}
+			if errs[i] != nil || *values[i] != "v"+strconv.Itoa(key) {
+				t.Errorf("expected v%d, got %v, %v", key, values[i], errs[i])
+			}
+		}
+
+		_, _ = loader.LoadAll([]int{1, 2, 4})
+		if n := calls.Load(); n != 1 {
+			t.Errorf("expected the batch-mates of the failing key to be cached, got %d fetches", n)
+		}
+		if stats := loader.Stats(); stats.FailedBatches != 1 {
+			t.Errorf("expected the batch to still count as failed, got %d", stats.FailedBatches)
+		}
+	})
+
+	t.Run("a batch-wide error fails every key", func(t *testing.T) {
+		rec := &recordingFetch{}
+		loader := NewDataLoader(func(keys []int) ([]*string, []error) {
+			_, _ = rec.fetch(keys)
+			return nil, []error{errBoom}
+		}, time.Millisecond, 0, WithKeyErrors[int, string]())
+
+		_, errs := loader.LoadAll([]int{1, 2})
+		for i, err := range errs {
+			if !errors.Is(err, errBoom) {
+				t.Errorf("key %d: expected the batch error, got %v", i+1, err)
+			}
+		}
+		_, _ = loader.Load(1)
+		if n := rec.callCount(); n != 2 {
+			t.Errorf("expected the failed keys not to be cached, got %d fetches", n)
+		}
+	})
+}
+
+func TestThunkMemoized(t *testing.T) {
+	rec := &recordingFetch{}
+	loader := NewDataLoader(rec.fetch, time.Hour, 0)
+	thunk := loader.LoadThunk(1)
+	allThunk := loader.LoadAllThunk([]int{1, 2})
+	loader.Flush()
+
+	values := make([]*string, 20)
+	var wg sync.WaitGroup
+	for i := range values {
+		wg.Go(func() {
+			v, err := thunk()
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			values[i] = v
+		})
+	}
+	wg.Wait()
+	for i, v := range values {
+		if v != values[0] || *v != "v1" {
+			t.Fatalf("call %d: expected the same v1, got %v", i, v)
+		}
+	}
+
+	first, _ := allThunk()
+	loader.Clear(1)
+	loader.Clear(2)
+	if again, _ := allThunk(); &again[0] != &first[0] {
+		t.Error("expected the LoadAll thunk to return the memoized slices")
+	}
+	if v, _ := thunk(); v != values[0] {
+		t.Errorf("expected the thunk not to observe the Clear, got %v", v)
+	}
+	if n := rec.callCount(); n != 1 {
+		t.Errorf("expected a single fetch, got %d", n)
+	}
+}
+
+func TestLoadIntoAndPeekInto(t *testing.T) {
+	fetchFn := func(keys []int) ([]*int, []error) {
+		results := make([]*int, len(keys))
+		for i, k := range keys {
+			if k < 0 {
+				continue
+			}
+			v := k * 10
+			results[i] = &v
+		}
+		return results, make([]error, len(keys))
+	}
+
+	loader := NewDataLoader(fetchFn, 1*time.Millisecond, 10)
+
+	dst := -1
+	if loader.PeekInto(2, &dst) {
+		t.Fatalf("expected PeekInto to miss before the key is loaded")
+	}
+	if dst != -1 {
+		t.Errorf("expected dst to be untouched on a miss, got %d", dst)
+	}
+
+	if err := loader.LoadInto(2, &dst); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dst != 20 {
+		t.Errorf("expected 20, got %d", dst)
+	}
+
+	dst = -1
+	if !loader.PeekInto(2, &dst) || dst != 20 {
+		t.Errorf("expected PeekInto to hit with 20, got %d", dst)
+	}
+
+	if err := loader.LoadInto(-1, &dst); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dst != 0 {
+		t.Errorf("expected a missing value to be written as zero, got %d", dst)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = loader.LoadInto(2, &dst)
+	})
+	if allocs != 0 {
+		t.Errorf("expected cached LoadInto not to allocate, got %v allocs", allocs)
+	}
+}
+
+func BenchmarkLoadIntoCached(b *testing.B) {
+	fetchFn := func(keys []int) ([]*int, []error) {
+		results := make([]*int, len(keys))
+		for i, k := range keys {
+			results[i] = &k
+		}
+		return results, make([]error, len(keys))
+	}
+
+	loader := NewDataLoader(fetchFn, 1*time.Millisecond, 10)
+	for i := 0; i < 100; i++ {
+		loader.Prime(i, &i)
+	}
+
+	b.ReportAllocs()
+	var dst int
+	for i := 0; b.Loop(); i++ {
+		if err := loader.LoadInto(i%100, &dst); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoadCached(b *testing.B) {
+	fetchFn := func(keys []int) ([]*int, []error) {
+		results := make([]*int, len(keys))
+		for i, k := range keys {
+			results[i] = &k
+		}
+		return results, make([]error, len(keys))
+	}
+
+	loader := NewDataLoader(fetchFn, 1*time.Millisecond, 10)
+	for i := 0; i < 100; i++ {
+		loader.Prime(i, &i)
+	}
+
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		if _, err := loader.Load(i % 100); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestLoaderName(t *testing.T) {
+	release := make(chan struct{})
+	fetchFn := func(keys []int) ([]*string, []error) {
+		<-release
+		return make([]*string, len(keys)), nil
+	}
+
+	stuck := make(chan StuckBatch[int], 1)
+	loader := NewDataLoader(fetchFn, 1*time.Millisecond, 10,
+		WithName[int, string]("users"),
+		WithWatchdog[int, string](Watchdog[int]{
+			After:         5 * time.Millisecond,
+			OnStuck:       func(batch StuckBatch[int]) { stuck <- batch },
+			ForceComplete: true,
+		}),
+	)
+
+	_, err := loader.Load(1)
+	close(release)
+	if !errors.Is(err, ErrFetchStuck) || !strings.Contains(err.Error(), `"users"`) {
+		t.Errorf("expected a stuck error naming the loader, got %v", err)
+	}
+	if batch := <-stuck; batch.Loader != "users" {
+		t.Errorf("expected the watchdog report to name the loader, got %q", batch.Loader)
+	}
+	if name := loader.Stats().Name; name != "users" {
+		t.Errorf("expected stats to name the loader, got %q", name)
+	}
+
+	_ = loader.Close(context.Background())
+	_, err = loader.Load(2)
+	if !errors.Is(err, ErrClosed) || !strings.Contains(err.Error(), `"users"`) {
+		t.Errorf("expected a closed error naming the loader, got %v", err)
+	}
+}
+
+func TestPendingKeysFetchedOnce(t *testing.T) {
+	var mu sync.Mutex
+	fetched := map[int]int{}
+	fetchFn := func(keys []int) ([]*string, []error) {
+		mu.Lock()
+		for _, k := range keys {
+			fetched[k]++
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		results := make([]*string, len(keys))
+		for i, k := range keys {
+			v := string(rune('A' + k))
+			results[i] = &v
+		}
+		return results, make([]error, len(keys))
+	}
+
+	loader := NewDataLoader(fetchFn, 1*time.Millisecond, 3)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 300; i++ {
+		wg.Go(func() {
+			key := i % 10
+			val, err := loader.Load(key)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if *val != string(rune('A'+key)) {
+				t.Errorf("key %d: unexpected value %s", key, *val)
+			}
+		})
+	}
+	wg.Wait()
+
+	for key, count := range fetched {
+		if count != 1 {
+			t.Errorf("key %d: expected to be fetched once, fetched %d times", key, count)
+		}
+	}
+	if len(fetched) != 10 {
+		t.Errorf("expected 10 keys to be fetched, got %d", len(fetched))
+	}
+}
+
+func TestBatchWeight(t *testing.T) {
+	// keys of 10 and more are fat keys weighing 5, every other key weighs 1
+	weight := func(key int) int {
+		if key >= 10 {
+			return 5
+		}
+		return 1
+	}
+
+	// batches are fetched synchronously, as soon as they are dispatched
+	synchronous := WithExecutor[int, string](runNow)
+
+	t.Run("dispatch on weight", func(t *testing.T) {
+		rec := &recordingFetch{}
+		loader := NewDataLoader(rec.fetch, time.Hour, 10, WithBatchWeight[int, string](weight, 8), synchronous)
+
+		for _, key := range []int{1, 10, 2, 11} {
+			loader.LoadThunk(key)
+		}
+
+		if len(rec.calls) != 1 || !reflect.DeepEqual(rec.calls[0], []int{1, 10, 2, 11}) {
+			t.Errorf("expected one batch closed by weight, got %v", rec.calls)
+		}
+	})
+
+	t.Run("dispatch on count", func(t *testing.T) {
+		rec := &recordingFetch{}
+		loader := NewDataLoader(rec.fetch, time.Hour, 3, WithBatchWeight[int, string](weight, 100), synchronous)
+
+		for _, key := range []int{1, 2, 3} {
+			loader.LoadThunk(key)
+		}
+
+		if len(rec.calls) != 1 || !reflect.DeepEqual(rec.calls[0], []int{1, 2, 3}) {
+			t.Errorf("expected one batch closed by count, got %v", rec.calls)
+		}
+	})
+
+	t.Run("dispatch on timer", func(t *testing.T) {
+		rec := &recordingFetch{}
+		clock := newFakeClock()
+		loader := NewDataLoader(rec.fetch, 5*time.Millisecond, 0, WithBatchWeight[int, string](weight, 100), synchronous,
+			WithClock[int, string](clock))
+
+		thunk := loader.LoadAllThunk([]int{1, 10, 11})
+		if len(rec.calls) != 0 {
+			t.Fatalf("expected the batch to wait for its window, got %v", rec.calls)
+		}
+		clock.Advance(5 * time.Millisecond)
+		values, _ := thunk()
+
+		if len(rec.calls) != 1 || len(values) != 3 || *values[1] != "v10" {
+			t.Errorf("expected one batch closed by the timer, got %v", rec.calls)
+		}
+	})
+}
+
+func TestSetMaxBatchSplitsOversizedBatch(t *testing.T) {
+	rec := &recordingFetch{}
+	// the chunks are fetched one after the other, in order
+	loader := NewDataLoader(rec.fetch, time.Hour, 10, WithExecutor[int, string](runNow))
+
+	thunks := make([]func() (*string, error), 6)
+	for i := range thunks {
+		thunks[i] = loader.LoadThunk(i)
+	}
+
+	// lowering the limit below the size of the open batch dispatches it, split into chunks
+	loader.SetMaxBatch(4)
+	if !reflect.DeepEqual(rec.calls, [][]int{{0, 1, 2, 3}, {4, 5}}) {
+		t.Fatalf("expected the chunks to be fetched before SetMaxBatch returns, got %v", rec.calls)
+	}
+	for i, thunk := range thunks {
+		v, err := thunk()
+		if err != nil || *v != "v"+strconv.Itoa(i) {
+			t.Errorf("key %d: unexpected result %v, %v", i, v, err)
+		}
+	}
+
+}
+
+func TestSplitBatchStitchesErrors(t *testing.T) {
+	errChunk := errors.New("chunk failed")
+	errKey := errors.New("key failed")
+	fetchFn := func(keys []int) ([]*string, []error) {
+		results := make([]*string, len(keys))
+		for i, k := range keys {
+			v := strconv.Itoa(k)
+			results[i] = &v
+		}
+		if keys[0] == 0 {
+			return results, []error{errChunk}
+		}
+		errs := make([]error, len(keys))
+		errs[1] = errKey
+		return results, errs
+	}
+
+	loader := NewDataLoader(fetchFn, time.Hour, 10, WithExecutor[int, string](runNow))
+	thunks := make([]func() (*string, error), 4)
+	for i := range thunks {
+		thunks[i] = loader.LoadThunk(i)
+	}
+	loader.SetMaxBatch(2)
+
+	for i, thunk := range thunks {
+		v, err := thunk()
+		if !errors.Is(err, errChunk) || !errors.Is(err, errKey) {
+			t.Errorf("key %d: expected the errors of both chunks, got %v", i, err)
+		}
+		if *v != strconv.Itoa(i) {
+			t.Errorf("key %d: expected the value to be stitched in batch order, got %s", i, *v)
+		}
+	}
+	if _, err := thunks[0](); strings.Count(err.Error(), "chunk failed") != 1 {
+		t.Errorf("expected the chunk error to be reported once, got %q", err)
+	}
+}
+
+func TestLoadAllOrError(t *testing.T) {
+	fetchFn := func(keys []int) ([]*string, []error) {
+		results := make([]*string, len(keys))
+		errs := make([]error, len(keys))
+		for i, k := range keys {
+			if k%2 == 1 {
+				errs[i] = errors.New("odd key " + strconv.Itoa(k))
+				continue
+			}
+			v := strconv.Itoa(k)
+			results[i] = &v
+		}
+		return results, errs
+	}
+	loader := NewDataLoader(fetchFn, time.Millisecond, 2)
+
+	values, err := loader.LoadAllOrError([]int{0, 2, 4})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(values) != 3 || *values[2] != "4" {
+		t.Errorf("unexpected values %v", values)
+	}
+
+	_, err = loader.LoadAllThunkOrError([]int{10, 11, 12, 13})()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, msg := range []string{"odd key 11", "odd key 13"} {
+		if strings.Count(err.Error(), msg) != 1 {
+			t.Errorf("expected %q to be reported once, got %q", msg, err)
+		}
+	}
+}
+
+func TestLoadAllPartitionsCachedKeys(t *testing.T) {
+	rec := &recordingFetch{}
+	errNegative := errors.New("negative key")
+	loader := NewDataLoader(rec.fetch, time.Millisecond, 0, WithKeyFilter[int, string](func(key int) error {
+		if key < 0 {
+			return errNegative
+		}
+		return nil
+	}))
+	for _, key := range []int{2, 4} {
+		v := "p" + strconv.Itoa(key)
+		loader.Prime(key, &v)
+	}
+
+	values, errs := loader.LoadAll([]int{1, 2, -3, 4, 5, 1})
+	want := []string{"v1", "p2", "", "p4", "v5", "v1"}
+	for i, w := range want {
+		switch {
+		case w == "":
+			if !errors.Is(errs[i], errNegative) {
+				t.Errorf("expected the error of the rejected key at %d, got %v", i, errs[i])
+			}
+		case errs[i] != nil || *values[i] != w:
+			t.Errorf("expected %s at %d, got %v, %v", w, i, values[i], errs[i])
+		}
+	}
+	if !reflect.DeepEqual(rec.calls, [][]int{{1, 5}}) {
+		t.Errorf("expected only the uncached keys to be fetched once, got %v", rec.calls)
+	}
+	if stats := loader.Stats(); stats.Loads != 6 || stats.CacheHits != 2 || stats.Coalesced != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// primes and clears racing with LoadAll never mix up keys
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for i := range 1000 {
+			v := "p" + strconv.Itoa(i%10)
+			loader.Prime(i%10, &v)
+			loader.Clear((i + 5) % 10)
+		}
+	})
+	keys := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	for range 100 {
+		values, errs := loader.LoadAll(keys)
+		for i, v := range values {
+			if k, ok := keyOfValue(*v); errs[i] != nil || !ok || k != keys[i] {
+				t.Fatalf("expected the value of key %d, got %q, %v", keys[i], *v, errs[i])
+			}
+		}
+	}
+	wg.Wait()
+}
+
+// BenchmarkLoadAllWarm loads 10k keys of which 90% are cached, the cached keys are answered under the
+// same acquisition of the loader's mutex that batches the rest. It reports the acquisitions per LoadAll:
+// one to request the keys, one to flush the batch of the rest right away so the window does not count,
+// and three for the fetch of that batch, where answering every key on its own took one per key.
+func BenchmarkLoadAllWarm(b *testing.B) {
+	fetchFn := func(keys []int) ([]*string, []error) {
+		return make([]*string, len(keys)), nil
+	}
+	loader := NewDataLoader(fetchFn, time.Hour, 0)
+	keys := make([]int, 10000)
+	for i := range keys {
+		keys[i] = i
+		if i%10 != 0 {
+			loader.Prime(i, new(string))
+		}
+	}
+	var acquisitions atomic.Uint64
+	loader.mu.acquisitions = &acquisitions
+
+	b.ReportAllocs()
+	var locked uint64
+	for b.Loop() {
+		before := acquisitions.Load()
+		thunk := loader.LoadAllThunk(keys)
+		loader.Flush()
+		_, _ = thunk()
+		locked += acquisitions.Load() - before
+
+		b.StopTimer()
+		for i := 0; i < len(keys); i += 10 {
+			loader.Clear(i)
+		}
+		b.StartTimer()
+	}
+	b.ReportMetric(float64(locked)/float64(b.N), "locks/op")
+}
+
+// BenchmarkLoadThunkContended requests distinct keys from many goroutines into small batches, run it with
+// -mutexprofile to see how long requests and the batch lifecycle hold the loader's mutex
+func BenchmarkLoadThunkContended(b *testing.B) {
+	fetchFn := func(keys []int) ([]*string, []error) {
+		return make([]*string, len(keys)), nil
+	}
+	loader := NewDataLoader(fetchFn, time.Millisecond, 16)
+
+	var next atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			loader.LoadThunk(int(next.Add(1)))
+		}
+	})
+}
+
+// BenchmarkNewLoaderUnused creates and drops 10k lite loaders per op, as a server creating loaders per request
+// does, loaders that are never used should cost little more than their own allocation
+func BenchmarkNewLoaderUnused(b *testing.B) {
+	fetchFn := func(keys []int) ([]*string, []error) {
+		return make([]*string, len(keys)), nil
+	}
+	lite := WithLite[int, string]()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 10000; j++ {
+			NewDataLoader(fetchFn, time.Millisecond, 100, lite)
+		}
+	}
+}
+
+func TestNewLoaderAllocs(t *testing.T) {
+	fetchFn := func(keys []int) ([]*string, []error) {
+		return make([]*string, len(keys)), nil
+	}
+	lite := WithLite[int, string]()
+	// validating the options must not allocate, whatever options are added later
+	if n := testing.AllocsPerRun(100, func() { NewDataLoader(fetchFn, time.Millisecond, 100, lite) }); n != 1 {
+		t.Errorf("expected an unused loader to cost a single allocation, got %v", n)
+	}
+}
+
+func TestInlineFetch(t *testing.T) {
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	fetchFn := func(keys []int) ([]*string, []error) {
+		calls.Add(1)
+		stack := make([]byte, 4<<10)
+		if !strings.Contains(string(stack[:runtime.Stack(stack, false)]), "TestInlineFetch") {
+			t.Errorf("expected the fetch to run on the goroutine of the load")
+		}
+		close(started)
+		<-release
+		v := "v" + strconv.Itoa(keys[0])
+		return []*string{&v}, nil
+	}
+	loader := NewDataLoader(fetchFn, 0, 1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if v, err := loader.Load(1); err != nil || *v != "v1" {
+			t.Errorf("expected v1, got %v, %v", v, err)
+		}
+	}()
+	<-started
+
+	// concurrent loads of the key join the fetch in flight
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := loader.Load(1); err != nil || *v != "v1" {
+				t.Errorf("expected v1, got %v, %v", v, err)
+			}
+		}()
+	}
+	for loader.Stats().Coalesced != 5 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("expected a single fetch, got %d", calls.Load())
+	}
+	stats := loader.Stats()
+	if stats.Batches != 1 || stats.BatchesByTrigger[MaxBatchReached] != 1 {
+		t.Errorf("expected one batch that reached its max size, got %+v", stats)
+	}
+}
+
+// BenchmarkLoadInline loads a new key every iteration from a loader that does not batch, compare it with
+// BenchmarkMapSingleFlight for the overhead of the loader over a plain cache with single flight
+func BenchmarkLoadInline(b *testing.B) {
+	fetchFn := func(keys []int) ([]*int, []error) {
+		return []*int{&keys[0]}, nil
+	}
+	loader := NewDataLoader(fetchFn, 0, 1)
+
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		if _, err := loader.Load(i); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMapSingleFlight(b *testing.B) {
+	type call struct {
+		done  chan struct{}
+		value *int
+	}
+	var mu sync.Mutex
+	cache := map[int]*int{}
+	calls := map[int]*call{}
+	load := func(key int) *int {
+		mu.Lock()
+		if v, ok := cache[key]; ok {
+			mu.Unlock()
+			return v
+		}
+		if c, ok := calls[key]; ok {
+			mu.Unlock()
+			<-c.done
+			return c.value
+		}
+		c := &call{done: make(chan struct{})}
+		calls[key] = c
+		mu.Unlock()
+
+		c.value = &key
+		mu.Lock()
+		cache[key] = c.value
+		delete(calls, key)
+		mu.Unlock()
+		close(c.done)
+		return c.value
+	}
+
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		load(i)
+	}
+}
+
+func TestClearWhere(t *testing.T) {
+	var fetched atomic.Int32
+	fetchFn := func(keys []int) ([]*string, []error) {
+		fetched.Add(int32(len(keys)))
+		results := make([]*string, len(keys))
+		for i, k := range keys {
+			org := "org" + strconv.Itoa(k%3)
+			results[i] = &org
+		}
+		return results, nil
+	}
+	loader := NewDataLoader(fetchFn, time.Millisecond, 0)
+
+	keys := []int{0, 1, 2, 3, 4, 5, 6, 7, 8}
+	_, _ = loader.LoadAll(keys)
+
+	removed := loader.ClearWhere(func(_ int, v *string) bool {
+		return v != nil && *v == "org1"
+	})
+	if removed != 3 {
+		t.Fatalf("expected 3 entries removed, got %d", removed)
+	}
+	_, _ = loader.LoadAll(keys)
+	if n := fetched.Load(); n != 12 {
+		t.Errorf("expected only the cleared keys to be fetched again, got %d fetched keys", n)
+	}
+
+	var wg sync.WaitGroup
+	for g := range 4 {
+		wg.Go(func() {
+			for i := range 200 {
+				k := (g + i) % len(keys)
+				if v, err := loader.Load(k); err != nil || *v != "org"+strconv.Itoa(k%3) {
+					t.Errorf("key %d: unexpected result %v, %v", k, v, err)
+					return
+				}
+			}
+		})
+	}
+	for range 50 {
+		loader.ClearWhere(func(k int, v *string) bool {
+			return *v == "org"+strconv.Itoa(k%3)
+		})
+	}
+	wg.Wait()
+}
+
+func TestClearMany(t *testing.T) {
+	loader, rec := newStringLoader(t, 0)
+	_, _ = loader.LoadAll([]int{1, 2, 3, 4})
+
+	if n := loader.ClearMany([]int{1, 3, 3, 9}); n != 2 {
+		t.Errorf("expected 2 entries to be removed, got %d", n)
+	}
+	if n := loader.ClearMany(nil); n != 0 {
+		t.Errorf("expected nothing to be removed, got %d", n)
+	}
+	var v string
+	for key, cached := range map[int]bool{1: false, 2: true, 3: false, 4: true} {
+		if loader.PeekInto(key, &v) != cached {
+			t.Errorf("key %d: expected cached to be %v", key, cached)
+		}
+	}
+	_, _ = loader.LoadAll([]int{1, 2, 3, 4})
+	if n := rec.callCount(); n != 2 {
+		t.Errorf("expected only the cleared keys to be fetched again, got %d fetches", n)
+	}
+}
+
+func TestClearFunc(t *testing.T) {
+	var fetched atomic.Int32
+	loader := NewDataLoader(func(keys []string) ([]*string, []error) {
+		fetched.Add(int32(len(keys)))
+		return make([]*string, len(keys)), nil
+	}, time.Millisecond, 0)
+	keys := []string{"org:1:user:1", "org:1:user:2", "org:12:user:3", "org:2:user:4"}
+	_, _ = loader.LoadAll(keys)
+
+	removed := loader.ClearFunc(func(key string) bool {
+		// the predicate may use the loader, it runs outside of its lock
+		var v string
+		_ = loader.PeekInto(key, &v)
+		return strings.HasPrefix(key, "org:1:")
+	})
+	if removed != 2 {
+		t.Fatalf("expected 2 entries removed, got %d", removed)
+	}
+	_, _ = loader.LoadAll(keys)
+	if n := fetched.Load(); n != 6 {
+		t.Errorf("expected only the keys of org 1 to be fetched again, got %d fetched keys", n)
+	}
+}
+
+func TestReplaceCache(t *testing.T) {
+	release := make(chan struct{})
+	fetchFn := func(keys []int) ([]*string, []error) {
+		<-release
+		results := make([]*string, len(keys))
+		for i, k := range keys {
+			v := "fetched" + strconv.Itoa(k)
+			results[i] = &v
+		}
+		return results, nil
+	}
+	loader := NewDataLoader(fetchFn, time.Millisecond, 0)
+
+	// a batch is being fetched while the cache is swapped
+	thunks := []func() (*string, error){loader.LoadThunk(1), loader.LoadThunk(2)}
+	for loader.Pending().DispatchedBatches == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	v := "replaced"
+	entries := map[int]*string{1: &v, 3: &v}
+	loader.ReplaceCache(entries)
+	v = "changed after the swap"
+	close(release)
+
+	for i, thunk := range thunks {
+		if got, err := thunk(); err != nil || *got != "fetched"+strconv.Itoa(i+1) {
+			t.Fatalf("key %d: the waiter should get the fetched value, got %v %v", i+1, got, err)
+		}
+	}
+
+	want := map[int]string{1: "replaced", 2: "fetched2", 3: "replaced"}
+	for key, value := range want {
+		if got, err := loader.Load(key); err != nil || *got != value {
+			t.Errorf("key %d: expected %q, got %v %v", key, value, got, err)
+		}
+	}
+	if info, _ := loader.Info(1); info.Source != SourceReplace {
+		t.Errorf("expected the swapped in entry to report its source, got %v", info.Source)
+	}
+
+	loader.ReplaceCache(nil)
+	loader.Clear(2)
+	if got, err := loader.Load(1); err != nil || *got != "fetched1" {
+		t.Errorf("expected the emptied cache to fetch again, got %v %v", got, err)
+	}
+}
+
+func TestReplaceCacheBounded(t *testing.T) {
+	loader := NewDataLoader(func(keys []int) ([]*string, []error) {
+		return make([]*string, len(keys)), nil
+	}, time.Millisecond, 0, WithMaxCacheBytes[int, string](8, stringSize))
+
+	a, b, c := "aaaa", "bbbb", "cccc"
+	loader.Prime(9, &a)
+	loader.ReplaceCache(map[int]*string{1: &a, 2: &b, 3: &c})
+
+	stats := loader.Stats()
+	if stats.CacheBytes != 8 || stats.Evictions != 1 {
+		t.Fatalf("expected 8 bytes cached after one eviction, got %d bytes and %d evictions", stats.CacheBytes, stats.Evictions)
+	}
+	if _, ok := loader.Info(9); ok {
+		t.Fatal("expected the old entries to be gone")
+	}
+}
+
+func TestLoadCtxCanceled(t *testing.T) {
+	t.Run("before dispatch", func(t *testing.T) {
+		rec := &recordingFetch{}
+		loader := NewDataLoader(rec.fetch, time.Hour, 0)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := loader.LoadCtx(ctx, 1); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+		if n := rec.callCount(); n != 0 {
+			t.Fatalf("expected the batch to still be collecting, got %d fetches", n)
+		}
+
+		loader.Flush()
+		if v, err := loader.Load(1); err != nil || *v != "v1" {
+			t.Errorf("unexpected result %v, %v", v, err)
+		}
+		if n := rec.callCount(); n != 1 {
+			t.Errorf("expected the abandoned key to be fetched once and cached, got %d fetches", n)
+		}
+	})
+
+	t.Run("load all", func(t *testing.T) {
+		rec := &recordingFetch{}
+		loader := NewDataLoader(rec.fetch, time.Hour, 0)
+		primed := "primed"
+		loader.Prime(1, &primed)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		values, errs := loader.LoadAllCtx(ctx, []int{1, 2})
+		if errs[0] != nil || *values[0] != "primed" {
+			t.Errorf("expected the cached key to load, got %v, %v", values[0], errs[0])
+		}
+		if !errors.Is(errs[1], context.Canceled) {
+			t.Errorf("expected context.Canceled for the pending key, got %v", errs[1])
+		}
+		if _, errs := loader.LoadAllThunkCtx(ctx, []int{3})(); !errors.Is(errs[0], context.Canceled) {
+			t.Errorf("expected the thunk to give up too, got %v", errs[0])
+		}
+		if n := rec.callCount(); n != 0 {
+			t.Errorf("expected the batch to still be collecting, got %d fetches", n)
+		}
+	})
+
+	t.Run("during fetch", func(t *testing.T) {
+		f := newGatedVersionFetch()
+		loader := NewDataLoader(f.fetch, 0, 1)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			_, err := loader.LoadCtx(ctx, 1)
+			done <- err
+		}()
+		<-f.started
+		other := loader.LoadThunk(1)
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+
+		f.release(1)
+		if v, err := other(); err != nil || *v != "v1.1" {
+			t.Errorf("expected the other waiter to get the value, got %v, %v", v, err)
+		}
+		if v, _ := loader.Load(1); *v != "v1.1" {
+			t.Errorf("expected a cache hit, got %q", *v)
+		}
+	})
+
+	t.Run("after completion", func(t *testing.T) {
+		rec := &recordingFetch{}
+		loader := NewDataLoader(rec.fetch, time.Hour, 0)
+		ctx, cancel := context.WithCancel(context.Background())
+		thunk := loader.LoadThunkCtx(ctx, 1)
+		other := loader.LoadThunk(1)
+		loader.Flush()
+		_, _ = other()
+		cancel()
+		if v, err := thunk(); err != nil || *v != "v1" {
+			t.Errorf("expected the available result to win over the cancellation, got %v, %v", v, err)
+		}
+		if v, err := loader.LoadCtx(ctx, 1); err != nil || *v != "v1" {
+			t.Errorf("expected a cache hit with a done context, got %v, %v", v, err)
+		}
+	})
+}
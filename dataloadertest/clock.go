@@ -0,0 +1,104 @@
+package dataloadertest
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/UnAfraid/dataloaden/v3"
+)
+
+// Clock is a dataloaden.Clock that only moves when advanced, pass it to a loader with dataloaden.WithClock.
+// Due timers run in the order they are due, timers due at the same time in the order they were created.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	seq    int
+	timers []*timer
+}
+
+type timer struct {
+	clock *Clock
+	at    time.Time
+	seq   int
+	f     func()
+	done  bool
+}
+
+// NewClock returns a clock standing at start
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) AfterFunc(d time.Duration, f func()) dataloaden.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	t := &timer{clock: c, at: c.now.Add(d), seq: c.seq, f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (t *timer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	stopped := !t.done
+	t.done = true
+	return stopped
+}
+
+// Advance moves the clock forward by d, running every timer that becomes due on the calling goroutine
+func (c *Clock) Advance(d time.Duration) {
+	c.advanceTo(c.Now().Add(d), func(f func()) { f() })
+}
+
+// Next returns when the earliest pending timer is due
+func (c *Clock) Next() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.next()
+	if t == nil {
+		return time.Time{}, false
+	}
+	return t.at, true
+}
+
+func (c *Clock) next() *timer {
+	c.timers = slices.DeleteFunc(c.timers, func(t *timer) bool { return t.done })
+	var first *timer
+	for _, t := range c.timers {
+		if first == nil || t.at.Before(first.at) || t.at.Equal(first.at) && t.seq < first.seq {
+			first = t
+		}
+	}
+	return first
+}
+
+// advanceTo moves the clock to at one due timer at a time, passing each to run. Timers that the callbacks
+// create and that are due by at run as well.
+func (c *Clock) advanceTo(at time.Time, run func(f func())) {
+	for {
+		c.mu.Lock()
+		t := c.next()
+		if t == nil || t.at.After(at) {
+			if c.now.Before(at) {
+				c.now = at
+			}
+			c.mu.Unlock()
+			return
+		}
+		t.done = true
+		if c.now.Before(t.at) {
+			c.now = t.at
+		}
+		c.mu.Unlock()
+
+		run(t.f)
+	}
+}
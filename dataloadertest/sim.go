@@ -0,0 +1,298 @@
+// Package dataloadertest runs dataloaden loaders against scripted traffic on a fake clock, so batching
+// policies can be tested deterministically instead of with real sleeps.
+//
+// A Sim scripts the loads and how long the fetch takes, Run plays them against a loader created with the
+// fake clock and returns the log of every fetch and every waiter:
+//
+//	log := dataloadertest.Sim[int, User]{
+//		Events: []dataloadertest.Event[int]{
+//			{At: 0, Keys: []int{1}},
+//			{At: 2 * time.Millisecond, Keys: []int{2, 3}},
+//		},
+//		Latency: func(keys []int) time.Duration { return 10 * time.Millisecond },
+//	}.Run(func(fetch func([]int) ([]*User, []error), clock dataloaden.Clock) *dataloaden.Loader[int, User] {
+//		return dataloaden.NewDataLoader(fetch, 5*time.Millisecond, 100, dataloaden.WithClock[int, User](clock))
+//	})
+//
+// Time only moves from one scripted instant to the next: the next load, the next timer of the loader or
+// the next fetch to complete, and everything due at an instant runs before the clock moves on. Timers run
+// first, then fetches complete, then the loads scripted for the instant are made.
+//
+// The harness needs every dispatched batch to call the fetch and the loader to keep stats, it cannot run
+// loaders created WithLite, WithEagerSingle or with a retry backoff, which wait on the caller or the clock
+// outside of the scripted instants.
+package dataloadertest
+
+import (
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/UnAfraid/dataloaden/v3"
+)
+
+// Event is a scripted load: a single key is loaded with LoadThunk, several with LoadAllThunk
+type Event[K comparable] struct {
+	// when the load is made, relative to the start of the simulation
+	At   time.Duration
+	Keys []K
+}
+
+// Sim is a script of loads and a model of the fetch
+type Sim[K comparable, V any] struct {
+	Events []Event[K]
+
+	// how long a fetch of keys takes, nil means fetches complete at the instant they are called
+	Latency func(keys []K) time.Duration
+
+	// produces the results of a fetch, nil means every key is not found
+	Fetch func(keys []K) ([]*V, []error)
+}
+
+// Log is what happened during a simulation, all times are relative to its start
+type Log[K comparable] struct {
+	// every call of the fetch in the order they were made
+	Batches []Batch[K]
+
+	// every scripted event in the order they were made
+	Waiters []Waiter[K]
+}
+
+// Batch is a call of the fetch
+type Batch[K comparable] struct {
+	Keys    []K
+	Trigger dataloaden.TriggerReason
+
+	// when the fetch was called and when it returned
+	Dispatched time.Duration
+	Completed  time.Duration
+}
+
+// Waiter is a scripted event and its outcome
+type Waiter[K comparable] struct {
+	Keys []K
+	Errs []error
+
+	// when the load was made and when its results were available, Resolved is -1 when they never were
+	Requested time.Duration
+	Resolved  time.Duration
+}
+
+// Latency returns how long the waiter waited for its results
+func (w Waiter[K]) Latency() time.Duration {
+	return w.Resolved - w.Requested
+}
+
+// BatchSizes returns the number of keys of every fetch in order
+func (l Log[K]) BatchSizes() []int {
+	sizes := make([]int, len(l.Batches))
+	for i, b := range l.Batches {
+		sizes[i] = len(b.Keys)
+	}
+	return sizes
+}
+
+// fetchCall is a call of the fetch, blocked until the simulation releases it
+type fetchCall[K comparable] struct {
+	keys      []K
+	batch     int
+	releaseAt time.Time
+	release   chan struct{}
+}
+
+type waiter[K comparable, V any] struct {
+	keys  []K
+	thunk func() ([]*V, []error)
+	log   *Waiter[K]
+}
+
+type run[K comparable, V any] struct {
+	sim     Sim[K, V]
+	clock   *Clock
+	start   time.Time
+	loader  *dataloaden.Loader[K, V]
+	entered chan *fetchCall[K]
+	blocked []*fetchCall[K]
+	log     Log[K]
+
+	// trigger reasons of batches counted by the loader but not matched to a fetch call yet
+	triggers     []dataloaden.TriggerReason
+	seenTriggers map[dataloaden.TriggerReason]uint64
+}
+
+// Run plays the script against the loader newLoader creates, which must use fetch and clock
+func (s Sim[K, V]) Run(newLoader func(fetch func(keys []K) ([]*V, []error), clock dataloaden.Clock) *dataloaden.Loader[K, V]) Log[K] {
+	r := &run[K, V]{
+		sim:     s,
+		clock:   NewClock(time.Unix(1_700_000_000, 0)),
+		entered: make(chan *fetchCall[K]),
+	}
+	r.start = r.clock.Now()
+	r.loader = newLoader(r.fetch, r.clock)
+
+	events := slices.Clone(s.Events)
+	slices.SortStableFunc(events, func(a, b Event[K]) int {
+		return int(a.At - b.At)
+	})
+
+	var waiters []*waiter[K, V]
+	r.log.Waiters = make([]Waiter[K], len(events))
+	for {
+		at, ok := r.nextInstant(events)
+		if !ok {
+			break
+		}
+
+		r.clock.advanceTo(at, r.runCallback)
+		for len(r.blocked) > 0 {
+			i := slices.IndexFunc(r.blocked, func(c *fetchCall[K]) bool { return !c.releaseAt.After(at) })
+			if i < 0 {
+				break
+			}
+			r.complete(i)
+		}
+		for len(events) > 0 && r.start.Add(events[0].At).Compare(at) <= 0 {
+			w := &waiter[K, V]{keys: events[0].Keys, log: &r.log.Waiters[len(waiters)]}
+			*w.log = Waiter[K]{Keys: w.keys, Requested: r.since(at), Resolved: -1}
+			w.thunk = r.load(w.keys)
+			waiters = append(waiters, w)
+			events = events[1:]
+			r.settle()
+		}
+
+		waiters = slices.DeleteFunc(waiters, func(w *waiter[K, V]) bool {
+			for _, key := range w.keys {
+				if _, inFlight := r.loader.InFlight(key); inFlight {
+					return false
+				}
+			}
+			_, w.log.Errs = w.thunk()
+			w.log.Resolved = r.since(at)
+			return true
+		})
+	}
+	return r.log
+}
+
+func (r *run[K, V]) since(t time.Time) time.Duration {
+	return t.Sub(r.start)
+}
+
+func (r *run[K, V]) load(keys []K) func() ([]*V, []error) {
+	if len(keys) == 1 {
+		thunk := r.loader.LoadThunk(keys[0])
+		return func() ([]*V, []error) {
+			v, err := thunk()
+			return []*V{v}, []error{err}
+		}
+	}
+	return r.loader.LoadAllThunk(keys)
+}
+
+// nextInstant returns the earliest of the next event, timer and fetch completion
+func (r *run[K, V]) nextInstant(events []Event[K]) (time.Time, bool) {
+	var next time.Time
+	found := false
+	consider := func(t time.Time) {
+		if !found || t.Before(next) {
+			next, found = t, true
+		}
+	}
+	if len(events) > 0 {
+		consider(r.start.Add(events[0].At))
+	}
+	if t, ok := r.clock.Next(); ok {
+		consider(t)
+	}
+	for _, c := range r.blocked {
+		consider(c.releaseAt)
+	}
+	return next, found
+}
+
+// fetch is handed to the loader, it blocks every call until the simulation completes it
+func (r *run[K, V]) fetch(keys []K) ([]*V, []error) {
+	c := &fetchCall[K]{keys: slices.Clone(keys), release: make(chan struct{})}
+	r.entered <- c
+	<-c.release
+
+	if r.sim.Fetch != nil {
+		return r.sim.Fetch(keys)
+	}
+	return make([]*V, len(keys)), nil
+}
+
+// runCallback runs a timer callback until it returns or calls the fetch
+func (r *run[K, V]) runCallback(f func()) {
+	done := make(chan struct{})
+	go func() {
+		f()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case c := <-r.entered:
+		r.track(c, r.nextTrigger())
+	}
+	r.settle()
+}
+
+// settle waits until every dispatched batch has called the fetch
+func (r *run[K, V]) settle() {
+	for r.loader.Pending().DispatchedBatches > len(r.blocked) {
+		c := <-r.entered
+		r.track(c, r.nextTrigger())
+	}
+}
+
+func (r *run[K, V]) track(c *fetchCall[K], trigger dataloaden.TriggerReason) {
+	now := r.clock.Now()
+	c.releaseAt = now
+	if r.sim.Latency != nil {
+		c.releaseAt = now.Add(r.sim.Latency(c.keys))
+	}
+	c.batch = len(r.log.Batches)
+	r.log.Batches = append(r.log.Batches, Batch[K]{Keys: c.keys, Trigger: trigger, Dispatched: r.since(now)})
+	r.blocked = append(r.blocked, c)
+}
+
+// complete releases the blocked fetch call at i and waits until its batch completed or calls the fetch for
+// its next chunk
+func (r *run[K, V]) complete(i int) {
+	c := r.blocked[i]
+	r.blocked = slices.Delete(r.blocked, i, i+1)
+	r.log.Batches[c.batch].Completed = r.since(c.releaseAt)
+
+	dispatched := r.loader.Pending().DispatchedBatches
+	close(c.release)
+	for {
+		select {
+		case next := <-r.entered:
+			r.track(next, r.log.Batches[c.batch].Trigger)
+			return
+		default:
+		}
+		if r.loader.Pending().DispatchedBatches < dispatched {
+			return
+		}
+		runtime.Gosched()
+	}
+}
+
+// nextTrigger returns the reason the loader counted for the next batch that calls the fetch
+func (r *run[K, V]) nextTrigger() dataloaden.TriggerReason {
+	counts := r.loader.Stats().BatchesByTrigger
+	for reason := dataloaden.WaitExpired; reason <= dataloaden.SingletonFlush; reason++ {
+		for n := r.seenTriggers[reason]; n < counts[reason]; n++ {
+			r.triggers = append(r.triggers, reason)
+		}
+	}
+	r.seenTriggers = counts
+
+	if len(r.triggers) == 0 {
+		return 0
+	}
+	reason := r.triggers[0]
+	r.triggers = r.triggers[1:]
+	return reason
+}
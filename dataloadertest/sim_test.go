@@ -0,0 +1,98 @@
+package dataloadertest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/UnAfraid/dataloaden/v3"
+)
+
+func newLoader(wait time.Duration, maxBatch int) func(func([]int) ([]*int, []error), dataloaden.Clock) *dataloaden.Loader[int, int] {
+	return func(fetch func([]int) ([]*int, []error), clock dataloaden.Clock) *dataloaden.Loader[int, int] {
+		return dataloaden.NewDataLoader(fetch, wait, maxBatch, dataloaden.WithClock[int, int](clock))
+	}
+}
+
+func TestSimLatency(t *testing.T) {
+	ms := time.Millisecond
+	log := Sim[int, int]{
+		Events: []Event[int]{
+			{At: 0, Keys: []int{1}},
+			{At: 3 * ms, Keys: []int{2, 3}},
+			// key 1 is being fetched and joins its batch, key 4 starts a new one
+			{At: 12 * ms, Keys: []int{1, 4}},
+		},
+		Latency: func(keys []int) time.Duration { return time.Duration(len(keys)) * 10 * ms },
+	}.Run(newLoader(5*ms, 0))
+
+	wantBatches := []Batch[int]{
+		{Keys: []int{1, 2, 3}, Trigger: dataloaden.WaitExpired, Dispatched: 5 * ms, Completed: 35 * ms},
+		{Keys: []int{4}, Trigger: dataloaden.WaitExpired, Dispatched: 17 * ms, Completed: 27 * ms},
+	}
+	if !reflect.DeepEqual(log.Batches, wantBatches) {
+		t.Fatalf("expected batches %+v, got %+v", wantBatches, log.Batches)
+	}
+
+	wantLatencies := []time.Duration{35 * ms, 32 * ms, 23 * ms}
+	for i, w := range log.Waiters {
+		if w.Latency() != wantLatencies[i] {
+			t.Errorf("waiter %d: expected a latency of %v, got %v", i, wantLatencies[i], w.Latency())
+		}
+	}
+	if sizes := log.BatchSizes(); !reflect.DeepEqual(sizes, []int{3, 1}) {
+		t.Errorf("unexpected batch sizes %v", sizes)
+	}
+}
+
+func TestSimMaxBatch(t *testing.T) {
+	errFailed := errors.New("failed")
+	log := Sim[int, int]{
+		Events: []Event[int]{
+			{At: 0, Keys: []int{1, 2, 3, 4, 5}},
+		},
+		Latency: func(keys []int) time.Duration { return time.Millisecond },
+		Fetch: func(keys []int) ([]*int, []error) {
+			return nil, []error{errFailed}
+		},
+	}.Run(newLoader(time.Millisecond, 2))
+
+	if sizes := log.BatchSizes(); !reflect.DeepEqual(sizes, []int{2, 2, 1}) {
+		t.Fatalf("unexpected batch sizes %v", sizes)
+	}
+	triggers := []dataloaden.TriggerReason{dataloaden.MaxBatchReached, dataloaden.MaxBatchReached, dataloaden.WaitExpired}
+	for i, b := range log.Batches {
+		if b.Trigger != triggers[i] {
+			t.Errorf("batch %d: expected trigger %v, got %v", i, triggers[i], b.Trigger)
+		}
+	}
+	w := log.Waiters[0]
+	if w.Resolved != 2*time.Millisecond || !errors.Is(w.Errs[4], errFailed) {
+		t.Errorf("unexpected waiter %+v", w)
+	}
+}
+
+func TestClockAdvance(t *testing.T) {
+	clock := NewClock(time.Unix(0, 0))
+	var fired []int
+	clock.AfterFunc(2*time.Second, func() { fired = append(fired, 2) })
+	clock.AfterFunc(time.Second, func() {
+		fired = append(fired, 1)
+		clock.AfterFunc(0, func() { fired = append(fired, 3) })
+	})
+	stopped := clock.AfterFunc(time.Second, func() { fired = append(fired, 4) })
+	stopped.Stop()
+
+	clock.Advance(time.Second)
+	if !reflect.DeepEqual(fired, []int{1, 3}) {
+		t.Fatalf("unexpected timers fired %v", fired)
+	}
+	if next, ok := clock.Next(); !ok || !next.Equal(time.Unix(2, 0)) {
+		t.Fatalf("expected the next timer at 2s, got %v %v", next, ok)
+	}
+	clock.Advance(time.Second)
+	if !reflect.DeepEqual(fired, []int{1, 3, 2}) || !clock.Now().Equal(time.Unix(2, 0)) {
+		t.Fatalf("unexpected timers fired %v at %v", fired, clock.Now())
+	}
+}
@@ -0,0 +1,14 @@
+package dataloaden
+
+// BumpEpoch makes every value cached so far stale without enumerating the keys, e.g. after a bulk import.
+// Stale entries are misses, whether or not they have outlived the cache TTL, and are evicted as they are
+// read. Batches dispatched before the bump still deliver their results to their waiters, but later loads
+// of their keys fetch them again.
+func (l *Loader[K, V]) BumpEpoch() {
+	l.InvalidateBatchMemo()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.epoch.Add(1)
+	// values memoized by LoadAll are only valid for the cache generation they were read at
+	l.cache.gen++
+}
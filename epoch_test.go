@@ -0,0 +1,161 @@
+package dataloaden
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedVersionFetch returns "v<key>.<call>" and blocks every call until the test releases it
+type gatedVersionFetch struct {
+	mu      sync.Mutex
+	calls   int
+	started chan int
+	gates   [8]chan struct{}
+}
+
+func newGatedVersionFetch() *gatedVersionFetch {
+	f := &gatedVersionFetch{started: make(chan int, 8)}
+	for i := range f.gates {
+		f.gates[i] = make(chan struct{})
+	}
+	return f
+}
+
+// release lets the fetch of call return
+func (f *gatedVersionFetch) release(call int) {
+	close(f.gates[call])
+}
+
+func (f *gatedVersionFetch) fetch(keys []int) ([]*string, []error) {
+	f.mu.Lock()
+	f.calls++
+	call := f.calls
+	f.mu.Unlock()
+
+	f.started <- call
+	<-f.gates[call]
+	data := make([]*string, len(keys))
+	for i, key := range keys {
+		v := "v" + strconv.Itoa(key) + "." + strconv.Itoa(call)
+		data[i] = &v
+	}
+	return data, nil
+}
+
+func TestBumpEpoch(t *testing.T) {
+	t.Run("reads after a bump miss", func(t *testing.T) {
+		rec := &recordingFetch{}
+		loader := NewDataLoader(rec.fetch, 0, 0)
+		_, _ = loader.Load(1)
+		_, _ = loader.Load(1)
+		loader.Prime(2, nil)
+
+		loader.BumpEpoch()
+		_, _ = loader.Load(1)
+		_, _ = loader.Load(2)
+		if n := rec.callCount(); n != 3 {
+			t.Errorf("expected the keys cached before the bump to be fetched again, got %d fetches", n)
+		}
+
+		v := "p3"
+		if !loader.Prime(3, &v) {
+			t.Error("expected a prime after the bump to be stored")
+		}
+		if got, _ := loader.Load(3); *got != "p3" {
+			t.Errorf("expected the primed value, got %q", *got)
+		}
+		if n := rec.callCount(); n != 3 {
+			t.Errorf("expected values written after the bump to be fresh, got %d fetches", n)
+		}
+	})
+
+	t.Run("in flight batches", func(t *testing.T) {
+		for _, newerFirst := range []bool{false, true} {
+			f := newGatedVersionFetch()
+			loader := NewDataLoader(f.fetch, 0, 0)
+
+			before := loader.LoadThunk(1)
+			<-f.started
+			loader.BumpEpoch()
+			after := loader.LoadThunk(1)
+			<-f.started
+
+			if newerFirst {
+				f.release(2)
+				if v, _ := after(); *v != "v1.2" {
+					t.Errorf("expected the load after the bump to fetch again, got %q", *v)
+				}
+				f.release(1)
+			} else {
+				f.release(1)
+				f.release(2)
+				if v, _ := after(); *v != "v1.2" {
+					t.Errorf("expected the load after the bump to fetch again, got %q", *v)
+				}
+			}
+			if v, _ := before(); *v != "v1.1" {
+				t.Errorf("expected the batch dispatched before the bump to deliver its result, got %q", *v)
+			}
+
+			var cached string
+			if !loader.PeekInto(1, &cached) || cached != "v1.2" {
+				t.Errorf("expected the result fetched after the bump to be cached, got %q", cached)
+			}
+			if v, _ := loader.Load(1); *v != "v1.2" {
+				t.Errorf("expected a cache hit, got %q", *v)
+			}
+		}
+	})
+
+	t.Run("batches collecting during the bump", func(t *testing.T) {
+		rec := &recordingFetch{}
+		loader := NewDataLoader(rec.fetch, time.Hour, 0)
+		thunk := loader.LoadThunk(1)
+		loader.BumpEpoch()
+		loader.Flush()
+		_, _ = thunk()
+		_, _ = loader.Load(1)
+		if n := rec.callCount(); n != 1 {
+			t.Errorf("expected a batch dispatched after the bump to be fresh, got %d fetches", n)
+		}
+	})
+
+	t.Run("composes with ttl", func(t *testing.T) {
+		clock := newFakeClock()
+		rec := &recordingFetch{}
+		loader := NewDataLoader(rec.fetch, 0, 1, WithClock[int, string](clock), WithCacheTTL[int, string](time.Minute))
+		_, _ = loader.Load(1)
+		clock.Advance(2 * time.Minute)
+		_, _ = loader.Load(1)
+		loader.BumpEpoch()
+		_, _ = loader.Load(1)
+		if n := rec.callCount(); n != 3 {
+			t.Errorf("expected both the ttl and the bump to invalidate, got %d fetches", n)
+		}
+	})
+}
+
+func TestGroupBumpEpoch(t *testing.T) {
+	var g Group
+	users, usersRec := newStringLoader(t, 0)
+	orgsRec := &recordingFetch{}
+	orgs := NewScopedDataLoader(scopeOf, func(groups []ScopedKeys[int]) ([]*string, []error) {
+		return orgsRec.fetch(groups[0].Keys)
+	}, 0, 0)
+	_ = g.Register("users", users)
+	_ = g.Register("orgs", orgs)
+
+	ctx := context.Background()
+	_, _ = users.Load(1)
+	_, _ = orgs.Load(ctx, 1)
+	g.BumpEpoch()
+	_, _ = users.Load(1)
+	_, _ = orgs.Load(ctx, 1)
+
+	if usersRec.callCount() != 2 || orgsRec.callCount() != 2 {
+		t.Errorf("expected every loader of the group to fetch again, got %d and %d fetches", usersRec.callCount(), orgsRec.callCount())
+	}
+}
@@ -0,0 +1,54 @@
+package dataloaden
+
+// ErrorClass tells what kind of failure a fetch error is, see WithClassifyError
+type ErrorClass uint8
+
+const (
+	// Unknown errors are neither retried nor cached and do not trip the circuit breaker
+	Unknown ErrorClass = iota
+
+	// NotFound errors mean the key does not exist, with WithNegativeCache they are cached as missing values
+	NotFound
+
+	// Transient errors are worth retrying, see WithRetry
+	Transient
+
+	// Fatal errors fail right away and count towards tripping the circuit breaker, see WithCircuitBreaker
+	Fatal
+
+	// sizes the per class counters, keep last
+	errorClassCount = iota
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case NotFound:
+		return "not found"
+	case Transient:
+		return "transient"
+	case Fatal:
+		return "fatal"
+	default:
+		return "unknown"
+	}
+}
+
+// classify returns the class of a fetch error, Unknown without a classifier
+func (l *Loader[K, V]) classify(err error) ErrorClass {
+	if l.classifyError == nil || err == nil {
+		return Unknown
+	}
+	return l.classifyError(err)
+}
+
+// countErrors counts the errors a fetch returned by class
+func (l *Loader[K, V]) countErrors(errs []error) {
+	if l.noStats {
+		return
+	}
+	for _, err := range errs {
+		if err != nil {
+			l.count(&l.stats.errorClasses[l.classify(err)], 1)
+		}
+	}
+}
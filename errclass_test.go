@@ -0,0 +1,157 @@
+package dataloaden
+
+import (
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var (
+	errMissing = errors.New("missing")
+	errTimeout = errors.New("timeout")
+	errCorrupt = errors.New("corrupt")
+)
+
+func classifyTestError(err error) ErrorClass {
+	switch {
+	case errors.Is(err, errMissing):
+		return NotFound
+	case errors.Is(err, errTimeout):
+		return Transient
+	case errors.Is(err, errCorrupt):
+		return Fatal
+	default:
+		return Unknown
+	}
+}
+
+// scriptedFetch fails every key with the next error of its script, "v<key>" once the script has run out
+type scriptedFetch struct {
+	calls  atomic.Int32
+	script []error
+}
+
+func (f *scriptedFetch) fetch(keys []int) ([]*string, []error) {
+	n := int(f.calls.Add(1))
+	results := make([]*string, len(keys))
+	errs := make([]error, len(keys))
+	for i, k := range keys {
+		if n <= len(f.script) && f.script[n-1] != nil {
+			errs[i] = f.script[n-1]
+			continue
+		}
+		v := "v" + strconv.Itoa(k)
+		results[i] = &v
+	}
+	return results, errs
+}
+
+func newClassifiedLoader(f *scriptedFetch, clock Clock) *Loader[int, string] {
+	return NewDataLoader(f.fetch, time.Millisecond, 0,
+		WithClock[int, string](clock),
+		WithEagerSingle[int, string](),
+		WithClassifyError[int, string](classifyTestError),
+		WithRetry[int, string](2, 0),
+		WithNegativeCache[int, string](),
+		WithCircuitBreaker[int, string](2, time.Minute),
+	)
+}
+
+func TestErrorClassesDrivePolicies(t *testing.T) {
+	t.Run("transient errors are retried", func(t *testing.T) {
+		f := &scriptedFetch{script: []error{errTimeout, errTimeout}}
+		loader := newClassifiedLoader(f, newFakeClock())
+
+		if v, err := loader.Load(1); err != nil || *v != "v1" {
+			t.Fatalf("expected the third attempt to succeed, got %v, %v", v, err)
+		}
+		stats := loader.Stats()
+		if f.calls.Load() != 3 || stats.Retries != 2 || stats.ErrorsByClass[Transient] != 2 {
+			t.Errorf("expected 2 retries, got %d calls and %+v", f.calls.Load(), stats)
+		}
+	})
+
+	t.Run("retries give up", func(t *testing.T) {
+		f := &scriptedFetch{script: []error{errTimeout, errTimeout, errTimeout}}
+		loader := newClassifiedLoader(f, newFakeClock())
+
+		if _, err := loader.Load(1); !errors.Is(err, errTimeout) {
+			t.Fatalf("expected the transient error, got %v", err)
+		}
+		if f.calls.Load() != 3 {
+			t.Errorf("expected 3 attempts, got %d", f.calls.Load())
+		}
+	})
+
+	t.Run("not found errors are cached as missing", func(t *testing.T) {
+		f := &scriptedFetch{script: []error{errMissing}}
+		loader := newClassifiedLoader(f, newFakeClock())
+
+		for range 2 {
+			r := loader.LoadResult(1)
+			if r.Err != nil || r.Found || r.Value != nil {
+				t.Fatalf("expected a missing value, got %+v", r)
+			}
+		}
+		stats := loader.Stats()
+		if f.calls.Load() != 1 || stats.ErrorsByClass[NotFound] != 1 || stats.Retries != 0 {
+			t.Errorf("expected a single fetch, got %d calls and %+v", f.calls.Load(), stats)
+		}
+	})
+
+	t.Run("fatal errors fail fast and trip the breaker", func(t *testing.T) {
+		clock := newFakeClock()
+		f := &scriptedFetch{script: []error{errCorrupt, errCorrupt, errCorrupt}}
+		loader := newClassifiedLoader(f, clock)
+
+		for i := range 2 {
+			if _, err := loader.Load(1); !errors.Is(err, errCorrupt) {
+				t.Fatalf("load %d: expected the fatal error, got %v", i, err)
+			}
+		}
+		if f.calls.Load() != 2 {
+			t.Fatalf("expected fatal errors not to be retried, got %d calls", f.calls.Load())
+		}
+
+		if _, err := loader.Load(1); !errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected the breaker to be open, got %v", err)
+		}
+		if f.calls.Load() != 2 {
+			t.Fatalf("expected the open breaker not to call the fetch, got %d calls", f.calls.Load())
+		}
+
+		// after the cooldown one more fatal failure reopens it
+		clock.Advance(time.Minute)
+		if _, err := loader.Load(1); !errors.Is(err, errCorrupt) {
+			t.Fatalf("expected the fetch to be tried again, got %v", err)
+		}
+		if _, err := loader.Load(1); !errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected the breaker to reopen, got %v", err)
+		}
+
+		// and a success closes it
+		clock.Advance(time.Minute)
+		if v, err := loader.Load(1); err != nil || *v != "v1" {
+			t.Fatalf("expected the fetch to succeed, got %v, %v", v, err)
+		}
+		if stats := loader.Stats(); stats.ErrorsByClass[Fatal] != 3 {
+			t.Errorf("expected 3 fatal errors, got %+v", stats)
+		}
+	})
+
+	t.Run("unknown errors", func(t *testing.T) {
+		f := &scriptedFetch{script: []error{errors.New("boom"), errors.New("boom"), errors.New("boom")}}
+		loader := newClassifiedLoader(f, newFakeClock())
+
+		for range 3 {
+			if _, err := loader.Load(1); err == nil {
+				t.Fatal("expected the error")
+			}
+		}
+		if f.calls.Load() != 3 {
+			t.Errorf("expected unknown errors neither to be retried nor to trip the breaker, got %d calls", f.calls.Load())
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package dataloaden
+
+// submit hands a dispatched batch to the executor, or to a goroutine of its own without one. It must not
+// be called with the lock held, and does nothing for a nil batch.
+func (l *Loader[K, V]) submit(b *genericLoaderBatch[K, V]) {
+	if b == nil {
+		return
+	}
+	// a reentrant batch runs within a fetch that may occupy the last worker of the executor
+	if l.executor == nil || b.trigger == ReentrantFlush {
+		go b.end(l)
+		return
+	}
+
+	var queued Timer
+	if l.watchdog.QueuedAfter > 0 && l.watchdog.OnQueued != nil {
+		queued = b.watchQueued(l)
+	}
+	l.executor(func() {
+		if queued != nil {
+			queued.Stop()
+		}
+		b.end(l)
+	})
+}
+
+// endTimed ends a batch dispatched by one of its timers, on the timer's goroutine unless the loader has
+// an executor
+func (l *Loader[K, V]) endTimed(b *genericLoaderBatch[K, V]) {
+	if l.executor == nil {
+		b.end(l)
+		return
+	}
+	l.submit(b)
+}
+
+// watchQueued starts the timer reporting a batch that waits for the executor longer than
+// Watchdog.QueuedAfter, the task stops it once it runs
+func (b *genericLoaderBatch[K, V]) watchQueued(l *Loader[K, V]) Timer {
+	submitted := l.clock.Now()
+	return l.clock.AfterFunc(l.watchdog.QueuedAfter, func() {
+		l.watchdog.OnQueued(StuckBatch[K]{
+			Loader: l.name,
+			Keys:   append([]K(nil), b.keys...),
+			Age:    l.clock.Now().Sub(submitted),
+		})
+	})
+}
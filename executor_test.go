@@ -0,0 +1,147 @@
+package dataloaden
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// workerPool runs tasks on a fixed number of workers, submitting blocks while all of them are busy
+type workerPool struct {
+	tasks         chan func()
+	running, peak atomic.Int32
+}
+
+func newWorkerPool(t *testing.T, workers int) *workerPool {
+	p := &workerPool{tasks: make(chan func())}
+	for range workers {
+		go func() {
+			for task := range p.tasks {
+				n := p.running.Add(1)
+				for peak := p.peak.Load(); n > peak && !p.peak.CompareAndSwap(peak, n); peak = p.peak.Load() {
+				}
+				task()
+				p.running.Add(-1)
+			}
+		}()
+	}
+	t.Cleanup(func() { close(p.tasks) })
+	return p
+}
+
+func (p *workerPool) run(task func()) {
+	p.tasks <- task
+}
+
+// runNow is an executor that runs every task on the goroutine that submits it
+func runNow(task func()) {
+	task()
+}
+
+func TestExecutor(t *testing.T) {
+	t.Run("synchronous", func(t *testing.T) {
+		clock := newFakeClock()
+		rec := &recordingFetch{}
+		loader := NewDataLoader(rec.fetch, 10*time.Millisecond, 2,
+			WithClock[int, string](clock),
+			WithExecutor[int, string](runNow),
+		)
+
+		// a full batch is fetched before the load that filled it returns
+		_ = loader.LoadThunk(1)
+		_ = loader.LoadThunk(2)
+		if n := rec.callCount(); n != 1 {
+			t.Fatalf("expected the full batch to be fetched, got %d fetches", n)
+		}
+		// so is a flushed batch before Flush returns, and an expired one before the clock moved on
+		_ = loader.LoadThunk(3)
+		loader.Flush()
+		if n := rec.callCount(); n != 2 {
+			t.Fatalf("expected the flushed batch to be fetched, got %d fetches", n)
+		}
+		thunk := loader.LoadThunk(4)
+		clock.Advance(10 * time.Millisecond)
+		if n := rec.callCount(); n != 3 {
+			t.Fatalf("expected the expired batch to be fetched, got %d fetches", n)
+		}
+		if v, err := thunk(); err != nil || *v != "v4" {
+			t.Errorf("unexpected result %v, %v", v, err)
+		}
+	})
+
+	t.Run("bounded pool", func(t *testing.T) {
+		pool := newWorkerPool(t, 1)
+		var fetching, peak atomic.Int32
+		loader := NewDataLoader(func(keys []int) ([]*string, []error) {
+			n := fetching.Add(1)
+			defer fetching.Add(-1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(time.Millisecond)
+			return (&recordingFetch{}).fetch(keys)
+		}, 0, 1, WithExecutor[int, string](pool.run))
+
+		var wg sync.WaitGroup
+		for g := range 4 {
+			wg.Go(func() {
+				values, errs := loader.LoadAll([]int{g * 10, g*10 + 1, g*10 + 2})
+				for i, err := range errs {
+					if err != nil || values[i] == nil {
+						t.Errorf("unexpected result %v, %v", values[i], err)
+					}
+				}
+			})
+		}
+		wg.Wait()
+		if p := peak.Load(); p != 1 {
+			t.Errorf("expected the pool to run one fetch at a time, got %d", p)
+		}
+	})
+
+	t.Run("reentrant loads bypass the executor", func(t *testing.T) {
+		pool := newWorkerPool(t, 1)
+		var loader *Loader[int, string]
+		loader = NewDataLoaderCtx(parentFetch(&loader), 0, 0, WithExecutor[int, string](pool.run))
+		result := withinDeadline(t, func() Result[*string] { return loader.LoadResult(2) })
+		if result.Err != nil || *result.Value != "v2<v1<v0" {
+			t.Errorf("unexpected result %+v", result)
+		}
+	})
+
+	t.Run("watchdog reports queued batches", func(t *testing.T) {
+		clock := newFakeClock()
+		var queued []func()
+		var reported []StuckBatch[int]
+		loader := NewDataLoader((&recordingFetch{}).fetch, time.Hour, 0,
+			WithClock[int, string](clock),
+			WithExecutor[int, string](func(task func()) { queued = append(queued, task) }),
+			WithWatchdog[int, string](Watchdog[int]{
+				QueuedAfter: time.Second,
+				OnQueued:    func(batch StuckBatch[int]) { reported = append(reported, batch) },
+			}),
+		)
+
+		thunk := loader.LoadThunk(1)
+		loader.Flush()
+		clock.Advance(2 * time.Second)
+		if len(reported) != 1 || len(reported[0].Keys) != 1 || reported[0].Age != 2*time.Second {
+			t.Fatalf("expected the queued batch to be reported, got %+v", reported)
+		}
+		for _, task := range queued {
+			task()
+		}
+		if v, err := thunk(); err != nil || *v != "v1" {
+			t.Errorf("unexpected result %v, %v", v, err)
+		}
+
+		thunk = loader.LoadThunk(2)
+		loader.Flush()
+		queued[1]()
+		clock.Advance(2 * time.Second)
+		if len(reported) != 1 {
+			t.Errorf("expected a batch that ran in time not to be reported, got %+v", reported)
+		}
+		_, _ = thunk()
+	})
+}
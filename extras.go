@@ -0,0 +1,39 @@
+package dataloaden
+
+import (
+	"context"
+	"time"
+)
+
+// NewDataLoaderWithExtras creates a loader whose fetch can return values for keys it was not asked for,
+// e.g. related entities a batch endpoint includes for free. The extras are primed into the cache with
+// Prime semantics: they never overwrite a cached entry and are never delivered to a waiter of the batch,
+// keys already pending in a batch get the value their batch fetches.
+func NewDataLoaderWithExtras[K comparable, V any](fetchFn func(keys []K) ([]*V, []error, map[K]*V), waitDuration time.Duration, maxBatch int, opts ...Option[K, V]) *Loader[K, V] {
+	l := NewDataLoader(nil, waitDuration, maxBatch, opts...)
+	l.fetch = func(_ context.Context, keys []K) ([]*V, []error) {
+		data, errs, extras := fetchFn(keys)
+		l.primeExtras(extras)
+		return data, errs
+	}
+	return l
+}
+
+func (l *Loader[K, V]) primeExtras(extras map[K]*V) {
+	if len(extras) == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, value := range extras {
+		key, err := l.checkKey(key)
+		if err != nil {
+			continue
+		}
+		if _, pending := l.pending[key]; pending {
+			continue
+		}
+		l.unsafePrime(key, value, SourceExtra)
+	}
+}
@@ -0,0 +1,58 @@
+package dataloaden
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoaderWithExtras(t *testing.T) {
+	var calls atomic.Int32
+	var returned []*string
+	// every user comes with its organization, keyed by the negated user id
+	fetchFn := func(keys []int) ([]*string, []error, map[int]*string) {
+		calls.Add(1)
+		results := make([]*string, len(keys))
+		extras := map[int]*string{}
+		for i, k := range keys {
+			user := "user" + strconv.Itoa(k)
+			org := "org" + strconv.Itoa(k)
+			results[i] = &user
+			extras[-k] = &org
+			returned = append(returned, &org)
+		}
+		// extras for keys of the batch itself are ignored
+		bogus := "bogus"
+		extras[keys[0]] = &bogus
+		return results, make([]error, len(keys)), extras
+	}
+
+	loader := NewDataLoaderWithExtras(fetchFn, time.Millisecond, 0)
+	primed := "primed org2"
+	loader.Prime(-2, &primed)
+
+	users, errs := loader.LoadAll([]int{1, 2})
+	if errs[0] != nil || *users[0] != "user1" || *users[1] != "user2" {
+		t.Fatalf("unexpected results %v, %v", users, errs)
+	}
+	for _, v := range returned {
+		*v = "mutated"
+	}
+
+	if org, err := loader.Load(-1); err != nil || *org != "org1" {
+		t.Errorf("expected a copy of the extra to be cached, got %v, %v", org, err)
+	}
+	if org, _ := loader.Load(-2); *org != primed {
+		t.Errorf("expected the extra not to overwrite the primed entry, got %q", *org)
+	}
+	if user, _ := loader.Load(1); *user != "user1" {
+		t.Errorf("expected the fetched value to win over the extra, got %q", *user)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected the extras to be served from the cache, got %d fetches", calls.Load())
+	}
+	if info, _ := loader.Info(-1); info.Source != SourceExtra {
+		t.Errorf("expected the entry to come from the extras, got %v", info.Source)
+	}
+}
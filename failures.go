@@ -0,0 +1,100 @@
+package dataloaden
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FailureRecord describes a batch whose fetch failed, see WithFailureLog
+type FailureRecord struct {
+	// when the batch completed
+	At time.Time
+
+	// the number of keys in the batch and why it was dispatched
+	Keys    int
+	Trigger TriggerReason
+
+	// up to the configured number of errors the fetch returned, in the order of the keys
+	Errors []FailedKey
+}
+
+// FailedKey is an error the fetch returned for a key. Key is empty for an error that failed the whole batch.
+type FailedKey struct {
+	Key string
+	Err error
+}
+
+// failureLog keeps the most recent failure records in a ring
+type failureLog[K comparable] struct {
+	maxErrors int
+	redactKey func(key K) string
+
+	mu      sync.Mutex
+	records []FailureRecord
+	next    int
+	full    bool
+}
+
+func (f *failureLog[K]) add(record FailureRecord) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.records[f.next] = record
+	f.next++
+	if f.next == len(f.records) {
+		f.next, f.full = 0, true
+	}
+}
+
+func (f *failureLog[K]) recent() []FailureRecord {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.full {
+		return append([]FailureRecord(nil), f.records[:f.next]...)
+	}
+	return append(append([]FailureRecord(nil), f.records[f.next:]...), f.records[:f.next]...)
+}
+
+func (f *failureLog[K]) keyString(key K) string {
+	if f.redactKey != nil {
+		return f.redactKey(key)
+	}
+	return fmt.Sprint(key)
+}
+
+// recordFailure adds the batch to the failure log. errs are the errors of the batch, aligned with its keys
+// or not, err their aggregate.
+func (b *genericLoaderBatch[K, V]) recordFailure(l *Loader[K, V], errs []error, err error) {
+	log := l.failures
+	if log == nil {
+		return
+	}
+	record := FailureRecord{At: l.clock.Now(), Keys: len(b.keys), Trigger: b.trigger}
+	aligned := len(errs) == len(b.keys)
+	for pos, e := range errs {
+		if len(record.Errors) == log.maxErrors {
+			break
+		}
+		if e == nil {
+			continue
+		}
+		failed := FailedKey{Err: e}
+		if aligned {
+			failed.Key = log.keyString(b.keys[pos])
+		}
+		record.Errors = append(record.Errors, failed)
+	}
+	if len(record.Errors) == 0 && log.maxErrors > 0 {
+		record.Errors = append(record.Errors, FailedKey{Err: err})
+	}
+	log.add(record)
+}
+
+// RecentFailures returns the most recent batches whose fetch failed, oldest first. It is empty unless
+// the loader was created WithFailureLog.
+func (l *Loader[K, V]) RecentFailures() []FailureRecord {
+	if l.failures == nil {
+		return nil
+	}
+	return l.failures.recent()
+}
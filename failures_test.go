@@ -0,0 +1,63 @@
+package dataloaden
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRecentFailures(t *testing.T) {
+	errDown := errors.New("backend down")
+	fetchFn := func(keys []int) ([]*string, []error) {
+		if keys[0] < 0 {
+			return nil, []error{errDown}
+		}
+		errs := make([]error, len(keys))
+		for i, key := range keys {
+			errs[i] = errors.New("no key " + strconv.Itoa(key))
+		}
+		return make([]*string, len(keys)), errs
+	}
+	redact := func(key int) string { return "k#" + strconv.Itoa(key%10) }
+	loader := NewDataLoader(fetchFn, time.Hour, 1, WithFailureLog[int, string](3, 2, redact))
+
+	if failures := loader.RecentFailures(); len(failures) != 0 {
+		t.Fatalf("expected no failures yet, got %v", failures)
+	}
+	for key := range 5 {
+		_, _ = loader.Load(key)
+	}
+
+	failures := loader.RecentFailures()
+	if len(failures) != 3 {
+		t.Fatalf("expected the log to hold 3 failures, got %d", len(failures))
+	}
+	for i, failure := range failures {
+		want := "k#" + strconv.Itoa(i+2)
+		if failure.Keys != 1 || failure.Trigger != MaxBatchReached || len(failure.Errors) != 1 || failure.Errors[0].Key != want {
+			t.Errorf("expected the failure of %s oldest first, got %+v", want, failure)
+		}
+		if failure.At.IsZero() {
+			t.Error("expected the failure to be timestamped")
+		}
+	}
+
+	loader.SetMaxBatch(0)
+	thunk := loader.LoadAllThunk([]int{10, 11, 12})
+	loader.Flush()
+	_, _ = thunk()
+	down := loader.LoadAllThunk([]int{-1, -2})
+	loader.Flush()
+	_, _ = down()
+
+	failures = loader.RecentFailures()
+	last := failures[len(failures)-1]
+	if last.Keys != 2 || len(last.Errors) != 1 || last.Errors[0].Key != "" || !errors.Is(last.Errors[0].Err, errDown) {
+		t.Errorf("expected the batch wide error without a key, got %+v", last)
+	}
+	batch := failures[len(failures)-2]
+	if batch.Keys != 3 || batch.Trigger != ManualFlush || len(batch.Errors) != 2 || batch.Errors[1].Key != "k#1" {
+		t.Errorf("expected 2 of the 3 errors of the batch, got %+v", batch)
+	}
+}
@@ -0,0 +1,219 @@
+package dataloaden
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingFetch returns values of the form "v<key>" and records every key slice it was called with
+type recordingFetch struct {
+	mu    sync.Mutex
+	calls [][]int
+}
+
+func (r *recordingFetch) fetch(keys []int) ([]*string, []error) {
+	r.mu.Lock()
+	r.calls = append(r.calls, append([]int(nil), keys...))
+	r.mu.Unlock()
+
+	results := make([]*string, len(keys))
+	for i, k := range keys {
+		v := "v" + strconv.Itoa(k)
+		results[i] = &v
+	}
+	return results, make([]error, len(keys))
+}
+
+func (r *recordingFetch) callCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.calls)
+}
+
+// keyOfValue extracts the key a fetched ("v<key>") or primed ("p<key>") value was produced for
+func keyOfValue(v string) (int, bool) {
+	if !strings.HasPrefix(v, "v") && !strings.HasPrefix(v, "p") {
+		return 0, false
+	}
+	k, err := strconv.Atoi(v[1:])
+	return k, err == nil
+}
+
+// FuzzLoaderOperations interprets the input as a sequence of operations against a single loader:
+// each pair of bytes is an operation and a key (or key count for LoadAll).
+func FuzzLoaderOperations(f *testing.F) {
+	f.Add([]byte{2, 0, 1, 0, 1, 1, 0, 4})
+	f.Add([]byte{3, 0, 1, 0, 1, 1, 0, 2, 4, 0, 3, 1})
+	f.Add([]byte{1, 2, 0, 2, 3, 2, 1, 3, 4, 0, 0, 0, 0, 7})
+	f.Add([]byte{0, 0, 0, 5, 2, 9, 0, 1, 0, 2, 0, 3, 4, 0, 1, 8})
+
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		if len(ops) == 0 {
+			return
+		}
+		maxBatch := int(ops[0] % 4)
+		ops = ops[1:]
+
+		rec := &recordingFetch{}
+		loader := NewDataLoader(rec.fetch, 0, maxBatch)
+
+		check := func(key int, v *string, err error) {
+			t.Helper()
+			if err != nil {
+				t.Fatalf("key %d: unexpected error: %v", key, err)
+			}
+			if v == nil {
+				t.Fatalf("key %d: unexpected nil value", key)
+			}
+			if got, ok := keyOfValue(*v); !ok || got != key {
+				t.Fatalf("key %d: received value %q for another key", key, *v)
+			}
+		}
+
+		type pending struct {
+			key   int
+			thunk func() (*string, error)
+		}
+		var thunks []pending
+		requested := 0
+		resolve := func() {
+			for _, p := range thunks {
+				v, err := p.thunk()
+				check(p.key, v, err)
+			}
+			thunks = thunks[:0]
+		}
+
+		for i := 0; i+1 < len(ops); i += 2 {
+			key := int(ops[i+1] % 8)
+			switch ops[i] % 5 {
+			case 0:
+				thunks = append(thunks, pending{key: key, thunk: loader.LoadThunk(key)})
+				requested++
+			case 1:
+				keys := make([]int, int(ops[i+1]%6))
+				for j := range keys {
+					keys[j] = (key + j*3) % 8
+				}
+				values, errs := loader.LoadAll(keys)
+				for j, k := range keys {
+					check(k, values[j], errs[j])
+				}
+				requested += len(keys)
+			case 2:
+				v := "p" + strconv.Itoa(key)
+				loader.Prime(key, &v)
+			case 3:
+				loader.Clear(key)
+			case 4:
+				resolve()
+			}
+		}
+		resolve()
+
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		fetched := 0
+		for _, call := range rec.calls {
+			if maxBatch != 0 && len(call) > maxBatch {
+				t.Fatalf("fetch called with %d keys, exceeding maxBatch %d", len(call), maxBatch)
+			}
+			seen := map[int]bool{}
+			for _, k := range call {
+				if seen[k] {
+					t.Fatalf("key %d sent to fetch twice in one batch: %v", k, call)
+				}
+				seen[k] = true
+			}
+			fetched += len(call)
+		}
+		if fetched > requested {
+			t.Fatalf("fetched %d keys for %d requested", fetched, requested)
+		}
+	})
+}
+
+// FuzzBatchErrorShapes feeds every shape of result and error slice a fetch can return through a batch
+// of a lenient loader.
+// dataLen and errLen pick the slice lengths (0 means a nil slice), errMask picks the positions that
+// carry an error and nilMask the positions whose value is nil.
+func FuzzBatchErrorShapes(f *testing.F) {
+	f.Add(uint8(3), uint8(3), uint8(3), uint64(0), uint64(0))
+	f.Add(uint8(3), uint8(0), uint8(0), uint64(0), uint64(0))
+	f.Add(uint8(3), uint8(1), uint8(1), uint64(1), uint64(0))
+	f.Add(uint8(4), uint8(4), uint8(2), uint64(2), uint64(5))
+	f.Add(uint8(5), uint8(5), uint8(5), uint64(0b10100), uint64(0b00010))
+
+	f.Fuzz(func(t *testing.T, numKeys, dataLen, errLen uint8, errMask, nilMask uint64) {
+		numKeys = numKeys%16 + 1
+		dataLen %= 20
+		errLen %= 20
+
+		errsAt := map[int]error{}
+		fetchFn := func(keys []int) ([]*string, []error) {
+			var data []*string
+			if dataLen > 0 {
+				data = make([]*string, dataLen)
+				for i := range data {
+					if nilMask&(1<<i) != 0 {
+						continue
+					}
+					v := fmt.Sprintf("v%d", i)
+					data[i] = &v
+				}
+			}
+			var errs []error
+			if errLen > 0 {
+				errs = make([]error, errLen)
+				for i := range errs {
+					if errMask&(1<<i) != 0 {
+						errs[i] = errors.New("err" + strconv.Itoa(i))
+						errsAt[i] = errs[i]
+					}
+				}
+			}
+			return data, errs
+		}
+
+		loader := NewDataLoader(fetchFn, time.Millisecond, 0)
+		keys := make([]int, numKeys)
+		for i := range keys {
+			keys[i] = i
+		}
+		values, errs := loader.LoadAll(keys)
+
+		// more values than keys cannot be attributed
+		if int(dataLen) > len(keys) {
+			for i := range keys {
+				if values[i] != nil || !errors.Is(errs[i], ErrContractViolation) {
+					t.Fatalf("key %d: expected a contract violation, got %v %v", i, values[i], errs[i])
+				}
+			}
+			return
+		}
+
+		for i := range keys {
+			wantNil := i >= int(dataLen) || nilMask&(1<<i) != 0
+			if (values[i] == nil) != wantNil || (!wantNil && *values[i] != fmt.Sprintf("v%d", i)) {
+				t.Fatalf("key %d: unexpected value %v", i, values[i])
+			}
+
+			if len(errsAt) == 0 {
+				if errs[i] != nil {
+					t.Fatalf("key %d: unexpected error %v", i, errs[i])
+				}
+				continue
+			}
+			for _, err := range errsAt {
+				if !errors.Is(errs[i], err) {
+					t.Fatalf("key %d: error %v does not include %v", i, errs[i], err)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package dataloaden
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNotReady is returned by loads that gave up waiting for the ready gate of a loader, see WithReadyGate
+var ErrNotReady = errors.New("dataloaden: loader not ready")
+
+// readyGate holds loads back until the cache is warm
+type readyGate struct {
+	ready    <-chan struct{}
+	timeout  time.Duration
+	failOpen bool
+
+	// set once ready was seen closed, loads skip the gate from then on
+	open atomic.Bool
+}
+
+// isOpen reports whether loads may proceed without waiting
+func (g *readyGate) isOpen() bool {
+	if g == nil || g.open.Load() {
+		return true
+	}
+	select {
+	case <-g.ready:
+		g.open.Store(true)
+		return true
+	default:
+		return false
+	}
+}
+
+// passGate waits for the ready gate, bounded by ctx, the gate timeout and the budget of the load that ends
+// at deadline. It returns the error the load fails with instead of proceeding, nil to proceed.
+func (l *Loader[K, V]) passGate(ctx context.Context, deadline time.Time) error {
+	g := l.gate
+	if g.isOpen() {
+		return nil
+	}
+
+	wait, budget := g.timeout, false
+	if !deadline.IsZero() {
+		if remaining := deadline.Sub(l.clock.Now()); wait <= 0 || remaining < wait {
+			wait, budget = remaining, true
+		}
+	}
+	if budget && wait <= 0 {
+		return l.budgetExceeded().Err
+	}
+	var expired chan struct{}
+	if wait > 0 {
+		expired = make(chan struct{})
+		t := l.clock.AfterFunc(wait, func() { close(expired) })
+		defer t.Stop()
+	}
+
+	select {
+	case <-g.ready:
+		g.open.Store(true)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-expired:
+		switch {
+		case budget:
+			return l.budgetExceeded().Err
+		case g.failOpen:
+			return nil
+		default:
+			return l.namedError(ErrNotReady)
+		}
+	}
+}
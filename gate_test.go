@@ -0,0 +1,106 @@
+package dataloaden
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadyGate(t *testing.T) {
+	t.Run("early loads wait for the warmup", func(t *testing.T) {
+		ready := make(chan struct{})
+		rec := &recordingFetch{}
+		loader := NewDataLoader(rec.fetch, time.Millisecond, 0, WithReadyGate[int, string](ready, 0, false))
+
+		var wg sync.WaitGroup
+		values := make([]string, 10)
+		for i := range values {
+			wg.Go(func() {
+				v, err := loader.Load(i % 5)
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+				values[i] = *v
+			})
+		}
+		loader.Prefetch(4)
+
+		time.Sleep(10 * time.Millisecond)
+		if n := rec.callCount(); n != 0 {
+			t.Fatalf("expected no fetch before the gate opens, got %d", n)
+		}
+		for key := range 4 {
+			v := "p" + string(rune('0'+key))
+			loader.Prime(key, &v)
+		}
+		close(ready)
+		wg.Wait()
+
+		if !reflect.DeepEqual(rec.calls, [][]int{{4}}) {
+			t.Errorf("expected only the cold key to be fetched, got %v", rec.calls)
+		}
+		for i, v := range values {
+			if k, ok := keyOfValue(v); !ok || k != i%5 {
+				t.Errorf("expected the value of key %d, got %q", i%5, v)
+			}
+		}
+	})
+
+	for _, failOpen := range []bool{true, false} {
+		name := "timeout fails closed"
+		if failOpen {
+			name = "timeout fails open"
+		}
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			rec := &recordingFetch{}
+			loader := NewDataLoader(rec.fetch, 0, 1,
+				WithClock[int, string](clock),
+				WithReadyGate[int, string](make(chan struct{}), 5*time.Second, failOpen),
+			)
+
+			done := make(chan error)
+			go func() {
+				_, err := loader.Load(1)
+				done <- err
+			}()
+			for clock.live() != 1 {
+				time.Sleep(time.Millisecond)
+			}
+			clock.Advance(5 * time.Second)
+
+			err := <-done
+			switch {
+			case failOpen && (err != nil || rec.callCount() != 1):
+				t.Errorf("expected the load to proceed, got %v and %d fetches", err, rec.callCount())
+			case !failOpen && (!errors.Is(err, ErrNotReady) || rec.callCount() != 0):
+				t.Errorf("expected ErrNotReady without a fetch, got %v and %d fetches", err, rec.callCount())
+			}
+		})
+	}
+
+	t.Run("bounded by the context and budget", func(t *testing.T) {
+		ready := make(chan struct{})
+		rec := &recordingFetch{}
+		loader := NewDataLoader(rec.fetch, 0, 1, WithReadyGate[int, string](ready, 0, false),
+			WithLoadBudget[int, string](10*time.Millisecond))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := loader.LoadCtx(ctx, 1); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the context error, got %v", err)
+		}
+		if _, errs := loader.LoadAll([]int{1, 2}); !errors.Is(errs[0], ErrBudgetExceeded) || !errors.Is(errs[1], ErrBudgetExceeded) {
+			t.Errorf("expected ErrBudgetExceeded, got %v", errs)
+		}
+
+		close(ready)
+		if _, err := loader.Load(1); err != nil || rec.callCount() != 1 {
+			t.Errorf("expected the load to proceed once ready, got %v and %d fetches", err, rec.callCount())
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package dataloaden
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrDuplicateName is returned when a loader is registered under a name that is already taken
+var ErrDuplicateName = errors.New("dataloaden: duplicate loader name")
+
+// GroupMember is the part of a Loader that a Group operates on, every Loader implements it
+type GroupMember interface {
+	Flush()
+	ClearAll()
+	Close(ctx context.Context) error
+	Stats() LoaderStats
+}
+
+// Group owns the loaders of a scope (usually a request) and offers operations over all of them.
+// The zero value is an empty group ready to use, it is safe for concurrent use.
+type Group struct {
+	loaders map[string]GroupMember
+
+	// registration order, collective operations visit the loaders in this order
+	names []string
+
+	// the dispatch slots shared by the loaders, see SetMaxConcurrentBatches
+	slots slotLimiter
+
+	mu sync.Mutex
+}
+
+// Register adds a loader to the group under name, with a weight of 1 in the dispatch slots of the group
+func (g *Group) Register(name string, loader GroupMember) error {
+	return g.RegisterWeighted(name, loader, 1)
+}
+
+// RegisterWeighted adds a loader to the group under name. While the batches of the group are limited by
+// SetMaxConcurrentBatches, the loaders waiting for a slot get them in proportion to their weight: a loader
+// of weight 2 is handed twice as many slots as a loader of weight 1 that is waiting as well. Weights below
+// 1 count as 1. A loader registered with several groups takes its slots from the last one.
+func (g *Group) RegisterWeighted(name string, loader GroupMember, weight int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, found := g.loaders[name]; found {
+		return fmt.Errorf("%w: %q", ErrDuplicateName, name)
+	}
+	if g.loaders == nil {
+		g.loaders = map[string]GroupMember{}
+	}
+	g.loaders[name] = loader
+	g.names = append(g.names, name)
+	if user, ok := loader.(slotUser); ok {
+		user.useSlots(g.slots.join(weight))
+	}
+	return nil
+}
+
+// SetMaxConcurrentBatches limits the number of batches the loaders of the group fetch at the same time,
+// n <= 0 removes the limit. Batches that find every slot taken wait for one before calling the fetch, and
+// the time they waited is reported in LoaderStats.SlotWait. Slots are shared fairly by weight, see
+// RegisterWeighted, so a loader that dispatches a lot of batches cannot starve one that dispatches few.
+// A fetch that loads from another loader of the group holds its slot meanwhile, so a limit below the
+// depth of such nested loads deadlocks.
+func (g *Group) SetMaxConcurrentBatches(n int) {
+	g.slots.setMax(n)
+}
+
+// FlushAll dispatches the currently collected batch of every loader
+func (g *Group) FlushAll() {
+	for _, loader := range g.members() {
+		loader.Flush()
+	}
+}
+
+// ClearAll empties the cache of every loader
+func (g *Group) ClearAll() {
+	for _, loader := range g.members() {
+		loader.ClearAll()
+	}
+}
+
+// CloseAll closes every loader, waiting for their dispatched batches until ctx is done
+func (g *Group) CloseAll(ctx context.Context) error {
+	members := g.members()
+
+	errs := make([]error, len(members))
+	var wg sync.WaitGroup
+	for i, loader := range members {
+		wg.Go(func() {
+			errs[i] = loader.Close(ctx)
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// epochBumper is implemented by every Loader, see Loader.BumpEpoch
+type epochBumper interface {
+	BumpEpoch()
+}
+
+// BumpEpoch makes the values cached so far by every loader stale, see Loader.BumpEpoch. Members that
+// are not a Loader or a wrapper of one are skipped.
+func (g *Group) BumpEpoch() {
+	for _, loader := range g.members() {
+		if bumper, ok := loader.(epochBumper); ok {
+			bumper.BumpEpoch()
+		}
+	}
+}
+
+// Stats returns a snapshot of the counters of every loader by name, lazy loaders that were not constructed are left out
+func (g *Group) Stats() map[string]LoaderStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	stats := make(map[string]LoaderStats, len(g.loaders))
+	for name, member := range g.loaders {
+		if loader, ok := resolve(member); ok {
+			stats[name] = loader.Stats()
+		}
+	}
+	return stats
+}
+
+// GroupPending is the backlog of a loader of a Group
+type GroupPending struct {
+	PendingInfo
+
+	// the pending keys formatted with fmt, see Loader.PendingKeys
+	Keys      []string
+	Truncated bool
+}
+
+// pendingReporter is implemented by every Loader, independent of its key type
+type pendingReporter interface {
+	Pending() PendingInfo
+	pendingKeyStrings(max int) ([]string, bool)
+}
+
+// Pending returns the backlog of every loader by name, listing up to maxKeys pending keys per loader,
+// maxKeys <= 0 lists all of them. Members that are not a Loader and lazy loaders that were not
+// constructed are left out.
+func (g *Group) Pending(maxKeys int) map[string]GroupPending {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	pending := make(map[string]GroupPending, len(g.loaders))
+	for name, member := range g.loaders {
+		loader, _ := resolve(member)
+		reporter, ok := loader.(pendingReporter)
+		if !ok {
+			continue
+		}
+		keys, truncated := reporter.pendingKeyStrings(maxKeys)
+		pending[name] = GroupPending{PendingInfo: reporter.Pending(), Keys: keys, Truncated: truncated}
+	}
+	return pending
+}
+
+func (g *Group) members() []GroupMember {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	members := make([]GroupMember, 0, len(g.names))
+	for _, name := range g.names {
+		if loader, ok := resolve(g.loaders[name]); ok {
+			members = append(members, loader)
+		}
+	}
+	return members
+}
@@ -0,0 +1,185 @@
+package dataloaden
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newStringLoader(t *testing.T, wait time.Duration, opts ...Option[int, string]) (*Loader[int, string], *recordingFetch) {
+	t.Helper()
+	rec := &recordingFetch{}
+	return NewDataLoader(rec.fetch, wait, 0, opts...), rec
+}
+
+func TestGroupRegister(t *testing.T) {
+	var g Group
+	users, _ := newStringLoader(t, time.Millisecond)
+	orgs, _ := newStringLoader(t, time.Millisecond)
+
+	if err := g.Register("users", users); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := g.Register("orgs", orgs); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := g.Register("users", orgs); !errors.Is(err, ErrDuplicateName) {
+		t.Errorf("expected ErrDuplicateName, got %v", err)
+	}
+}
+
+func TestGroupConcurrentRegister(t *testing.T) {
+	var g Group
+	var wg sync.WaitGroup
+	errs := make([]error, 50)
+	for i := range errs {
+		wg.Go(func() {
+			loader, _ := newStringLoader(t, time.Millisecond)
+			errs[i] = g.Register("loader"+strconv.Itoa(i%25), loader)
+			g.FlushAll()
+			_ = g.Stats()
+		})
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed != 25 {
+		t.Errorf("expected 25 duplicate registrations to fail, got %d", failed)
+	}
+	if len(g.Stats()) != 25 {
+		t.Errorf("expected 25 loaders, got %d", len(g.Stats()))
+	}
+}
+
+func TestGroupFlushAll(t *testing.T) {
+	var g Group
+	users, _ := newStringLoader(t, time.Hour)
+	orgs, _ := newStringLoader(t, time.Hour)
+	_ = g.Register("users", users)
+	_ = g.Register("orgs", orgs)
+
+	userThunk := users.LoadThunk(1)
+	orgThunk := orgs.LoadThunk(2)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if v, err := userThunk(); err != nil || *v != "v1" {
+			t.Errorf("unexpected user result %v, %v", v, err)
+		}
+		if v, err := orgThunk(); err != nil || *v != "v2" {
+			t.Errorf("unexpected org result %v, %v", v, err)
+		}
+	}()
+
+	g.FlushAll()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected FlushAll to dispatch the batches")
+	}
+}
+
+func TestGroupClearAll(t *testing.T) {
+	var g Group
+	users, rec := newStringLoader(t, time.Millisecond)
+	_ = g.Register("users", users)
+
+	_, _ = users.Load(1)
+	g.ClearAll()
+	_, _ = users.Load(1)
+
+	if len(rec.calls) != 2 {
+		t.Errorf("expected the cleared key to be fetched again, got %d fetches", len(rec.calls))
+	}
+}
+
+func TestGroupCloseAll(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	slow := NewDataLoader(func(keys []int) ([]*string, []error) {
+		<-release
+		return make([]*string, len(keys)), nil
+	}, time.Hour, 0)
+	fast, _ := newStringLoader(t, time.Hour)
+	_ = g.Register("slow", slow)
+	_ = g.Register("fast", fast)
+
+	slow.LoadThunk(1)
+	fastThunk := fast.LoadThunk(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := g.CloseAll(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the slow loader to miss the deadline, got %v", err)
+	}
+	if v, err := fastThunk(); err != nil || *v != "v1" {
+		t.Errorf("expected the fast batch to complete, got %v, %v", v, err)
+	}
+
+	close(release)
+	if err := g.CloseAll(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := fast.Load(2); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	if v, err := fast.Load(1); err != nil || *v != "v1" {
+		t.Errorf("expected cached values to survive Close, got %v, %v", v, err)
+	}
+}
+
+func TestGroupStats(t *testing.T) {
+	var g Group
+	users, _ := newStringLoader(t, time.Millisecond)
+	orgs, _ := newStringLoader(t, time.Millisecond)
+	_ = g.Register("users", users)
+	_ = g.Register("orgs", orgs)
+
+	_, _ = users.LoadAll([]int{1, 2})
+	_, _ = users.Load(1)
+
+	stats := g.Stats()
+	if stats["users"].Loads != 3 || stats["users"].CacheHits != 1 {
+		t.Errorf("unexpected users stats %+v", stats["users"])
+	}
+	if !reflect.DeepEqual(stats["orgs"], LoaderStats{}) {
+		t.Errorf("unexpected orgs stats %+v", stats["orgs"])
+	}
+}
+
+func TestGroupPending(t *testing.T) {
+	var g Group
+	users, _ := newStringLoader(t, time.Hour)
+	orgs, _ := newStringLoader(t, time.Hour)
+	_ = g.Register("users", users)
+	_ = g.Register("orgs", orgs)
+
+	users.LoadThunk(1)
+	users.LoadThunk(2)
+
+	pending := g.Pending(1)
+	if p := pending["users"]; p.PendingKeys != 2 || len(p.Keys) != 1 || !p.Truncated {
+		t.Errorf("unexpected users backlog %+v", p)
+	}
+	if p, ok := pending["orgs"]; !ok || p.PendingKeys != 0 || len(p.Keys) != 0 || p.Truncated {
+		t.Errorf("unexpected orgs backlog %+v", p)
+	}
+
+	keys := g.Pending(0)["users"].Keys
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"1", "2"}) {
+		t.Errorf("expected the formatted pending keys, got %v", keys)
+	}
+	g.FlushAll()
+}
@@ -0,0 +1,63 @@
+package dataloaden
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrCacheHandleUsed is returned when a cache handle is attached a second time
+var ErrCacheHandleUsed = errors.New("dataloaden: cache handle already attached")
+
+// CacheHandle owns a cache detached from a loader until it is attached to another one, see Detach
+type CacheHandle[K comparable, V any] struct {
+	mu    sync.Mutex
+	cache *entryCache[K, V]
+}
+
+// Len returns the number of entries in the cache, 0 once it was attached
+func (h *CacheHandle[K, V]) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cache == nil {
+		return 0
+	}
+	return h.cache.len()
+}
+
+// take hands the cache over to its new owner, only the first call gets it
+func (h *CacheHandle[K, V]) take() (*entryCache[K, V], error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cache == nil {
+		return nil, ErrCacheHandleUsed
+	}
+	cache := h.cache
+	h.cache = nil
+	return cache, nil
+}
+
+// Detach takes the cache out of the loader, which continues with an empty cache with the same limits.
+// The entries move to the handle without being copied, batches pending during Detach cache their
+// results in the loader's new cache.
+func (l *Loader[K, V]) Detach() *CacheHandle[K, V] {
+	l.InvalidateBatchMemo()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	detached := l.cache
+	l.cache.replace(detached.empty())
+	l.stats.cacheBytes.Store(0)
+	return &CacheHandle[K, V]{cache: &detached}
+}
+
+// WithAttachedCache creates the loader around the cache of h, which keeps the entries it was detached
+// with, their write times and its size limit: WithMaxCacheBytes does not apply to it. A handle can only
+// be attached once, later calls return ErrCacheHandleUsed so that no two loaders ever share a cache.
+func WithAttachedCache[K comparable, V any](h *CacheHandle[K, V]) (Option[K, V], error) {
+	cache, err := h.take()
+	if err != nil {
+		return nil, err
+	}
+	return func(l *Loader[K, V]) {
+		l.attached = cache
+	}, nil
+}
@@ -0,0 +1,100 @@
+package dataloaden
+
+import (
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDetachAttach(t *testing.T) {
+	var fetched atomic.Int32
+	release := make(chan struct{})
+	fetchFn := func(keys []int) ([]*string, []error) {
+		fetched.Add(int32(len(keys)))
+		<-release
+		results := make([]*string, len(keys))
+		for i, k := range keys {
+			v := "v" + strconv.Itoa(k)
+			results[i] = &v
+		}
+		return results, nil
+	}
+
+	first := NewDataLoader(fetchFn, time.Millisecond, 0)
+	a, b := "a", "b"
+	first.Prime(1, &a)
+	first.Prime(2, &b)
+
+	// key 3 is being fetched while the cache is detached
+	thunk := first.LoadThunk(3)
+	for first.Pending().DispatchedBatches == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	h := first.Detach()
+	close(release)
+	if v, err := thunk(); err != nil || *v != "v3" {
+		t.Fatalf("expected the pending load to complete, got %v %v", v, err)
+	}
+
+	if h.Len() != 2 {
+		t.Fatalf("expected the handle to hold the 2 primed entries, got %d", h.Len())
+	}
+	if _, ok := first.Info(1); ok {
+		t.Error("expected the detached loader to continue empty")
+	}
+	if _, ok := first.Info(3); !ok {
+		t.Error("expected the pending batch to cache its result in the detached loader")
+	}
+
+	opt, err := WithAttachedCache(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := NewDataLoader(fetchFn, time.Millisecond, 0, opt)
+	fetched.Store(0)
+	for key, want := range map[int]string{1: "a", 2: "b"} {
+		if v, err := second.Load(key); err != nil || *v != want {
+			t.Errorf("key %d: expected %q from the attached cache, got %v %v", key, want, v, err)
+		}
+	}
+	if _, ok := second.Info(3); ok {
+		t.Error("expected the result cached after the detach to stay with the first loader")
+	}
+	if n := fetched.Load(); n != 0 {
+		t.Errorf("expected no fetch for attached entries, got %d keys fetched", n)
+	}
+
+	if h.Len() != 0 {
+		t.Errorf("expected the handle to be empty once attached, got %d", h.Len())
+	}
+	if _, err := WithAttachedCache(h); !errors.Is(err, ErrCacheHandleUsed) {
+		t.Errorf("expected a second attach to fail, got %v", err)
+	}
+}
+
+func TestAttachKeepsLimit(t *testing.T) {
+	fetchFn := func(keys []int) ([]*string, []error) {
+		return make([]*string, len(keys)), nil
+	}
+	first := NewDataLoader(fetchFn, time.Millisecond, 0, WithMaxCacheBytes[int, string](8, stringSize))
+	a, b := "aaaa", "bbbb"
+	first.Prime(1, &a)
+	first.Prime(2, &b)
+
+	opt, err := WithAttachedCache(first.Detach())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := NewDataLoader(fetchFn, time.Millisecond, 0, opt, WithMaxCacheBytes[int, string](100, stringSize))
+	if stats := second.Stats(); stats.CacheBytes != 8 {
+		t.Fatalf("expected the attached entries to be tracked, got %d bytes", stats.CacheBytes)
+	}
+
+	c := "cccc"
+	second.Prime(3, &c)
+	if stats := second.Stats(); stats.CacheBytes != 8 || stats.Evictions != 1 {
+		t.Fatalf("expected the attached cache to keep its limit, got %d bytes and %d evictions", stats.CacheBytes, stats.Evictions)
+	}
+}
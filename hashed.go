@@ -0,0 +1,212 @@
+package dataloaden
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+	"weak"
+)
+
+// HashedKey identifies a key of a HashedLoader, keys that are equal share the same HashedKey
+type HashedKey struct {
+	hash uint64
+
+	// the *K the key was first loaded as, which tells keys with the same hash apart
+	key any
+}
+
+// HashedLoader is a loader for keys that cannot be map keys, e.g. structs holding a slice. Keys are told
+// apart by a hash and an equality function, while the fetch still receives the keys as they were loaded.
+//
+// A distinct key is remembered while the loader holds a cache entry or a pending load of it, and forgotten
+// once the garbage collector finds it unused after that. Keys are compared with those remembered by equal
+// and handed to the fetch as they were loaded, so they must not be modified after they are passed in.
+type HashedLoader[K any, V any] struct {
+	loader *Loader[HashedKey, V]
+	keys   *keyRegistry[K]
+}
+
+// NewHashedDataLoader creates a loader whose keys are identified by hash, keys with the same hash are
+// compared with equal. Loads of equal keys are coalesced and share their cache entry like loads of the same
+// key of a DataLoader, and a hash collision never makes two keys that are not equal share anything.
+//
+// The options apply to the underlying loader, which keys its entries by HashedKey.
+func NewHashedDataLoader[K any, V any](
+	hash func(key K) uint64,
+	equal func(a, b K) bool,
+	fetchFn func(keys []K) ([]*V, []error),
+	waitDuration time.Duration,
+	maxBatch int,
+	opts ...Option[HashedKey, V],
+) *HashedLoader[K, V] {
+	keys := &keyRegistry[K]{hash: hash, equal: equal, byHash: map[uint64][]weak.Pointer[K]{}}
+	fetch := func(hashed []HashedKey) ([]*V, []error) {
+		return fetchFn(keys.resolve(hashed))
+	}
+	return &HashedLoader[K, V]{
+		loader: NewDataLoader(fetch, waitDuration, maxBatch, opts...),
+		keys:   keys,
+	}
+}
+
+// keyRegistry assigns every distinct key its HashedKey. It only holds weak pointers to the keys, which
+// the HashedKeys keep alive, and forgets the keys the garbage collector reclaimed.
+type keyRegistry[K any] struct {
+	hash  func(key K) uint64
+	equal func(a, b K) bool
+
+	mu     sync.Mutex
+	byHash map[uint64][]weak.Pointer[K]
+}
+
+// intern returns the HashedKey of key, assigning one when the key is not known
+func (r *keyRegistry[K]) intern(key K) HashedKey {
+	h := r.hash(key)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if hk, ok := r.unsafeFind(h, key); ok {
+		return hk
+	}
+	p := new(K)
+	*p = key
+	r.byHash[h] = append(r.byHash[h], weak.Make(p))
+	runtime.AddCleanup(p, r.forget, h)
+	return HashedKey{hash: h, key: p}
+}
+
+// find returns the HashedKey of key, ok is false when the key is not known
+func (r *keyRegistry[K]) find(key K) (HashedKey, bool) {
+	h := r.hash(key)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.unsafeFind(h, key)
+}
+
+func (r *keyRegistry[K]) unsafeFind(h uint64, key K) (HashedKey, bool) {
+	for _, wp := range r.byHash[h] {
+		if seen := wp.Value(); seen != nil && r.equal(*seen, key) {
+			return HashedKey{hash: h, key: seen}, true
+		}
+	}
+	return HashedKey{}, false
+}
+
+// forget drops the keys with hash h that were reclaimed
+func (r *keyRegistry[K]) forget(h uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	known := slices.DeleteFunc(r.byHash[h], func(wp weak.Pointer[K]) bool {
+		return wp.Value() == nil
+	})
+	if len(known) == 0 {
+		delete(r.byHash, h)
+	} else {
+		r.byHash[h] = known
+	}
+}
+
+// len returns how many keys are known
+func (r *keyRegistry[K]) len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, known := range r.byHash {
+		n += len(known)
+	}
+	return n
+}
+
+// resolve returns the keys the HashedKeys were assigned to
+func (r *keyRegistry[K]) resolve(hashed []HashedKey) []K {
+	keys := make([]K, len(hashed))
+	for i, hk := range hashed {
+		keys[i] = *hk.key.(*K)
+	}
+	return keys
+}
+
+// Load loads key, like DataLoader.Load
+func (h *HashedLoader[K, V]) Load(key K) (*V, error) {
+	return h.loader.Load(h.keys.intern(key))
+}
+
+// LoadCtx loads key, like Loader.LoadCtx
+func (h *HashedLoader[K, V]) LoadCtx(ctx context.Context, key K) (*V, error) {
+	return h.loader.LoadCtx(ctx, h.keys.intern(key))
+}
+
+// LoadThunk returns a thunk for key, like DataLoader.LoadThunk
+func (h *HashedLoader[K, V]) LoadThunk(key K) func() (*V, error) {
+	return h.loader.LoadThunk(h.keys.intern(key))
+}
+
+// LoadAll loads keys, like DataLoader.LoadAll
+func (h *HashedLoader[K, V]) LoadAll(keys []K) ([]*V, []error) {
+	return h.loader.LoadAll(h.internAll(keys))
+}
+
+// LoadAllThunk returns a thunk for keys, like DataLoader.LoadAllThunk
+func (h *HashedLoader[K, V]) LoadAllThunk(keys []K) func() ([]*V, []error) {
+	return h.loader.LoadAllThunk(h.internAll(keys))
+}
+
+// LoadResult loads key, like Loader.LoadResult
+func (h *HashedLoader[K, V]) LoadResult(key K) Result[*V] {
+	return h.loader.LoadResult(h.keys.intern(key))
+}
+
+func (h *HashedLoader[K, V]) internAll(keys []K) []HashedKey {
+	hashed := make([]HashedKey, len(keys))
+	for i, key := range keys {
+		hashed[i] = h.keys.intern(key)
+	}
+	return hashed
+}
+
+// Prime primes key, like DataLoader.Prime
+func (h *HashedLoader[K, V]) Prime(key K, value *V) bool {
+	return h.loader.Prime(h.keys.intern(key), value)
+}
+
+func (h *HashedLoader[K, V]) txnBind(key K, value *V) (*loaderMutex, func() func(), error) {
+	return h.loader.txnBind(h.keys.intern(key), value)
+}
+
+// Clear removes key from the cache
+func (h *HashedLoader[K, V]) Clear(key K) {
+	if hk, ok := h.keys.find(key); ok {
+		h.loader.Clear(hk)
+	}
+}
+
+// ClearAll empties the cache
+func (h *HashedLoader[K, V]) ClearAll() {
+	h.loader.ClearAll()
+}
+
+// BumpEpoch makes every value cached so far stale, see Loader.BumpEpoch
+func (h *HashedLoader[K, V]) BumpEpoch() {
+	h.loader.BumpEpoch()
+}
+
+// Flush dispatches the currently collected batch
+func (h *HashedLoader[K, V]) Flush() {
+	h.loader.Flush()
+}
+
+// Close closes the underlying loader, see Loader.Close
+func (h *HashedLoader[K, V]) Close(ctx context.Context) error {
+	return h.loader.Close(ctx)
+}
+
+// Stats returns a snapshot of the counters of the underlying loader
+func (h *HashedLoader[K, V]) Stats() LoaderStats {
+	return h.loader.Stats()
+}
+
+// useSlots makes the underlying loader take its dispatch slots from c, see Group.RegisterWeighted
+func (h *HashedLoader[K, V]) useSlots(c *slotClient) {
+	h.loader.useSlots(c)
+}
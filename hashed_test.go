@@ -0,0 +1,150 @@
+package dataloaden
+
+import (
+	"errors"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// grant is a key that cannot be a map key
+type grant struct {
+	user   string
+	scopes []string
+}
+
+func equalGrants(a, b grant) bool {
+	return a.user == b.user && slices.Equal(a.scopes, b.scopes)
+}
+
+func grantValue(g grant) string {
+	return g.user + ":" + strings.Join(g.scopes, ",")
+}
+
+type grantFetch struct {
+	mu    sync.Mutex
+	calls [][]grant
+}
+
+func (f *grantFetch) fetch(keys []grant) ([]*string, []error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, keys)
+	f.mu.Unlock()
+	results := make([]*string, len(keys))
+	for i, k := range keys {
+		v := grantValue(k)
+		results[i] = &v
+	}
+	return results, nil
+}
+
+func (f *grantFetch) fetchedKeys() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, c := range f.calls {
+		n += len(c)
+	}
+	return n
+}
+
+func TestHashedLoader(t *testing.T) {
+	hashes := map[string]func(grant) uint64{
+		"hash by user": func(g grant) uint64 {
+			var h uint64
+			for _, c := range g.user {
+				h = h*31 + uint64(c)
+			}
+			return h
+		},
+		// every key collides with every other one
+		"constant hash": func(grant) uint64 { return 7 },
+	}
+	for name, hash := range hashes {
+		t.Run(name, func(t *testing.T) {
+			f := &grantFetch{}
+			loader := NewHashedDataLoader(hash, equalGrants, f.fetch, time.Millisecond, 0)
+
+			keys := []grant{
+				{user: "ann", scopes: []string{"read"}},
+				{user: "ann", scopes: []string{"read", "write"}},
+				{user: "bob", scopes: []string{"read"}},
+				// equal to the first key, but a different slice
+				{user: "ann", scopes: []string{"read"}},
+			}
+			values, errs := loader.LoadAll(keys)
+			for i, key := range keys {
+				if errs[i] != nil || *values[i] != grantValue(key) {
+					t.Errorf("expected %q for key %d, got %v, %v", grantValue(key), i, values[i], errs[i])
+				}
+			}
+			if n := f.fetchedKeys(); n != 3 {
+				t.Errorf("expected the equal keys to be fetched once, got %d fetched keys", n)
+			}
+
+			// the fetch receives the keys as they were loaded
+			if got := f.calls[0][1]; !equalGrants(got, keys[1]) {
+				t.Errorf("expected the original key, got %+v", got)
+			}
+
+			if v, err := loader.Load(grant{user: "bob", scopes: []string{"read"}}); err != nil || *v != "bob:read" {
+				t.Errorf("expected a cache hit for an equal key, got %v, %v", v, err)
+			}
+			if f.fetchedKeys() != 3 {
+				t.Error("expected no fetch for a cached key")
+			}
+
+			loader.Clear(grant{user: "nobody"})
+			loader.Clear(keys[3])
+			if v, err := loader.Load(keys[0]); err != nil || *v != "ann:read" || f.fetchedKeys() != 4 {
+				t.Errorf("expected the cleared key to be fetched again, got %v, %v", v, err)
+			}
+			if v, err := loader.Load(keys[1]); err != nil || *v != "ann:read,write" || f.fetchedKeys() != 4 {
+				t.Errorf("expected the colliding key to stay cached, got %v, %v", v, err)
+			}
+
+			primed := "primed"
+			if !loader.Prime(grant{user: "cy"}, &primed) {
+				t.Fatal("expected the prime to succeed")
+			}
+			if v, err := loader.Load(grant{user: "cy", scopes: []string{}}); err != nil || *v != "primed" {
+				t.Errorf("expected the primed value for an equal key, got %v, %v", v, err)
+			}
+		})
+	}
+}
+
+func TestHashedLoaderForgetsKeys(t *testing.T) {
+	hash := func(g grant) uint64 { return uint64(len(g.user)) }
+	f := &grantFetch{}
+	loader := NewHashedDataLoader(hash, equalGrants, f.fetch, 0, 0, WithMaxCacheSize[HashedKey, string](2))
+
+	keys := make([]grant, 50)
+	for i := range keys {
+		keys[i] = grant{user: strings.Repeat("u", i%5+1), scopes: []string{strconv.Itoa(i)}}
+	}
+	if _, errs := loader.LoadAll(keys); errors.Join(errs...) != nil {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	loader.Clear(keys[49])
+
+	// the keys evicted or cleared are forgotten once they are reclaimed, the cached one is kept
+	deadline := time.Now().Add(5 * time.Second)
+	for loader.keys.len() > 1 && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	if n := loader.keys.len(); n != 1 {
+		t.Fatalf("expected only the cached key to be remembered, got %d", n)
+	}
+	if v, err := loader.Load(keys[48]); err != nil || *v != grantValue(keys[48]) || f.fetchedKeys() != 50 {
+		t.Errorf("expected the cached key to be a hit, got %v, %v", v, err)
+	}
+	if v, err := loader.Load(keys[0]); err != nil || *v != grantValue(keys[0]) || f.fetchedKeys() != 51 {
+		t.Errorf("expected a forgotten key to be fetched again, got %v, %v", v, err)
+	}
+}
@@ -0,0 +1,132 @@
+package dataloaden
+
+import "slices"
+
+// KeyCount is how often a key was requested, as estimated by the hot key tracker
+type KeyCount[K comparable] struct {
+	Key K
+
+	// the estimated number of requests, never below the real number
+	Count uint64
+
+	// how much Count may overestimate the real number, which lies within [Count-Error, Count]
+	Error uint64
+}
+
+// hotKeyTracker counts requested keys with the space-saving algorithm: it keeps at most capacity counters
+// and a key without one takes over the counter of the least requested tracked key, inheriting its count
+// as error. With N requests recorded every key requested more than N/capacity times is tracked and no
+// count overestimates by more than N/capacity. While there are no more distinct keys than counters, all
+// counts are exact.
+type hotKeyTracker[K comparable] struct {
+	capacity int
+	index    map[K]int
+	counts   []KeyCount[K] // min heap by count
+}
+
+func newHotKeyTracker[K comparable](capacity int) *hotKeyTracker[K] {
+	return &hotKeyTracker[K]{capacity: capacity, index: map[K]int{}}
+}
+
+func (t *hotKeyTracker[K]) record(key K) {
+	if i, ok := t.index[key]; ok {
+		t.counts[i].Count++
+		t.siftDown(i)
+		return
+	}
+	if len(t.counts) < t.capacity {
+		// a new key has the lowest possible count, which keeps the heap ordered
+		t.counts = append(t.counts, KeyCount[K]{Key: key, Count: 1})
+		t.siftUp(len(t.counts) - 1)
+		return
+	}
+
+	least := t.counts[0]
+	delete(t.index, least.Key)
+	t.index[key] = 0
+	t.counts[0] = KeyCount[K]{Key: key, Count: least.Count + 1, Error: least.Count}
+	t.siftDown(0)
+}
+
+// siftDown moves the count at i below the smaller counts, counts only grow so they never move up
+func (t *hotKeyTracker[K]) siftDown(i int) {
+	for {
+		least := i
+		for _, child := range [2]int{2*i + 1, 2*i + 2} {
+			if child < len(t.counts) && t.counts[child].Count < t.counts[least].Count {
+				least = child
+			}
+		}
+		if least == i {
+			t.index[t.counts[i].Key] = i
+			return
+		}
+		t.counts[i], t.counts[least] = t.counts[least], t.counts[i]
+		t.index[t.counts[i].Key] = i
+		i = least
+	}
+}
+
+// siftUp moves the count at i above the larger counts
+func (t *hotKeyTracker[K]) siftUp(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if t.counts[parent].Count <= t.counts[i].Count {
+			break
+		}
+		t.counts[i], t.counts[parent] = t.counts[parent], t.counts[i]
+		t.index[t.counts[i].Key] = i
+		i = parent
+	}
+	t.index[t.counts[i].Key] = i
+}
+
+func (t *hotKeyTracker[K]) top(n int) []KeyCount[K] {
+	counts := slices.Clone(t.counts)
+	slices.SortFunc(counts, func(a, b KeyCount[K]) int {
+		switch {
+		case a.Count > b.Count:
+			return -1
+		case a.Count < b.Count:
+			return 1
+		}
+		return 0
+	})
+	if n < len(counts) {
+		counts = counts[:n]
+	}
+	return counts
+}
+
+func (t *hotKeyTracker[K]) reset() {
+	clear(t.index)
+	t.counts = t.counts[:0]
+}
+
+// unsafeRecordHot counts a request for key when hot keys are tracked
+func (l *Loader[K, V]) unsafeRecordHot(key K) {
+	if l.hotKeys != nil {
+		l.hotKeys.record(key)
+	}
+}
+
+// HotKeys returns up to n of the most requested keys, most requested first, counting cache hits and misses
+// alike. It returns nil unless the loader was created WithHotKeys, see KeyCount for the accuracy of counts.
+func (l *Loader[K, V]) HotKeys(n int) []KeyCount[K] {
+	if l.hotKeys == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.hotKeys.top(n)
+}
+
+// ResetHotKeys forgets every request counted for HotKeys
+func (l *Loader[K, V]) ResetHotKeys() {
+	if l.hotKeys == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hotKeys.reset()
+}
@@ -0,0 +1,106 @@
+package dataloaden
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestHotKeyTrackerExact(t *testing.T) {
+	tracker := newHotKeyTracker[int](4)
+	for _, key := range []int{1, 2, 1, 3, 1, 2, 4} {
+		tracker.record(key)
+	}
+
+	top := tracker.top(2)
+	want := []KeyCount[int]{{Key: 1, Count: 3}, {Key: 2, Count: 2}}
+	if len(top) != len(want) || top[0] != want[0] || top[1] != want[1] {
+		t.Fatalf("expected %v, got %v", want, top)
+	}
+	if all := tracker.top(10); len(all) != 4 {
+		t.Fatalf("expected all 4 keys, got %v", all)
+	}
+
+	tracker.reset()
+	if top := tracker.top(10); len(top) != 0 {
+		t.Fatalf("expected no keys after reset, got %v", top)
+	}
+	tracker.record(5)
+	if top := tracker.top(1); len(top) != 1 || top[0] != (KeyCount[int]{Key: 5, Count: 1}) {
+		t.Fatalf("expected key 5 counted once, got %v", top)
+	}
+}
+
+func TestHotKeyTrackerBounds(t *testing.T) {
+	const capacity, requests = 16, 20000
+	tracker := newHotKeyTracker[int](capacity)
+	exact := map[int]uint64{}
+
+	// a skewed key space much larger than the tracker
+	zipf := rand.NewZipf(rand.New(rand.NewSource(1)), 1.2, 1, 500)
+	for i := 0; i < requests; i++ {
+		key := int(zipf.Uint64())
+		exact[key]++
+		tracker.record(key)
+	}
+
+	tracked := map[int]KeyCount[int]{}
+	for _, kc := range tracker.top(capacity) {
+		tracked[kc.Key] = kc
+		if kc.Count < exact[kc.Key] || kc.Count-kc.Error > exact[kc.Key] {
+			t.Errorf("key %d: real count %d outside [%d, %d]", kc.Key, exact[kc.Key], kc.Count-kc.Error, kc.Count)
+		}
+		if kc.Error > requests/capacity {
+			t.Errorf("key %d: error %d above N/capacity", kc.Key, kc.Error)
+		}
+	}
+	for key, n := range exact {
+		if _, ok := tracked[key]; n > requests/capacity && !ok {
+			t.Errorf("key %d requested %d times is not tracked", key, n)
+		}
+	}
+}
+
+func TestHotKeys(t *testing.T) {
+	fetchFn := func(keys []int) ([]*string, []error) {
+		return make([]*string, len(keys)), nil
+	}
+	loader := NewDataLoader(fetchFn, time.Millisecond, 0, WithHotKeys[int, string](8))
+
+	loader.Load(1)
+	loader.Load(1)
+	loader.LoadAll([]int{1, 2})
+
+	top := loader.HotKeys(1)
+	if len(top) != 1 || top[0] != (KeyCount[int]{Key: 1, Count: 3}) {
+		t.Fatalf("expected key 1 requested 3 times, got %v", top)
+	}
+	loader.ResetHotKeys()
+	if top := loader.HotKeys(1); len(top) != 0 {
+		t.Fatalf("expected no hot keys after reset, got %v", top)
+	}
+
+	if top := NewDataLoader(fetchFn, time.Millisecond, 0).HotKeys(1); top != nil {
+		t.Fatalf("expected no hot keys without tracking, got %v", top)
+	}
+}
+
+// BenchmarkLoadCachedHotKeys is BenchmarkLoadCached with hot key tracking, compare the two for its cost
+func BenchmarkLoadCachedHotKeys(b *testing.B) {
+	fetchFn := func(keys []int) ([]*string, []error) {
+		return make([]*string, len(keys)), nil
+	}
+	loader := NewDataLoader(fetchFn, time.Millisecond, 0, WithHotKeys[int, string](128))
+	keys := make([]int, 100)
+	for i := range keys {
+		keys[i] = i
+	}
+	loader.LoadAll(keys)
+
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		if _, err := loader.Load(i % 100); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package dataloaden
+
+import "time"
+
+// EntrySource tells which write path put an entry into the cache
+type EntrySource uint8
+
+const (
+	// SourceFetch entries hold a result of the fetch
+	SourceFetch EntrySource = iota + 1
+
+	// SourcePrime entries were written by Prime
+	SourcePrime
+
+	// SourceExtra entries were returned by a fetch alongside the keys it was asked for,
+	// see NewDataLoaderWithExtras
+	SourceExtra
+
+	// SourceReplace entries were swapped in by ReplaceCache
+	SourceReplace
+)
+
+func (s EntrySource) String() string {
+	switch s {
+	case SourceFetch:
+		return "fetch"
+	case SourcePrime:
+		return "prime"
+	case SourceExtra:
+		return "extra"
+	case SourceReplace:
+		return "replace"
+	default:
+		return "unknown"
+	}
+}
+
+// EntryInfo describes a cache entry
+type EntryInfo struct {
+	// when the entry was written, zero when recording is disabled with WithoutEntryInfo
+	StoredAt time.Time
+
+	// the write path that put the entry into the cache
+	Source EntrySource
+
+	// whether the entry outlived the cache TTL
+	Expired bool
+
+	// whether the entry holds a value for the key, see Result
+	Found bool
+}
+
+// Info returns when and how the entry for key was written, including entries that have expired
+func (l *Loader[K, V]) Info(key K) (EntryInfo, bool) {
+	key, err := l.checkKey(key)
+	if err != nil {
+		return EntryInfo{}, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	it, ok := l.cache.peek(key)
+	if !ok {
+		return EntryInfo{}, false
+	}
+
+	info := EntryInfo{
+		Source: it.source,
+		Found:  it.found,
+	}
+	if it.storedAt != 0 {
+		info.StoredAt = time.Unix(0, it.storedAt)
+	}
+	if _, fresh := l.unsafeGet(key); !fresh {
+		info.Expired = true
+	}
+	return info, true
+}
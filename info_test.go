@@ -0,0 +1,54 @@
+package dataloaden
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInfo(t *testing.T) {
+	rec := &recordingFetch{}
+	loader := NewDataLoader(rec.fetch, time.Millisecond, 10, WithCacheTTL[int, string](20*time.Millisecond))
+
+	if _, ok := loader.Info(1); ok {
+		t.Errorf("expected no info for an uncached key")
+	}
+
+	before := time.Now()
+	_, _ = loader.Load(1)
+	primed := "primed"
+	loader.Prime(2, &primed)
+
+	info, ok := loader.Info(1)
+	if !ok || info.Source != SourceFetch || !info.Found || info.Expired || info.StoredAt.Before(before) {
+		t.Errorf("unexpected info for a fetched entry %+v", info)
+	}
+	info, ok = loader.Info(2)
+	if !ok || info.Source != SourcePrime || info.Source.String() != "prime" || info.StoredAt.Before(before) {
+		t.Errorf("unexpected info for a primed entry %+v", info)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	if info, ok = loader.Info(1); !ok || !info.Expired {
+		t.Errorf("expected the entry to be reported as expired, got %+v", info)
+	}
+
+	loader.Clear(1)
+	loader.ClearAll()
+	if _, ok := loader.Info(1); ok {
+		t.Errorf("expected Clear to drop the info")
+	}
+	if _, ok := loader.Info(2); ok {
+		t.Errorf("expected ClearAll to drop the info")
+	}
+}
+
+func TestWithoutEntryInfo(t *testing.T) {
+	rec := &recordingFetch{}
+	loader := NewDataLoader(rec.fetch, time.Millisecond, 10, WithoutEntryInfo[int, string]())
+
+	_, _ = loader.Load(1)
+	info, ok := loader.Info(1)
+	if !ok || !info.StoredAt.IsZero() || info.Source != SourceFetch {
+		t.Errorf("expected the source without a time, got %+v", info)
+	}
+}
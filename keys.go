@@ -0,0 +1,71 @@
+package dataloaden
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrTransformKeys fails the batches whose keys could not be transformed
+var ErrTransformKeys = errors.New("dataloaden: transform keys broke its contract")
+
+// checkKey normalizes the key and runs it through the key filter
+func (l *Loader[K, V]) checkKey(key K) (K, error) {
+	if l.normalizeKey != nil {
+		normalized, err := l.normalizeKey(key)
+		if err != nil {
+			return key, err
+		}
+		key = normalized
+	}
+	key = l.canonicalKey(key)
+	if l.keyFilter != nil {
+		if err := l.keyFilter(key); err != nil {
+			return key, err
+		}
+	}
+	return key, nil
+}
+
+// fetchTransformed fetches the keys as rewritten by transformKeys, then routes the results back to the
+// positions of the original keys
+func (l *Loader[K, V]) fetchTransformed(ctx context.Context, keys []K) ([]*V, []error, error) {
+	// the transform gets a copy, so it cannot rewrite the keys the batch reports elsewhere
+	transformed := l.transformKeys(append([]K(nil), keys...))
+	if len(transformed) != len(keys) {
+		err := l.namedError(fmt.Errorf("%w: returned %d keys for %d", ErrTransformKeys, len(transformed), len(keys)))
+		return nil, []error{err}, err
+	}
+
+	unique := make([]K, 0, len(transformed))
+	index := make([]int, len(transformed))
+	positions := make(map[K]int, len(transformed))
+	for i, key := range transformed {
+		pos, ok := positions[key]
+		if !ok {
+			pos = len(unique)
+			positions[key] = pos
+			unique = append(unique, key)
+		}
+		index[i] = pos
+	}
+
+	data, errs, err := l.fetchChunks(ctx, unique)
+
+	routedData := make([]*V, len(keys))
+	for i, pos := range index {
+		if pos < len(data) {
+			routedData[i] = data[pos]
+		}
+	}
+
+	// errors aligned with the fetched keys are routed like the data, any other shape applies to the whole batch
+	if len(errs) != len(unique) {
+		return routedData, errs, err
+	}
+	routedErrs := make([]error, len(keys))
+	for i, pos := range index {
+		routedErrs[i] = errs[pos]
+	}
+	return routedData, routedErrs, err
+}
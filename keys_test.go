@@ -0,0 +1,132 @@
+package dataloaden
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// upperFetch returns the upper-cased key as the value and records every key slice it was called with
+type upperFetch struct {
+	mu    sync.Mutex
+	calls [][]string
+}
+
+func (u *upperFetch) fetch(keys []string) ([]*string, []error) {
+	u.mu.Lock()
+	u.calls = append(u.calls, append([]string(nil), keys...))
+	u.mu.Unlock()
+
+	results := make([]*string, len(keys))
+	for i, k := range keys {
+		v := strings.ToUpper(k)
+		results[i] = &v
+	}
+	return results, nil
+}
+
+func TestKeyFilter(t *testing.T) {
+	errEmpty := errors.New("empty key")
+	u := &upperFetch{}
+	loader := NewDataLoader(u.fetch, time.Millisecond, 10, WithKeyFilter[string, string](func(key string) error {
+		if key == "" {
+			return errEmpty
+		}
+		return nil
+	}))
+
+	values, errs := loader.LoadAll([]string{"a", "", "b"})
+	if errs[0] != nil || *values[0] != "A" || errs[2] != nil || *values[2] != "B" {
+		t.Errorf("expected valid keys to load, got %v, %v", values, errs)
+	}
+	if !errors.Is(errs[1], errEmpty) || values[1] != nil {
+		t.Errorf("expected the empty key to be rejected, got %v, %v", values[1], errs[1])
+	}
+	if !reflect.DeepEqual(u.calls, [][]string{{"a", "b"}}) {
+		t.Errorf("expected the rejected key not to be fetched, got %v", u.calls)
+	}
+
+	if _, err := loader.Load(""); !errors.Is(err, errEmpty) {
+		t.Errorf("expected the empty key to be rejected again, got %v", err)
+	}
+}
+
+func TestTransformKeys(t *testing.T) {
+	u := &upperFetch{}
+	loader := NewDataLoader(u.fetch, time.Millisecond, 10, WithTransformKeys[string, string](func(keys []string) []string {
+		for i, k := range keys {
+			keys[i] = strings.ToLower(strings.TrimSpace(k))
+		}
+		return keys
+	}))
+
+	keys := []string{" Alice", "bob", "alice ", "ALICE", "carol"}
+	values, errs := loader.LoadAll(keys)
+	for i, want := range []string{"ALICE", "BOB", "ALICE", "ALICE", "CAROL"} {
+		if errs[i] != nil || values[i] == nil || *values[i] != want {
+			t.Errorf("key %q: expected %s, got %v, %v", keys[i], want, values[i], errs[i])
+		}
+	}
+	if !reflect.DeepEqual(u.calls, [][]string{{"alice", "bob", "carol"}}) {
+		t.Errorf("expected the canonical keys to be fetched once, got %v", u.calls)
+	}
+
+	// the results are cached under the original keys
+	if v, err := loader.Load("ALICE"); err != nil || *v != "ALICE" || len(u.calls) != 1 {
+		t.Errorf("expected a cache hit for the original key, got %v, %v after %d fetches", v, err, len(u.calls))
+	}
+}
+
+func TestTransformKeysRoutesErrors(t *testing.T) {
+	errBob := errors.New("bob failed")
+	fetchFn := func(keys []string) ([]*string, []error) {
+		results := make([]*string, len(keys))
+		errs := make([]error, len(keys))
+		for i, k := range keys {
+			if k == "bob" {
+				errs[i] = errBob
+				continue
+			}
+			v := strings.ToUpper(k)
+			results[i] = &v
+		}
+		return results, errs
+	}
+
+	loader := NewDataLoader(fetchFn, time.Millisecond, 10, WithTransformKeys[string, string](func(keys []string) []string {
+		for i, k := range keys {
+			keys[i] = strings.ToLower(k)
+		}
+		return keys
+	}))
+
+	values, errs := loader.LoadAll([]string{"Alice", "BOB", "bob", "alice"})
+	for i := range errs {
+		if !errors.Is(errs[i], errBob) {
+			t.Errorf("position %d: expected the batch error, got %v", i, errs[i])
+		}
+	}
+	if *values[0] != "ALICE" || values[1] != nil || values[2] != nil || *values[3] != "ALICE" {
+		t.Errorf("expected values to be routed to the original positions, got %v", values)
+	}
+}
+
+func TestTransformKeysLengthMismatch(t *testing.T) {
+	u := &upperFetch{}
+	loader := NewDataLoader(u.fetch, time.Millisecond, 10, WithTransformKeys[string, string](func(keys []string) []string {
+		return keys[:1]
+	}))
+
+	_, errs := loader.LoadAll([]string{"a", "b"})
+	for i, err := range errs {
+		if !errors.Is(err, ErrTransformKeys) {
+			t.Errorf("position %d: expected ErrTransformKeys, got %v", i, err)
+		}
+	}
+	if len(u.calls) != 0 {
+		t.Errorf("expected no fetch, got %v", u.calls)
+	}
+}
@@ -0,0 +1,106 @@
+package dataloaden
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Lazy is a loader of a Group that is only constructed on first access, so a bundle of loaders that a
+// request mostly leaves unused costs one closure per loader. It is safe for concurrent use.
+type Lazy[L GroupMember] struct {
+	construct func() L
+	once      sync.Once
+	loader    L
+
+	// set once loader is constructed, collective operations of the group skip the loader until then
+	built atomic.Bool
+
+	// the dispatch slots of the group, handed to the loader when it is constructed
+	slots *slotClient
+}
+
+// RegisterLazy adds a loader to g under name that is constructed by construct on the first call to Get.
+// Until then FlushAll, ClearAll, CloseAll, Stats and Pending of the group skip it.
+func RegisterLazy[L GroupMember](g *Group, name string, construct func() L) (*Lazy[L], error) {
+	lazy := &Lazy[L]{construct: construct}
+	if err := g.RegisterWeighted(name, lazy, 1); err != nil {
+		return nil, err
+	}
+	return lazy, nil
+}
+
+// Get returns the loader, constructing it on the first call
+func (z *Lazy[L]) Get() L {
+	z.once.Do(func() {
+		z.loader = z.construct()
+		z.construct = nil
+		if user, ok := any(z.loader).(slotUser); ok && z.slots != nil {
+			user.useSlots(z.slots)
+		}
+		z.built.Store(true)
+	})
+	return z.loader
+}
+
+// member returns the loader if it was constructed
+func (z *Lazy[L]) member() (GroupMember, bool) {
+	if !z.built.Load() {
+		return nil, false
+	}
+	return z.loader, true
+}
+
+// constructed returns the loader, constructing it if needed
+func (z *Lazy[L]) constructed() GroupMember {
+	return z.Get()
+}
+
+// useSlots keeps the dispatch slots of the group for the loader until it is constructed
+func (z *Lazy[L]) useSlots(c *slotClient) {
+	z.slots = c
+}
+
+// Flush dispatches the collected batch of the loader if it was constructed
+func (z *Lazy[L]) Flush() {
+	if loader, ok := z.member(); ok {
+		loader.Flush()
+	}
+}
+
+// ClearAll empties the cache of the loader if it was constructed
+func (z *Lazy[L]) ClearAll() {
+	if loader, ok := z.member(); ok {
+		loader.ClearAll()
+	}
+}
+
+// Close closes the loader if it was constructed
+func (z *Lazy[L]) Close(ctx context.Context) error {
+	if loader, ok := z.member(); ok {
+		return loader.Close(ctx)
+	}
+	return nil
+}
+
+// Stats returns a snapshot of the counters of the loader, the zero value if it was not constructed
+func (z *Lazy[L]) Stats() LoaderStats {
+	if loader, ok := z.member(); ok {
+		return loader.Stats()
+	}
+	return LoaderStats{}
+}
+
+// lazyMember is implemented by Lazy, independent of its loader type
+type lazyMember interface {
+	member() (GroupMember, bool)
+	constructed() GroupMember
+}
+
+// resolve returns the loader behind a member of a group, false for a lazy loader that was not constructed
+func resolve(member GroupMember) (GroupMember, bool) {
+	if lazy, ok := member.(lazyMember); ok {
+		return lazy.member()
+	}
+	return member, true
+}
@@ -0,0 +1,108 @@
+package dataloaden
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRegisterLazy(t *testing.T) {
+	var g Group
+	var constructed atomic.Int32
+	rec := &recordingFetch{}
+	users, err := RegisterLazy(&g, "users", func() *Loader[int, string] {
+		constructed.Add(1)
+		return NewDataLoader(rec.fetch, time.Hour, 0)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, _ = RegisterLazy(&g, "orgs", func() *Loader[int, string] {
+		t.Error("expected the unused loader not to be constructed")
+		return nil
+	})
+	if _, err := RegisterLazy(&g, "users", func() *Loader[int, string] { return nil }); err == nil {
+		t.Error("expected a duplicate name to be rejected")
+	}
+
+	g.FlushAll()
+	g.ClearAll()
+	if stats := g.Stats(); len(stats) != 0 {
+		t.Errorf("expected loaders that were not constructed to be left out, got %v", stats)
+	}
+
+	var wg sync.WaitGroup
+	thunks := make([]func() (*string, error), 10)
+	for i := range thunks {
+		wg.Go(func() {
+			thunks[i] = users.Get().LoadThunk(i)
+		})
+	}
+	wg.Wait()
+	if n := constructed.Load(); n != 1 {
+		t.Fatalf("expected the loader to be constructed once, got %d", n)
+	}
+
+	g.FlushAll()
+	for i, thunk := range thunks {
+		if v, err := thunk(); err != nil || *v != "v"+strconv.Itoa(i) {
+			t.Errorf("unexpected result %v, %v", v, err)
+		}
+	}
+	if stats := g.Stats(); len(stats) != 1 || stats["users"].Loads != 10 {
+		t.Errorf("expected the stats of the constructed loader, got %v", stats)
+	}
+	if pending := g.Pending(0); len(pending) != 1 {
+		t.Errorf("expected the pending view of the constructed loader, got %v", pending)
+	}
+	if err := g.CloseAll(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := users.Get().Load(100); err == nil {
+		t.Error("expected the constructed loader to be closed")
+	}
+}
+
+// 40 loaders per request of which 6 are used
+const (
+	bundleSize = 40
+	bundleUsed = 6
+)
+
+func BenchmarkBundleEager(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		var g Group
+		loaders := make([]*Loader[int, string], bundleSize)
+		for i := range loaders {
+			rec := &recordingFetch{}
+			loaders[i] = NewDataLoader(rec.fetch, 0, 0)
+			_ = g.Register(strconv.Itoa(i), loaders[i])
+		}
+		for _, loader := range loaders[:bundleUsed] {
+			_, _ = loader.Load(1)
+		}
+		_ = g.CloseAll(context.Background())
+	}
+}
+
+func BenchmarkBundleLazy(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		var g Group
+		loaders := make([]*Lazy[*Loader[int, string]], bundleSize)
+		for i := range loaders {
+			loaders[i], _ = RegisterLazy(&g, strconv.Itoa(i), func() *Loader[int, string] {
+				rec := &recordingFetch{}
+				return NewDataLoader(rec.fetch, 0, 0)
+			})
+		}
+		for _, loader := range loaders[:bundleUsed] {
+			_, _ = loader.Get().Load(1)
+		}
+		_ = g.CloseAll(context.Background())
+	}
+}
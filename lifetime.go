@@ -0,0 +1,54 @@
+package dataloaden
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// lifetime tracks the request a loader was created for, see WithLifetime
+type lifetime struct {
+	// set once the request is done and the grace period has passed, the only thing loads read
+	expired  atomic.Bool
+	reported atomic.Bool
+
+	stack  []byte
+	onLeak func(loaderName string, stack []byte)
+}
+
+// checkLifetime reports a load on a loader that outlived its request, once
+func (l *Loader[K, V]) checkLifetime() {
+	if l.lifetime == nil || !l.lifetime.expired.Load() {
+		return
+	}
+	if l.lifetime.reported.CompareAndSwap(false, true) {
+		l.lifetime.onLeak(l.name, l.lifetime.stack)
+	}
+}
+
+// WithLifetime ties the loader to the request ctx belongs to, to catch loaders that leak into long lived
+// code: the first load more than grace after ctx is done calls onLeak with the name of the loader and the
+// stack it was created from. A nil onLeak logs the leak. Loads keep working either way.
+func WithLifetime[K comparable, V any](ctx context.Context, grace time.Duration, onLeak func(loaderName string, stack []byte)) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		if onLeak == nil {
+			onLeak = func(loaderName string, stack []byte) {
+				log.Printf("dataloaden: loader %q used after its request was done, created at:\n%s", loaderName, stack)
+			}
+		}
+		lt := &lifetime{stack: debug.Stack(), onLeak: onLeak}
+		l.lifetime = lt
+
+		context.AfterFunc(ctx, func() {
+			if grace <= 0 {
+				lt.expired.Store(true)
+				return
+			}
+			l.clock.AfterFunc(grace, func() {
+				lt.expired.Store(true)
+			})
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package dataloaden
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLifetime(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	leaks := make(chan string, 10)
+	onLeak := func(loaderName string, stack []byte) {
+		if !strings.Contains(string(stack), "TestLifetime") {
+			t.Errorf("expected the stack to show where the loader was created, got %s", stack)
+		}
+		leaks <- loaderName
+	}
+	loader, _ := newStringLoader(t, time.Millisecond, WithName[int, string]("users"),
+		WithLifetime[int, string](ctx, 200*time.Millisecond, onLeak))
+
+	_, _ = loader.Load(1)
+	cancel()
+	_, _ = loader.Load(2)
+	if len(leaks) != 0 {
+		t.Fatal("expected no leak within the grace period")
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	_, _ = loader.Load(1)
+	_, _ = loader.Load(3)
+	loader.LoadAll([]int{4, 5})
+
+	if n := len(leaks); n != 1 {
+		t.Fatalf("expected exactly one leak to be reported, got %d", n)
+	}
+	if name := <-leaks; name != "users" {
+		t.Errorf("expected the leak to name the loader, got %q", name)
+	}
+}
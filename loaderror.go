@@ -0,0 +1,49 @@
+package dataloaden
+
+import (
+	"fmt"
+	"time"
+)
+
+// LoadError is the error a load fails with when its batch failed the key and the loader was created
+// WithLoadErrors. It tells where the error came from, Unwrap returns the error of the batch.
+type LoadError struct {
+	// the error the batch failed the key with
+	Err error
+
+	// the name of the loader, see WithName, and the batch, numbered from 1 in the order the loader
+	// started them
+	Loader  string
+	BatchID uint64
+
+	// the key as formatted by WithLoadErrors
+	Key string
+
+	// why the batch was dispatched, and how long its fetch ran until it failed the key
+	Trigger  TriggerReason
+	Duration time.Duration
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("dataloaden: loader %q batch %d key %s (%v, %v): %v", e.Loader, e.BatchID, e.Key, e.Trigger, e.Duration, e.Err)
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// loadError wraps err, the error the batch failed key with, see WithLoadErrors
+func (b *genericLoaderBatch[K, V]) loadError(l *Loader[K, V], key K, err error) error {
+	duration := b.fetchTime
+	if b.ready != nil {
+		// streaming batches fail keys one by one while the fetch is still running
+		duration = l.clock.Now().Sub(b.fetchStart)
+	}
+	var formatted string
+	if l.loadErrorKey != nil {
+		formatted = l.loadErrorKey(key)
+	} else {
+		formatted = fmt.Sprint(key)
+	}
+	return &LoadError{Err: err, Loader: l.name, BatchID: b.id, Key: formatted, Trigger: b.trigger, Duration: duration}
+}
@@ -0,0 +1,106 @@
+package dataloaden
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadErrors(t *testing.T) {
+	errBoom := errors.New("boom")
+	failOdd := func(keys []int) ([]*string, []error) {
+		time.Sleep(2 * time.Millisecond)
+		data := make([]*string, len(keys))
+		errs := make([]error, len(keys))
+		for i, key := range keys {
+			if key%2 == 1 {
+				errs[i] = throttleError{err: errBoom, after: time.Second}
+				continue
+			}
+			v := "v" + strconv.Itoa(key)
+			data[i] = &v
+		}
+		return data, errs
+	}
+
+	t.Run("metadata", func(t *testing.T) {
+		loader := NewDataLoader(failOdd, time.Hour, 0,
+			WithName[int, string]("users"),
+			WithKeyErrors[int, string](),
+			WithLoadErrors[int, string](func(key int) string { return "user-" + strconv.Itoa(key) }),
+		)
+		first := loader.LoadThunk(2)
+		loader.Flush()
+		_, _ = first()
+		thunks := []func() (*string, error){loader.LoadThunk(3), loader.LoadThunk(4)}
+		loader.Flush()
+
+		_, err := thunks[0]()
+		var le *LoadError
+		if !errors.As(err, &le) {
+			t.Fatalf("expected a LoadError, got %v", err)
+		}
+		if le.Loader != "users" || le.BatchID != 2 || le.Key != "user-3" || le.Trigger != ManualFlush {
+			t.Errorf("unexpected metadata %+v", le)
+		}
+		if le.Duration < 2*time.Millisecond {
+			t.Errorf("expected the duration of the fetch, got %v", le.Duration)
+		}
+		if !strings.Contains(err.Error(), `loader "users" batch 2 key user-3`) || !strings.HasSuffix(err.Error(), "throttled: boom") {
+			t.Errorf("unexpected message %q", err.Error())
+		}
+		if v, err := thunks[1](); err != nil || *v != "v4" {
+			t.Errorf("expected the other key to load, got %v, %v", v, err)
+		}
+	})
+
+	t.Run("transparent to Is and As", func(t *testing.T) {
+		loader := NewDataLoader(failOdd, 0, 1, WithLoadErrors[int, string](nil))
+		_, err := loader.Load(1)
+		if !errors.Is(err, errBoom) {
+			t.Errorf("expected errors.Is to find the cause, got %v", err)
+		}
+		var throttled RetryAfterError
+		if !errors.As(err, &throttled) || throttled.RetryAfter() != time.Second {
+			t.Errorf("expected errors.As to find the throttle error, got %v", err)
+		}
+		var le *LoadError
+		if !errors.As(err, &le) || le.Key != "1" || le.Trigger != MaxBatchReached {
+			t.Errorf("expected the key formatted with fmt, got %+v", le)
+		}
+	})
+
+	t.Run("batch errors are combined once", func(t *testing.T) {
+		loader := NewDataLoader(func(keys []int) ([]*string, []error) {
+			return nil, []error{errBoom}
+		}, 0, 0, WithLoadErrors[int, string](nil))
+		_, err := loader.LoadAllOrError([]int{1, 2, 3})
+		var le *LoadError
+		if !errors.As(err, &le) || strings.Count(err.Error(), "boom") != 1 {
+			t.Errorf("expected the batch error once, got %v", err)
+		}
+	})
+
+	t.Run("errors outside of batches", func(t *testing.T) {
+		errInvalid := errors.New("invalid")
+		loader := NewDataLoader(failOdd, 0, 0,
+			WithLoadErrors[int, string](nil),
+			WithKeyFilter[int, string](func(key int) error {
+				if key < 0 {
+					return errInvalid
+				}
+				return nil
+			}),
+		)
+		_, err := loader.Load(-1)
+		if err != errInvalid && !errors.Is(err, errInvalid) {
+			t.Fatalf("expected the key error, got %v", err)
+		}
+		var le *LoadError
+		if errors.As(err, &le) {
+			t.Errorf("expected key errors not to be wrapped, got %v", err)
+		}
+	})
+}
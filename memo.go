@@ -0,0 +1,88 @@
+package dataloaden
+
+import (
+	"hash/maphash"
+	"slices"
+)
+
+// loadAllMemo remembers the results LoadAll assembled for a key list, see WithLoadAllMemo
+type loadAllMemo[K comparable, V any] struct {
+	max     int
+	seed    maphash.Seed
+	entries map[uint64]memoEntry[K, V]
+}
+
+// memoEntry is valid as long as the cache is still at generation gen
+type memoEntry[K comparable, V any] struct {
+	keys       []K
+	normalized []K
+	values     []*V
+	gen        uint64
+}
+
+func (m *loadAllMemo[K, V]) hash(keys []K) uint64 {
+	var h maphash.Hash
+	h.SetSeed(m.seed)
+	for _, key := range keys {
+		maphash.WriteComparable(&h, key)
+	}
+	return h.Sum64()
+}
+
+// memoGet returns the remembered results for keys when the cache has not changed since they were assembled
+func (l *Loader[K, V]) memoGet(keys []K) ([]*V, bool) {
+	if l.cacheTTL > 0 {
+		return nil, false
+	}
+	hash := l.memo.hash(keys)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	it, ok := l.memo.entries[hash]
+	if !ok || it.gen != l.cache.gen || !slices.Equal(it.keys, keys) {
+		return nil, false
+	}
+	l.count(&l.stats.loads, uint64(len(keys)))
+	l.count(&l.stats.cacheHits, uint64(len(keys)))
+	for _, key := range it.normalized {
+		l.unsafeRecordHot(key)
+		l.unsafeCountBucket(key, true, false)
+	}
+	return slices.Clone(it.values), true
+}
+
+// memoPut remembers the results of a LoadAll. Results are only remembered when every key succeeded and is
+// still cached with the returned value, which makes them exactly what LoadAll would assemble right now.
+func (l *Loader[K, V]) memoPut(keys []K, values []*V, errs []error) {
+	if l.cacheTTL > 0 {
+		return
+	}
+	normalized := make([]K, len(keys))
+	for i, key := range keys {
+		if errs[i] != nil {
+			return
+		}
+		var err error
+		if normalized[i], err = l.checkKey(key); err != nil {
+			return
+		}
+	}
+	hash := l.memo.hash(keys)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, key := range normalized {
+		if it, ok := l.cache.peek(key); !ok || it.value != values[i] {
+			return
+		}
+	}
+	if l.memo.entries == nil || len(l.memo.entries) >= l.memo.max {
+		l.memo.entries = map[uint64]memoEntry[K, V]{}
+	}
+	l.memo.entries[hash] = memoEntry[K, V]{
+		keys:       slices.Clone(keys),
+		normalized: normalized,
+		values:     slices.Clone(values),
+		gen:        l.cache.gen,
+	}
+}
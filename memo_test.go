@@ -0,0 +1,149 @@
+package dataloaden
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// keyVersionFetch returns "<key>@<n>" where n counts the fetches of that key, so results do not depend on batching
+type keyVersionFetch struct {
+	mu       sync.Mutex
+	versions map[int]int
+	calls    int
+}
+
+func (f *keyVersionFetch) fetch(keys []int) ([]*string, []error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.versions == nil {
+		f.versions = map[int]int{}
+	}
+	f.calls++
+	results := make([]*string, len(keys))
+	for i, k := range keys {
+		f.versions[k]++
+		v := strconv.Itoa(k) + "@" + strconv.Itoa(f.versions[k])
+		results[i] = &v
+	}
+	return results, nil
+}
+
+func derefAll(values []*string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		if v != nil {
+			out[i] = *v
+		}
+	}
+	return out
+}
+
+func TestLoadAllMemo(t *testing.T) {
+	f := &keyVersionFetch{}
+	loader := NewDataLoader(f.fetch, time.Millisecond, 0, WithLoadAllMemo[int, string](4))
+	keys := []int{3, 1, 2, 1}
+
+	first, _ := loader.LoadAll(keys)
+	if len(loader.memo.entries) != 1 {
+		t.Fatalf("expected the list to be remembered, got %d entries", len(loader.memo.entries))
+	}
+	second, errs := loader.LoadAll(keys)
+	if !reflect.DeepEqual(first, second) || errs[0] != nil {
+		t.Fatalf("expected the remembered results, got %v, %v", derefAll(second), errs)
+	}
+	second[0] = nil
+	if third, _ := loader.LoadAll(keys); third[0] == nil {
+		t.Error("expected the remembered results to be copied")
+	}
+	if stats := loader.Stats(); stats.Loads != 12 || stats.CacheHits != 8 {
+		t.Errorf("expected memoized loads to count as cache hits, got %+v", stats)
+	}
+
+	// the same keys in another order are another list
+	if values, _ := loader.LoadAll([]int{1, 2, 3}); !reflect.DeepEqual(derefAll(values), []string{"1@1", "2@1", "3@1"}) {
+		t.Errorf("unexpected results %v", derefAll(values))
+	}
+
+	loader.Clear(2)
+	if values, _ := loader.LoadAll(keys); !reflect.DeepEqual(derefAll(values), []string{"3@1", "1@1", "2@2", "1@1"}) {
+		t.Errorf("expected the cleared key to be fetched again, got %v", derefAll(values))
+	}
+}
+
+// TestLoadAllMemoInterleaved runs the same random sequence of operations against a memoizing and a plain
+// loader, their LoadAll results must never differ
+func TestLoadAllMemoInterleaved(t *testing.T) {
+	lists := [][]int{{1, 2, 3}, {3, 2, 1}, {1, 1, 4}, {5}, {2, 4, 6, 8}}
+
+	for seed := range uint64(20) {
+		rng := rand.New(rand.NewPCG(seed, seed))
+		memoFetch, plainFetch := &keyVersionFetch{}, &keyVersionFetch{}
+		memo := NewDataLoader(memoFetch.fetch, time.Millisecond, 0, WithLoadAllMemo[int, string](2))
+		plain := NewDataLoader(plainFetch.fetch, time.Millisecond, 0)
+
+		for op := range 60 {
+			key := rng.IntN(9)
+			switch rng.IntN(6) {
+			case 0:
+				memo.Clear(key)
+				plain.Clear(key)
+			case 1:
+				v := "p" + strconv.Itoa(op)
+				memo.Prime(key, &v)
+				plain.Prime(key, &v)
+			case 2:
+				if rng.IntN(4) == 0 {
+					memo.ClearAll()
+					plain.ClearAll()
+				}
+			default:
+				list := lists[rng.IntN(len(lists))]
+				got, _ := memo.LoadAll(list)
+				want, _ := plain.LoadAll(list)
+				if !reflect.DeepEqual(derefAll(got), derefAll(want)) {
+					t.Fatalf("seed %d op %d: LoadAll(%v) returned %v, expected %v", seed, op, list, derefAll(got), derefAll(want))
+				}
+			}
+		}
+	}
+}
+
+func TestLoadAllMemoConcurrentClears(t *testing.T) {
+	f := &keyVersionFetch{}
+	loader := NewDataLoader(f.fetch, time.Millisecond, 0, WithLoadAllMemo[int, string](4))
+	keys := []int{1, 2, 3}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Go(func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				loader.Clear(2)
+			}
+		}
+	})
+	for range 200 {
+		values, errs := loader.LoadAll(keys)
+		for i, v := range values {
+			if errs[i] != nil || v == nil || (*v)[0] != byte('0'+keys[i]) {
+				t.Fatalf("unexpected result %v, %v", derefAll(values), errs)
+			}
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	// once the clears stop, the list is remembered again and reflects the latest fetch of key 2
+	first, _ := loader.LoadAll(keys)
+	second, _ := loader.LoadAll(keys)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("expected identical results, got %v and %v", derefAll(first), derefAll(second))
+	}
+}
@@ -0,0 +1,74 @@
+package dataloaden
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidKey is wrapped by the errors of keys rejected by a StringKeyNormalizer
+var ErrInvalidKey = errors.New("dataloaden: invalid key")
+
+// StringKeyOption configures a StringKeyNormalizer
+type StringKeyOption func(n *stringKeyNormalizer)
+
+type stringKeyNormalizer struct {
+	trimSpace   bool
+	caseFold    bool
+	rejectEmpty bool
+	maxLen      int
+}
+
+// TrimSpace removes leading and trailing white space from keys
+func TrimSpace() StringKeyOption {
+	return func(n *stringKeyNormalizer) {
+		n.trimSpace = true
+	}
+}
+
+// CaseFold lower-cases keys so keys differing only in case share one cache entry
+func CaseFold() StringKeyOption {
+	return func(n *stringKeyNormalizer) {
+		n.caseFold = true
+	}
+}
+
+// RejectEmpty rejects keys that are empty, after trimming when combined with TrimSpace
+func RejectEmpty() StringKeyOption {
+	return func(n *stringKeyNormalizer) {
+		n.rejectEmpty = true
+	}
+}
+
+// MaxLen rejects keys longer than maxLen bytes, after trimming when combined with TrimSpace
+func MaxLen(maxLen int) StringKeyOption {
+	return func(n *stringKeyNormalizer) {
+		n.maxLen = maxLen
+	}
+}
+
+// StringKeyNormalizer returns a key normalization for WithNormalizeKey that applies the given options,
+// trimming first and validating last. Rejected keys fail with an error wrapping ErrInvalidKey.
+func StringKeyNormalizer(opts ...StringKeyOption) func(key string) (string, error) {
+	var n stringKeyNormalizer
+	for _, opt := range opts {
+		opt(&n)
+	}
+	return n.normalize
+}
+
+func (n *stringKeyNormalizer) normalize(key string) (string, error) {
+	if n.trimSpace {
+		key = strings.TrimSpace(key)
+	}
+	if n.caseFold {
+		key = strings.ToLower(key)
+	}
+	if n.rejectEmpty && key == "" {
+		return key, fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
+	if n.maxLen > 0 && len(key) > n.maxLen {
+		return key, fmt.Errorf("%w: key is %d bytes long, the limit is %d", ErrInvalidKey, len(key), n.maxLen)
+	}
+	return key, nil
+}
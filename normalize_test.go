@@ -0,0 +1,92 @@
+package dataloaden
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStringKeyNormalizer(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []StringKeyOption
+		key     string
+		want    string
+		invalid bool
+	}{
+		{name: "no options", key: " Key ", want: " Key "},
+		{name: "trim", opts: []StringKeyOption{TrimSpace()}, key: " Key\t", want: "Key"},
+		{name: "fold", opts: []StringKeyOption{CaseFold()}, key: " KeY ", want: " key "},
+		{name: "trim and fold", opts: []StringKeyOption{TrimSpace(), CaseFold()}, key: " KeY ", want: "key"},
+		{name: "reject empty accepts", opts: []StringKeyOption{RejectEmpty()}, key: "k", want: "k"},
+		{name: "reject empty rejects", opts: []StringKeyOption{RejectEmpty()}, key: "", invalid: true},
+		{name: "reject empty keeps blank", opts: []StringKeyOption{RejectEmpty()}, key: "  ", want: "  "},
+		{name: "trim and reject empty", opts: []StringKeyOption{TrimSpace(), RejectEmpty()}, key: "  ", invalid: true},
+		{name: "max len accepts", opts: []StringKeyOption{MaxLen(3)}, key: "abc", want: "abc"},
+		{name: "max len rejects", opts: []StringKeyOption{MaxLen(3)}, key: "abcd", invalid: true},
+		{name: "trim then max len", opts: []StringKeyOption{TrimSpace(), MaxLen(3)}, key: " abc ", want: "abc"},
+		{name: "fold then max len", opts: []StringKeyOption{CaseFold(), MaxLen(3)}, key: "ABCD", invalid: true},
+		{
+			name: "all options",
+			opts: []StringKeyOption{TrimSpace(), CaseFold(), RejectEmpty(), MaxLen(5)},
+			key:  "  HeLLo ",
+			want: "hello",
+		},
+		{
+			name:    "all options rejects empty",
+			opts:    []StringKeyOption{TrimSpace(), CaseFold(), RejectEmpty(), MaxLen(5)},
+			key:     " ",
+			invalid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := StringKeyNormalizer(tt.opts...)(tt.key)
+			if tt.invalid {
+				if !errors.Is(err, ErrInvalidKey) {
+					t.Errorf("expected ErrInvalidKey, got %q, %v", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("expected %q, got %q, %v", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestNormalizeKey(t *testing.T) {
+	u := &upperFetch{}
+	loader := NewDataLoader(u.fetch, time.Millisecond, 10,
+		WithNormalizeKey[string, string](StringKeyNormalizer(TrimSpace(), CaseFold(), RejectEmpty())),
+	)
+
+	values, errs := loader.LoadAll([]string{" Alice", "ALICE ", "", "bob"})
+	if *values[0] != "ALICE" || *values[1] != "ALICE" || *values[3] != "BOB" {
+		t.Errorf("unexpected values %v", values)
+	}
+	if !errors.Is(errs[2], ErrInvalidKey) || errs[0] != nil || errs[1] != nil || errs[3] != nil {
+		t.Errorf("expected only the empty key to be rejected, got %v", errs)
+	}
+	if !reflect.DeepEqual(u.calls, [][]string{{"alice", "bob"}}) {
+		t.Errorf("expected the normalized keys to be fetched once, got %v", u.calls)
+	}
+
+	primed := "primed"
+	if !loader.Prime(" Carol ", &primed) {
+		t.Errorf("expected Prime to succeed")
+	}
+	if v, err := loader.Load("carol"); err != nil || *v != "primed" {
+		t.Errorf("expected the primed value under the normalized key, got %v, %v", v, err)
+	}
+	loader.Clear("CAROL")
+	var dst string
+	if loader.PeekInto("carol", &dst) {
+		t.Errorf("expected Clear to use the normalized key")
+	}
+	if loader.Prime("  ", &primed) {
+		t.Errorf("expected Prime to reject an invalid key")
+	}
+}
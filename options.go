@@ -0,0 +1,466 @@
+package dataloaden
+
+import (
+	"hash/maphash"
+	"time"
+)
+
+// Option configures optional behavior of a data loader created with NewDataLoader
+type Option[K comparable, V any] func(l *Loader[K, V])
+
+// WithName names the loader, the name is included in the errors the loader synthesizes,
+// in watchdog reports and in stats snapshots
+func WithName[K comparable, V any](name string) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.name = name
+	}
+}
+
+// WithCacheTTL makes cached entries expire ttl after they were written, loads of an expired key fetch
+// it again. Expired entries are removed once a load finds them, unless they may still be served stale by
+// WithServeStaleOnError or LoadStale.
+func WithCacheTTL[K comparable, V any](ttl time.Duration) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.cacheTTL = ttl
+	}
+}
+
+// WithServeStaleOnError answers the loads of a key whose fetch failed with the entry still cached for it,
+// typically one that expired under WithCacheTTL, instead of the error. Keys without a cached entry get the
+// error, and so do keys whose error the classifier deems NotFound: the value is gone rather than
+// unavailable. With wrap the stale value comes with a *StaleDataError, otherwise with a nil error.
+func WithServeStaleOnError[K comparable, V any](wrap bool) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.serveStale = true
+		l.wrapStale = wrap
+	}
+}
+
+// WithMaxCacheBytes bounds the cache to roughly maxBytes, as estimated by sizeOf for every entry. When a
+// write would exceed the limit the least recently used entries are evicted, entries larger than the whole
+// limit are not cached at all. sizeOf is called with a nil value for keys that were not found.
+func WithMaxCacheBytes[K comparable, V any](maxBytes int, sizeOf func(key K, value *V) int) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.cache.maxBytes = maxBytes
+		l.cache.sizeOf = sizeOf
+	}
+}
+
+// WithMaxCacheSize bounds the cache to maxEntries entries, 0 = unbounded. When a write would exceed the
+// limit the least recently used entries are evicted. Loads that hit the cache and primes of a key, whether
+// or not they overwrite it, count as a use. It combines with WithMaxCacheBytes, whichever limit is
+// exceeded evicts.
+func WithMaxCacheSize[K comparable, V any](maxEntries int) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.cache.maxEntries = maxEntries
+	}
+}
+
+// WithOnEvict calls onEvict before an entry is evicted to keep the cache within the limit of
+// WithMaxCacheBytes or WithMaxCacheSize. Returning true vetoes the eviction: the entry is kept as if it
+// was just used and the next least recently used entry is considered instead. An entry is kept at most
+// maxVetoes times, after that it is evicted without asking, so a callback that always vetoes cannot keep
+// writes from fitting. Writing an entry again resets its count. Entries that expire under WithCacheTTL
+// or are removed by Clear are not passed to onEvict. It runs while the loader's lock is held and must
+// not call the loader.
+func WithOnEvict[K comparable, V any](onEvict func(key K, value *V) (veto bool), maxVetoes int) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.cache.onEvict = onEvict
+		l.cache.maxVetoes = maxVetoes
+	}
+}
+
+// WithCompactCache stores the cache in an open addressing table over keys hashed by hash, sized for
+// capacityHint entries, instead of a map. It takes less memory than a map for very large caches, see
+// BenchmarkCacheMemory, at the cost of slower lookups when hash collides often. Keys are still
+// compared in full, so a colliding hash never returns the entry of another key.
+func WithCompactCache[K comparable, V any](hash func(K) uint64, capacityHint int) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.cache.newStore = func() entryStore[K, V] {
+			return newCompactStore[K, V](hash, capacityHint)
+		}
+		l.compactStore = true
+	}
+}
+
+// WithKeyPrefixCompaction stores the string keys of the cache in two parts: the prefix up to and including
+// the last sep, interned once for all keys that share it, and the rest of the key. Every entry costs 8 bytes
+// more than in a map of full keys, so it only pays off once the shared prefixes are longer than about 32
+// bytes, like "projects/acme-production/locations/europe-west1/datasets/0000042/tables/17" with sep "/",
+// see BenchmarkPrefixCacheMemory. Keys compare and iterate as they were given, only how they are stored
+// changes. It cannot be combined with WithCompactCache, an empty sep stores keys whole. A cache
+// bounded by WithMaxCacheBytes or WithMaxCacheSize still tracks its full keys for eviction.
+func WithKeyPrefixCompaction[V any](sep string) Option[string, V] {
+	return func(l *Loader[string, V]) {
+		l.cache.newStore = func() entryStore[string, V] {
+			return newPrefixStore[V](sep)
+		}
+		l.prefixStore = true
+	}
+}
+
+// WithLoadAllMemo makes LoadAll remember the results it assembled for up to maxLists key lists and return
+// them again for an identical list, in the same order, as long as nothing was written to or removed from
+// the cache since. Any Prime, Clear, ClearAll or fetch invalidates every remembered list. Loaders with a
+// cache TTL do not memoize, entries expire without a write.
+func WithLoadAllMemo[K comparable, V any](maxLists int) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.memo = &loadAllMemo[K, V]{max: maxLists, seed: maphash.MakeSeed()}
+	}
+}
+
+// WithBatchMemo answers fetches from memo when it remembers the results of a fetch of the same set of keys,
+// in any order, and remembers the results of the fetches it did not answer. Results whose error is Transient
+// are not remembered. Streaming loaders do not use the memo.
+func WithBatchMemo[K comparable, V any](memo *BatchMemo[K, V]) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.batchMemo = memo
+	}
+}
+
+// WithoutEntryInfo stops recording when cache entries were written, which saves reading the clock on
+// every write. Info then reports zero times unless the cache has a TTL, which needs them.
+func WithoutEntryInfo[K comparable, V any]() Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.noEntryInfo = true
+	}
+}
+
+// WithNormalizeKey rewrites every key passed to the loader to its canonical form before it is looked up,
+// cached or fetched, see StringKeyNormalizer for common string normalizations. Keys for which normalize
+// returns an error are rejected like keys failing the key filter.
+func WithNormalizeKey[K comparable, V any](normalize func(key K) (K, error)) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.normalizeKey = normalize
+	}
+}
+
+// WithKeyFilter rejects keys before they enter a batch, loads of a key for which filter returns an error
+// fail with that error right away. Rejected keys are never fetched or cached.
+func WithKeyFilter[K comparable, V any](filter func(key K) error) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.keyFilter = filter
+	}
+}
+
+// WithTransformKeys rewrites the keys of every batch right before the fetch, for canonicalization that
+// needs to see the whole batch. transform must return a slice of the same length where the key at each
+// position replaces the key at the same position of its argument. Keys that become equal are fetched
+// once and the results are routed back to the waiters of the original keys, which is also what the
+// results are cached under.
+func WithTransformKeys[K comparable, V any](transform func(keys []K) []K) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.transformKeys = transform
+	}
+}
+
+// WithAffinity fetches the keys of every batch in groups that share the label affinity returns for them,
+// e.g. the shard a key lives on, calling the fetch once per group and up to parallelism groups at a time.
+// Loads still share one batch window and one cache, only the fetch is split. Unlike in other batches,
+// the waiters of a key only get its own error: an error the fetch returns for a whole group fails the
+// keys of that group alone, and an error it returns for a key fails only that key.
+func WithAffinity[K comparable, V any](affinity func(key K) string, parallelism int) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.affinity = affinity
+		l.affinityParallelism = parallelism
+	}
+}
+
+// WithKeyErrors gives the waiters of a key only the error the fetch returned for that key, like the
+// original dataloaden did, so a failing key no longer fails its batch-mates and their values are cached.
+// Without it every waiter of a batch with an error gets the aggregate of all its errors, and no key of
+// the batch is cached. Errors that are not aligned with the keys, like a single error, still fail every
+// key. Streaming loaders always report errors per key.
+func WithKeyErrors[K comparable, V any]() Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.keyErrors = true
+	}
+}
+
+// WithLoadErrors wraps the errors a batch fails keys with in a *LoadError, which names the loader, the
+// batch, the key, why the batch was dispatched and how long its fetch ran, so an error still tells where it
+// came from after it went up a few layers. errors.Is and errors.As see through it to the error of the batch.
+// Keys are formatted with fmt unless redactKey is set. Errors raised before a key joined a batch, like an
+// invalid key or a closed loader, and waits given up on are returned as they are.
+func WithLoadErrors[K comparable, V any](redactKey func(key K) string) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.loadErrors = true
+		l.loadErrorKey = redactKey
+	}
+}
+
+// WithExecutor runs the fetches of dispatched batches through run instead of a goroutine each, e.g. to
+// bound them with a worker pool, order them by priority or run them synchronously in tests. Batches
+// dispatched by their window timers, by Flush or by a load that fills them are all submitted to it. run
+// may block, it is never called with the loader's lock held, but it must eventually call every task it
+// is given: the waiters of a batch wait until its task has run. Batches of keys loaded from within the
+// loader's own fetch bypass the executor, which may be busy with that very fetch. Watchdog.QueuedAfter
+// reports batches that wait for the executor too long.
+func WithExecutor[K comparable, V any](run func(task func())) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.executor = run
+	}
+}
+
+// WithAliasResolver rewrites aliases among the keys of every batch to their canonical keys before the
+// fetch. resolve returns the canonical key of every key that is an alias, keys it leaves out are their
+// own canonical key, and aliases of aliases are followed to the end of the chain. The fetch sees every
+// canonical key once, the result is delivered to the waiters of the canonical key and of all its aliases,
+// and cached under the canonical key only.
+//
+// Resolved aliases are remembered, so later loads of an alias are cache hits or join the canonical key
+// without resolving it again. ClearAll forgets them.
+//
+// When resolve returns an error, the keys it returned no canonical key for fail with it, as do keys
+// whose aliases form a cycle with ErrAliasCycle. Like errors the fetch returns for single keys, these
+// fail the batch. Aliases are not resolved for streaming loaders.
+func WithAliasResolver[K comparable, V any](resolve func(keys []K) (map[K]K, error)) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.resolveAliases = resolve
+		l.aliases = &aliasTable[K]{}
+	}
+}
+
+// WithTransformValue rewrites every value the fetch returned before it is cached and delivered, e.g. to
+// normalize or redact it. transform runs outside the loader's lock, once per key that did not fail, and a
+// nil return makes the key missing like a value the fetch did not return. A transform that panics fails
+// its key with an error wrapping ErrTransformPanic. Primed values are not transformed.
+func WithTransformValue[K comparable, V any](transform func(key K, value *V) *V) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.valueTransform = transform
+	}
+}
+
+// WithReentrantPolicy decides what loads of keys that are not cached do when they are made from within
+// the loader's own fetch, ReentrantFetch by default: they never wait for a pending batch, which may be the
+// one that is fetching. Loads are recognized by their context: the context the fetch of NewDataLoaderCtx
+// or the fetchOne of NewSingleFetchLoader gets, or one derived from it, from whichever goroutine they
+// are made. The loads of a fetch without a context, like that of NewDataLoader, are not recognized, and
+// neither are loads made with an unrelated context, which wait for their batch like any other load.
+func WithReentrantPolicy[K comparable, V any](policy ReentrantPolicy) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.reentrantPolicy = policy
+	}
+}
+
+// WithMissingValueError makes loads of keys that the fetch returned neither a value nor an error for
+// fail with the error produced by fn, instead of succeeding with a nil value. Values primed as nil
+// are explicit entries and are not affected.
+func WithMissingValueError[K comparable, V any](fn func(key K) error) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.missingValueError = fn
+	}
+}
+
+// WithBatchWeight dispatches a batch once the summed weight of its keys reaches maxBatchWeight, in addition
+// to the maxBatch key count, whichever is reached first. weight is called once for every key added to a batch
+// while the loader's lock is held, so it has to be cheap.
+func WithBatchWeight[K comparable, V any](weight func(key K) int, maxBatchWeight int) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.weight = weight
+		l.maxBatchWeight = maxBatchWeight
+	}
+}
+
+// WithPrimePolicy decides which value wins when a key is primed while it is pending in a batch,
+// PreferFetched by default
+func WithPrimePolicy[K comparable, V any](policy PrimePolicy) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.primePolicy = policy
+	}
+}
+
+// WithStrict makes the loader panic with a message naming the loader when the fetch breaks its contract:
+// returning a value slice of the wrong length, an error slice that is neither empty, a single batch error
+// nor one error per key, no values and no errors at all, a value and an error for the same key, emitting
+// a position out of range, or modifying its keys. Without it the waiters get an error wrapping
+// ErrContractViolation for the results the loader cannot attribute to keys and the rest are tolerated,
+// which is what production wants; strict mode is meant to catch fetch bugs in tests and CI. Modified keys
+// are only detected with WithStrict or WithViolationHandler, checking them costs every fetch a copy of its
+// keys.
+func WithStrict[K comparable, V any]() Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.strict = true
+	}
+}
+
+// WithViolationHandler passes the detail of every broken fetch contract to handler, see WithStrict, and
+// delivers the results as the fetch returned them instead of failing them with ErrContractViolation.
+// It cannot be combined with WithStrict, which panics before the handler would be called.
+func WithViolationHandler[K comparable, V any](handler func(loaderName, detail string)) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.violationHandler = handler
+	}
+}
+
+// WithLite trims the loader for processes that create many short lived loaders: it keeps no stats and
+// no entry info, and schedules batch windows on a timer wheel shared by all lite loaders, which rounds
+// them up to the next millisecond. A clock set with WithClock takes precedence over the wheel. It cannot be
+// combined with WithStatsBy or WithHotKeys, which count every load.
+func WithLite[K comparable, V any]() Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.noStats = true
+		l.noEntryInfo = true
+		if _, ok := l.clock.(realClock); ok {
+			l.clock = sharedWheel
+		}
+	}
+}
+
+// WithStatsBy counts loads by the label statKey returns for their key, e.g. a tenant or a key prefix, see
+// StatsBy. At most maxLabels labels are counted apart, the loads of further labels are counted under
+// OtherBucket.
+func WithStatsBy[K comparable, V any](statKey func(key K) string, maxLabels int) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.statBuckets = &statBuckets[K]{label: statKey, max: maxLabels, counts: map[string]*bucketCounts{}}
+	}
+}
+
+// WithLoadSampler passes one in every loads to sample once its result is delivered, for export to an
+// analytics pipeline. The rate can be changed later with SetLoadSampleRate. sample runs on the goroutine
+// of the load and should return quickly.
+func WithLoadSampler[K comparable, V any](every int, sample func(sample LoadSample[K])) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.sampler = sample
+		l.sampleEvery.Store(int64(every))
+	}
+}
+
+// WithHotKeys tracks how often keys are requested in a bounded number of counters, see HotKeys. A capacity
+// a few times the number of hot keys you expect keeps their counts accurate.
+func WithHotKeys[K comparable, V any](capacity int) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		if capacity > 0 {
+			l.hotKeys = newHotKeyTracker[K](capacity)
+		}
+	}
+}
+
+// WithClock replaces the clock the loader reads time from and schedules its timers on,
+// which lets tests control batch windows, TTLs and the watchdog deterministically
+func WithClock[K comparable, V any](clock Clock) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.clock = clock
+	}
+}
+
+// WithSlidingWindow replaces the fixed batch window with one that every new key of the batch extends to
+// slide after its arrival, so a burst of keys is fetched in one batch once it is over. The window never
+// extends past maxWait after the first key, which bounds the wait of loads under a steady trickle of keys.
+// Batches dispatched by the window count as SlideExpired or MaxWaitExpired.
+func WithSlidingWindow[K comparable, V any](slide, maxWait time.Duration) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.slide = slide
+		l.maxWait = maxWait
+	}
+}
+
+// WithEagerSingle stops single key batches from paying for the whole window: the window only starts when
+// a batch gets its second key, and a batch that still holds a single key is dispatched as soon as that key
+// is waited for, by Load or by calling its thunk. It cannot be combined with WithSlidingWindow, whose window
+// is meant to collect the keys the eager dispatch would split off.
+func WithEagerSingle[K comparable, V any]() Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.eagerSingle = true
+	}
+}
+
+// WithDeadlineFlush dispatches a batch early when the deadline of the context of one of its waiters,
+// passed to LoadCtx or LoadThunkCtx, is less than margin away. Waiters without a deadline never
+// cause an early dispatch.
+func WithDeadlineFlush[K comparable, V any](margin time.Duration) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.deadlineFlush = true
+		l.deadlineMargin = margin
+	}
+}
+
+// WithClassifyError sets the classifier the retry, negative caching and the circuit breaker use to tell
+// fetch errors apart. It is called with the error of a batch, which joins the errors of all of its keys,
+// and with the error of a single key. Without a classifier every error is Unknown.
+func WithClassifyError[K comparable, V any](classify func(error) ErrorClass) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.classifyError = classify
+	}
+}
+
+// WithFailureLog keeps the last size batches whose fetch failed for RecentFailures, with up to maxErrors of
+// the errors of each. Keys are formatted with fmt unless redactKey is set, which lets sensitive keys be
+// masked. The log is bounded, recording a failure costs at most maxErrors key formats.
+func WithFailureLog[K comparable, V any](size, maxErrors int, redactKey func(key K) string) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		if size <= 0 {
+			l.failures = nil
+			return
+		}
+		l.failures = &failureLog[K]{maxErrors: max(maxErrors, 0), redactKey: redactKey, records: make([]FailureRecord, size)}
+	}
+}
+
+// WithReadyGate holds loads back until ready is closed, e.g. while the cache is warmed from a snapshot, so
+// traffic arriving early is answered from the warm cache instead of fetching. Waiting loads give up when
+// their context is done or their load budget is spent. After timeout, 0 = no timeout, they proceed when
+// failOpen is set and fail with ErrNotReady otherwise. Prime, ReplaceCache and the other cache writes are
+// not held back, and Prefetch does nothing while the gate is closed.
+func WithReadyGate[K comparable, V any](ready <-chan struct{}, timeout time.Duration, failOpen bool) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.gate = &readyGate{ready: ready, timeout: timeout, failOpen: failOpen}
+	}
+}
+
+// WithRetry fetches a batch again, up to attempts more times and backoff apart, while its error is Transient.
+// Errors implementing RetryAfterError are retried as well, after the wait they ask for capped by the load budget.
+func WithRetry[K comparable, V any](attempts int, backoff time.Duration) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.retries = attempts
+		l.retryBackoff = backoff
+	}
+}
+
+// WithLoadBudget limits how long a load waits for its result to d, counted from the moment the key is
+// requested and covering the batch window, retries and their backoff. A load that runs out of budget gets
+// ErrBudgetExceeded while its batch carries on in the background and still caches the result.
+func WithLoadBudget[K comparable, V any](d time.Duration) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.loadBudget = d
+	}
+}
+
+// WithNegativeCache caches keys whose error is NotFound as missing values, their waiters get a result
+// that is not found instead of the error and later loads do not fetch them again
+func WithNegativeCache[K comparable, V any]() Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.negativeCache = true
+	}
+}
+
+// WithCircuitBreaker stops calling the fetch for cooldown once threshold batches in a row failed with a
+// Fatal error, batches fail with ErrCircuitOpen in the meantime. After the cooldown a single fatal failure
+// opens the breaker again, a successful fetch closes it.
+func WithCircuitBreaker[K comparable, V any](threshold int, cooldown time.Duration) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.breaker = &circuitBreaker{threshold: threshold, cooldown: cooldown}
+	}
+}
+
+// WithPromote primes the values every batch fetched into parent, typically a long-lived loader behind a
+// request-scoped one, so the next request finds them cached. Only values shareable returns true for are
+// primed, a nil shareable shares all of them. Priming happens in the background through a bounded queue,
+// batches are dropped rather than slowing down loads when the parent falls behind.
+func WithPromote[K comparable, V any](parent DataLoader[K, V], shareable func(key K, value *V) bool) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.promoter = &promoter[K, V]{
+			parent:    parent,
+			shareable: shareable,
+			queue:     make(chan []promotion[K, V], promoteQueue),
+		}
+	}
+}
+
+// WithWatchdog reports batches whose fetch has not returned within watchdog.After,
+// see Watchdog for the available settings
+func WithWatchdog[K comparable, V any](watchdog Watchdog[K]) Option[K, V] {
+	return func(l *Loader[K, V]) {
+		l.watchdog = watchdog
+	}
+}
@@ -0,0 +1,461 @@
+package dataloaden
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ErrInvalidOptions is wrapped by the errors NewDataLoaderE returns for options with invalid settings or
+// options that cannot be combined
+var ErrInvalidOptions = errors.New("dataloaden: invalid options")
+
+// optionSettings is what validateOptions needs to know of a loader's settings. Unlike the loader it is not
+// generic, so that optionSpecs can be a single table whose funcs capture nothing: validating the options
+// of a new loader does not allocate.
+type optionSettings struct {
+	stream, sizeOf, onEvict, memo, batchMemo, transformKeys, resolveAliases, affinity bool
+	keyErrors, loadErrors, executor, weight, strict, violationHandler, classifyError  bool
+	negativeCache, breaker, named, serveStale, noEntryInfo, normalizeKey, keyFilter   bool
+	valueTransform, missingValueError, lite, statsBy, statKey, sampler, hotKeys       bool
+	eagerSingle, deadlineFlush, failureLog, gate, gateReady, promote, promoteParent   bool
+	watchdog, attached, lifetime, customClock, nilClock, compactStore, prefixStore    bool
+
+	cacheTTL, retryBackoff, slide, maxWait, breakerCooldown, loadBudget time.Duration
+	deadlineMargin, gateTimeout, watchdogAfter, watchdogQueued          time.Duration
+
+	maxBytes, maxEntries, maxVetoes, memoLists, maxBatchWeight, retries, breakerThreshold int
+	reentrantPolicy, primePolicy                                                          int
+}
+
+func (l *Loader[K, V]) optionSettings() optionSettings {
+	s := optionSettings{
+		stream:            l.stream != nil,
+		sizeOf:            l.cache.sizeOf != nil,
+		onEvict:           l.cache.onEvict != nil,
+		memo:              l.memo != nil,
+		batchMemo:         l.batchMemo != nil,
+		transformKeys:     l.transformKeys != nil,
+		resolveAliases:    l.resolveAliases != nil,
+		affinity:          l.affinity != nil,
+		keyErrors:         l.keyErrors,
+		loadErrors:        l.loadErrors,
+		executor:          l.executor != nil,
+		weight:            l.weight != nil,
+		strict:            l.strict,
+		violationHandler:  l.violationHandler != nil,
+		classifyError:     l.classifyError != nil,
+		negativeCache:     l.negativeCache,
+		breaker:           l.breaker != nil,
+		named:             l.name != "",
+		serveStale:        l.serveStale,
+		noEntryInfo:       l.noEntryInfo,
+		normalizeKey:      l.normalizeKey != nil,
+		keyFilter:         l.keyFilter != nil,
+		valueTransform:    l.valueTransform != nil,
+		missingValueError: l.missingValueError != nil,
+		lite:              l.noStats,
+		statsBy:           l.statBuckets != nil,
+		sampler:           l.sampler != nil,
+		hotKeys:           l.hotKeys != nil,
+		eagerSingle:       l.eagerSingle,
+		deadlineFlush:     l.deadlineFlush,
+		failureLog:        l.failures != nil,
+		gate:              l.gate != nil,
+		promote:           l.promoter != nil,
+		watchdog:          l.watchdog.After != 0 || l.watchdog.QueuedAfter != 0 || l.watchdog.OnStuck != nil || l.watchdog.OnQueued != nil,
+		attached:          l.attached != nil,
+		lifetime:          l.lifetime != nil,
+		compactStore:      l.compactStore,
+		prefixStore:       l.prefixStore,
+		cacheTTL:          l.cacheTTL,
+		retryBackoff:      l.retryBackoff,
+		slide:             l.slide,
+		maxWait:           l.maxWait,
+		loadBudget:        l.loadBudget,
+		deadlineMargin:    l.deadlineMargin,
+		watchdogAfter:     l.watchdog.After,
+		watchdogQueued:    l.watchdog.QueuedAfter,
+		maxBytes:          l.cache.maxBytes,
+		maxEntries:        l.cache.maxEntries,
+		maxVetoes:         l.cache.maxVetoes,
+		maxBatchWeight:    l.maxBatchWeight,
+		retries:           l.retries,
+		reentrantPolicy:   int(l.reentrantPolicy),
+		primePolicy:       int(l.primePolicy),
+	}
+	if l.memo != nil {
+		s.memoLists = l.memo.max
+	}
+	if l.breaker != nil {
+		s.breakerThreshold, s.breakerCooldown = l.breaker.threshold, l.breaker.cooldown
+	}
+	if l.statBuckets != nil {
+		s.statKey = l.statBuckets.label != nil
+	}
+	if l.gate != nil {
+		s.gateReady, s.gateTimeout = l.gate.ready != nil, l.gate.timeout
+	}
+	if l.promoter != nil {
+		s.promoteParent = l.promoter.parent != nil
+	}
+	// the clocks WithLite and the constructors set are not chosen with WithClock, clocks need not be
+	// comparable so they are told apart by type
+	switch clock := l.clock.(type) {
+	case nil:
+		s.customClock, s.nilClock = true, true
+	case realClock:
+	case *timerWheel:
+		s.customClock = clock != sharedWheel
+	default:
+		s.customClock = true
+	}
+	return s
+}
+
+// optionSpec recognizes an option on the settings of the loader it configured. check returns why the
+// settings of the option are invalid, "" when they are valid.
+type optionSpec struct {
+	name  string
+	used  func(s optionSettings) bool
+	check func(s optionSettings) string
+}
+
+// optionSpecs lists every option, so that all of them are validated and take part in the checks of
+// optionRules. Loaders created with NewStreamingDataLoader are listed as if it was an
+// option, since it decides which options apply. validateOptions tracks the options in use in a bitset, so
+// there can be at most 64.
+var optionSpecs = []optionSpec{
+	{
+		name: "NewStreamingDataLoader",
+		used: func(s optionSettings) bool { return s.stream },
+	},
+	{
+		name: "WithCacheTTL",
+		used: func(s optionSettings) bool { return s.cacheTTL != 0 },
+		check: func(s optionSettings) string {
+			return invalidIf(s.cacheTTL < 0, "ttl is negative")
+		},
+	},
+	{
+		name: "WithMaxCacheBytes",
+		used: func(s optionSettings) bool { return s.maxBytes != 0 || s.sizeOf },
+		check: func(s optionSettings) string {
+			return invalidIf(s.maxBytes > 0 && !s.sizeOf, "sizeOf is nil")
+		},
+	},
+	{
+		name: "WithMaxCacheSize",
+		used: func(s optionSettings) bool { return s.maxEntries != 0 },
+		check: func(s optionSettings) string {
+			return invalidIf(s.maxEntries < 0, "maxEntries is negative")
+		},
+	},
+	{
+		name: "WithOnEvict",
+		used: func(s optionSettings) bool { return s.onEvict },
+		check: func(s optionSettings) string {
+			return invalidIf(s.maxVetoes < 0, "maxVetoes is negative")
+		},
+	},
+	{
+		name: "WithLoadAllMemo",
+		used: func(s optionSettings) bool { return s.memo },
+		check: func(s optionSettings) string {
+			return invalidIf(s.memoLists <= 0, "maxLists is not positive")
+		},
+	},
+	{
+		name: "WithBatchMemo",
+		used: func(s optionSettings) bool { return s.batchMemo },
+	},
+	{
+		name: "WithTransformKeys",
+		used: func(s optionSettings) bool { return s.transformKeys },
+	},
+	{
+		name: "WithAliasResolver",
+		used: func(s optionSettings) bool { return s.resolveAliases },
+	},
+	{
+		name: "WithAffinity",
+		used: func(s optionSettings) bool { return s.affinity },
+	},
+	{
+		name: "WithKeyErrors",
+		used: func(s optionSettings) bool { return s.keyErrors },
+	},
+	{
+		name: "WithLoadErrors",
+		used: func(s optionSettings) bool { return s.loadErrors },
+	},
+	{
+		name: "WithExecutor",
+		used: func(s optionSettings) bool { return s.executor },
+	},
+	{
+		name: "WithBatchWeight",
+		used: func(s optionSettings) bool { return s.weight || s.maxBatchWeight != 0 },
+		check: func(s optionSettings) string {
+			return invalidIf(!s.weight || s.maxBatchWeight <= 0, "weight is nil or maxBatchWeight is not positive")
+		},
+	},
+	{
+		name: "WithSlidingWindow",
+		used: func(s optionSettings) bool { return s.slide != 0 || s.maxWait != 0 },
+		check: func(s optionSettings) string {
+			return invalidIf(s.slide <= 0 || s.maxWait < s.slide, "slide is not positive or maxWait is shorter than slide")
+		},
+	},
+	{
+		name: "WithStrict",
+		used: func(s optionSettings) bool { return s.strict },
+	},
+	{
+		name: "WithViolationHandler",
+		used: func(s optionSettings) bool { return s.violationHandler },
+	},
+	{
+		name: "WithClassifyError",
+		used: func(s optionSettings) bool { return s.classifyError },
+	},
+	{
+		name: "WithRetry",
+		used: func(s optionSettings) bool { return s.retries != 0 || s.retryBackoff != 0 },
+		check: func(s optionSettings) string {
+			return invalidIf(s.retries < 0 || s.retryBackoff < 0, "attempts or backoff is negative")
+		},
+	},
+	{
+		name: "WithNegativeCache",
+		used: func(s optionSettings) bool { return s.negativeCache },
+	},
+	{
+		name: "WithCircuitBreaker",
+		used: func(s optionSettings) bool { return s.breaker },
+		check: func(s optionSettings) string {
+			return invalidIf(s.breakerThreshold <= 0 || s.breakerCooldown < 0, "threshold is not positive or cooldown is negative")
+		},
+	},
+	{
+		name: "WithLoadBudget",
+		used: func(s optionSettings) bool { return s.loadBudget != 0 },
+		check: func(s optionSettings) string {
+			return invalidIf(s.loadBudget < 0, "d is negative")
+		},
+	},
+	{
+		name: "WithName",
+		used: func(s optionSettings) bool { return s.named },
+	},
+	{
+		name: "WithServeStaleOnError",
+		used: func(s optionSettings) bool { return s.serveStale },
+	},
+	{
+		name: "WithCompactCache",
+		used: func(s optionSettings) bool { return s.compactStore },
+	},
+	{
+		name: "WithKeyPrefixCompaction",
+		used: func(s optionSettings) bool { return s.prefixStore },
+	},
+	{
+		name: "WithAttachedCache",
+		used: func(s optionSettings) bool { return s.attached },
+	},
+	{
+		name: "WithoutEntryInfo",
+		used: func(s optionSettings) bool { return s.noEntryInfo },
+	},
+	{
+		name: "WithNormalizeKey",
+		used: func(s optionSettings) bool { return s.normalizeKey },
+	},
+	{
+		name: "WithKeyFilter",
+		used: func(s optionSettings) bool { return s.keyFilter },
+	},
+	{
+		name: "WithTransformValue",
+		used: func(s optionSettings) bool { return s.valueTransform },
+	},
+	{
+		name: "WithReentrantPolicy",
+		used: func(s optionSettings) bool { return s.reentrantPolicy != int(ReentrantFetch) },
+		check: func(s optionSettings) string {
+			return invalidIf(s.reentrantPolicy < 0 || s.reentrantPolicy > int(ReentrantFail), "unknown policy")
+		},
+	},
+	{
+		name: "WithMissingValueError",
+		used: func(s optionSettings) bool { return s.missingValueError },
+	},
+	{
+		name: "WithPrimePolicy",
+		used: func(s optionSettings) bool { return s.primePolicy != int(PreferFetched) },
+		check: func(s optionSettings) string {
+			return invalidIf(s.primePolicy < 0 || s.primePolicy > int(PreferPrimed), "unknown policy")
+		},
+	},
+	{
+		name: "WithLite",
+		used: func(s optionSettings) bool { return s.lite },
+	},
+	{
+		name: "WithStatsBy",
+		used: func(s optionSettings) bool { return s.statsBy },
+		check: func(s optionSettings) string {
+			return invalidIf(!s.statKey, "statKey is nil")
+		},
+	},
+	{
+		name: "WithLoadSampler",
+		used: func(s optionSettings) bool { return s.sampler },
+	},
+	{
+		name: "WithHotKeys",
+		used: func(s optionSettings) bool { return s.hotKeys },
+	},
+	{
+		name: "WithClock",
+		used: func(s optionSettings) bool { return s.customClock },
+		check: func(s optionSettings) string {
+			return invalidIf(s.nilClock, "clock is nil")
+		},
+	},
+	{
+		name: "WithEagerSingle",
+		used: func(s optionSettings) bool { return s.eagerSingle },
+	},
+	{
+		name: "WithDeadlineFlush",
+		used: func(s optionSettings) bool { return s.deadlineFlush },
+		check: func(s optionSettings) string {
+			return invalidIf(s.deadlineMargin < 0, "margin is negative")
+		},
+	},
+	{
+		name: "WithFailureLog",
+		used: func(s optionSettings) bool { return s.failureLog },
+	},
+	{
+		name: "WithReadyGate",
+		used: func(s optionSettings) bool { return s.gate },
+		check: func(s optionSettings) string {
+			return invalidIf(!s.gateReady || s.gateTimeout < 0, "ready is nil or timeout is negative")
+		},
+	},
+	{
+		name: "WithPromote",
+		used: func(s optionSettings) bool { return s.promote },
+		check: func(s optionSettings) string {
+			return invalidIf(!s.promoteParent, "parent is nil")
+		},
+	},
+	{
+		name: "WithWatchdog",
+		used: func(s optionSettings) bool { return s.watchdog },
+		check: func(s optionSettings) string {
+			switch {
+			case s.watchdogAfter < 0 || s.watchdogQueued < 0:
+				return "After or QueuedAfter is negative"
+			case s.watchdogQueued > 0 && !s.executor:
+				return "QueuedAfter is set without WithExecutor"
+			}
+			return ""
+		},
+	},
+	{
+		name: "WithLifetime",
+		used: func(s optionSettings) bool { return s.lifetime },
+	},
+}
+
+func invalidIf(invalid bool, reason string) string {
+	if invalid {
+		return reason
+	}
+	return ""
+}
+
+// optionRule constrains the combination of two options: with requires the option does nothing without the
+// other, otherwise the two cannot be combined
+type optionRule struct {
+	option   string
+	other    string
+	requires bool
+	reason   string
+
+	// with requires, an option that can take the place of other
+	alt string
+}
+
+// present reports whether the other option of the rule, or the one that can take its place, is used
+func (r optionRule) present(used func(name string) bool) bool {
+	return used(r.other) || r.alt != "" && used(r.alt)
+}
+
+// others names the other option of the rule and the one that can take its place
+func (r optionRule) others() string {
+	if r.alt == "" {
+		return r.other
+	}
+	return r.other + " or " + r.alt
+}
+
+// optionRules is the compatibility matrix of the options in optionSpecs, pairs without a rule combine freely
+var optionRules = []optionRule{
+	{option: "WithStrict", other: "WithViolationHandler", reason: "strict loaders panic before the handler is called"},
+	{option: "WithOnEvict", other: "WithMaxCacheBytes", alt: "WithMaxCacheSize", requires: true, reason: "only entries evicted to stay within the limit are passed to it"},
+	{option: "WithLoadAllMemo", other: "WithCacheTTL", reason: "entries expire without a write, so LoadAll does not memoize"},
+	{option: "NewStreamingDataLoader", other: "WithBatchMemo", reason: "streaming loaders do not use the batch memo"},
+	{option: "NewStreamingDataLoader", other: "WithTransformKeys", reason: "streaming fetches get the keys as they were requested"},
+	{option: "NewStreamingDataLoader", other: "WithAliasResolver", reason: "streaming fetches get the keys as they were requested"},
+	{option: "NewStreamingDataLoader", other: "WithAffinity", reason: "streaming fetches get all keys of the batch at once"},
+	{option: "NewStreamingDataLoader", other: "WithKeyErrors", reason: "streaming loaders always report errors per key"},
+	{option: "WithNegativeCache", other: "WithClassifyError", requires: true, reason: "only errors classified NotFound are cached"},
+	{option: "WithCircuitBreaker", other: "WithClassifyError", requires: true, reason: "only errors classified Fatal open the breaker"},
+	{option: "WithEagerSingle", other: "WithSlidingWindow", reason: "a lone key is dispatched before the window can collect the rest of the burst"},
+	{option: "WithLite", other: "WithStatsBy", reason: "lite loaders do not count loads"},
+	{option: "WithLite", other: "WithHotKeys", reason: "lite loaders do not count loads"},
+	{option: "WithKeyPrefixCompaction", other: "WithCompactCache", reason: "both replace the store of the cache"},
+}
+
+// validateOptions checks the settings of every option the loader was configured with against
+// optionSpecs and their combination against optionRules
+func (l *Loader[K, V]) validateOptions() error {
+	var errs []error
+	settings := l.optionSettings()
+	var used uint64
+	for i, spec := range optionSpecs {
+		if !spec.used(settings) {
+			continue
+		}
+		used |= 1 << i
+		if spec.check == nil {
+			continue
+		}
+		if reason := spec.check(settings); reason != "" {
+			errs = append(errs, fmt.Errorf("%w: %s: %s", ErrInvalidOptions, spec.name, reason))
+		}
+	}
+	isUsed := func(name string) bool {
+		i := slices.IndexFunc(optionSpecs, func(spec optionSpec) bool { return spec.name == name })
+		return i >= 0 && used&(1<<i) != 0
+	}
+	for _, rule := range optionRules {
+		switch {
+		case !isUsed(rule.option) || rule.present(isUsed) == rule.requires:
+		case rule.requires:
+			errs = append(errs, fmt.Errorf("%w: %s requires %s: %s", ErrInvalidOptions, rule.option, rule.others(), rule.reason))
+		default:
+			errs = append(errs, fmt.Errorf("%w: %s cannot be combined with %s: %s", ErrInvalidOptions, rule.option, rule.other, rule.reason))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return l.namedError(errors.Join(errs...))
+}
@@ -0,0 +1,228 @@
+package dataloaden
+
+import (
+	"context"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// optionSamples holds a valid setting of every option in optionSpecs, NewStreamingDataLoader is not an
+// option and has none. The keys are strings for WithKeyPrefixCompaction.
+func optionSamples() map[string]Option[string, string] {
+	noop := func([]string) ([]*string, []error) { return nil, nil }
+	attached, _ := WithAttachedCache(NewDataLoader(noop, 0, 0).Detach())
+	ready := make(chan struct{})
+	close(ready)
+	return map[string]Option[string, string]{
+		"NewStreamingDataLoader":  nil,
+		"WithCacheTTL":            WithCacheTTL[string, string](time.Minute),
+		"WithMaxCacheBytes":       WithMaxCacheBytes[string, string](1024, stringKeySize),
+		"WithMaxCacheSize":        WithMaxCacheSize[string, string](8),
+		"WithOnEvict":             WithOnEvict[string, string](func(string, *string) bool { return true }, 2),
+		"WithLoadAllMemo":         WithLoadAllMemo[string, string](8),
+		"WithBatchMemo":           WithBatchMemo(NewBatchMemo[string, string](8, 0)),
+		"WithTransformKeys":       WithTransformKeys[string, string](func(keys []string) []string { return keys }),
+		"WithAliasResolver":       WithAliasResolver[string, string](func([]string) (map[string]string, error) { return nil, nil }),
+		"WithAffinity":            WithAffinity[string, string](func(string) string { return "" }, 2),
+		"WithKeyErrors":           WithKeyErrors[string, string](),
+		"WithLoadErrors":          WithLoadErrors[string, string](nil),
+		"WithExecutor":            WithExecutor[string, string](func(task func()) { task() }),
+		"WithBatchWeight":         WithBatchWeight[string, string](func(string) int { return 1 }, 10),
+		"WithSlidingWindow":       WithSlidingWindow[string, string](time.Millisecond, time.Second),
+		"WithStrict":              WithStrict[string, string](),
+		"WithViolationHandler":    WithViolationHandler[string, string](func(string, string) {}),
+		"WithClassifyError":       WithClassifyError[string, string](classifyTestError),
+		"WithRetry":               WithRetry[string, string](2, time.Millisecond),
+		"WithNegativeCache":       WithNegativeCache[string, string](),
+		"WithCircuitBreaker":      WithCircuitBreaker[string, string](3, time.Second),
+		"WithLoadBudget":          WithLoadBudget[string, string](time.Second),
+		"WithName":                WithName[string, string]("users"),
+		"WithServeStaleOnError":   WithServeStaleOnError[string, string](false),
+		"WithCompactCache":        WithCompactCache[string, string](func(key string) uint64 { return uint64(len(key)) }, 8),
+		"WithKeyPrefixCompaction": WithKeyPrefixCompaction[string](":"),
+		"WithAttachedCache":       attached,
+		"WithoutEntryInfo":        WithoutEntryInfo[string, string](),
+		"WithNormalizeKey":        WithNormalizeKey[string, string](func(key string) (string, error) { return key, nil }),
+		"WithKeyFilter":           WithKeyFilter[string, string](func(string) error { return nil }),
+		"WithTransformValue":      WithTransformValue[string, string](func(_ string, v *string) *string { return v }),
+		"WithReentrantPolicy":     WithReentrantPolicy[string, string](ReentrantFail),
+		"WithMissingValueError":   WithMissingValueError[string, string](func(string) error { return nil }),
+		"WithPrimePolicy":         WithPrimePolicy[string, string](PreferPrimed),
+		"WithLite":                WithLite[string, string](),
+		"WithStatsBy":             WithStatsBy[string, string](func(key string) string { return key }, 4),
+		"WithLoadSampler":         WithLoadSampler[string, string](10, func(LoadSample[string]) {}),
+		"WithHotKeys":             WithHotKeys[string, string](16),
+		"WithClock":               WithClock[string, string](newFakeClock()),
+		"WithEagerSingle":         WithEagerSingle[string, string](),
+		"WithDeadlineFlush":       WithDeadlineFlush[string, string](time.Millisecond),
+		"WithFailureLog":          WithFailureLog[string, string](4, 2, nil),
+		"WithReadyGate":           WithReadyGate[string, string](ready, time.Second, false),
+		"WithPromote":             WithPromote[string, string](NewDataLoader(noop, 0, 0), nil),
+		"WithWatchdog":            WithWatchdog[string, string](Watchdog[string]{After: time.Second}),
+		"WithLifetime":            WithLifetime[string, string](context.Background(), 0, nil),
+	}
+}
+
+func stringKeySize(_ string, v *string) int {
+	return len(*v)
+}
+
+// buildWith creates a loader with the samples of the named options
+func buildWith(extra []Option[string, string], names ...string) error {
+	samples := optionSamples()
+	opts := slices.Clone(extra)
+	var stream func(keys []string, emit func(int, *string, error))
+	for _, name := range names {
+		if name == "NewStreamingDataLoader" {
+			stream = func([]string, func(int, *string, error)) {}
+			continue
+		}
+		opts = append(opts, samples[name])
+	}
+	_, err := newLoader(nil, stream, 0, 0, opts)
+	return err
+}
+
+func TestOptionCombinations(t *testing.T) {
+	samples := optionSamples()
+	if len(optionSpecs) > 64 {
+		t.Fatalf("expected at most 64 options to fit the bitset of validateOptions, got %d", len(optionSpecs))
+	}
+	var names []string
+	for _, spec := range optionSpecs {
+		if _, ok := samples[spec.name]; !ok {
+			t.Fatalf("expected a sample of %s", spec.name)
+		}
+		names = append(names, spec.name)
+	}
+	for _, rule := range optionRules {
+		if !slices.Contains(names, rule.option) || !slices.Contains(names, rule.other) || rule.alt != "" && !slices.Contains(names, rule.alt) {
+			t.Fatalf("expected the options of rule %+v to be registered", rule)
+		}
+	}
+
+	for i, a := range names {
+		for _, b := range names[i:] {
+			combination := []string{a, b}
+			err := buildWith(nil, combination...)
+
+			var broken []optionRule
+			for _, rule := range optionRules {
+				has := func(name string) bool { return slices.Contains(combination, name) }
+				if has(rule.option) && rule.present(has) != rule.requires {
+					broken = append(broken, rule)
+				}
+			}
+			if len(broken) == 0 {
+				if err != nil {
+					t.Errorf("expected %s with %s to be accepted, got %v", a, b, err)
+				}
+				continue
+			}
+			if !errors.Is(err, ErrInvalidOptions) {
+				t.Errorf("expected %s with %s to be rejected, got %v", a, b, err)
+				continue
+			}
+			for _, rule := range broken {
+				msg := err.Error()
+				if !strings.Contains(msg, rule.option) || !strings.Contains(msg, rule.other) || !strings.Contains(msg, rule.reason) {
+					t.Errorf("expected the rejection of %s with %s to name both options and why, got %q", a, b, msg)
+				}
+			}
+		}
+	}
+}
+
+// TestOptionSpecsComplete fails for an option constructor without a spec, which validateOptions would let
+// through unchecked
+func TestOptionSpecsComplete(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, parser.SkipObjectResolution)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || !fn.Name.IsExported() || fn.Type.Results == nil {
+				continue
+			}
+			result, ok := fn.Type.Results.List[0].Type.(*ast.IndexListExpr)
+			if !ok {
+				continue
+			}
+			if ident, _ := result.X.(*ast.Ident); ident == nil || ident.Name != "Option" {
+				continue
+			}
+			if !slices.ContainsFunc(optionSpecs, func(spec optionSpec) bool { return spec.name == fn.Name.Name }) {
+				t.Errorf("%s: expected option %s to have a spec in optionSpecs", fset.Position(fn.Pos()), fn.Name.Name)
+			}
+		}
+	}
+}
+
+func TestOptionSettings(t *testing.T) {
+	tests := []struct {
+		name   string
+		option Option[string, string]
+	}{
+		{"WithCacheTTL", WithCacheTTL[string, string](-time.Second)},
+		{"WithMaxCacheBytes", WithMaxCacheBytes[string, string](1024, nil)},
+		{"WithMaxCacheSize", WithMaxCacheSize[string, string](-1)},
+		{"WithLoadAllMemo", WithLoadAllMemo[string, string](0)},
+		{"WithOnEvict", WithOnEvict[string, string](func(string, *string) bool { return true }, -1)},
+		{"WithBatchWeight", WithBatchWeight[string, string](nil, 10)},
+		{"WithBatchWeight", WithBatchWeight[string, string](func(string) int { return 1 }, 0)},
+		{"WithRetry", WithRetry[string, string](-1, 0)},
+		{"WithCircuitBreaker", WithCircuitBreaker[string, string](0, time.Second)},
+		{"WithLoadBudget", WithLoadBudget[string, string](-time.Second)},
+		{"WithSlidingWindow", WithSlidingWindow[string, string](time.Second, time.Millisecond)},
+		{"WithReentrantPolicy", WithReentrantPolicy[string, string](ReentrantFail + 1)},
+		{"WithPrimePolicy", WithPrimePolicy[string, string](PreferPrimed + 1)},
+		{"WithStatsBy", WithStatsBy[string, string](nil, 4)},
+		{"WithClock", WithClock[string, string](nil)},
+		{"WithDeadlineFlush", WithDeadlineFlush[string, string](-time.Millisecond)},
+		{"WithReadyGate", WithReadyGate[string, string](nil, time.Second, false)},
+		{"WithPromote", WithPromote[string, string](nil, nil)},
+		{"WithWatchdog", WithWatchdog[string, string](Watchdog[string]{After: -time.Second})},
+		{"WithWatchdog", WithWatchdog[string, string](Watchdog[string]{QueuedAfter: time.Second})},
+	}
+	for _, tt := range tests {
+		err := buildWith([]Option[string, string]{tt.option, WithClassifyError[string, string](classifyTestError)})
+		if !errors.Is(err, ErrInvalidOptions) || !strings.Contains(err.Error(), tt.name+":") {
+			t.Errorf("expected invalid settings of %s to be rejected, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestNewDataLoaderE(t *testing.T) {
+	rec := &recordingFetch{}
+	loader, err := NewDataLoaderE(rec.fetch, 0, 0, WithName[int, string]("users"), WithNegativeCache[int, string]())
+	if loader != nil || !errors.Is(err, ErrInvalidOptions) || !strings.HasPrefix(err.Error(), `loader "users": `) {
+		t.Errorf("expected a named ErrInvalidOptions, got %v, %v", loader, err)
+	}
+	if loader, err := NewDataLoaderE(rec.fetch, 0, 0, WithNegativeCache[int, string](), WithClassifyError[int, string](classifyTestError)); loader == nil || err != nil {
+		t.Errorf("expected valid options to be accepted, got %v", err)
+	}
+
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("expected NewStreamingDataLoader to panic with ErrInvalidOptions, got %v", err)
+		}
+	}()
+	NewStreamingDataLoader(func([]int, func(int, *string, error)) {}, 0, 0, WithTransformKeys[int, string](func(keys []int) []int { return keys }))
+}
@@ -0,0 +1,104 @@
+package dataloaden
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnhealthy is wrapped by the errors HealthCheck returns
+var ErrUnhealthy = errors.New("dataloaden: loader backlog unhealthy")
+
+// PendingInfo is a snapshot of the work a loader has not finished yet
+type PendingInfo struct {
+	// how long ago the first key of the open batch arrived, 0 when there is no open batch
+	OpenBatchAge time.Duration
+
+	// number of keys collected in the open batch
+	OpenBatchKeys int
+
+	// number of batches that have been dispatched but whose fetch has not completed yet
+	DispatchedBatches int
+
+	// number of keys waiting for a fetch to complete, in the open and the dispatched batches
+	PendingKeys int
+}
+
+// Pending reports the backlog of the loader: the open batch and the batches still being fetched
+func (l *Loader[K, V]) Pending() PendingInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	info := PendingInfo{
+		DispatchedBatches: l.dispatched,
+		PendingKeys:       len(l.pending),
+	}
+	if l.batch != nil {
+		info.OpenBatchAge = l.clock.Now().Sub(l.batch.started)
+		info.OpenBatchKeys = len(l.batch.keys)
+	}
+	return info
+}
+
+// PendingKeys returns the keys waiting for a fetch to complete, in the open and the dispatched batches, in no
+// particular order. At most max keys are returned, truncated reports whether there were more, max <= 0
+// returns all of them. Taking the snapshot holds up requests no longer than copying the keys.
+func (l *Loader[K, V]) PendingKeys(max int) (keys []K, truncated bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := len(l.pending)
+	if max > 0 && n > max {
+		n, truncated = max, true
+	}
+	keys = make([]K, 0, n)
+	for key := range l.pending {
+		if len(keys) == n {
+			break
+		}
+		keys = append(keys, key)
+	}
+	return keys, truncated
+}
+
+// pendingKeyStrings returns PendingKeys formatted for a debug view, see Group.Pending
+func (l *Loader[K, V]) pendingKeyStrings(max int) ([]string, bool) {
+	keys, truncated := l.PendingKeys(max)
+	formatted := make([]string, len(keys))
+	for i, key := range keys {
+		formatted[i] = fmt.Sprint(key)
+	}
+	return formatted, truncated
+}
+
+// HealthCheck returns an error wrapping ErrUnhealthy when the open batch is older than maxAge or more
+// than maxPending keys are waiting for a fetch to complete, a zero limit disables its check
+func (l *Loader[K, V]) HealthCheck(maxAge time.Duration, maxPending int) error {
+	info := l.Pending()
+	if maxAge > 0 && info.OpenBatchAge > maxAge {
+		return l.namedError(fmt.Errorf("%w: open batch is %v old", ErrUnhealthy, info.OpenBatchAge))
+	}
+	if maxPending > 0 && info.PendingKeys > maxPending {
+		return l.namedError(fmt.Errorf("%w: %d keys pending", ErrUnhealthy, info.PendingKeys))
+	}
+	return nil
+}
+
+// InFlight returns a channel that is closed once the result for key is available, when the key is pending
+// in the open batch or in one that is being fetched. It never adds the key to a batch.
+func (l *Loader[K, V]) InFlight(key K) (<-chan struct{}, bool) {
+	key, err := l.checkKey(key)
+	if err != nil {
+		return nil, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	p, ok := l.pending[key]
+	switch {
+	case !ok:
+		return nil, false
+	case p.ready != nil:
+		return p.ready, true
+	default:
+		return p.batch.done, true
+	}
+}
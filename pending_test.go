@@ -0,0 +1,128 @@
+package dataloaden
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPending(t *testing.T) {
+	release := make(chan struct{})
+	fetchFn := func(keys []int) ([]*string, []error) {
+		<-release
+		return make([]*string, len(keys)), nil
+	}
+
+	loader := NewDataLoader(fetchFn, time.Hour, 2)
+
+	if info := loader.Pending(); info != (PendingInfo{}) {
+		t.Errorf("expected no backlog, got %+v", info)
+	}
+
+	// the first two keys fill a batch that is dispatched right away, the third opens a new one
+	first := loader.LoadThunk(1)
+	loader.LoadThunk(2)
+	loader.LoadThunk(3)
+	time.Sleep(5 * time.Millisecond)
+
+	info := loader.Pending()
+	if info.OpenBatchKeys != 1 || info.DispatchedBatches != 1 || info.PendingKeys != 3 {
+		t.Errorf("unexpected backlog %+v", info)
+	}
+	if info.OpenBatchAge < 5*time.Millisecond {
+		t.Errorf("expected the open batch to be at least 5ms old, got %v", info.OpenBatchAge)
+	}
+
+	if err := loader.HealthCheck(time.Millisecond, 0); !errors.Is(err, ErrUnhealthy) {
+		t.Errorf("expected the open batch to be too old, got %v", err)
+	}
+	if err := loader.HealthCheck(0, 2); !errors.Is(err, ErrUnhealthy) {
+		t.Errorf("expected too many pending keys, got %v", err)
+	}
+	if err := loader.HealthCheck(time.Hour, 3); err != nil {
+		t.Errorf("expected a healthy backlog, got %v", err)
+	}
+
+	close(release)
+	_, _ = first()
+	info = loader.Pending()
+	if info.OpenBatchKeys != 1 || info.DispatchedBatches != 0 || info.PendingKeys != 1 {
+		t.Errorf("unexpected backlog after the dispatched batch completed %+v", info)
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	clock := newFakeClock()
+	release := make(chan struct{})
+	fetchFn := func(keys []int) ([]*string, []error) {
+		<-release
+		return make([]*string, len(keys)), nil
+	}
+	loader := NewDataLoader(fetchFn, 10*time.Millisecond, 0, WithClock[int, string](clock))
+
+	if _, ok := loader.InFlight(1); ok {
+		t.Fatal("expected nothing in flight")
+	}
+	if info := loader.Pending(); info.PendingKeys != 0 || info.OpenBatchKeys != 0 {
+		t.Fatalf("expected InFlight not to create a batch, got %+v", info)
+	}
+
+	thunk := loader.LoadThunk(1)
+	open, ok := loader.InFlight(1)
+	if !ok {
+		t.Fatal("expected the key in the open batch to be in flight")
+	}
+
+	go clock.Advance(10 * time.Millisecond)
+	dispatched, ok := loader.InFlight(1)
+	if !ok || dispatched != open {
+		t.Fatal("expected the same channel once the batch is dispatched")
+	}
+	select {
+	case <-dispatched:
+		t.Fatal("expected the channel to stay open until the fetch completes")
+	default:
+	}
+
+	close(release)
+	_, _ = thunk()
+	<-dispatched
+	<-dispatched
+	if _, ok := loader.InFlight(1); ok {
+		t.Error("expected the cached key not to be in flight")
+	}
+}
+
+func TestPendingKeys(t *testing.T) {
+	release := make(chan struct{})
+	fetchFn := func(keys []int) ([]*string, []error) {
+		<-release
+		return make([]*string, len(keys)), nil
+	}
+	loader := NewDataLoader(fetchFn, time.Hour, 2)
+
+	if keys, truncated := loader.PendingKeys(0); len(keys) != 0 || truncated {
+		t.Errorf("expected no pending keys, got %v, %v", keys, truncated)
+	}
+
+	// 1 and 2 are dispatched, 3 waits in the open batch
+	first := loader.LoadThunk(1)
+	loader.LoadThunk(2)
+	loader.LoadThunk(3)
+
+	keys, truncated := loader.PendingKeys(0)
+	slices.Sort(keys)
+	if !slices.Equal(keys, []int{1, 2, 3}) || truncated {
+		t.Errorf("expected the open and the dispatched keys, got %v, %v", keys, truncated)
+	}
+	if keys, truncated := loader.PendingKeys(2); len(keys) != 2 || !truncated {
+		t.Errorf("expected 2 keys and truncation, got %v, %v", keys, truncated)
+	}
+
+	close(release)
+	_, _ = first()
+	if keys, _ := loader.PendingKeys(0); !slices.Equal(keys, []int{3}) {
+		t.Errorf("expected only the open batch once the dispatched batch completed, got %v", keys)
+	}
+}
@@ -0,0 +1,45 @@
+package dataloaden
+
+// Prefetch adds the keys that are neither cached nor pending to the current batch without waiting for them,
+// their results land in the cache when the batch completes. Batches are dispatched on their usual schedule,
+// the keys count toward maxBatch like loaded keys. Keys rejected by the normalizer or the key filter are
+// skipped, and nothing happens once the loader is closed or while its ready gate is closed.
+func (l *Loader[K, V]) Prefetch(keys ...K) {
+	l.checkLifetime()
+	if !l.gate.isOpen() {
+		return
+	}
+
+	checked := make([]K, 0, len(keys))
+	for _, key := range keys {
+		if key, err := l.checkKey(key); err == nil {
+			checked = append(checked, key)
+		}
+	}
+
+	var full []*genericLoaderBatch[K, V]
+	l.mu.Lock()
+	for _, key := range checked {
+		if l.closed {
+			break
+		}
+		if _, ok := l.unsafeGet(key); ok {
+			continue
+		}
+		if _, ok := l.pending[key]; ok {
+			continue
+		}
+		p, filled := l.unsafeEnqueue(key)
+		if filled {
+			full = append(full, p.batch)
+			continue
+		}
+		// under WithEagerSingle a lone key waits for a waiter to dispatch it, which a prefetch never is
+		p.batch.unsafeStartWindow(l)
+	}
+	l.mu.Unlock()
+
+	for _, b := range full {
+		l.submit(b)
+	}
+}
@@ -0,0 +1,70 @@
+package dataloaden
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPrefetch(t *testing.T) {
+	clock := newFakeClock()
+	loader, rec := newStringLoader(t, 10*time.Millisecond, WithClock[int, string](clock))
+	loader.Prime(3, nil)
+
+	loader.Prefetch(1, 2, 2, 3)
+	if info := loader.Pending(); info.OpenBatchKeys != 2 {
+		t.Fatalf("expected the uncached keys in the open batch, got %+v", info)
+	}
+	if rec.callCount() != 0 {
+		t.Fatal("expected prefetch not to dispatch before the window elapsed")
+	}
+
+	clock.Advance(10 * time.Millisecond)
+	rec.mu.Lock()
+	calls := rec.calls
+	rec.mu.Unlock()
+	if len(calls) != 1 || !slices.Equal(calls[0], []int{1, 2}) {
+		t.Fatalf("expected one fetch of 1 and 2, got %v", calls)
+	}
+
+	for _, key := range []int{1, 2} {
+		if v, err := loader.Load(key); err != nil || *v != "v"+strconv.Itoa(key) {
+			t.Errorf("expected the prefetched value for %d, got %v, %v", key, v, err)
+		}
+	}
+	if stats := loader.Stats(); stats.CacheHits != 2 || stats.Loads != 2 {
+		t.Errorf("expected the loads to be cache hits, got %+v", stats)
+	}
+
+	// prefetching cached keys is a no-op
+	loader.Prefetch(1, 3)
+	if info := loader.Pending(); info.OpenBatchKeys != 0 {
+		t.Errorf("expected no batch for cached keys, got %+v", info)
+	}
+}
+
+func TestPrefetchMaxBatch(t *testing.T) {
+	rec := &recordingFetch{}
+	loader := NewDataLoader(rec.fetch, time.Hour, 2, WithEagerSingle[int, string]())
+
+	// the first two keys fill a batch, the third waits in the next one
+	loader.Prefetch(1, 2, 3)
+	deadline := time.Now().Add(5 * time.Second)
+	for rec.callCount() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the full batch to be dispatched")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// a load joins the pending key instead of fetching it again
+	thunk := loader.LoadThunk(3)
+	loader.Flush()
+	if v, err := thunk(); err != nil || *v != "v3" {
+		t.Errorf("expected v3, got %v, %v", v, err)
+	}
+	if stats := loader.Stats(); stats.Coalesced != 1 {
+		t.Errorf("expected the load to join the prefetched key, got %+v", stats)
+	}
+}
@@ -0,0 +1,100 @@
+package dataloaden
+
+import (
+	"iter"
+	"strings"
+)
+
+// prefixStore holds the entries of string keys that share long prefixes, e.g. "tenant:0000042:user:17".
+// Every key is split after the last separator: the prefix is interned in a table shared by all keys, the
+// entry is stored under the id of the prefix and a copy of the rest of the key. Keys are rebuilt in full
+// for iteration. Interned prefixes are kept until the cache is cleared.
+type prefixStore[V any] struct {
+	sep string
+
+	// the ids of the interned prefixes and the prefixes by id, id 0 is the empty prefix
+	ids      map[string]uint32
+	prefixes []string
+
+	entries map[prefixKey]cacheEntry[V]
+}
+
+type prefixKey struct {
+	prefix uint32
+	rest   string
+}
+
+func newPrefixStore[V any](sep string) *prefixStore[V] {
+	return &prefixStore[V]{
+		sep:      sep,
+		ids:      map[string]uint32{"": 0},
+		prefixes: []string{""},
+		entries:  map[prefixKey]cacheEntry[V]{},
+	}
+}
+
+// split returns the prefix of key, up to and including the last separator, and the rest
+func (s *prefixStore[V]) split(key string) (prefix, rest string) {
+	if s.sep == "" {
+		return "", key
+	}
+	i := strings.LastIndex(key, s.sep)
+	if i < 0 {
+		return "", key
+	}
+	i += len(s.sep)
+	return key[:i], key[i:]
+}
+
+// lookup returns the stored form of key, false when its prefix was never interned
+func (s *prefixStore[V]) lookup(key string) (prefixKey, bool) {
+	prefix, rest := s.split(key)
+	id, ok := s.ids[prefix]
+	return prefixKey{prefix: id, rest: rest}, ok
+}
+
+func (s *prefixStore[V]) get(key string) (cacheEntry[V], bool) {
+	k, ok := s.lookup(key)
+	if !ok {
+		return cacheEntry[V]{}, false
+	}
+	it, ok := s.entries[k]
+	return it, ok
+}
+
+func (s *prefixStore[V]) set(key string, entry cacheEntry[V]) {
+	prefix, rest := s.split(key)
+	id, ok := s.ids[prefix]
+	if !ok {
+		id = uint32(len(s.prefixes))
+		prefix = strings.Clone(prefix)
+		s.ids[prefix] = id
+		s.prefixes = append(s.prefixes, prefix)
+	}
+	k := prefixKey{prefix: id, rest: rest}
+	if _, found := s.entries[k]; !found {
+		// the key usually lives in memory the caller drops, keeping a slice of it would keep all of it
+		k.rest = strings.Clone(rest)
+	}
+	s.entries[k] = entry
+}
+
+func (s *prefixStore[V]) delete(key string) {
+	if k, ok := s.lookup(key); ok {
+		delete(s.entries, k)
+	}
+}
+
+func (s *prefixStore[V]) len() int {
+	return len(s.entries)
+}
+
+func (s *prefixStore[V]) all() iter.Seq2[string, cacheEntry[V]] {
+	return func(yield func(string, cacheEntry[V]) bool) {
+		for k, it := range s.entries {
+			if !yield(s.prefixes[k.prefix]+k.rest, it) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package dataloaden
+
+import (
+	"fmt"
+	"maps"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrefixStore(t *testing.T) {
+	v := "v"
+	entry := cacheEntry[string]{value: &v, found: true}
+	s := newPrefixStore[string](":")
+	keys := []string{"tenant:1:user:1", "tenant:1:user:2", "tenant:2:user:1", "plain", "", ":", "trailing:", "a::b"}
+	for _, key := range keys {
+		s.set(key, entry)
+	}
+	s.set("tenant:1:user:1", entry)
+
+	if n := s.len(); n != len(keys) {
+		t.Errorf("expected %d entries, got %d", len(keys), n)
+	}
+	for _, key := range keys {
+		if _, ok := s.get(key); !ok {
+			t.Errorf("expected %q to be stored", key)
+		}
+	}
+	for _, key := range []string{"tenant:1:user:3", "tenant:3:user:1", "tenant:1", "a:b", "trailing"} {
+		if _, ok := s.get(key); ok {
+			t.Errorf("expected %q not to be stored", key)
+		}
+	}
+	// the empty prefix, "tenant:1:user:", "tenant:2:user:", ":", "trailing:" and "a::"
+	if n := len(s.prefixes); n != 6 {
+		t.Errorf("expected shared prefixes to be interned once, got %q", s.prefixes)
+	}
+
+	got := slices.Sorted(maps.Keys(maps.Collect(s.all())))
+	if want := slices.Sorted(slices.Values(keys)); !slices.Equal(got, want) {
+		t.Errorf("expected the full keys, got %q", got)
+	}
+
+	s.delete("tenant:1:user:2")
+	s.delete("tenant:9:user:2")
+	if _, ok := s.get("tenant:1:user:2"); ok || s.len() != len(keys)-1 {
+		t.Errorf("expected the deleted key to be gone, got %d entries", s.len())
+	}
+
+	whole := newPrefixStore[string]("")
+	whole.set("tenant:1", entry)
+	if _, ok := whole.get("tenant:1"); !ok || len(whole.prefixes) != 1 {
+		t.Errorf("expected an empty separator to store keys whole, got %q", whole.prefixes)
+	}
+}
+
+func TestKeyPrefixCompaction(t *testing.T) {
+	rec := &recordingFetch{}
+	loader := NewDataLoader(func(keys []string) ([]*string, []error) {
+		ids := make([]int, len(keys))
+		for i, key := range keys {
+			ids[i], _ = strconv.Atoi(key[strings.LastIndex(key, ":")+1:])
+		}
+		return rec.fetch(ids)
+	}, time.Millisecond, 0, WithKeyPrefixCompaction[string](":"))
+
+	keys := make([]string, 20)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("tenant:%d:user:%d", i%2, i)
+	}
+	if _, errs := loader.LoadAll(keys); errs[0] != nil {
+		t.Fatal(errs[0])
+	}
+	values, _ := loader.LoadAll(keys)
+	for i, v := range values {
+		if *v != "v"+strconv.Itoa(i) {
+			t.Errorf("%s: unexpected value %s", keys[i], *v)
+		}
+	}
+	if n := rec.callCount(); n != 1 {
+		t.Errorf("expected every key to be cached, got %d fetches", n)
+	}
+
+	p := "primed"
+	if !loader.Prime("tenant:9:user:100", &p) || loader.Prime("tenant:0:user:0", &p) {
+		t.Error("expected only the new key to be primed")
+	}
+	var cleared []string
+	if n := loader.ClearWhere(func(key string, _ *string) bool {
+		if strings.HasPrefix(key, "tenant:1:") {
+			cleared = append(cleared, key)
+			return true
+		}
+		return false
+	}); n != 10 {
+		t.Errorf("expected 10 entries cleared, got %d", n)
+	}
+	for _, key := range cleared {
+		if !slices.Contains(keys, key) {
+			t.Errorf("expected the predicate to see full keys, got %q", key)
+		}
+	}
+	var v string
+	if !loader.PeekInto("tenant:9:user:100", &v) || v != "primed" || loader.PeekInto("tenant:1:user:1", &v) {
+		t.Error("expected only the matched keys to be cleared")
+	}
+}
+
+// BenchmarkPrefixCacheMemory fills a map cache and a prefix compacted cache with 1M keys of 1000 tenants,
+// once with short and once with long shared prefixes, and reports the heap each of them takes per entry,
+// keys included.
+// Run it with -bench PrefixCacheMemory -benchtime 1x.
+func BenchmarkPrefixCacheMemory(b *testing.B) {
+	const n = 1_000_000
+	shapes := []struct {
+		name, sep, format string
+	}{
+		{"short", ":", "tenant:%07d:user:%d"},
+		{"long", "/", "projects/acme-production/locations/europe-west1/datasets/%07d/tables/%d"},
+	}
+	v := "v"
+	entry := cacheEntry[string]{value: &v, found: true}
+
+	for _, shape := range shapes {
+		stores := map[string]func() entryStore[string, string]{
+			"map":    func() entryStore[string, string] { return mapStore[string, string]{} },
+			"prefix": func() entryStore[string, string] { return newPrefixStore[string](shape.sep) },
+		}
+		for _, name := range []string{"map", "prefix"} {
+			b.Run(shape.name+"/"+name, func(b *testing.B) {
+				var before, after runtime.MemStats
+				for b.Loop() {
+					runtime.GC()
+					runtime.ReadMemStats(&before)
+					s := stores[name]()
+					for i := range n {
+						// built for every set like keys decoded from requests, so the cache holds the only copy
+						s.set(fmt.Sprintf(shape.format, i%1000, i), entry)
+					}
+					runtime.GC()
+					runtime.ReadMemStats(&after)
+					runtime.KeepAlive(s)
+				}
+				b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/n, "B/entry")
+			})
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package dataloaden
+
+import "context"
+
+// PrimePolicy decides which value wins when a key is primed while it is pending in a batch, including while
+// its fetch is in flight: a fetch that read the key before it was primed may return an older value than
+// the primed one. Either way the waiters of the key and the cache agree on the value afterwards.
+type PrimePolicy uint8
+
+const (
+	// PreferFetched delivers the fetched value to the waiters of the key and writes it over the primed one,
+	// the last write wins
+	PreferFetched PrimePolicy = iota
+
+	// PreferPrimed delivers the primed value to the waiters of the key and keeps it cached. The key is left
+	// out of the fetch when its batch has not been dispatched yet.
+	PreferPrimed
+)
+
+// primeEntry makes the cache entry for a value primed at epoch
+func primeEntry[V any](value *V, source EntrySource, epoch uint64) cacheEntry[V] {
+	entry := cacheEntry[V]{found: true, source: source, epoch: epoch}
+	if value != nil {
+		// to make a copy when writing to the cache, it's easy to pass a pointer in from a loop var
+		// and end up with the whole cache pointing to the same value.
+		entry.value = cloneValue(value)
+	}
+	return entry
+}
+
+// unsafePrimePending primes a key that is pending in a batch under PreferPrimed, the primed entry overrides
+// the result of the fetch for the waiters of the key
+func (l *Loader[K, V]) unsafePrimePending(p batchPosition[K, V], key K, value *V) bool {
+	if _, primed := p.batch.overrides[p.pos]; primed {
+		return false
+	}
+	if p.batch.overrides == nil {
+		p.batch.overrides = map[int]cacheEntry[V]{}
+	}
+	entry := primeEntry(value, SourcePrime, l.epoch.Load())
+	p.batch.overrides[p.pos] = entry
+	l.unsafeSet(key, entry)
+	return true
+}
+
+// fetchUnprimed fetches the keys of the batch that were not primed while it collected them, see PreferPrimed.
+// The results are aligned with all keys of the batch.
+func (b *genericLoaderBatch[K, V]) fetchUnprimed(ctx context.Context, l *Loader[K, V]) ([]*V, []error, error) {
+	l.mu.Lock()
+	var keys []K
+	var positions []int
+	if len(b.overrides) > 0 {
+		for pos, key := range b.keys {
+			if _, primed := b.overrides[pos]; !primed {
+				keys = append(keys, key)
+				positions = append(positions, pos)
+			}
+		}
+	}
+	primed := len(b.overrides) > 0
+	l.mu.Unlock()
+
+	if !primed {
+		l.count(&l.stats.fetchedKeys, uint64(len(b.keys)))
+		return l.fetchClassified(ctx, b, b.keys)
+	}
+	l.count(&l.stats.fetchedKeys, uint64(len(keys)))
+	if len(keys) == 0 {
+		return nil, nil, nil
+	}
+
+	data, errs, err := l.fetchClassified(ctx, b, keys)
+	allData := make([]*V, len(b.keys))
+	for i, pos := range positions {
+		if i < len(data) {
+			allData[pos] = data[i]
+		}
+	}
+	if len(errs) != len(keys) {
+		return allData, errs, err
+	}
+	allErrs := make([]error, len(b.keys))
+	for i, pos := range positions {
+		allErrs[pos] = errs[i]
+	}
+	return allData, allErrs, err
+}
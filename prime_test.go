@@ -0,0 +1,131 @@
+package dataloaden
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPrimePolicy(t *testing.T) {
+	primed := "primed"
+	cached := func(t *testing.T, loader *Loader[int, string], key int) string {
+		t.Helper()
+		var v string
+		if !loader.PeekInto(key, &v) {
+			t.Fatalf("expected key %d to be cached", key)
+		}
+		return v
+	}
+
+	for _, tt := range []struct {
+		name   string
+		policy PrimePolicy
+		want   string
+	}{
+		{"prefer fetched", PreferFetched, "v1"},
+		{"prefer primed", PreferPrimed, "primed"},
+	} {
+		t.Run(tt.name+"/before dispatch", func(t *testing.T) {
+			clock := newFakeClock()
+			loader, rec := newStringLoader(t, 10*time.Millisecond,
+				WithClock[int, string](clock), WithPrimePolicy[int, string](tt.policy))
+
+			thunk := loader.LoadThunk(1)
+			other := loader.LoadThunk(2)
+			if !loader.Prime(1, &primed) {
+				t.Fatal("expected the pending key to be primed")
+			}
+			clock.Advance(10 * time.Millisecond)
+
+			if v, err := thunk(); err != nil || *v != tt.want {
+				t.Errorf("expected %q to be delivered, got %v, %v", tt.want, v, err)
+			}
+			_, _ = other()
+			if v := cached(t, loader, 1); v != tt.want {
+				t.Errorf("expected %q to be cached, got %q", tt.want, v)
+			}
+
+			wantKeys := 2
+			if tt.policy == PreferPrimed {
+				wantKeys = 1
+			}
+			if len(rec.calls) != 1 || len(rec.calls[0]) != wantKeys {
+				t.Errorf("expected one fetch of %d keys, got %v", wantKeys, rec.calls)
+			}
+		})
+
+		t.Run(tt.name+"/during fetch", func(t *testing.T) {
+			clock := newFakeClock()
+			started := make(chan struct{})
+			release := make(chan struct{})
+			rec := &recordingFetch{}
+			fetchFn := func(keys []int) ([]*string, []error) {
+				close(started)
+				<-release
+				return rec.fetch(keys)
+			}
+			loader := NewDataLoader(fetchFn, 10*time.Millisecond, 0,
+				WithClock[int, string](clock), WithPrimePolicy[int, string](tt.policy))
+
+			thunk := loader.LoadThunk(1)
+			go clock.Advance(10 * time.Millisecond)
+			<-started
+			loader.Prime(1, &primed)
+			close(release)
+
+			if v, err := thunk(); err != nil || *v != tt.want {
+				t.Errorf("expected %q to be delivered, got %v, %v", tt.want, v, err)
+			}
+			if v := cached(t, loader, 1); v != tt.want {
+				t.Errorf("expected %q to be cached, got %q", tt.want, v)
+			}
+		})
+
+		t.Run(tt.name+"/after fetch", func(t *testing.T) {
+			clock := newFakeClock()
+			loader, _ := newStringLoader(t, 10*time.Millisecond,
+				WithClock[int, string](clock), WithPrimePolicy[int, string](tt.policy))
+
+			thunk := loader.LoadThunk(1)
+			done, _ := loader.InFlight(1)
+			clock.Advance(10 * time.Millisecond)
+			<-done
+
+			if loader.Prime(1, &primed) {
+				t.Error("expected the fetched key not to be primed again")
+			}
+			if v, err := thunk(); err != nil || *v != "v1" {
+				t.Errorf("expected the fetched value, got %v, %v", v, err)
+			}
+			if v := cached(t, loader, 1); v != "v1" {
+				t.Errorf("expected the fetched value to stay cached, got %q", v)
+			}
+		})
+	}
+}
+
+func TestPreferPrimedStreaming(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fetchFn := func(keys []int, emit func(i int, v *string, err error)) {
+		close(started)
+		<-release
+		for i := range keys {
+			v := "fetched"
+			emit(i, &v, nil)
+		}
+	}
+	loader := NewStreamingDataLoader(fetchFn, time.Millisecond, 0, WithPrimePolicy[int, string](PreferPrimed))
+
+	thunk := loader.LoadThunk(1)
+	<-started
+	primed := "primed"
+	loader.Prime(1, &primed)
+	close(release)
+
+	if v, err := thunk(); err != nil || *v != "primed" {
+		t.Errorf("expected the primed value, got %v, %v", v, err)
+	}
+	if v, _ := loader.Load(1); *v != "primed" {
+		t.Errorf("expected the primed value to stay cached, got %q", *v)
+	}
+}
@@ -0,0 +1,78 @@
+package dataloaden
+
+import "sync/atomic"
+
+// promoteQueue is how many batches may wait to be primed into the parent before further batches are dropped
+const promoteQueue = 64
+
+// promoter primes the results of a loader's batches into a parent loader in the background, see WithPromote
+type promoter[K comparable, V any] struct {
+	parent    DataLoader[K, V]
+	shareable func(key K, value *V) bool
+
+	// batches waiting to be primed, drained by at most one goroutine that exits once the queue is empty
+	queue    chan []promotion[K, V]
+	draining atomic.Bool
+}
+
+type promotion[K comparable, V any] struct {
+	key   K
+	value *V
+}
+
+// promote hands the values the batch fetched to the promoter, it never blocks: when the queue is full the
+// batch is dropped
+func (b *genericLoaderBatch[K, V]) promote(l *Loader[K, V]) {
+	p := l.promoter
+	if p == nil {
+		return
+	}
+
+	var entries []promotion[K, V]
+	for pos, key := range b.keys {
+		// primed values did not come from the fetch, failed keys have nothing to share
+		if _, primed := b.overrides[pos]; primed || b.entry(pos).value == nil {
+			continue
+		}
+		if b.keyError(pos) != nil {
+			continue
+		}
+		entries = append(entries, promotion[K, V]{key: key, value: b.data[pos]})
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	select {
+	case p.queue <- entries:
+	default:
+		l.count(&l.stats.promotionsDropped, uint64(len(entries)))
+		return
+	}
+	if p.draining.CompareAndSwap(false, true) {
+		go p.drain(l)
+	}
+}
+
+func (p *promoter[K, V]) drain(l *Loader[K, V]) {
+	for {
+		select {
+		case entries := <-p.queue:
+			for _, e := range entries {
+				if p.shareable != nil && !p.shareable(e.key, e.value) {
+					l.count(&l.stats.promotionsSkipped, 1)
+					continue
+				}
+				p.parent.Prime(e.key, e.value)
+				l.count(&l.stats.promoted, 1)
+			}
+		default:
+			p.draining.Store(false)
+			// a batch queued between the empty check and the store is picked up here, unless a new
+			// drain already took over
+			if len(p.queue) == 0 || !p.draining.CompareAndSwap(false, true) {
+				return
+			}
+		}
+	}
+}
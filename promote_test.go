@@ -0,0 +1,135 @@
+package dataloaden
+
+import (
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// awaitStats polls the loader's stats until done reports true
+func awaitStats(t *testing.T, loader *Loader[int, string], done func(LoaderStats) bool) LoaderStats {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stats := loader.Stats()
+		if done(stats) {
+			return stats
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stats did not settle, got %+v", stats)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPromote(t *testing.T) {
+	even := func(key int, _ *string) bool { return key%2 == 0 }
+
+	t.Run("shareable values", func(t *testing.T) {
+		parent, parentFetch := newStringLoader(t, time.Millisecond)
+		child, _ := newStringLoader(t, time.Millisecond, WithPromote(parent, even))
+
+		_, _ = child.LoadAll([]int{1, 2, 3, 4})
+		stats := awaitStats(t, child, func(s LoaderStats) bool { return s.Promoted+s.PromotionsSkipped == 4 })
+		if stats.Promoted != 2 || stats.PromotionsSkipped != 2 {
+			t.Errorf("expected 2 promoted and 2 skipped values, got %+v", stats)
+		}
+
+		var v string
+		if !parent.PeekInto(2, &v) || v != "v2" || !parent.PeekInto(4, &v) {
+			t.Errorf("expected the even keys to be primed into the parent")
+		}
+		if parent.PeekInto(1, &v) || parent.PeekInto(3, &v) {
+			t.Errorf("expected the odd keys to stay out of the parent")
+		}
+		if _, _ = parent.Load(2); parentFetch.callCount() != 0 {
+			t.Errorf("expected the parent to answer from its cache, got %d fetches", parentFetch.callCount())
+		}
+	})
+
+	t.Run("failed keys are not promoted", func(t *testing.T) {
+		parent, _ := newStringLoader(t, time.Millisecond)
+		errOdd := errors.New("odd key")
+		child := NewStreamingDataLoader(func(keys []int, emit func(int, *string, error)) {
+			for i, k := range keys {
+				if k%2 != 0 {
+					emit(i, nil, errOdd)
+					continue
+				}
+				v := "v" + strconv.Itoa(k)
+				emit(i, &v, nil)
+			}
+		}, time.Millisecond, 0, WithPromote(parent, nil))
+
+		_, _ = child.LoadAll([]int{1, 2, 3, 4})
+		stats := awaitStats(t, child, func(s LoaderStats) bool { return s.Promoted == 2 })
+		if stats.PromotionsSkipped != 0 {
+			t.Errorf("expected no skipped values, got %+v", stats)
+		}
+		var v string
+		if parent.PeekInto(1, &v) || !parent.PeekInto(2, &v) {
+			t.Errorf("expected only the loaded keys in the parent")
+		}
+	})
+
+	t.Run("batch errors fail every key", func(t *testing.T) {
+		parent, _ := newStringLoader(t, time.Millisecond)
+		errBatch := errors.New("backend down")
+		var failing atomic.Bool
+		failing.Store(true)
+		child := NewDataLoader(func(keys []int) ([]*string, []error) {
+			data, _ := (&recordingFetch{}).fetch(keys)
+			if failing.Load() {
+				return data, []error{errBatch}
+			}
+			return data, nil
+		}, time.Millisecond, 0, WithKeyErrors[int, string](), WithPromote(parent, nil))
+
+		_, errs := child.LoadAll([]int{1, 2, 3})
+		for i, err := range errs {
+			if !errors.Is(err, errBatch) {
+				t.Errorf("expected key %d to fail, got %v", i+1, err)
+			}
+		}
+		// batches are promoted in order, once the next one is in the parent the failed one was handled
+		failing.Store(false)
+		_, _ = child.Load(4)
+		awaitStats(t, child, func(s LoaderStats) bool { return s.Promoted == 1 })
+		var v string
+		for _, key := range []int{1, 2, 3} {
+			if parent.PeekInto(key, &v) {
+				t.Errorf("expected the failed key %d to stay out of the parent", key)
+			}
+		}
+	})
+
+	t.Run("slow parent", func(t *testing.T) {
+		parent, _ := newStringLoader(t, time.Millisecond)
+		entered, release := make(chan struct{}), make(chan struct{})
+		blocking := func(key int, _ *string) bool {
+			if key == 0 {
+				close(entered)
+				<-release
+			}
+			return true
+		}
+		child, _ := newStringLoader(t, 0, WithPromote(parent, blocking))
+
+		// the first batch holds up the drain, the next promoteQueue batches fill the queue
+		_, _ = child.Load(0)
+		<-entered
+		for key := 1; key <= promoteQueue+5; key++ {
+			if _, err := child.Load(key); err != nil {
+				t.Fatalf("expected loads to go on while the parent is behind, got %v", err)
+			}
+		}
+		if stats := child.Stats(); stats.PromotionsDropped != 5 {
+			t.Errorf("expected 5 dropped values, got %+v", stats)
+		}
+
+		close(release)
+		awaitStats(t, child, func(s LoaderStats) bool { return s.Promoted == promoteQueue+1 })
+	})
+}
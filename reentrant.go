@@ -0,0 +1,58 @@
+package dataloaden
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrReentrantLoad is returned to loads from within the loader's own fetch under ReentrantFail
+var ErrReentrantLoad = errors.New("dataloaden: load from within the loader's own fetch")
+
+// ReentrantPolicy decides what happens to loads of keys that are not cached from within the loader's own
+// fetch, e.g. a fetch resolving entities that reference entities of the same loader. Waiting for a batch
+// there could wait for the very fetch that is loading, which never returns. Only loads made with the
+// context of the fetch are recognized, see WithReentrantPolicy.
+type ReentrantPolicy uint8
+
+const (
+	// ReentrantFetch fetches the key right away in a batch of its own, the default
+	ReentrantFetch ReentrantPolicy = iota
+
+	// ReentrantFail fails the load with ErrReentrantLoad
+	ReentrantFail
+)
+
+// fetchingKey is the key of the context value that marks the context of a fetch of loader, and every
+// context derived from it
+type fetchingKey struct {
+	loader any
+}
+
+// markFetching returns ctx marked as the context of a fetch of l
+func (l *Loader[K, V]) markFetching(ctx context.Context) context.Context {
+	return context.WithValue(ctx, fetchingKey{l}, struct{}{})
+}
+
+// withinFetch reports whether ctx is the context of a fetch of l. It is only asked when a load misses the
+// cache, cache hits never need the answer.
+func (l *Loader[K, V]) withinFetch(ctx context.Context) bool {
+	return ctx.Value(fetchingKey{l}) != nil
+}
+
+// unsafeReentrant handles the load of a key that is not cached from within the loader's own fetch, by
+// dispatching it in a batch of its own that the caller has to end, or failing it
+func (l *Loader[K, V]) unsafeReentrant(key K) (req loadRequest[K, V], full bool) {
+	if l.reentrantPolicy == ReentrantFail {
+		return loadRequest[K, V]{key: key, err: l.namedError(fmt.Errorf("%w: key %v", ErrReentrantLoad, key))}, false
+	}
+	b := l.newBatch()
+	b.keys = []K{key}
+	req = loadRequest[K, V]{key: key, batch: b}
+	if l.stream != nil {
+		req.ready = make(chan struct{})
+		b.ready = []chan struct{}{req.ready}
+	}
+	l.unsafeDispatch(b, ReentrantFlush)
+	return req, true
+}
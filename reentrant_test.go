@@ -0,0 +1,169 @@
+package dataloaden
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// parentFetch fetches entities that reference their parent, key-1, through the same loader: "v2<v1<v0"
+func parentFetch(loader **Loader[int, string]) func(ctx context.Context, keys []int) ([]*string, []error) {
+	return func(ctx context.Context, keys []int) ([]*string, []error) {
+		data := make([]*string, len(keys))
+		errs := make([]error, len(keys))
+		for i, key := range keys {
+			v := "v" + strconv.Itoa(key)
+			if key > 0 {
+				parent, err := (*loader).LoadCtx(ctx, key-1)
+				if err != nil {
+					errs[i] = err
+					continue
+				}
+				v += "<" + *parent
+			}
+			data[i] = &v
+		}
+		return data, errs
+	}
+}
+
+// withinDeadline fails the test when load does not return, instead of hanging it
+func withinDeadline[T any](t *testing.T, load func() T) T {
+	t.Helper()
+	done := make(chan T, 1)
+	go func() {
+		done <- load()
+	}()
+	select {
+	case result := <-done:
+		return result
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the reentrant load not to deadlock")
+		panic("unreachable")
+	}
+}
+
+func TestReentrantLoad(t *testing.T) {
+	t.Run("fetches standalone", func(t *testing.T) {
+		var loader *Loader[int, string]
+		loader = NewDataLoaderCtx(parentFetch(&loader), time.Millisecond, 0)
+		var g Group
+		_ = g.Register("entities", loader)
+		g.SetMaxConcurrentBatches(1)
+
+		// 1 is pending in the batch whose fetch loads it
+		values := withinDeadline(t, func() []*string {
+			values, errs := loader.LoadAll([]int{2, 1})
+			if err := errors.Join(errs...); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			return values
+		})
+		if *values[0] != "v2<v1<v0" || *values[1] != "v1<v0" {
+			t.Errorf("unexpected values %q, %q", *values[0], *values[1])
+		}
+		if n := loader.Stats().BatchesByTrigger[ReentrantFlush]; n != 2 {
+			t.Errorf("expected the parents to be fetched in batches of their own, got %d", n)
+		}
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		var loader *Loader[int, string]
+		loader = NewDataLoaderCtx(parentFetch(&loader), 0, 0, WithReentrantPolicy[int, string](ReentrantFail))
+		result := withinDeadline(t, func() Result[*string] { return loader.LoadResult(1) })
+		if err := result.Err; !errors.Is(err, ErrReentrantLoad) {
+			t.Errorf("expected ErrReentrantLoad, got %v", err)
+		}
+
+		// cached keys are answered as usual
+		v := "p0"
+		loader.Prime(0, &v)
+		if v, err := loader.Load(1); err != nil || *v != "v1<p0" {
+			t.Errorf("expected the cached parent, got %v, %v", v, err)
+		}
+	})
+
+	t.Run("from other goroutines", func(t *testing.T) {
+		var loader *Loader[int, string]
+		parents := parentFetch(&loader)
+		loader = NewDataLoaderCtx(func(ctx context.Context, keys []int) ([]*string, []error) {
+			// the keys are resolved by workers, which load with the context of the fetch
+			data := make([]*string, len(keys))
+			errs := make([]error, len(keys))
+			var wg sync.WaitGroup
+			for i, key := range keys {
+				wg.Go(func() {
+					d, e := parents(ctx, []int{key})
+					data[i], errs[i] = d[0], e[0]
+				})
+			}
+			wg.Wait()
+			return data, errs
+		}, time.Millisecond, 0)
+		values := withinDeadline(t, func() []*string {
+			values, _ := loader.LoadAll([]int{2, 1})
+			return values
+		})
+		if values[0] == nil || *values[0] != "v2<v1<v0" {
+			t.Errorf("unexpected value %v", values[0])
+		}
+	})
+
+	t.Run("other loaders", func(t *testing.T) {
+		// a load of another loader from within the fetch waits for its batch as usual
+		other := NewDataLoader((&recordingFetch{}).fetch, time.Millisecond, 0)
+		loader := NewDataLoaderCtx(func(ctx context.Context, keys []int) ([]*string, []error) {
+			return other.LoadAllCtx(ctx, keys)
+		}, 0, 0)
+		if result := withinDeadline(t, func() Result[*string] { return loader.LoadResult(1) }); result.Err != nil {
+			t.Errorf("unexpected error %v", result.Err)
+		}
+		if n := other.Stats().BatchesByTrigger[ReentrantFlush]; n != 0 {
+			t.Errorf("expected no reentrant batches of the other loader, got %d", n)
+		}
+	})
+
+	t.Run("single fetch", func(t *testing.T) {
+		var loader *Loader[int, string]
+		fetch := parentFetch(&loader)
+		loader = NewSingleFetchLoader(func(ctx context.Context, key int) (*string, error) {
+			data, errs := fetch(ctx, []int{key})
+			return data[0], errs[0]
+		}, 2)
+		values := withinDeadline(t, func() []*string {
+			values, _ := loader.LoadAll([]int{3, 2, 1})
+			return values
+		})
+		if values[0] == nil || *values[0] != "v3<v2<v1<v0" {
+			t.Errorf("unexpected value %v", values[0])
+		}
+	})
+}
+
+// BenchmarkLoadCachedDuringFetch loads a cached key while the fetch of another key is running, cache hits
+// must not pay for recognizing reentrant loads
+func BenchmarkLoadCachedDuringFetch(b *testing.B) {
+	started, release := make(chan struct{}), make(chan struct{})
+	loader := NewDataLoader(func(keys []int) ([]*string, []error) {
+		if keys[0] == 1 {
+			close(started)
+			<-release
+		}
+		return (&recordingFetch{}).fetch(keys)
+	}, 0, 0)
+	_, _ = loader.Load(0)
+	thunk := loader.LoadThunk(1)
+	<-started
+	defer func() {
+		close(release)
+		_, _ = thunk()
+	}()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		_, _ = loader.Load(0)
+	}
+}
@@ -0,0 +1,31 @@
+package dataloaden
+
+import "context"
+
+// Result is the outcome of loading a single key
+type Result[T any] struct {
+	Value T
+	Err   error
+
+	// Found is true when the fetch or a Prime produced an entry for the key and false when the key
+	// was absent from the fetched results or the load failed. A fetch that returns a shorter result
+	// slice or nil at the position of a key leaves the key absent, while a Prime with a nil value
+	// caches nil as a found entry.
+	Found bool
+}
+
+// LoadResult loads a key like Load, and additionally reports whether the key was found
+func (l *Loader[K, V]) LoadResult(key K) Result[*V] {
+	return l.load(context.Background(), key)
+}
+
+// LoadAllResult loads many keys like LoadAll, and additionally reports whether each key was found
+func (l *Loader[K, V]) LoadAllResult(keys []K) []Result[*V] {
+	requests := l.requestAll(context.Background(), keys)
+
+	results := make([]Result[*V], len(keys))
+	for i, req := range requests {
+		results[i] = req.wait(l)
+	}
+	return results
+}
@@ -0,0 +1,100 @@
+package dataloaden
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadResultFound(t *testing.T) {
+	fetchFn := func(keys []int) ([]*string, []error) {
+		// key 0 has a value, key 1 is nil and key 2 is cut off by the short result slice
+		v := "A"
+		return []*string{&v, nil}, nil
+	}
+
+	loader := NewDataLoader(fetchFn, 1*time.Millisecond, 10)
+	if !loader.Prime(3, nil) {
+		t.Fatalf("expected Prime to return true")
+	}
+
+	for i := 0; i < 2; i++ {
+		results := loader.LoadAllResult([]int{0, 1, 2, 3})
+
+		if r := results[0]; !r.Found || r.Err != nil || *r.Value != "A" {
+			t.Errorf("key 0: expected a found value, got %+v", r)
+		}
+		if r := results[1]; r.Found || r.Err != nil || r.Value != nil {
+			t.Errorf("key 1: expected a missing value, got %+v", r)
+		}
+		if r := results[2]; r.Found || r.Err != nil || r.Value != nil {
+			t.Errorf("key 2: expected a missing value, got %+v", r)
+		}
+		if r := results[3]; !r.Found || r.Err != nil || r.Value != nil {
+			t.Errorf("key 3: expected a primed nil value, got %+v", r)
+		}
+	}
+
+	// the cached keys are answered under a single acquisition of the lock
+	var acquisitions atomic.Uint64
+	loader.mu.acquisitions = &acquisitions
+	loader.LoadAllResult([]int{0, 1, 2, 3})
+	if n := acquisitions.Load(); n != 1 {
+		t.Errorf("expected LoadAllResult to lock the loader once, got %d", n)
+	}
+	loader.mu.acquisitions = nil
+
+	if r := loader.LoadResult(0); !r.Found || *r.Value != "A" {
+		t.Errorf("expected a found value from the cache, got %+v", r)
+	}
+	if v, err := loader.Load(3); v != nil || err != nil {
+		t.Errorf("expected Load to keep returning nil, nil for a primed nil, got %v, %v", v, err)
+	}
+}
+
+func TestLoadResultError(t *testing.T) {
+	fetchFn := func(keys []int) ([]*string, []error) {
+		return nil, []error{errors.New("boom")}
+	}
+
+	loader := NewDataLoader(fetchFn, 1*time.Millisecond, 10)
+
+	r := loader.LoadResult(1)
+	if r.Err == nil || r.Found {
+		t.Errorf("expected an error and no entry, got %+v", r)
+	}
+}
+
+func TestMissingValueError(t *testing.T) {
+	errNotFound := errors.New("not found")
+	fetchFn := func(keys []int) ([]*string, []error) {
+		// key 1 has a value, key 2 is nil and key 3 is cut off by the short result slice
+		v := "B"
+		return []*string{&v, nil}, nil
+	}
+
+	var missing []int
+	loader := NewDataLoader(fetchFn, 1*time.Millisecond, 10, WithMissingValueError[int, string](func(key int) error {
+		missing = append(missing, key)
+		return errNotFound
+	}))
+	loader.Prime(4, nil)
+
+	for i := 0; i < 2; i++ {
+		values, errs := loader.LoadAll([]int{1, 2, 3, 4})
+		if errs[0] != nil || *values[0] != "B" {
+			t.Errorf("key 1: expected B, got %v, %v", values[0], errs[0])
+		}
+		if !errors.Is(errs[1], errNotFound) || !errors.Is(errs[2], errNotFound) {
+			t.Errorf("expected missing keys to fail, got %v and %v", errs[1], errs[2])
+		}
+		if errs[3] != nil {
+			t.Errorf("key 4: expected a primed nil not to fail, got %v", errs[3])
+		}
+	}
+
+	if len(missing) != 4 || missing[0] != 2 || missing[1] != 3 {
+		t.Errorf("expected the error to be produced for keys 2 and 3 on every load, got %v", missing)
+	}
+}
@@ -0,0 +1,63 @@
+package dataloaden
+
+import "context"
+
+// fetchClassified fetches the keys of a batch applying the policies driven by the error classifier:
+// transient failures are retried, not found errors are turned into missing values when negative
+// caching is enabled, and fatal failures feed the circuit breaker. Throttled failures are retried after
+// the wait they ask for and are not failures to the breaker, see RetryAfterError.
+func (l *Loader[K, V]) fetchClassified(ctx context.Context, b *genericLoaderBatch[K, V], keys []K) ([]*V, []error, error) {
+	for attempt := 0; ; attempt++ {
+		if err := l.breaker.allow(l.clock.Now()); err != nil {
+			err = l.namedError(err)
+			return nil, []error{err}, err
+		}
+
+		data, errs, err := l.fetchKeys(ctx, keys)
+		l.countErrors(errs)
+		if err != nil && l.negativeCache {
+			data, errs, err = l.dropNotFound(keys, data, errs, err)
+		}
+
+		class := l.classify(err)
+		hint, throttled := retryAfter(err)
+		if throttled {
+			l.count(&l.stats.throttledBatches, 1)
+		}
+		l.breaker.record(l.clock.Now(), err == nil, class == Fatal && !throttled)
+		if err == nil || class != Transient && !throttled || attempt >= l.retries {
+			return data, errs, err
+		}
+
+		l.count(&l.stats.retries, 1)
+		switch {
+		case throttled:
+			wait := l.throttleWait(hint)
+			b.throttle(l.clock.Now().Add(wait))
+			sleep(l.clock, wait)
+		case l.retryBackoff > 0:
+			sleep(l.clock, l.retryBackoff)
+		}
+	}
+}
+
+// dropNotFound turns the not found errors of a fetch into missing values. Errors aligned with the keys are
+// handled one by one, a single error for the whole batch applies to every key.
+func (l *Loader[K, V]) dropNotFound(keys []K, data []*V, errs []error, err error) ([]*V, []error, error) {
+	if len(errs) != len(keys) {
+		if l.classify(err) == NotFound {
+			return nil, nil, nil
+		}
+		return data, errs, err
+	}
+
+	for i, e := range errs {
+		if e != nil && l.classify(e) == NotFound {
+			errs[i] = nil
+			if i < len(data) {
+				data[i] = nil
+			}
+		}
+	}
+	return data, errs, joinBatchErrors(errs)
+}
@@ -0,0 +1,32 @@
+package dataloaden
+
+import "time"
+
+// LoadSample describes a load picked by the sampler, see WithLoadSampler
+type LoadSample[K comparable] struct {
+	Key K
+
+	// whether the load was answered from the cache, loads that joined a pending key are misses
+	Hit bool
+
+	// how long it took from requesting the key until its result was delivered
+	Latency time.Duration
+}
+
+// sampleStart returns when a load that is sampled started, zero for loads that are not
+func (l *Loader[K, V]) sampleStart() time.Time {
+	if l.sampler == nil {
+		return time.Time{}
+	}
+	every := l.sampleEvery.Load()
+	if every <= 0 || l.sampleCount.Add(1)%uint64(every) != 0 {
+		return time.Time{}
+	}
+	return l.clock.Now()
+}
+
+// SetLoadSampleRate passes one in every loads to the sampler of a loader created WithLoadSampler,
+// 0 stops sampling
+func (l *Loader[K, V]) SetLoadSampleRate(every int) {
+	l.sampleEvery.Store(int64(every))
+}
@@ -0,0 +1,56 @@
+package dataloaden
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoadSampler(t *testing.T) {
+	var mu sync.Mutex
+	var samples []LoadSample[int]
+	sample := func(s LoadSample[int]) {
+		mu.Lock()
+		samples = append(samples, s)
+		mu.Unlock()
+	}
+	clock := newFakeClock()
+	loader, _ := newStringLoader(t, 0, WithClock[int, string](clock), WithEagerSingle[int, string](),
+		WithLoadSampler[int, string](2, sample))
+	loader.Prime(1, nil)
+
+	for _, key := range []int{1, 1, 2, 2} {
+		_, _ = loader.Load(key)
+	}
+	want := []LoadSample[int]{{Key: 1, Hit: true}, {Key: 2, Hit: true}}
+	if len(samples) != 2 || samples[0] != want[0] || samples[1] != want[1] {
+		t.Errorf("expected every second load, got %+v", samples)
+	}
+
+	// a sampled miss
+	loader.SetLoadSampleRate(1)
+	_, _ = loader.Load(3)
+	if last := samples[len(samples)-1]; last.Key != 3 || last.Hit {
+		t.Errorf("expected a miss for 3, got %+v", last)
+	}
+
+	loader.SetLoadSampleRate(0)
+	_, _ = loader.Load(4)
+	if len(samples) != 3 {
+		t.Errorf("expected sampling to stop, got %+v", samples)
+	}
+}
+
+func TestLoadSamplerLatency(t *testing.T) {
+	clock := newFakeClock()
+	sampled := make(chan LoadSample[int], 1)
+	loader, _ := newStringLoader(t, 10*time.Millisecond, WithClock[int, string](clock),
+		WithLoadSampler[int, string](1, func(s LoadSample[int]) { sampled <- s }))
+
+	thunk := loader.LoadThunk(1)
+	clock.Advance(10 * time.Millisecond)
+	_, _ = thunk()
+	if s := <-sampled; s.Latency != 10*time.Millisecond || s.Hit {
+		t.Errorf("expected a 10ms miss, got %+v", s)
+	}
+}
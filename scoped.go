@@ -0,0 +1,216 @@
+package dataloaden
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrScopesFailed is returned for the keys of a batch of several scopes that the fetch failed as a whole, see
+// NewScopedDataLoader
+var ErrScopesFailed = errors.New("dataloaden: fetch failed a batch of several scopes")
+
+// ScopedKey is a key together with the scope it was requested in, see NewScopedDataLoader
+type ScopedKey[K comparable] struct {
+	Scope string
+	Key   K
+}
+
+// ScopedKeys are the keys of a batch requested in one scope
+type ScopedKeys[K comparable] struct {
+	Scope string
+	Keys  []K
+}
+
+// ScopedLoader batches the keys of all scopes together but caches and delivers results per scope, for data
+// that different callers may not see alike, e.g. under row level security. A value fetched or primed in one
+// scope is never returned to another one.
+type ScopedLoader[K comparable, V any] struct {
+	loader *Loader[ScopedKey[K], V]
+	scope  func(ctx context.Context) string
+}
+
+// NewScopedDataLoader creates a loader whose cache is partitioned by the scope scope returns for the context
+// of each load. Keys of all scopes are collected into the same batches, and the fetch gets them grouped by
+// scope in the order the scopes first appeared in the batch. It returns the values and errors aligned with
+// the keys of all groups one after the other, or a single error for the whole batch.
+//
+// Unlike a Loader, an error for one key fails only that key rather than the whole batch, which would
+// show the errors of a scope to every other scope in the batch. For the same reason an error for the whole
+// batch is returned as it is only when all keys of the batch are of one scope, otherwise every key fails
+// with ErrScopesFailed, which carries none of the details.
+//
+// The options apply to the underlying loader, which keys its entries by ScopedKey.
+func NewScopedDataLoader[K comparable, V any](
+	scope func(ctx context.Context) string,
+	fetchFn func(groups []ScopedKeys[K]) ([]*V, []error),
+	waitDuration time.Duration,
+	maxBatch int,
+	opts ...Option[ScopedKey[K], V],
+) *ScopedLoader[K, V] {
+	// the streaming loader keeps errors per key
+	stream := func(keys []ScopedKey[K], emit func(i int, v *V, err error)) {
+		data, errs := fetchGrouped(fetchFn, keys)
+		if len(data) > len(keys) {
+			err := fmt.Errorf("%w: fetch returned %d values for %d keys", ErrContractViolation, len(data), len(keys))
+			for i := range keys {
+				emit(i, nil, err)
+			}
+			return
+		}
+
+		batchErr := joinBatchErrors(errs)
+		if batchErr != nil && len(errs) != len(keys) && !sameScope(keys) {
+			batchErr = ErrScopesFailed
+		}
+		for i := range keys {
+			var v *V
+			if i < len(data) {
+				v = data[i]
+			}
+			err := batchErr
+			if len(errs) == len(keys) {
+				err = errs[i]
+			}
+			emit(i, v, err)
+		}
+	}
+	return &ScopedLoader[K, V]{
+		loader: NewStreamingDataLoader(stream, waitDuration, maxBatch, opts...),
+		scope:  scope,
+	}
+}
+
+// sameScope tells whether all keys were requested in the same scope
+func sameScope[K comparable](keys []ScopedKey[K]) bool {
+	for _, key := range keys {
+		if key.Scope != keys[0].Scope {
+			return false
+		}
+	}
+	return true
+}
+
+// fetchGrouped hands the keys of a batch to fetchFn grouped by scope and aligns the results with keys again
+func fetchGrouped[K comparable, V any](fetchFn func(groups []ScopedKeys[K]) ([]*V, []error), keys []ScopedKey[K]) ([]*V, []error) {
+	var groups []ScopedKeys[K]
+	index := map[string]int{}
+	for _, key := range keys {
+		g, ok := index[key.Scope]
+		if !ok {
+			g = len(groups)
+			index[key.Scope] = g
+			groups = append(groups, ScopedKeys[K]{Scope: key.Scope})
+		}
+		groups[g].Keys = append(groups[g].Keys, key.Key)
+	}
+
+	// the position of every key in the grouped order
+	offsets := make([]int, len(groups))
+	for g := 1; g < len(groups); g++ {
+		offsets[g] = offsets[g-1] + len(groups[g-1].Keys)
+	}
+	positions := make([]int, len(keys))
+	for i, key := range keys {
+		g := index[key.Scope]
+		positions[i] = offsets[g]
+		offsets[g]++
+	}
+
+	data, errs := fetchFn(groups)
+
+	// short or misaligned results are passed on in a shape that reads the same way, values that cannot be
+	// attributed are passed on as they are to be rejected
+	aligned := data
+	if data != nil && len(data) <= len(keys) {
+		aligned = make([]*V, len(keys))
+		for i, pos := range positions {
+			if pos < len(data) {
+				aligned[i] = data[pos]
+			}
+		}
+	}
+	if len(errs) != len(keys) {
+		return aligned, errs
+	}
+	alignedErrs := make([]error, len(keys))
+	for i, pos := range positions {
+		alignedErrs[i] = errs[pos]
+	}
+	return aligned, alignedErrs
+}
+
+func (s *ScopedLoader[K, V]) key(ctx context.Context, key K) ScopedKey[K] {
+	return ScopedKey[K]{Scope: s.scope(ctx), Key: key}
+}
+
+// Load loads key in the scope of ctx
+func (s *ScopedLoader[K, V]) Load(ctx context.Context, key K) (*V, error) {
+	return s.loader.LoadCtx(ctx, s.key(ctx, key))
+}
+
+// LoadThunk returns a thunk for key in the scope of ctx, like DataLoader.LoadThunk
+func (s *ScopedLoader[K, V]) LoadThunk(ctx context.Context, key K) func() (*V, error) {
+	return s.loader.LoadThunkCtx(ctx, s.key(ctx, key))
+}
+
+// LoadAll loads keys in the scope of ctx, like Loader.LoadAllCtx
+func (s *ScopedLoader[K, V]) LoadAll(ctx context.Context, keys []K) ([]*V, []error) {
+	scopedKeys := make([]ScopedKey[K], len(keys))
+	for i, key := range keys {
+		scopedKeys[i] = s.key(ctx, key)
+	}
+	return s.loader.LoadAllCtx(ctx, scopedKeys)
+}
+
+// Prime primes key in the scope of ctx only, like DataLoader.Prime
+func (s *ScopedLoader[K, V]) Prime(ctx context.Context, key K, value *V) bool {
+	return s.loader.Prime(s.key(ctx, key), value)
+}
+
+func (s *ScopedLoader[K, V]) txnBind(key ScopedKey[K], value *V) (*loaderMutex, func() func(), error) {
+	return s.loader.txnBind(key, value)
+}
+
+// Clear removes key from the cache of the scope of ctx
+func (s *ScopedLoader[K, V]) Clear(ctx context.Context, key K) {
+	s.loader.Clear(s.key(ctx, key))
+}
+
+// ClearScope removes every cached entry of scope and returns how many were removed
+func (s *ScopedLoader[K, V]) ClearScope(scope string) int {
+	return s.loader.ClearWhere(func(key ScopedKey[K], _ *V) bool {
+		return key.Scope == scope
+	})
+}
+
+// ClearAll empties the cache of all scopes
+func (s *ScopedLoader[K, V]) ClearAll() {
+	s.loader.ClearAll()
+}
+
+// BumpEpoch makes every value cached so far stale, see Loader.BumpEpoch
+func (s *ScopedLoader[K, V]) BumpEpoch() {
+	s.loader.BumpEpoch()
+}
+
+// Flush dispatches the currently collected batch
+func (s *ScopedLoader[K, V]) Flush() {
+	s.loader.Flush()
+}
+
+// Close closes the underlying loader, see Loader.Close
+func (s *ScopedLoader[K, V]) Close(ctx context.Context) error {
+	return s.loader.Close(ctx)
+}
+
+// Stats returns a snapshot of the counters of the underlying loader
+func (s *ScopedLoader[K, V]) Stats() LoaderStats {
+	return s.loader.Stats()
+}
+
+// useSlots makes the underlying loader take its dispatch slots from c, see Group.RegisterWeighted
+func (s *ScopedLoader[K, V]) useSlots(c *slotClient) {
+	s.loader.useSlots(c)
+}
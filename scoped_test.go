@@ -0,0 +1,193 @@
+package dataloaden
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type scopeKey struct{}
+
+func withScope(scope string) context.Context {
+	return context.WithValue(context.Background(), scopeKey{}, scope)
+}
+
+func scopeOf(ctx context.Context) string {
+	scope, _ := ctx.Value(scopeKey{}).(string)
+	return scope
+}
+
+// scopedFetch returns values of the form "<scope>:<key>", so a value delivered to the wrong scope shows
+type scopedFetch struct {
+	mu    sync.Mutex
+	calls [][]ScopedKeys[int]
+}
+
+func (f *scopedFetch) fetch(groups []ScopedKeys[int]) ([]*string, []error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, groups)
+	f.mu.Unlock()
+
+	var data []*string
+	var errs []error
+	for _, g := range groups {
+		for _, k := range g.Keys {
+			v := g.Scope + ":" + strconv.Itoa(k)
+			data = append(data, &v)
+			if k < 0 {
+				errs = append(errs, fmt.Errorf("%s: no key %d", g.Scope, k))
+			} else {
+				errs = append(errs, nil)
+			}
+		}
+	}
+	return data, errs
+}
+
+func TestScopedLoaderIsolation(t *testing.T) {
+	f := &scopedFetch{}
+	loader := NewScopedDataLoader(scopeOf, f.fetch, 10*time.Millisecond, 0)
+
+	// many scopes request the same keys at once, interleaved into the same batches
+	scopes := []string{"alice", "bob", "carol", ""}
+	var wg sync.WaitGroup
+	for _, scope := range scopes {
+		for key := range 10 {
+			wg.Go(func() {
+				v, err := loader.Load(withScope(scope), key)
+				if want := scope + ":" + strconv.Itoa(key); err != nil || *v != want {
+					t.Errorf("scope %q key %d: expected %q, got %v %v", scope, key, want, v, err)
+				}
+			})
+		}
+	}
+	wg.Wait()
+
+	f.mu.Lock()
+	batches := len(f.calls)
+	for _, groups := range f.calls {
+		seen := map[string]bool{}
+		for _, g := range groups {
+			if seen[g.Scope] {
+				t.Errorf("scope %q appears in two groups of one batch", g.Scope)
+			}
+			seen[g.Scope] = true
+		}
+	}
+	f.mu.Unlock()
+	if batches >= len(scopes)*10 {
+		t.Errorf("expected the scopes to share batches, got %d fetches", batches)
+	}
+
+	// cached values stay in their scope too
+	for _, scope := range scopes {
+		values, errs := loader.LoadAll(withScope(scope), []int{3, 7})
+		for i, key := range []int{3, 7} {
+			if want := scope + ":" + strconv.Itoa(key); errs[i] != nil || *values[i] != want {
+				t.Errorf("scope %q key %d: expected cached %q, got %v %v", scope, key, want, values[i], errs[i])
+			}
+		}
+	}
+}
+
+func TestScopedLoaderContext(t *testing.T) {
+	f := &scopedFetch{}
+	loader := NewScopedDataLoader(scopeOf, f.fetch, time.Hour, 0)
+	ctx, cancel := context.WithCancel(withScope("alice"))
+	cancel()
+
+	if _, errs := loader.LoadAll(ctx, []int{1, 2}); !errors.Is(errs[0], context.Canceled) || !errors.Is(errs[1], context.Canceled) {
+		t.Fatalf("expected LoadAll to give up with the context, got %v", errs)
+	}
+	loader.Flush()
+	if v, err := loader.Load(withScope("alice"), 1); err != nil || *v != "alice:1" {
+		t.Fatalf("expected the abandoned key to be fetched in its scope, got %v %v", v, err)
+	}
+}
+
+func TestScopedLoaderPrimeAndClear(t *testing.T) {
+	f := &scopedFetch{}
+	loader := NewScopedDataLoader(scopeOf, f.fetch, time.Millisecond, 0)
+	alice, bob := withScope("alice"), withScope("bob")
+
+	secret := "alice's secret"
+	loader.Prime(alice, 1, &secret)
+	if v, _ := loader.Load(bob, 1); *v != "bob:1" {
+		t.Fatalf("expected bob to fetch his own value, got %q", *v)
+	}
+	if v, _ := loader.Load(alice, 1); *v != secret {
+		t.Fatalf("expected alice to get her primed value, got %q", *v)
+	}
+
+	loader.Clear(bob, 1)
+	if v, _ := loader.Load(alice, 1); *v != secret {
+		t.Fatalf("expected clearing bob's key to keep alice's, got %q", *v)
+	}
+	if n := loader.ClearScope("alice"); n != 1 {
+		t.Fatalf("expected one entry cleared for alice, got %d", n)
+	}
+	if v, _ := loader.Load(alice, 1); *v != "alice:1" {
+		t.Fatalf("expected alice to fetch again after ClearScope, got %q", *v)
+	}
+}
+
+func TestScopedLoaderErrors(t *testing.T) {
+	f := &scopedFetch{}
+	loader := NewScopedDataLoader(scopeOf, f.fetch, 10*time.Millisecond, 0)
+	alice, bob := withScope("alice"), withScope("bob")
+
+	// bob's failing key and alice's key share a batch, bob's error must not reach alice
+	aliceThunk := loader.LoadThunk(alice, 1)
+	bobThunk := loader.LoadThunk(bob, -1)
+	if v, err := aliceThunk(); err != nil || *v != "alice:1" {
+		t.Fatalf("expected alice's value, got %v %v", v, err)
+	}
+	if _, err := bobThunk(); err == nil || err.Error() != "bob: no key -1" {
+		t.Fatalf("expected bob's error, got %v", err)
+	}
+	if len(f.calls) != 1 {
+		t.Fatalf("expected the keys to share a batch, got %d fetches", len(f.calls))
+	}
+
+	errFailed := errors.New("failed")
+	failing := NewScopedDataLoader(scopeOf, func(groups []ScopedKeys[int]) ([]*string, []error) {
+		return nil, []error{errFailed}
+	}, time.Millisecond, 0)
+	if _, err := failing.Load(alice, 1); !errors.Is(err, errFailed) {
+		t.Fatalf("expected the batch error, got %v", err)
+	}
+
+	// a batch error of alice's group fails bob's keys too, but without telling him about it
+	errAlice := errors.New("alice's rows are locked")
+	shared := NewScopedDataLoader(scopeOf, func(groups []ScopedKeys[int]) ([]*string, []error) {
+		for _, g := range groups {
+			if g.Scope == "alice" {
+				return nil, []error{errAlice}
+			}
+		}
+		return f.fetch(groups)
+	}, 10*time.Millisecond, 0)
+	aliceThunk = shared.LoadThunk(alice, 1)
+	bobThunk = shared.LoadThunk(bob, 1)
+	if _, err := bobThunk(); !errors.Is(err, ErrScopesFailed) || errors.Is(err, errAlice) || strings.Contains(err.Error(), "alice") {
+		t.Fatalf("expected bob to get a generic error, got %v", err)
+	}
+	if _, err := aliceThunk(); !errors.Is(err, ErrScopesFailed) {
+		t.Fatalf("expected alice's key to fail with the batch, got %v", err)
+	}
+	if v, err := shared.Load(bob, 2); err != nil || *v != "bob:2" {
+		t.Fatalf("expected bob to load on his own, got %v %v", v, err)
+	}
+
+	long := NewScopedDataLoader(scopeOf, func(groups []ScopedKeys[int]) ([]*string, []error) {
+		return make([]*string, 3), nil
+	}, time.Millisecond, 0)
+	if _, err := long.Load(alice, 1); !errors.Is(err, ErrContractViolation) {
+		t.Fatalf("expected values that cannot be attributed to fail, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package dataloaden_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/UnAfraid/dataloaden/v3"
+	"github.com/UnAfraid/dataloaden/v3/dataloadertest"
+)
+
+// letters returns "A" for key 0, "B" for key 1 and so on
+func letters(keys []int) ([]*string, []error) {
+	results := make([]*string, len(keys))
+	for i, k := range keys {
+		v := string(rune('A' + k))
+		results[i] = &v
+	}
+	return results, make([]error, len(keys))
+}
+
+func loaderWith(wait time.Duration, maxBatch int) func(func([]int) ([]*string, []error), dataloaden.Clock) *dataloaden.Loader[int, string] {
+	return func(fetch func([]int) ([]*string, []error), clock dataloaden.Clock) *dataloaden.Loader[int, string] {
+		return dataloaden.NewDataLoader(fetch, wait, maxBatch, dataloaden.WithClock[int, string](clock))
+	}
+}
+
+func TestBatching(t *testing.T) {
+	log := dataloadertest.Sim[int, string]{
+		Events: []dataloadertest.Event[int]{
+			{At: 0, Keys: []int{0}},
+			{At: 2 * time.Millisecond, Keys: []int{1}},
+		},
+		Fetch: letters,
+	}.Run(loaderWith(5*time.Millisecond, 10))
+
+	if len(log.Batches) != 1 || !reflect.DeepEqual(log.Batches[0].Keys, []int{0, 1}) {
+		t.Fatalf("expected both keys in one batch, got %+v", log.Batches)
+	}
+	if b := log.Batches[0]; b.Trigger != dataloaden.WaitExpired || b.Dispatched != 5*time.Millisecond {
+		t.Errorf("expected the batch to be dispatched when the window expired, got %+v", b)
+	}
+	for i, w := range log.Waiters {
+		if w.Errs[0] != nil || w.Resolved != 5*time.Millisecond {
+			t.Errorf("waiter %d: unexpected outcome %+v", i, w)
+		}
+	}
+}
+
+func TestMaxBatchSize(t *testing.T) {
+	log := dataloadertest.Sim[int, string]{
+		Events: []dataloadertest.Event[int]{
+			{At: 0, Keys: []int{0}},
+			{At: 0, Keys: []int{1}},
+			{At: 0, Keys: []int{2}},
+		},
+		Fetch: letters,
+	}.Run(loaderWith(50*time.Millisecond, 2))
+
+	if len(log.Batches) != 2 {
+		t.Fatalf("expected 2 batches, got %d", len(log.Batches))
+	}
+	if !reflect.DeepEqual(log.Batches[0].Keys, []int{0, 1}) || !reflect.DeepEqual(log.Batches[1].Keys, []int{2}) {
+		t.Errorf("unexpected batching: %+v", log.Batches)
+	}
+	if b := log.Batches[0]; b.Trigger != dataloaden.MaxBatchReached || b.Dispatched != 0 {
+		t.Errorf("expected the full batch to be dispatched right away, got %+v", b)
+	}
+	if b := log.Batches[1]; b.Trigger != dataloaden.WaitExpired || b.Dispatched != 50*time.Millisecond {
+		t.Errorf("expected the rest to wait for the window, got %+v", b)
+	}
+}
+
+// TestWaiterWakeupSpread closes a batch through maxBatch while the clock holds its window open and
+// measures how far apart its waiters wake. Waking them is a broadcast that takes no locks, so the
+// spread stays within the microseconds even with many waiters competing for the processors. The best
+// of a few rounds counts, so that the test process being preempted does not fail it.
+func TestWaiterWakeupSpread(t *testing.T) {
+	const numWaiters, rounds = 200, 5
+	best := time.Duration(-1)
+	for range rounds {
+		spread := wakeupSpread(t, numWaiters)
+		if best < 0 || spread < best {
+			best = spread
+		}
+		if best < time.Millisecond {
+			break
+		}
+	}
+	t.Logf("wakeup spread across %d waiters: %v", numWaiters-1, best)
+	if best >= time.Millisecond {
+		t.Errorf("expected the waiters to wake within the microseconds, spread was %v", best)
+	}
+}
+
+// wakeupSpread returns the time between the first and the last of the waiters of a batch of
+// numWaiters keys waking up
+func wakeupSpread(t *testing.T, numWaiters int) time.Duration {
+	clock := dataloadertest.NewClock(time.Unix(0, 0))
+	loader := loaderWith(time.Millisecond, numWaiters)(letters, clock)
+
+	woke := make([]time.Time, numWaiters-1)
+	var started, wg sync.WaitGroup
+	for i := range woke {
+		thunk := loader.LoadThunk(i)
+		started.Add(1)
+		wg.Go(func() {
+			started.Done()
+			_, _ = thunk()
+			woke[i] = time.Now()
+		})
+	}
+	started.Wait()
+	// the last key fills the batch, the clock never moves so the window cannot dispatch it instead
+	_, _ = loader.LoadThunk(numWaiters - 1)()
+	wg.Wait()
+
+	if stats := loader.Stats(); stats.Batches != 1 || stats.BatchesByTrigger[dataloaden.MaxBatchReached] != 1 {
+		t.Fatalf("expected a single batch closed by maxBatch, got %+v", stats.BatchesByTrigger)
+	}
+	first, last := woke[0], woke[0]
+	for _, at := range woke {
+		if at.Before(first) {
+			first = at
+		}
+		if at.After(last) {
+			last = at
+		}
+	}
+	return last.Sub(first)
+}
@@ -0,0 +1,55 @@
+package dataloaden
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// NewSingleFetchLoader creates a loader for sources that only look up one key at a time. The keys of a
+// batch are spread over up to parallelism concurrent calls of fetchOne, parallelism < 1 fetches them one
+// by one, and every result is delivered as soon as its call returns: errors belong to their key alone,
+// like with NewStreamingDataLoader. Caching, deduplication and the options apply as to any loader.
+//
+// The context passed to fetchOne is canceled when Close gives up waiting for the loader's batches, and
+// once the load budget has passed since the batch started fetching, see WithLoadBudget. Keys whose call
+// has not started by then fail with the context's error without calling fetchOne.
+func NewSingleFetchLoader[K comparable, V any](fetchOne func(ctx context.Context, key K) (*V, error), parallelism int, opts ...Option[K, V]) *Loader[K, V] {
+	var l *Loader[K, V]
+	l = NewStreamingDataLoader(func(keys []K, emit func(i int, v *V, err error)) {
+		ctx := l.markFetching(l.abort)
+		if l.loadBudget > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithCancel(ctx)
+			defer cancel()
+			t := l.clock.AfterFunc(l.loadBudget, cancel)
+			defer t.Stop()
+		}
+		fetchEach(ctx, keys, max(parallelism, 1), fetchOne, emit)
+	}, 0, 0, opts...)
+	l.abort, l.cancelAbort = context.WithCancel(context.Background())
+	return l
+}
+
+// fetchEach calls fetchOne for every key from up to parallelism goroutines and emits the results
+func fetchEach[K comparable, V any](ctx context.Context, keys []K, parallelism int, fetchOne func(ctx context.Context, key K) (*V, error), emit func(i int, v *V, err error)) {
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range min(parallelism, len(keys)) {
+		wg.Go(func() {
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(keys) {
+					return
+				}
+				if err := ctx.Err(); err != nil {
+					emit(i, nil, err)
+					continue
+				}
+				v, err := fetchOne(ctx, keys[i])
+				emit(i, v, err)
+			}
+		})
+	}
+	wg.Wait()
+}
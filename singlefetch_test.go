@@ -0,0 +1,103 @@
+package dataloaden
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSingleFetchLoader(t *testing.T) {
+	t.Run("bounded parallelism", func(t *testing.T) {
+		errSeven := errors.New("no seven")
+		var calls, running, peak atomic.Int32
+		loader := NewSingleFetchLoader(func(ctx context.Context, key int) (*string, error) {
+			calls.Add(1)
+			n := running.Add(1)
+			defer running.Add(-1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(time.Millisecond)
+			if key == 7 {
+				return nil, errSeven
+			}
+			v := "v" + strconv.Itoa(key)
+			return &v, nil
+		}, 4)
+
+		keys := make([]int, 20)
+		for i := range keys {
+			keys[i] = i
+		}
+		keys[19] = 3
+		values, errs := loader.LoadAll(keys)
+		for i, key := range keys {
+			switch {
+			case key == 7:
+				if !errors.Is(errs[i], errSeven) {
+					t.Errorf("expected the error of key 7, got %v", errs[i])
+				}
+			case errs[i] != nil || *values[i] != "v"+strconv.Itoa(key):
+				t.Errorf("expected v%d at %d, got %v, %v", key, i, values[i], errs[i])
+			}
+		}
+		if p := peak.Load(); p > 4 {
+			t.Errorf("expected at most 4 concurrent fetches, got %d", p)
+		}
+		if n := calls.Load(); n != 19 {
+			t.Errorf("expected every distinct key to be fetched once, got %d calls", n)
+		}
+
+		_, _ = loader.Load(3)
+		if n := calls.Load(); n != 19 {
+			t.Errorf("expected cached keys not to be fetched again, got %d calls", n)
+		}
+	})
+
+	t.Run("close stops the remaining fetches", func(t *testing.T) {
+		var calls atomic.Int32
+		loader := NewSingleFetchLoader(func(ctx context.Context, key int) (*string, error) {
+			calls.Add(1)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}, 1)
+
+		thunk := loader.LoadAllThunk([]int{1, 2, 3})
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := loader.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected Close to give up, got %v", err)
+		}
+		_, errs := thunk()
+		for i, err := range errs {
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("expected key %d to be canceled, got %v", i+1, err)
+			}
+		}
+		if n := calls.Load(); n != 1 {
+			t.Errorf("expected the keys behind the canceled fetch not to be fetched, got %d calls", n)
+		}
+	})
+
+	t.Run("load budget", func(t *testing.T) {
+		stopped := make(chan error, 1)
+		loader := NewSingleFetchLoader(func(ctx context.Context, key int) (*string, error) {
+			<-ctx.Done()
+			stopped <- ctx.Err()
+			return nil, ctx.Err()
+		}, 1, WithLoadBudget[int, string](10*time.Millisecond))
+
+		// the waiter gives up at the same time the fetch is canceled, either may come first
+		if _, err := loader.Load(1); !errors.Is(err, ErrBudgetExceeded) && !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the load to fail once the budget passed, got %v", err)
+		}
+		if err := <-stopped; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the fetch to be canceled, got %v", err)
+		}
+		if err := loader.Close(context.Background()); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package dataloaden
+
+import "time"
+
+// unsafeSlide pushes the dispatch of a batch out to slide after now, the timer is only re-armed when it
+// fires early, so a burst of keys costs a single timer
+func (b *genericLoaderBatch[K, V]) unsafeSlide(l *Loader[K, V]) {
+	if b.state.Load() == batchDispatched {
+		return
+	}
+	b.slideUntil = l.clock.Now().Add(l.slide)
+	if b.timer == nil {
+		b.unsafeArmSlide(l, l.slide)
+	}
+}
+
+func (b *genericLoaderBatch[K, V]) unsafeArmSlide(l *Loader[K, V], d time.Duration) {
+	b.timer = l.clock.AfterFunc(d, func() {
+		l.slideExpired(b)
+	})
+}
+
+// slideExpired dispatches a sliding batch once no key arrived for slide, or once it waited maxWait since
+// its first key, and re-arms the timer for whichever comes first otherwise
+func (l *Loader[K, V]) slideExpired(b *genericLoaderBatch[K, V]) {
+	l.mu.Lock()
+	if b.state.Load() == batchDispatched {
+		l.mu.Unlock()
+		return
+	}
+	now := l.clock.Now()
+	limit := b.started.Add(l.maxWait)
+	var reason TriggerReason
+	switch {
+	case !now.Before(b.slideUntil):
+		reason = SlideExpired
+	case !now.Before(limit):
+		reason = MaxWaitExpired
+	default:
+		next := b.slideUntil
+		if limit.Before(next) {
+			next = limit
+		}
+		b.unsafeArmSlide(l, next.Sub(now))
+		l.mu.Unlock()
+		return
+	}
+	ok := l.unsafeDispatch(b, reason)
+	l.mu.Unlock()
+
+	if ok {
+		l.endTimed(b)
+	}
+}
+
+// windowEnd returns the latest time the window of a batch dispatches it
+func (l *Loader[K, V]) windowEnd(b *genericLoaderBatch[K, V]) time.Time {
+	if l.slide > 0 {
+		return b.started.Add(l.maxWait)
+	}
+	return b.started.Add(l.wait)
+}
@@ -0,0 +1,77 @@
+package dataloaden
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSlidingWindow(t *testing.T) {
+	newLoader := func() (*Loader[int, string], *recordingFetch, *fakeClock) {
+		clock := newFakeClock()
+		rec := &recordingFetch{}
+		loader := NewDataLoader(rec.fetch, time.Hour, 0, WithClock[int, string](clock),
+			WithSlidingWindow[int, string](10*time.Millisecond, 30*time.Millisecond))
+		return loader, rec, clock
+	}
+
+	t.Run("single key", func(t *testing.T) {
+		loader, rec, clock := newLoader()
+		thunk := loader.LoadThunk(1)
+		clock.Advance(10*time.Millisecond - time.Nanosecond)
+		if n := rec.callCount(); n != 0 {
+			t.Fatalf("expected the batch to wait for the slide, got %d fetches", n)
+		}
+		clock.Advance(time.Nanosecond)
+		if v, err := thunk(); err != nil || *v != "v1" {
+			t.Errorf("unexpected result %v, %v", v, err)
+		}
+		if n := loader.Stats().BatchesByTrigger[SlideExpired]; n != 1 {
+			t.Errorf("expected the slide to dispatch the batch, got %d", n)
+		}
+	})
+
+	t.Run("burst then silence", func(t *testing.T) {
+		loader, rec, clock := newLoader()
+		loader.Prefetch(1)
+		for _, key := range []int{2, 3} {
+			clock.Advance(5 * time.Millisecond)
+			loader.Prefetch(key)
+		}
+		if n := clock.live(); n != 1 {
+			t.Errorf("expected the burst to share one timer, got %d", n)
+		}
+		clock.Advance(10*time.Millisecond - time.Nanosecond)
+		if n := rec.callCount(); n != 0 {
+			t.Fatalf("expected every key to extend the window, got %d fetches", n)
+		}
+		clock.Advance(time.Nanosecond)
+		if rec.callCount() != 1 || !slices.Equal(rec.calls[0], []int{1, 2, 3}) {
+			t.Errorf("expected the burst in one batch, got %v", rec.calls)
+		}
+		if n := loader.Stats().BatchesByTrigger[SlideExpired]; n != 1 {
+			t.Errorf("expected the slide to dispatch the batch, got %d", n)
+		}
+	})
+
+	t.Run("continuous trickle", func(t *testing.T) {
+		loader, rec, clock := newLoader()
+		loader.Prefetch(0)
+		for key := 1; key <= 6; key++ {
+			clock.Advance(5 * time.Millisecond)
+			loader.Prefetch(key)
+		}
+		if rec.callCount() != 1 || !slices.Equal(rec.calls[0], []int{0, 1, 2, 3, 4, 5}) {
+			t.Fatalf("expected the batch to be dispatched after maxWait, got %v", rec.calls)
+		}
+		if n := loader.Stats().BatchesByTrigger[MaxWaitExpired]; n != 1 {
+			t.Errorf("expected maxWait to dispatch the batch, got %d", n)
+		}
+
+		// the key arriving at the limit starts the next batch
+		clock.Advance(10 * time.Millisecond)
+		if rec.callCount() != 2 || !slices.Equal(rec.calls[1], []int{6}) {
+			t.Errorf("expected the next batch to slide on its own, got %v", rec.calls)
+		}
+	})
+}
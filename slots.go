@@ -0,0 +1,145 @@
+package dataloaden
+
+import "sync"
+
+// strideUnit is the pass a loader of weight 1 advances by for every slot it takes
+const strideUnit = 1 << 20
+
+// slotLimiter bounds the number of batches the loaders of a Group fetch at the same time. Free slots are
+// handed out by stride scheduling: every loader advances its pass by strideUnit/weight for each slot it
+// takes, and the waiting loader with the lowest pass takes the next one. Each loader so gets slots in
+// proportion to its weight, however many batches it asks for, and a quiet loader never queues behind
+// the whole backlog of a chatty one.
+type slotLimiter struct {
+	mu sync.Mutex
+
+	// the number of slots, <= 0 = unlimited
+	max int
+
+	// the number of slots taken
+	running int
+
+	// the number of batches waiting for a slot across all loaders
+	waiters int
+
+	// the highest pass a slot was taken at, a loader that starts waiting catches up to it so the
+	// time it spent idle is not banked into a burst of slots
+	pass uint64
+
+	// in registration order, which breaks ties between equal passes
+	clients []*slotClient
+}
+
+// slotClient is the share of a loader in a slotLimiter
+type slotClient struct {
+	limiter *slotLimiter
+	stride  uint64
+	pass    uint64
+	waiting []chan struct{}
+}
+
+// slotUser is implemented by the loaders that take dispatch slots from their Group
+type slotUser interface {
+	useSlots(c *slotClient)
+}
+
+// join adds a loader of weight to the limiter, weights below 1 count as 1
+func (s *slotLimiter) join(weight int) *slotClient {
+	c := &slotClient{limiter: s, stride: strideUnit / uint64(max(weight, 1))}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients = append(s.clients, c)
+	return c
+}
+
+// setMax changes the number of slots, raising it hands the new slots to the waiting batches right away
+func (s *slotLimiter) setMax(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.max = n
+	s.unsafeGrant()
+}
+
+func (s *slotLimiter) unsafeFree() bool {
+	return s.max <= 0 || s.running < s.max
+}
+
+// unsafeGrant hands the free slots to the waiting loaders with the lowest pass
+func (s *slotLimiter) unsafeGrant() {
+	for s.waiters > 0 && s.unsafeFree() {
+		var next *slotClient
+		for _, c := range s.clients {
+			if len(c.waiting) > 0 && (next == nil || c.pass < next.pass) {
+				next = c
+			}
+		}
+		ready := next.waiting[0]
+		next.waiting[0] = nil
+		next.waiting = next.waiting[1:]
+		s.waiters--
+		next.unsafeTake()
+		close(ready)
+	}
+}
+
+// unsafeTake takes a slot and charges it to the loader
+func (c *slotClient) unsafeTake() {
+	s := c.limiter
+	s.running++
+	s.pass = max(s.pass, c.pass)
+	c.pass += c.stride
+}
+
+// acquire takes a slot, waiting for its turn when they are all taken, and reports whether it had to wait
+func (c *slotClient) acquire() bool {
+	s := c.limiter
+	s.mu.Lock()
+	if s.waiters == 0 && s.unsafeFree() {
+		c.unsafeTake()
+		s.mu.Unlock()
+		return false
+	}
+
+	ready := make(chan struct{})
+	if len(c.waiting) == 0 {
+		c.pass = max(c.pass, s.pass)
+	}
+	c.waiting = append(c.waiting, ready)
+	s.waiters++
+	s.mu.Unlock()
+
+	<-ready
+	return true
+}
+
+// release returns a slot taken by acquire, it is a no-op on a nil client
+func (c *slotClient) release() {
+	if c == nil {
+		return
+	}
+	s := c.limiter
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running--
+	s.unsafeGrant()
+}
+
+// useSlots makes the loader take a slot from c for every batch it fetches
+func (l *Loader[K, V]) useSlots(c *slotClient) {
+	l.slots.Store(c)
+}
+
+// acquireSlot takes a dispatch slot from the Group the loader is registered with, it returns the client
+// to release the slot to, nil when the loader is not limited
+func (l *Loader[K, V]) acquireSlot() *slotClient {
+	c := l.slots.Load()
+	if c == nil {
+		return nil
+	}
+	start := l.clock.Now()
+	if c.acquire() {
+		l.count(&l.stats.slotWaits, 1)
+		l.count(&l.stats.slotWait, uint64(l.clock.Now().Sub(start)))
+	}
+	return c
+}
@@ -0,0 +1,151 @@
+package dataloaden
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// gatedFetch reports every fetch it starts on started and returns once it receives from gate
+func gatedFetch(name string, started chan<- string, gate <-chan struct{}) func(keys []int) ([]*string, []error) {
+	return func(keys []int) ([]*string, []error) {
+		started <- name
+		<-gate
+		data := make([]*string, len(keys))
+		for i, key := range keys {
+			v := name + strconv.Itoa(key)
+			data[i] = &v
+		}
+		return data, nil
+	}
+}
+
+// awaitWaiters waits until n batches are waiting for a slot of s
+func awaitWaiters(t *testing.T, s *slotLimiter, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		waiters := s.waiters
+		s.mu.Unlock()
+		if waiters == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d batches waiting for a slot, got %d", n, waiters)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGroupFairSlots(t *testing.T) {
+	var g Group
+	g.SetMaxConcurrentBatches(1)
+	started := make(chan string, 16)
+	gate := make(chan struct{})
+	aggressive := NewDataLoader(gatedFetch("aggressive", started, gate), 0, 1)
+	sparse := NewDataLoader(gatedFetch("sparse", started, gate), 0, 1)
+	_ = g.Register("aggressive", aggressive)
+	_ = g.Register("sparse", sparse)
+
+	for key := range 10 {
+		aggressive.LoadThunk(key)
+	}
+	awaitWaiters(t, &g.slots, 9)
+	thunk := sparse.LoadThunk(1)
+	awaitWaiters(t, &g.slots, 10)
+
+	// first come first served would run the sparse batch after the whole backlog of the aggressive loader
+	var order []string
+	for range 11 {
+		order = append(order, <-started)
+		gate <- struct{}{}
+	}
+	if order[1] != "sparse" {
+		t.Errorf("expected the sparse batch to take the next free slot, got order %v", order)
+	}
+	if v, err := thunk(); err != nil || *v != "sparse1" {
+		t.Errorf("unexpected result %v, %v", v, err)
+	}
+
+	if stats := sparse.Stats(); stats.SlotWaits != 1 || stats.SlotWait <= 0 {
+		t.Errorf("expected the sparse batch to report its wait, got %d waits for %v", stats.SlotWaits, stats.SlotWait)
+	}
+	if stats := aggressive.Stats(); stats.SlotWaits != 9 {
+		t.Errorf("expected 9 aggressive batches to wait, got %d", stats.SlotWaits)
+	}
+}
+
+func TestSlotLimiterWeights(t *testing.T) {
+	var s slotLimiter
+	s.setMax(1)
+	blocker := s.join(1)
+	heavy := s.join(2)
+	light := s.join(0)
+
+	blocker.acquire()
+	granted := make(chan *slotClient)
+	for range 6 {
+		go func() {
+			heavy.acquire()
+			granted <- heavy
+		}()
+		go func() {
+			light.acquire()
+			granted <- light
+		}()
+	}
+	awaitWaiters(t, &s, 12)
+
+	blocker.release()
+	var heavyGrants, lightGrants int
+	for i := range 12 {
+		c := <-granted
+		if i < 9 {
+			if c == heavy {
+				heavyGrants++
+			} else {
+				lightGrants++
+			}
+		}
+		c.release()
+	}
+	if heavyGrants != 6 || lightGrants != 3 {
+		t.Errorf("expected slots to go 2:1 by weight, got %d heavy and %d light", heavyGrants, lightGrants)
+	}
+	if s.running != 0 || s.waiters != 0 {
+		t.Errorf("expected all slots to be returned, got %d running and %d waiting", s.running, s.waiters)
+	}
+}
+
+func TestGroupSetMaxConcurrentBatches(t *testing.T) {
+	var g Group
+	g.SetMaxConcurrentBatches(1)
+	started := make(chan string, 4)
+	gate := make(chan struct{})
+	users := NewDataLoader(gatedFetch("user", started, gate), 0, 1)
+	orgs := NewScopedDataLoader(scopeOf, func(groups []ScopedKeys[int]) ([]*string, []error) {
+		return gatedFetch("org", started, gate)(groups[0].Keys)
+	}, 0, 1)
+	_ = g.Register("users", users)
+	_ = g.Register("orgs", orgs)
+
+	userThunk := users.LoadThunk(1)
+	if name := <-started; name != "user" {
+		t.Fatalf("unexpected fetch %s", name)
+	}
+	orgThunk := orgs.LoadThunk(t.Context(), 1)
+	awaitWaiters(t, &g.slots, 1)
+
+	g.SetMaxConcurrentBatches(0)
+	if name := <-started; name != "org" {
+		t.Fatalf("unexpected fetch %s", name)
+	}
+	close(gate)
+	if _, err := userThunk(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := orgThunk(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package dataloaden
+
+import (
+	"fmt"
+	"time"
+)
+
+// LoadStale returns the cached value for key right away if it expired less than maxStale ago, reporting it
+// as stale and refreshing it in the background. Otherwise it behaves like Load.
+//
+// The refresh joins the batch that is already fetching the key if there is one, and it replaces the stale
+// entry only when it succeeds, so a failing refresh keeps serving the stale value until maxStale runs out.
+// Without a cache TTL entries never expire and LoadStale always behaves like Load. Loads remove the
+// entries that expired longer ago than the longest maxStale LoadStale was called with, so an entry may be
+// gone by the time LoadStale is first called with a longer one.
+func (l *Loader[K, V]) LoadStale(key K, maxStale time.Duration) (*V, bool, error) {
+	l.checkLifetime()
+	key, err := l.checkKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	l.mu.Lock()
+	l.staleWindow = max(l.staleWindow, maxStale)
+	if it, ok := l.cache.peek(key); ok && l.cacheTTL > 0 {
+		age := l.age(it)
+		if age >= l.cacheTTL && age < l.cacheTTL+maxStale {
+			l.count(&l.stats.loads, 1)
+			l.count(&l.stats.cacheHits, 1)
+			l.unsafeRecordHot(key)
+			l.unsafeCountBucket(key, true, false)
+			var refresh *genericLoaderBatch[K, V]
+			if _, pending := l.pending[key]; !pending && !l.closed {
+				// nobody waits for the refresh, so it cannot rely on its waiter to dispatch a singleton batch
+				p, full := l.unsafeEnqueue(key)
+				p.batch.unsafeStartWindow(l)
+				if full {
+					refresh = p.batch
+				}
+			}
+			l.mu.Unlock()
+
+			l.submit(refresh)
+
+			r := loadRequest[K, V]{key: key, entry: it}.wait(l)
+			return r.Value, true, r.Err
+		}
+	}
+	l.mu.Unlock()
+
+	value, err := l.Load(key)
+	return value, false, err
+}
+
+// StaleDataError comes with a value that was served from the cache because fetching a fresh one failed,
+// see WithServeStaleOnError
+type StaleDataError struct {
+	// the error the fetch failed with
+	Err error
+
+	// how long ago the served value was written
+	Age time.Duration
+}
+
+func (e *StaleDataError) Error() string {
+	return fmt.Sprintf("dataloaden: serving value from %v ago: %v", e.Age, e.Err)
+}
+
+func (e *StaleDataError) Unwrap() error {
+	return e.Err
+}
+
+// staleFallback answers a request whose fetch failed with err from the entry still cached for its key, which
+// is usually one that expired. NotFound errors say the value is gone and are delivered as they are.
+func (l *Loader[K, V]) staleFallback(key K, err error) (Result[*V], bool) {
+	if l.classify(err) == NotFound {
+		return Result[*V]{}, false
+	}
+
+	l.mu.Lock()
+	it, ok := l.cache.peek(key)
+	l.mu.Unlock()
+	if !ok {
+		return Result[*V]{}, false
+	}
+
+	l.count(&l.stats.staleServes, 1)
+	result := Result[*V]{Value: it.value, Found: it.found}
+	if l.wrapStale {
+		result.Err = &StaleDataError{Err: err, Age: l.age(it)}
+	}
+	return result, true
+}
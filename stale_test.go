@@ -0,0 +1,250 @@
+package dataloaden
+
+import (
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// versionedFetch returns "<key>@<n>" where n counts the fetches so far, or fails while failing is set
+type versionedFetch struct {
+	calls   atomic.Int32
+	failing atomic.Bool
+}
+
+func (f *versionedFetch) fetch(keys []int) ([]*string, []error) {
+	n := f.calls.Add(1)
+	if f.failing.Load() {
+		return nil, []error{errors.New("backend down")}
+	}
+	results := make([]*string, len(keys))
+	for i, k := range keys {
+		v := strconv.Itoa(k) + "@" + strconv.Itoa(int(n))
+		results[i] = &v
+	}
+	return results, nil
+}
+
+func TestCacheTTL(t *testing.T) {
+	f := &versionedFetch{}
+	loader := NewDataLoader(f.fetch, time.Millisecond, 10, WithCacheTTL[int, string](20*time.Millisecond))
+
+	if v, _ := loader.Load(1); *v != "1@1" {
+		t.Fatalf("expected 1@1, got %s", *v)
+	}
+	if v, _ := loader.Load(1); *v != "1@1" {
+		t.Errorf("expected a fresh cache hit, got %s", *v)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	var dst string
+	if loader.PeekInto(1, &dst) {
+		t.Errorf("expected PeekInto to skip the expired entry")
+	}
+	if v, _ := loader.Load(1); *v != "1@2" {
+		t.Errorf("expected the expired entry to be fetched again, got %s", *v)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	primed := "primed"
+	if !loader.Prime(1, &primed) {
+		t.Errorf("expected Prime to replace the expired entry")
+	}
+	if v, _ := loader.Load(1); *v != "primed" {
+		t.Errorf("expected the primed value, got %s", *v)
+	}
+}
+
+func TestCacheTTLZero(t *testing.T) {
+	clock := newFakeClock()
+	f := &versionedFetch{}
+	loader := NewDataLoader(f.fetch, 0, 1, WithClock[int, string](clock), WithCacheTTL[int, string](0))
+
+	_, _ = loader.Load(1)
+	clock.Advance(365 * 24 * time.Hour)
+	if v, _ := loader.Load(1); *v != "1@1" {
+		t.Errorf("expected a zero TTL never to expire, got %s", *v)
+	}
+	primed := "primed"
+	if loader.Prime(1, &primed) {
+		t.Error("expected Prime to keep the cached entry")
+	}
+}
+
+func TestCacheTTLRemovesExpired(t *testing.T) {
+	cached := func(l *Loader[int, string], key int) bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		_, ok := l.cache.peek(key)
+		return ok
+	}
+	newLoader := func(f *versionedFetch, clock Clock, opts ...Option[int, string]) *Loader[int, string] {
+		opts = append(opts, WithClock[int, string](clock), WithCacheTTL[int, string](time.Minute))
+		return NewDataLoader(f.fetch, 0, 1, opts...)
+	}
+
+	t.Run("removed", func(t *testing.T) {
+		clock, f := newFakeClock(), &versionedFetch{}
+		loader := newLoader(f, clock)
+		_, _ = loader.Load(1)
+		clock.Advance(2 * time.Minute)
+		f.failing.Store(true)
+		if _, err := loader.Load(1); err == nil {
+			t.Fatal("expected the failing fetch")
+		}
+		if cached(loader, 1) {
+			t.Error("expected the expired entry to be removed")
+		}
+	})
+
+	t.Run("serve stale on error", func(t *testing.T) {
+		clock, f := newFakeClock(), &versionedFetch{}
+		loader := newLoader(f, clock, WithServeStaleOnError[int, string](false))
+		_, _ = loader.Load(1)
+		clock.Advance(time.Hour)
+		f.failing.Store(true)
+		if v, err := loader.Load(1); err != nil || *v != "1@1" {
+			t.Errorf("expected the stale value, got %v, %v", v, err)
+		}
+	})
+
+	t.Run("stale window", func(t *testing.T) {
+		clock, f := newFakeClock(), &versionedFetch{}
+		loader := newLoader(f, clock)
+		_, _, _ = loader.LoadStale(1, 10*time.Minute)
+		f.failing.Store(true)
+
+		clock.Advance(5 * time.Minute)
+		_, _ = loader.Load(1)
+		if !cached(loader, 1) {
+			t.Fatal("expected the entry to be kept for LoadStale")
+		}
+		if v, stale, _ := loader.LoadStale(1, 10*time.Minute); !stale || *v != "1@1" {
+			t.Errorf("expected the stale value, got %v, %v", v, stale)
+		}
+
+		clock.Advance(10 * time.Minute)
+		_, _ = loader.Load(1)
+		if cached(loader, 1) {
+			t.Error("expected the entry to be removed past the stale window")
+		}
+	})
+}
+
+func TestLoadStale(t *testing.T) {
+	f := &versionedFetch{}
+	loader := NewDataLoader(f.fetch, time.Millisecond, 10, WithCacheTTL[int, string](20*time.Millisecond))
+
+	v, stale, err := loader.LoadStale(1, time.Hour)
+	if err != nil || stale || *v != "1@1" {
+		t.Fatalf("expected a regular load without a cached entry, got %v, %v, %v", *v, stale, err)
+	}
+
+	v, stale, _ = loader.LoadStale(1, time.Hour)
+	if stale || *v != "1@1" || f.calls.Load() != 1 {
+		t.Errorf("expected a fresh hit, got %s, %v after %d fetches", *v, stale, f.calls.Load())
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	v, stale, _ = loader.LoadStale(1, time.Hour)
+	if !stale || *v != "1@1" {
+		t.Errorf("expected the stale value, got %s, %v", *v, stale)
+	}
+	// a second stale read joins the refresh that is already pending
+	if v, stale, _ = loader.LoadStale(1, time.Hour); !stale || *v != "1@1" {
+		t.Errorf("expected the stale value, got %s, %v", *v, stale)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if f.calls.Load() != 2 {
+		t.Errorf("expected exactly one background refresh, got %d fetches", f.calls.Load())
+	}
+	if v, stale, _ = loader.LoadStale(1, time.Hour); stale || *v != "1@2" {
+		t.Errorf("expected the refreshed value, got %s, %v", *v, stale)
+	}
+}
+
+func TestLoadStaleFailingRefresh(t *testing.T) {
+	f := &versionedFetch{}
+	loader := NewDataLoader(f.fetch, time.Millisecond, 10, WithCacheTTL[int, string](10*time.Millisecond))
+
+	_, _ = loader.Load(1)
+	f.failing.Store(true)
+	time.Sleep(15 * time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		v, stale, err := loader.LoadStale(1, 30*time.Millisecond)
+		if err != nil || !stale || *v != "1@1" {
+			t.Errorf("expected the stale value while the refresh fails, got %v, %v, %v", v, stale, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, stale, err := loader.LoadStale(1, 30*time.Millisecond); err == nil || stale {
+		t.Errorf("expected a regular load past maxStale, got %v, %v", stale, err)
+	}
+}
+
+func TestServeStaleOnError(t *testing.T) {
+	newLoader := func(f *versionedFetch, clock Clock, opts ...Option[int, string]) *Loader[int, string] {
+		opts = append(opts,
+			WithClock[int, string](clock),
+			WithEagerSingle[int, string](),
+			WithCacheTTL[int, string](time.Minute))
+		return NewDataLoader(f.fetch, time.Millisecond, 0, opts...)
+	}
+
+	t.Run("nil error", func(t *testing.T) {
+		f, clock := &versionedFetch{}, newFakeClock()
+		loader := newLoader(f, clock, WithServeStaleOnError[int, string](false))
+		_, _ = loader.Load(1)
+
+		clock.Advance(2 * time.Minute)
+		f.failing.Store(true)
+		if v, err := loader.Load(1); err != nil || *v != "1@1" {
+			t.Errorf("expected the expired value, got %v, %v", v, err)
+		}
+		if v, err := loader.Load(2); err == nil {
+			t.Errorf("expected the error for a key without a cached value, got %v", *v)
+		}
+		if stats := loader.Stats(); stats.StaleServes != 1 {
+			t.Errorf("expected 1 stale serve, got %+v", stats)
+		}
+
+		// the expired entry is replaced once the fetch recovers
+		f.failing.Store(false)
+		if v, err := loader.Load(1); err != nil || *v != "1@4" {
+			t.Errorf("expected a fresh value, got %v, %v", v, err)
+		}
+	})
+
+	t.Run("wrapped", func(t *testing.T) {
+		f, clock := &versionedFetch{}, newFakeClock()
+		loader := newLoader(f, clock, WithServeStaleOnError[int, string](true))
+		_, _ = loader.Load(1)
+
+		clock.Advance(2 * time.Minute)
+		f.failing.Store(true)
+		v, err := loader.Load(1)
+		var stale *StaleDataError
+		if !errors.As(err, &stale) || stale.Age != 2*time.Minute || v == nil || *v != "1@1" {
+			t.Errorf("expected the expired value with a StaleDataError, got %v, %v", v, err)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		f, clock := &versionedFetch{}, newFakeClock()
+		loader := newLoader(f, clock, WithServeStaleOnError[int, string](false),
+			WithClassifyError[int, string](func(error) ErrorClass { return NotFound }))
+		_, _ = loader.Load(1)
+
+		clock.Advance(2 * time.Minute)
+		f.failing.Store(true)
+		if v, err := loader.Load(1); err == nil {
+			t.Errorf("expected the error for a key that is gone, got %v", *v)
+		}
+	})
+}
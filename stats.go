@@ -0,0 +1,160 @@
+package dataloaden
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// LoaderStats is a snapshot of the counters of a data loader
+type LoaderStats struct {
+	// the name of the loader
+	Name string
+
+	// number of keys requested through Load, LoadThunk, LoadAll and LoadAllThunk
+	Loads uint64
+
+	// number of requested keys that were answered from the cache
+	CacheHits uint64
+
+	// number of requested keys that joined a batch the key was already pending in
+	Coalesced uint64
+
+	// number of batches sent to the fetch
+	Batches uint64
+
+	// number of batches sent to the fetch by the reason they were dispatched for, nil when there were none
+	BatchesByTrigger map[TriggerReason]uint64
+
+	// number of keys sent to the fetch across all batches
+	FetchedKeys uint64
+
+	// number of batches whose fetch returned at least one error
+	FailedBatches uint64
+
+	// number of errors returned by the fetch by their class, nil when there were none
+	ErrorsByClass map[ErrorClass]uint64
+
+	// number of times a batch was fetched again after a transient failure
+	Retries uint64
+
+	// number of loads that gave up waiting for their result, see WithLoadBudget
+	BudgetExceeded uint64
+
+	// number of fetched values primed into the parent loader, see WithPromote
+	Promoted uint64
+
+	// number of fetched values the shareable predicate kept from the parent loader
+	PromotionsSkipped uint64
+
+	// number of fetched values that were not promoted because the parent loader fell behind
+	PromotionsDropped uint64
+
+	// number of loads answered from the cache because their fetch failed, see WithServeStaleOnError
+	StaleServes uint64
+
+	// number of fetches answered from the batch memo, see WithBatchMemo
+	BatchMemoHits uint64
+
+	// number of groups the batches were fetched in, see WithAffinity. Divided by Batches it is the
+	// average number of fetch calls per batch.
+	AffinityGroups uint64
+
+	// number of fetches that failed with a RetryAfterError, whether or not they were retried
+	ThrottledBatches uint64
+
+	// number of batches that waited for a dispatch slot, see Group.SetMaxConcurrentBatches
+	SlotWaits uint64
+
+	// the total time batches waited for a dispatch slot
+	SlotWait time.Duration
+
+	// the approximate size of the cache in bytes, only tracked with WithMaxCacheBytes
+	CacheBytes uint64
+
+	// number of entries evicted to keep the cache within its limit
+	Evictions uint64
+}
+
+// SavedFetches is the number of fetch calls the loader saved compared to fetching every requested key on
+// its own: cache hits and coalesced keys never reached the fetch, and batching folded the fetched keys into
+// one call per batch.
+func (s LoaderStats) SavedFetches() uint64 {
+	saved := s.CacheHits + s.Coalesced + s.FetchedKeys
+	if saved < s.Batches {
+		return 0
+	}
+	return saved - s.Batches
+}
+
+type loaderStats struct {
+	loads             atomic.Uint64
+	cacheHits         atomic.Uint64
+	coalesced         atomic.Uint64
+	batches           atomic.Uint64
+	triggers          [triggerReasonCount]atomic.Uint64
+	fetchedKeys       atomic.Uint64
+	failedBatches     atomic.Uint64
+	errorClasses      [errorClassCount]atomic.Uint64
+	retries           atomic.Uint64
+	budgetExceeded    atomic.Uint64
+	promoted          atomic.Uint64
+	promotionsSkipped atomic.Uint64
+	promotionsDropped atomic.Uint64
+	staleServes       atomic.Uint64
+	batchMemoHits     atomic.Uint64
+	affinityGroups    atomic.Uint64
+	throttledBatches  atomic.Uint64
+	slotWaits         atomic.Uint64
+	slotWait          atomic.Uint64
+	cacheBytes        atomic.Uint64
+	evictions         atomic.Uint64
+}
+
+// Stats returns a snapshot of the loader's counters, which all stay zero for loaders created WithLite
+func (l *Loader[K, V]) Stats() LoaderStats {
+	return LoaderStats{
+		Name:              l.name,
+		Loads:             l.stats.loads.Load(),
+		CacheHits:         l.stats.cacheHits.Load(),
+		Coalesced:         l.stats.coalesced.Load(),
+		Batches:           l.stats.batches.Load(),
+		BatchesByTrigger:  counts[TriggerReason](l.stats.triggers[:]),
+		FetchedKeys:       l.stats.fetchedKeys.Load(),
+		FailedBatches:     l.stats.failedBatches.Load(),
+		ErrorsByClass:     counts[ErrorClass](l.stats.errorClasses[:]),
+		Retries:           l.stats.retries.Load(),
+		BudgetExceeded:    l.stats.budgetExceeded.Load(),
+		Promoted:          l.stats.promoted.Load(),
+		PromotionsSkipped: l.stats.promotionsSkipped.Load(),
+		PromotionsDropped: l.stats.promotionsDropped.Load(),
+		StaleServes:       l.stats.staleServes.Load(),
+		BatchMemoHits:     l.stats.batchMemoHits.Load(),
+		AffinityGroups:    l.stats.affinityGroups.Load(),
+		ThrottledBatches:  l.stats.throttledBatches.Load(),
+		SlotWaits:         l.stats.slotWaits.Load(),
+		SlotWait:          time.Duration(l.stats.slotWait.Load()),
+		CacheBytes:        l.stats.cacheBytes.Load(),
+		Evictions:         l.stats.evictions.Load(),
+	}
+}
+
+// count adds n to a counter, unless stats are disabled with WithLite
+func (l *Loader[K, V]) count(counter *atomic.Uint64, n uint64) {
+	if !l.noStats {
+		counter.Add(n)
+	}
+}
+
+// counts returns the non-zero counters indexed by T, nil when they are all zero
+func counts[T ~uint8](counters []atomic.Uint64) map[T]uint64 {
+	var m map[T]uint64
+	for i := range counters {
+		if n := counters[i].Load(); n > 0 {
+			if m == nil {
+				m = map[T]uint64{}
+			}
+			m[T(i)] = n
+		}
+	}
+	return m
+}
@@ -0,0 +1,51 @@
+package dataloaden
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	fetchFn := func(keys []int) ([]*string, []error) {
+		results := make([]*string, len(keys))
+		errs := make([]error, len(keys))
+		for i, k := range keys {
+			if k < 0 {
+				errs[i] = errors.New("negative key")
+				continue
+			}
+			v := "v"
+			results[i] = &v
+		}
+		return results, errs
+	}
+
+	loader := NewDataLoader(fetchFn, 1*time.Millisecond, 10)
+
+	_, _ = loader.LoadAll([]int{1, 2, 2})
+	_, _ = loader.Load(1)
+	_, _ = loader.Load(-1)
+
+	want := LoaderStats{
+		Loads:         5,
+		CacheHits:     1,
+		Coalesced:     1,
+		Batches:       2,
+		FetchedKeys:   3,
+		FailedBatches: 1,
+
+		BatchesByTrigger: map[TriggerReason]uint64{WaitExpired: 2},
+		ErrorsByClass:    map[ErrorClass]uint64{Unknown: 1},
+	}
+	got := loader.Stats()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	// 5 loads took 2 fetch calls
+	if saved := got.SavedFetches(); saved != 3 {
+		t.Errorf("expected 3 saved fetches, got %d", saved)
+	}
+}
@@ -0,0 +1,63 @@
+package dataloaden
+
+// OtherBucket collects the loads of labels that found no free bucket, see WithStatsBy
+const OtherBucket = "other"
+
+// statBuckets counts loads per label, keeping at most max labels apart
+type statBuckets[K comparable] struct {
+	label  func(key K) string
+	max    int
+	counts map[string]*bucketCounts
+}
+
+type bucketCounts struct {
+	loads     uint64
+	cacheHits uint64
+	coalesced uint64
+}
+
+// unsafeCountBucket counts a load of key in the bucket of its label when stats are bucketed
+func (l *Loader[K, V]) unsafeCountBucket(key K, hit, coalesced bool) {
+	b := l.statBuckets
+	if b == nil {
+		return
+	}
+	label := b.label(key)
+	counts, ok := b.counts[label]
+	if !ok {
+		if len(b.counts) >= b.max && label != OtherBucket {
+			label = OtherBucket
+		}
+		if counts, ok = b.counts[label]; !ok {
+			counts = &bucketCounts{}
+			b.counts[label] = counts
+		}
+	}
+	counts.loads++
+	if hit {
+		counts.cacheHits++
+	}
+	if coalesced {
+		counts.coalesced++
+	}
+}
+
+// StatsBy returns the load counters by the label WithStatsBy assigned to the keys, only Loads, CacheHits and
+// Coalesced are counted per label. It returns nil unless the loader was created WithStatsBy.
+func (l *Loader[K, V]) StatsBy() map[string]LoaderStats {
+	if l.statBuckets == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := make(map[string]LoaderStats, len(l.statBuckets.counts))
+	for label, counts := range l.statBuckets.counts {
+		stats[label] = LoaderStats{
+			Name:      l.name,
+			Loads:     counts.loads,
+			CacheHits: counts.cacheHits,
+			Coalesced: counts.coalesced,
+		}
+	}
+	return stats
+}
@@ -0,0 +1,33 @@
+package dataloaden
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStatsBy(t *testing.T) {
+	// keys are labeled by their hundreds: 1xx, 2xx, ...
+	label := func(key int) string { return strconv.Itoa(key/100) + "xx" }
+	loader, _ := newStringLoader(t, time.Millisecond, WithStatsBy[int, string](label, 2))
+
+	_, _ = loader.LoadAll([]int{101, 101, 201})
+	_, _ = loader.Load(101)
+	_, _ = loader.Load(301)
+	_, _ = loader.Load(401)
+
+	want := map[string]LoaderStats{
+		"1xx":       {Loads: 3, CacheHits: 1, Coalesced: 1},
+		"2xx":       {Loads: 1},
+		OtherBucket: {Loads: 2},
+	}
+	if got := loader.StatsBy(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	plain, _ := newStringLoader(t, time.Millisecond)
+	if plain.StatsBy() != nil {
+		t.Error("expected no bucketed stats unless enabled")
+	}
+}
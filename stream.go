@@ -0,0 +1,117 @@
+package dataloaden
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotEmitted is returned to the waiters of keys a streaming fetch returned without emitting
+var ErrNotEmitted = errors.New("dataloaden: fetch returned without emitting a result for the key")
+
+// NewStreamingDataLoader creates a loader for fetches that produce their results incrementally. The fetch
+// calls emit once for every position of keys, in any order and from any goroutine, and each waiter is
+// released as soon as its position is emitted. Emitted values are cached right away, errors are per key
+// and are not shared with the rest of the batch. Positions that have not been emitted when the fetch
+// returns fail with ErrNotEmitted, later and repeated emits are ignored.
+//
+// Keys are passed to the fetch as they were requested, WithTransformKeys, WithAliasResolver and
+// WithBatchMemo do not apply and the loader panics when they are passed, like NewDataLoader does for
+// invalid options.
+func NewStreamingDataLoader[K comparable, V any](fetchFn func(keys []K, emit func(i int, v *V, err error)), waitDuration time.Duration, maxBatch int, opts ...Option[K, V]) *Loader[K, V] {
+	l, err := newLoader(nil, fetchFn, waitDuration, maxBatch, opts)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// fetchStream sends the keys of a streaming batch to the fetch, in chunks of maxBatch keys
+func (b *genericLoaderBatch[K, V]) fetchStream(l *Loader[K, V]) {
+	l.mu.Lock()
+	maxBatch := l.maxBatch
+	l.mu.Unlock()
+	if maxBatch <= 0 {
+		maxBatch = len(b.keys)
+	}
+
+	closeErr := l.namedError(ErrNotEmitted)
+	for start := 0; start < len(b.keys); start += maxBatch {
+		end := min(start+maxBatch, len(b.keys))
+		err := l.streamChecked(b.keys[start:end], func(i int, v *V, err error) {
+			if l.valueTransform != nil && v != nil && err == nil {
+				v, err = l.transformed(b.keys[start+i], v)
+			}
+			b.emit(l, start+i, v, err)
+		})
+		if err != nil {
+			closeErr = err
+		}
+	}
+	b.complete(l, nil, nil, closeErr)
+}
+
+// emit publishes the result at pos to the cache and releases its waiters, only the first emit of a position
+// has any effect
+func (b *genericLoaderBatch[K, V]) emit(l *Loader[K, V], pos int, v *V, err error) {
+	l.mu.Lock()
+	if b.emitted[pos] {
+		l.mu.Unlock()
+		return
+	}
+	b.emitted[pos] = true
+	primed, isPrimed := b.overrides[pos]
+	if isPrimed {
+		v, err = primed.value, nil
+	}
+	b.data[pos], b.error[pos] = v, err
+	b.failed = b.failed || err != nil
+
+	key := b.keys[pos]
+	if err == nil && !isPrimed {
+		b.unsafeStore(l, pos)
+	}
+	if l.pending[key].batch == b {
+		delete(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	close(b.ready[pos])
+}
+
+// closeStream fails every position that has not been emitted with err and finishes the batch
+func (b *genericLoaderBatch[K, V]) closeStream(l *Loader[K, V], err error) {
+	b.once.Do(func() {
+		var closed []int
+		l.mu.Lock()
+		l.dispatched--
+		for pos, key := range b.keys {
+			if b.emitted[pos] {
+				continue
+			}
+			b.emitted[pos] = true
+			closed = append(closed, pos)
+			if primed, ok := b.overrides[pos]; ok {
+				b.data[pos] = primed.value
+			} else {
+				b.error[pos] = err
+				b.failed = true
+			}
+			if l.pending[key].batch == b {
+				delete(l.pending, key)
+			}
+		}
+		failed := b.failed
+		l.mu.Unlock()
+
+		if failed {
+			l.count(&l.stats.failedBatches, 1)
+			b.recordFailure(l, b.error, err)
+		}
+		for _, pos := range closed {
+			close(b.ready[pos])
+		}
+		close(b.done)
+		l.inflight.Done()
+		b.promote(l)
+	})
+}
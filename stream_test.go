@@ -0,0 +1,117 @@
+package dataloaden
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStreamingLoaderReleasesEachPosition(t *testing.T) {
+	emitted := make(chan struct{})
+	release := make(chan struct{})
+	fetchFn := func(keys []int, emit func(i int, v *string, err error)) {
+		// out of order: the last key first
+		last := "v" + strconv.Itoa(keys[len(keys)-1])
+		emit(len(keys)-1, &last, nil)
+		close(emitted)
+
+		<-release
+		for i, k := range keys[:len(keys)-1] {
+			v := "v" + strconv.Itoa(k)
+			emit(i, &v, nil)
+		}
+	}
+	loader := NewStreamingDataLoader(fetchFn, time.Millisecond, 0)
+
+	first := loader.LoadThunk(1)
+	last := loader.LoadThunk(2)
+
+	<-emitted
+	if v, err := last(); err != nil || *v != "v2" {
+		t.Fatalf("unexpected result %v, %v", v, err)
+	}
+
+	// the emitted key is cached while the rest of the batch is still being fetched
+	if v, err := loader.Load(2); err != nil || *v != "v2" {
+		t.Fatalf("unexpected cached result %v, %v", v, err)
+	}
+	if stats := loader.Stats(); stats.CacheHits != 1 {
+		t.Errorf("expected a cache hit, got %+v", stats)
+	}
+
+	close(release)
+	if v, err := first(); err != nil || *v != "v1" {
+		t.Fatalf("unexpected result %v, %v", v, err)
+	}
+}
+
+func TestStreamingLoaderErrors(t *testing.T) {
+	errOdd := errors.New("odd key")
+	fetchFn := func(keys []int, emit func(i int, v *string, err error)) {
+		for i, k := range keys {
+			switch {
+			case k == 3:
+				// never emitted
+			case k%2 == 1:
+				emit(i, nil, errOdd)
+			default:
+				v := "v" + strconv.Itoa(k)
+				emit(i, &v, nil)
+				other := "other"
+				emit(i, &other, nil)
+			}
+		}
+		emit(len(keys), nil, errOdd)
+		emit(-1, nil, errOdd)
+	}
+	loader := NewStreamingDataLoader(fetchFn, time.Millisecond, 0)
+
+	values, errs := loader.LoadAll([]int{0, 1, 2, 3})
+	if errs[0] != nil || *values[0] != "v0" || errs[2] != nil || *values[2] != "v2" {
+		t.Errorf("expected the first emit of each position to win, got %v, %v", values, errs)
+	}
+	if !errors.Is(errs[1], errOdd) {
+		t.Errorf("expected the emitted error, got %v", errs[1])
+	}
+	if !errors.Is(errs[3], ErrNotEmitted) {
+		t.Errorf("expected ErrNotEmitted, got %v", errs[3])
+	}
+	if stats := loader.Stats(); stats.FailedBatches != 1 {
+		t.Errorf("expected one failed batch, got %+v", stats)
+	}
+
+	// errors are not cached
+	if _, err := loader.Load(1); !errors.Is(err, errOdd) {
+		t.Errorf("expected the error to be fetched again, got %v", err)
+	}
+	if stats := loader.Stats(); stats.Batches != 2 {
+		t.Errorf("expected a second batch, got %+v", stats)
+	}
+}
+
+func TestStreamingLoaderChunks(t *testing.T) {
+	rec := &recordingFetch{}
+	fetchFn := func(keys []int, emit func(i int, v *string, err error)) {
+		values, _ := rec.fetch(keys)
+		for i := len(values) - 1; i >= 0; i-- {
+			emit(i, values[i], nil)
+		}
+	}
+	loader := NewStreamingDataLoader(fetchFn, time.Hour, 10)
+
+	thunks := make([]func() (*string, error), 5)
+	for i := range thunks {
+		thunks[i] = loader.LoadThunk(i)
+	}
+	loader.SetMaxBatch(2)
+
+	for i, thunk := range thunks {
+		if v, err := thunk(); err != nil || *v != "v"+strconv.Itoa(i) {
+			t.Errorf("key %d: unexpected result %v, %v", i, v, err)
+		}
+	}
+	if calls := rec.callCount(); calls != 3 {
+		t.Errorf("expected the batch to be split into 3 fetches, got %d", calls)
+	}
+}
@@ -0,0 +1,118 @@
+package dataloaden
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrContractViolation is wrapped by the error the waiters of a batch get when its fetch returned results
+// the loader cannot attribute to keys: more values than keys. See WithStrict for the whole contract.
+var ErrContractViolation = errors.New("dataloaden: fetch contract violation")
+
+// fetchChecked calls the fetch and checks the shape of its results. When the fetch broke its contract
+// the results are replaced with the error violation returns, unless it returns nil.
+func (l *Loader[K, V]) fetchChecked(ctx context.Context, keys []K) ([]*V, []error) {
+	snapshot := l.snapshotKeys(keys)
+	var data []*V
+	var errs []error
+	if l.fetch != nil {
+		data, errs = l.fetch(l.markFetching(ctx), keys)
+	} else {
+		data, errs = l.plainFetch(keys)
+	}
+	if err := ctx.Err(); err != nil {
+		errs = canceledKeys(keys, data, errs, l.namedError(err))
+	}
+
+	if err := l.checkFetch(keys, snapshot, data, errs); err != nil {
+		return nil, []error{err}
+	}
+	return data, errs
+}
+
+func (l *Loader[K, V]) checkFetch(keys, snapshot []K, data []*V, errs []error) error {
+	if err := l.checkKeysUnchanged(keys, snapshot); err != nil {
+		return err
+	}
+	// results the loader cannot attribute are checked first, they fail the batch unless strict or handled
+	switch {
+	case len(data) > len(keys):
+		return l.violation(fmt.Sprintf("fetch returned %d values for %d keys", len(data), len(keys)), false)
+	case len(errs) > 1 && len(errs) != len(keys):
+		// misaligned errors apply to the whole batch
+		return l.violation(fmt.Sprintf("fetch returned %d errors for %d keys, expected none, one for the batch or one per key", len(errs), len(keys)), true)
+	case data != nil && len(data) < len(keys):
+		// a short value slice leaves the remaining keys absent
+		return l.violation(fmt.Sprintf("fetch returned %d values for %d keys", len(data), len(keys)), true)
+	case data == nil && len(keys) > 0 && joinBatchErrors(errs) == nil:
+		return l.violation(fmt.Sprintf("fetch returned neither values nor errors for %d keys", len(keys)), true)
+	}
+	if len(errs) == len(keys) {
+		for i := range data {
+			if err := l.checkResult(keys, i, data[i], errs[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// streamChecked calls the streaming fetch and checks every emitted result. It returns the violation of a
+// fetch that modified its keys, which fails the positions it has not emitted.
+func (l *Loader[K, V]) streamChecked(keys []K, emit func(i int, v *V, err error)) error {
+	snapshot := l.snapshotKeys(keys)
+	l.stream(keys, func(i int, v *V, err error) {
+		if i < 0 || i >= len(keys) {
+			// the positions it meant to emit fail with ErrNotEmitted
+			l.violation(fmt.Sprintf("fetch emitted position %d for %d keys", i, len(keys)), true)
+			return
+		}
+		l.checkResult(keys, i, v, err)
+		emit(i, v, err)
+	})
+	return l.checkKeysUnchanged(keys, snapshot)
+}
+
+func (l *Loader[K, V]) checkResult(keys []K, pos int, v *V, err error) error {
+	if v != nil && err != nil {
+		return l.violation(fmt.Sprintf("fetch returned both a value and an error for key %v at position %d: %v", keys[pos], pos, err), true)
+	}
+	return nil
+}
+
+// snapshotKeys copies the keys for checkKeysUnchanged. Only loaders that report violations, strict ones
+// and those with a violation handler, check the keys: the copy would cost every other fetch an allocation.
+func (l *Loader[K, V]) snapshotKeys(keys []K) []K {
+	if !l.strict && l.violationHandler == nil {
+		return nil
+	}
+	return slices.Clone(keys)
+}
+
+func (l *Loader[K, V]) checkKeysUnchanged(keys, snapshot []K) error {
+	if snapshot != nil && !slices.Equal(keys, snapshot) {
+		return l.violation(fmt.Sprintf("fetch modified its keys, called with %v, left %v", snapshot, keys), false)
+	}
+	return nil
+}
+
+// violation handles a broken fetch contract: strict loaders panic and loaders with a violation handler
+// pass it the detail. Otherwise the results fail with the returned error, unless the loader has a
+// documented reading of them: lenient violations are delivered as the fetch returned them.
+func (l *Loader[K, V]) violation(detail string, lenient bool) error {
+	switch {
+	case l.strict && l.name == "":
+		panic("dataloaden: fetch contract violation: " + detail)
+	case l.strict:
+		panic(fmt.Sprintf("dataloaden: loader %q: fetch contract violation: %s", l.name, detail))
+	case l.violationHandler != nil:
+		l.violationHandler(l.name, detail)
+		return nil
+	case lenient:
+		return nil
+	default:
+		return l.namedError(fmt.Errorf("%w: %s", ErrContractViolation, detail))
+	}
+}
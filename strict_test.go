@@ -0,0 +1,247 @@
+package dataloaden
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func panics(f func()) (msg string, panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg, _ = r.(string)
+			panicked = true
+		}
+	}()
+	f()
+	return "", false
+}
+
+func TestStrictFetchViolations(t *testing.T) {
+	errFailed := errors.New("failed")
+	v := "v"
+	tests := []struct {
+		name  string
+		fetch func(keys []int) ([]*string, []error)
+		want  string
+	}{
+		{"short values", func(keys []int) ([]*string, []error) {
+			return []*string{&v}, nil
+		}, "returned 1 values for 2 keys"},
+		{"long values", func(keys []int) ([]*string, []error) {
+			return []*string{&v, &v, &v}, nil
+		}, "returned 3 values for 2 keys"},
+		{"misaligned errors", func(keys []int) ([]*string, []error) {
+			return make([]*string, len(keys)), []error{errFailed, errFailed, errFailed}
+		}, "returned 3 errors for 2 keys"},
+		{"nothing returned", func(keys []int) ([]*string, []error) {
+			return nil, nil
+		}, "neither values nor errors"},
+		{"value and error", func(keys []int) ([]*string, []error) {
+			return []*string{&v, nil}, []error{errFailed, nil}
+		}, "both a value and an error for key 1"},
+		{"keys modified", func(keys []int) ([]*string, []error) {
+			keys[0] = 99
+			return []*string{&v, &v}, nil
+		}, "modified its keys"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strict := NewDataLoader(tt.fetch, time.Millisecond, 0, WithName[int, string]("users"), WithStrict[int, string]())
+			msg, panicked := panics(func() {
+				strict.fetchChecked(context.Background(), []int{1, 2})
+			})
+			if !panicked {
+				t.Fatal("expected strict mode to panic")
+			}
+			if !strings.Contains(msg, tt.want) || !strings.Contains(msg, `loader "users"`) {
+				t.Errorf("unexpected panic message %q", msg)
+			}
+
+			lenient := NewDataLoader(tt.fetch, time.Millisecond, 0)
+			if _, panicked := panics(func() { _, _ = lenient.LoadAll([]int{1, 2}) }); panicked {
+				t.Error("expected the lenient loader not to panic")
+			}
+		})
+	}
+
+	valid := func(keys []int) ([]*string, []error) {
+		return []*string{&v, nil}, []error{nil, errFailed}
+	}
+	strict := NewDataLoader(valid, time.Millisecond, 0, WithStrict[int, string]())
+	if msg, panicked := panics(func() { _, _ = strict.LoadAll([]int{1, 2}) }); panicked {
+		t.Errorf("unexpected panic for a valid fetch: %s", msg)
+	}
+}
+
+func TestStrictStreamViolations(t *testing.T) {
+	v := "v"
+	fetchFn := func(keys []int, emit func(i int, v *string, err error)) {
+		emit(0, &v, errors.New("failed"))
+	}
+
+	strict := NewStreamingDataLoader(fetchFn, time.Millisecond, 0, WithStrict[int, string]())
+	msg, panicked := panics(func() {
+		strict.streamChecked([]int{1}, func(int, *string, error) {})
+	})
+	if !panicked || !strings.Contains(msg, "both a value and an error") {
+		t.Errorf("expected strict mode to panic, got %q", msg)
+	}
+	if strings.Contains(msg, "loader") {
+		t.Errorf("expected the panic of an unnamed loader not to name it, got %q", msg)
+	}
+
+	lenient := NewStreamingDataLoader(fetchFn, time.Millisecond, 0)
+	if _, panicked := panics(func() { _, _ = lenient.Load(1) }); panicked {
+		t.Error("expected the lenient loader not to panic")
+	}
+}
+
+// malformedFetches are fetches breaking their contract in every way the loader can tell, violates marks
+// the ones whose results cannot be attributed to keys. Modified keys are only checked by loaders that report
+// violations.
+var malformedFetches = []struct {
+	name     string
+	fetch    func(keys []int) ([]*string, []error)
+	violates bool
+}{
+	{"nil results", func(keys []int) ([]*string, []error) { return nil, nil }, false},
+	{"nil values, nil error", func(keys []int) ([]*string, []error) { return nil, []error{nil} }, false},
+	{"empty values", func(keys []int) ([]*string, []error) { return []*string{}, nil }, false},
+	{"short values", func(keys []int) ([]*string, []error) { return make([]*string, len(keys)-1), nil }, false},
+	{"long values", func(keys []int) ([]*string, []error) { return make([]*string, len(keys)+2), nil }, true},
+	{"short errors", func(keys []int) ([]*string, []error) {
+		return make([]*string, len(keys)), make([]error, len(keys)-1)
+	}, false},
+	{"long errors", func(keys []int) ([]*string, []error) {
+		return make([]*string, len(keys)), []error{nil, errors.New("failed"), nil, nil, nil, nil}
+	}, false},
+	{"value and error", func(keys []int) ([]*string, []error) {
+		v := "v"
+		values, errs := make([]*string, len(keys)), make([]error, len(keys))
+		values[0], errs[0] = &v, errors.New("failed")
+		return values, errs
+	}, false},
+	{"keys modified", func(keys []int) ([]*string, []error) {
+		keys[0] = -keys[0] - 1
+		return make([]*string, len(keys)), nil
+	}, false},
+}
+
+// TestViolationShapes feeds every malformed fetch through the paths that read fetch results: a plain batch,
+// a singleton, a batch split into chunks, transformed keys and a batch with a key primed while pending. A panic on any
+// of them would crash the test binary from the batch goroutine.
+func TestViolationShapes(t *testing.T) {
+	setups := map[string]func(fetch func([]int) ([]*string, []error)) []error{
+		"batch": func(fetch func([]int) ([]*string, []error)) []error {
+			_, errs := NewDataLoader(fetch, time.Millisecond, 0).LoadAll([]int{1, 2, 3})
+			return errs
+		},
+		"singleton": func(fetch func([]int) ([]*string, []error)) []error {
+			_, err := NewDataLoader(fetch, time.Millisecond, 0).Load(1)
+			return []error{err}
+		},
+		"chunks": func(fetch func([]int) ([]*string, []error)) []error {
+			loader := NewDataLoader(fetch, time.Hour, 0)
+			thunk := loader.LoadAllThunk([]int{1, 2, 3, 4, 5})
+			loader.SetMaxBatch(2)
+			_, errs := thunk()
+			return errs
+		},
+		"transformed": func(fetch func([]int) ([]*string, []error)) []error {
+			loader := NewDataLoader(fetch, time.Millisecond, 0, WithTransformKeys[int, string](func(keys []int) []int {
+				for i := range keys {
+					keys[i] %= 2
+				}
+				return keys
+			}))
+			_, errs := loader.LoadAll([]int{1, 2, 3})
+			return errs
+		},
+		"primed": func(fetch func([]int) ([]*string, []error)) []error {
+			loader := NewDataLoader(fetch, time.Hour, 0, WithPrimePolicy[int, string](PreferPrimed))
+			thunk := loader.LoadAllThunk([]int{1, 2, 3})
+			v := "primed"
+			loader.Prime(2, &v)
+			loader.Flush()
+			_, errs := thunk()
+			return errs
+		},
+	}
+
+	for _, tt := range malformedFetches {
+		for name, load := range setups {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				for i, err := range load(tt.fetch) {
+					// the primed key keeps its primed value whatever the fetch returns
+					if name == "primed" && i == 1 {
+						continue
+					}
+					if errors.Is(err, ErrContractViolation) != tt.violates {
+						t.Errorf("key %d: unexpected error %v", i, err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestViolationHandler(t *testing.T) {
+	var details []string
+	handler := func(loaderName, detail string) {
+		details = append(details, loaderName+": "+detail)
+	}
+	fetchFn := func(keys []int) ([]*string, []error) {
+		v := "v"
+		return []*string{&v, &v, &v}, nil
+	}
+	loader := NewDataLoader(fetchFn, time.Millisecond, 0, WithName[int, string]("users"), WithViolationHandler[int, string](handler))
+
+	values, errs := loader.LoadAll([]int{1, 2})
+	if errs[0] != nil || errs[1] != nil || *values[0] != "v" || *values[1] != "v" {
+		t.Fatalf("expected the results as the fetch returned them, got %v %v", values, errs)
+	}
+	if len(details) != 1 || details[0] != "users: fetch returned 3 values for 2 keys" {
+		t.Fatalf("unexpected violations %q", details)
+	}
+}
+
+func TestStreamViolation(t *testing.T) {
+	fetchFn := func(keys []int, emit func(i int, v *string, err error)) {
+		v := "v"
+		emit(0, &v, nil)
+		emit(5, &v, nil)
+		keys[1] = 99
+	}
+	var details []string
+	loader := NewStreamingDataLoader(fetchFn, time.Millisecond, 0, WithViolationHandler[int, string](func(_, detail string) {
+		details = append(details, detail)
+	}))
+
+	values, errs := loader.LoadAll([]int{1, 2})
+	if errs[0] != nil || *values[0] != "v" {
+		t.Errorf("expected the emitted value, got %v %v", values[0], errs[0])
+	}
+	if !errors.Is(errs[1], ErrNotEmitted) {
+		t.Errorf("expected the position not emitted to fail, got %v", errs[1])
+	}
+	if len(details) != 2 || !strings.Contains(details[0], "emitted position 5") || !strings.Contains(details[1], "modified its keys") {
+		t.Errorf("unexpected violations %q", details)
+	}
+}
+
+func TestFetchCheckedAllocs(t *testing.T) {
+	keys, values := []int{1, 2}, make([]*string, 2)
+	fetchFn := func([]int) ([]*string, []error) { return values, nil }
+	lenient := NewDataLoader(fetchFn, time.Millisecond, 0)
+	if n := testing.AllocsPerRun(100, func() { lenient.fetchChecked(context.Background(), keys) }); n != 0 {
+		t.Errorf("expected loaders that report no violations not to copy the keys, got %v allocations", n)
+	}
+	strict := NewDataLoader(fetchFn, time.Millisecond, 0, WithStrict[int, string]())
+	if n := testing.AllocsPerRun(100, func() { strict.fetchChecked(context.Background(), keys) }); n != 1 {
+		t.Errorf("expected strict loaders to copy the keys once, got %v allocations", n)
+	}
+}
@@ -0,0 +1,166 @@
+package dataloaden
+
+import (
+	"context"
+	"time"
+)
+
+// TenantKey is a key together with the tenant it belongs to, see WrapTenant
+type TenantKey[K comparable] struct {
+	Tenant string
+	Key    K
+}
+
+// CtxLoader is a Loader keyed by TenantKey that takes plain keys and the tenant from the context of
+// every call, so call sites cannot forget to scope a key by its tenant. Two tenants never share a cached
+// entry, even for the same key.
+type CtxLoader[K comparable, V any] struct {
+	loader *Loader[TenantKey[K], V]
+	tenant func(ctx context.Context) string
+}
+
+// WrapTenant wraps loader so that every key is combined with the tenant tenantFrom returns for the context
+// of the call. Operations on the whole loader, such as ClearAll, Stats or Close, are left to the loader
+// returned by Loader.
+func WrapTenant[K comparable, V any](loader *Loader[TenantKey[K], V], tenantFrom func(ctx context.Context) string) *CtxLoader[K, V] {
+	return &CtxLoader[K, V]{loader: loader, tenant: tenantFrom}
+}
+
+// Loader returns the wrapped loader, which sees the keys of all tenants
+func (c *CtxLoader[K, V]) Loader() *Loader[TenantKey[K], V] {
+	return c.loader
+}
+
+func (c *CtxLoader[K, V]) key(ctx context.Context, key K) TenantKey[K] {
+	return TenantKey[K]{Tenant: c.tenant(ctx), Key: key}
+}
+
+func (c *CtxLoader[K, V]) keys(ctx context.Context, keys []K) []TenantKey[K] {
+	tenant := c.tenant(ctx)
+	tenantKeys := make([]TenantKey[K], len(keys))
+	for i, key := range keys {
+		tenantKeys[i] = TenantKey[K]{Tenant: tenant, Key: key}
+	}
+	return tenantKeys
+}
+
+// Load loads key of the tenant of ctx, like Loader.LoadCtx
+func (c *CtxLoader[K, V]) Load(ctx context.Context, key K) (*V, error) {
+	return c.loader.LoadCtx(ctx, c.key(ctx, key))
+}
+
+// LoadThunk returns a thunk for key of the tenant of ctx, like Loader.LoadThunkCtx
+func (c *CtxLoader[K, V]) LoadThunk(ctx context.Context, key K) func() (*V, error) {
+	return c.loader.LoadThunkCtx(ctx, c.key(ctx, key))
+}
+
+// LoadAll loads keys of the tenant of ctx, like Loader.LoadAllCtx
+func (c *CtxLoader[K, V]) LoadAll(ctx context.Context, keys []K) ([]*V, []error) {
+	return c.loader.LoadAllCtx(ctx, c.keys(ctx, keys))
+}
+
+// LoadAllThunk returns a thunk for keys of the tenant of ctx, like Loader.LoadAllThunkCtx
+func (c *CtxLoader[K, V]) LoadAllThunk(ctx context.Context, keys []K) func() ([]*V, []error) {
+	return c.loader.LoadAllThunkCtx(ctx, c.keys(ctx, keys))
+}
+
+// LoadAllOrError loads keys of the tenant of ctx, like Loader.LoadAllOrError
+func (c *CtxLoader[K, V]) LoadAllOrError(ctx context.Context, keys []K) ([]*V, error) {
+	return c.LoadAllThunkOrError(ctx, keys)()
+}
+
+// LoadAllThunkOrError returns a thunk for keys of the tenant of ctx, like Loader.LoadAllThunkOrError
+func (c *CtxLoader[K, V]) LoadAllThunkOrError(ctx context.Context, keys []K) func() ([]*V, error) {
+	return orError(c.LoadAllThunk(ctx, keys))
+}
+
+// LoadInto loads key of the tenant of ctx into dst, like Loader.LoadInto
+func (c *CtxLoader[K, V]) LoadInto(ctx context.Context, key K, dst *V) error {
+	return c.loader.LoadInto(c.key(ctx, key), dst)
+}
+
+// PeekInto copies the cached value for key of the tenant of ctx into dst, like Loader.PeekInto
+func (c *CtxLoader[K, V]) PeekInto(ctx context.Context, key K, dst *V) bool {
+	return c.loader.PeekInto(c.key(ctx, key), dst)
+}
+
+// LoadResult loads key of the tenant of ctx, like Loader.LoadResult
+func (c *CtxLoader[K, V]) LoadResult(ctx context.Context, key K) Result[*V] {
+	return c.loader.LoadResult(c.key(ctx, key))
+}
+
+// LoadAllResult loads keys of the tenant of ctx, like Loader.LoadAllResult
+func (c *CtxLoader[K, V]) LoadAllResult(ctx context.Context, keys []K) []Result[*V] {
+	return c.loader.LoadAllResult(c.keys(ctx, keys))
+}
+
+// LoadStale loads key of the tenant of ctx, like Loader.LoadStale
+func (c *CtxLoader[K, V]) LoadStale(ctx context.Context, key K, maxStale time.Duration) (*V, bool, error) {
+	return c.loader.LoadStale(c.key(ctx, key), maxStale)
+}
+
+// Info returns when and how the entry for key of the tenant of ctx was written, like Loader.Info
+func (c *CtxLoader[K, V]) Info(ctx context.Context, key K) (EntryInfo, bool) {
+	return c.loader.Info(c.key(ctx, key))
+}
+
+// Prefetch adds keys of the tenant of ctx to the current batch, like Loader.Prefetch
+func (c *CtxLoader[K, V]) Prefetch(ctx context.Context, keys ...K) {
+	c.loader.Prefetch(c.keys(ctx, keys)...)
+}
+
+// Prime primes key for the tenant of ctx only, like DataLoader.Prime
+func (c *CtxLoader[K, V]) Prime(ctx context.Context, key K, value *V) bool {
+	return c.loader.Prime(c.key(ctx, key), value)
+}
+
+func (c *CtxLoader[K, V]) txnBind(key TenantKey[K], value *V) (*loaderMutex, func() func(), error) {
+	return c.loader.txnBind(key, value)
+}
+
+// Clear removes key of the tenant of ctx from the cache
+func (c *CtxLoader[K, V]) Clear(ctx context.Context, key K) {
+	c.loader.Clear(c.key(ctx, key))
+}
+
+// ClearTenant removes every cached entry of tenant and returns how many were removed
+func (c *CtxLoader[K, V]) ClearTenant(tenant string) int {
+	return c.loader.ClearWhere(func(key TenantKey[K], _ *V) bool {
+		return key.Tenant == tenant
+	})
+}
+
+// InFlight reports whether key of the tenant of ctx is pending, like Loader.InFlight
+func (c *CtxLoader[K, V]) InFlight(ctx context.Context, key K) (<-chan struct{}, bool) {
+	return c.loader.InFlight(c.key(ctx, key))
+}
+
+// ClearAll empties the cache of all tenants
+func (c *CtxLoader[K, V]) ClearAll() {
+	c.loader.ClearAll()
+}
+
+// BumpEpoch makes every value cached so far stale, see Loader.BumpEpoch
+func (c *CtxLoader[K, V]) BumpEpoch() {
+	c.loader.BumpEpoch()
+}
+
+// Flush dispatches the currently collected batch
+func (c *CtxLoader[K, V]) Flush() {
+	c.loader.Flush()
+}
+
+// Close closes the underlying loader, see Loader.Close
+func (c *CtxLoader[K, V]) Close(ctx context.Context) error {
+	return c.loader.Close(ctx)
+}
+
+// Stats returns a snapshot of the counters of the underlying loader
+func (c *CtxLoader[K, V]) Stats() LoaderStats {
+	return c.loader.Stats()
+}
+
+// useSlots makes the underlying loader take its dispatch slots from c, see Group.RegisterWeighted
+func (c *CtxLoader[K, V]) useSlots(s *slotClient) {
+	c.loader.useSlots(s)
+}
@@ -0,0 +1,59 @@
+package dataloaden
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWrapTenant(t *testing.T) {
+	fetchFn := func(keys []TenantKey[int]) ([]*string, []error) {
+		results := make([]*string, len(keys))
+		for i, k := range keys {
+			v := k.Tenant + ":" + strconv.Itoa(k.Key)
+			results[i] = &v
+		}
+		return results, nil
+	}
+	loader := WrapTenant(NewDataLoader(fetchFn, time.Millisecond, 0), scopeOf)
+	acme, globex := withScope("acme"), withScope("globex")
+
+	if v, err := loader.Load(acme, 1); err != nil || *v != "acme:1" {
+		t.Fatalf("expected acme:1, got %v, %v", v, err)
+	}
+	if v, err := loader.Load(globex, 1); err != nil || *v != "globex:1" {
+		t.Fatalf("expected the other tenant to get its own entry, got %v, %v", v, err)
+	}
+
+	values, errs := loader.LoadAll(globex, []int{1, 2})
+	if errs[0] != nil || errs[1] != nil || *values[0] != "globex:1" || *values[1] != "globex:2" {
+		t.Errorf("unexpected values %v, %v", values, errs)
+	}
+
+	primed := "primed"
+	loader.Prime(acme, 3, &primed)
+	var v string
+	if loader.PeekInto(globex, 3, &v) {
+		t.Errorf("expected the primed key to stay with its tenant, got %q", v)
+	}
+
+	loader.Clear(acme, 1)
+	if loader.PeekInto(acme, 1, &v) || !loader.PeekInto(globex, 1, &v) {
+		t.Errorf("expected clear to remove the entry of its tenant only")
+	}
+
+	if n := loader.ClearTenant("globex"); n != 2 {
+		t.Errorf("expected 2 entries of globex to be removed, got %d", n)
+	}
+	if stats := loader.Loader().Stats(); stats.Batches != 3 {
+		t.Errorf("expected the underlying loader to report 3 batches, got %+v", stats)
+	}
+
+	canceled, cancel := context.WithCancel(acme)
+	cancel()
+	if _, errs := loader.LoadAll(canceled, []int{3, 9}); errs[0] != nil || !errors.Is(errs[1], context.Canceled) {
+		t.Errorf("expected the context to apply to LoadAll, got %v", errs)
+	}
+}
@@ -0,0 +1,52 @@
+package dataloaden
+
+import (
+	"errors"
+	"time"
+)
+
+// RetryAfterError is implemented by fetch errors that carry a scheduling hint from the backend, e.g. an
+// HTTP 429 with a Retry-After header. A batch failing with one is throttled rather than broken: loaders
+// created WithRetry wait RetryAfter before fetching again whatever the class of the error, and the circuit
+// breaker does not count it as a failure.
+type RetryAfterError interface {
+	error
+	RetryAfter() time.Duration
+}
+
+// retryAfter returns the hint of a throttled fetch error
+func retryAfter(err error) (time.Duration, bool) {
+	var throttled RetryAfterError
+	if err == nil || !errors.As(err, &throttled) {
+		return 0, false
+	}
+	return max(throttled.RetryAfter(), 0), true
+}
+
+// throttleNotice tells the waiters of a batch until when its fetch is waiting out a throttle. The fetch
+// swaps in a new notice and closes the channel of the old one, so waiters can tell whether the retry
+// resumes within their budget.
+type throttleNotice struct {
+	until   time.Time
+	changed chan struct{}
+}
+
+func newThrottleNotice(until time.Time) *throttleNotice {
+	return &throttleNotice{until: until, changed: make(chan struct{})}
+}
+
+// throttle announces to the waiters of the batch that the fetch resumes at until, waiters with a budget
+// that ends before then give up right away
+func (b *genericLoaderBatch[K, V]) throttle(until time.Time) {
+	if old := b.throttled.Swap(newThrottleNotice(until)); old != nil {
+		close(old.changed)
+	}
+}
+
+// throttleWait is how long the fetch waits before retrying a throttled batch, the hint capped by the budget
+func (l *Loader[K, V]) throttleWait(hint time.Duration) time.Duration {
+	if l.loadBudget > 0 {
+		return min(hint, l.loadBudget)
+	}
+	return hint
+}
@@ -0,0 +1,109 @@
+package dataloaden
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// throttleError is a fetch error asking to retry after a while, like an HTTP 429 with a Retry-After header
+type throttleError struct {
+	err   error
+	after time.Duration
+}
+
+func (e throttleError) Error() string             { return "throttled: " + e.err.Error() }
+func (e throttleError) Unwrap() error             { return e.err }
+func (e throttleError) RetryAfter() time.Duration { return e.after }
+
+func TestRetryAfter(t *testing.T) {
+	t.Run("retries after the hint", func(t *testing.T) {
+		clock := newFakeClock()
+		f := &scriptedFetch{script: []error{throttleError{err: errCorrupt, after: 30 * time.Second}}}
+		loader := NewDataLoader(f.fetch, 0, 1,
+			WithClock[int, string](clock),
+			WithClassifyError[int, string](classifyTestError),
+			WithRetry[int, string](2, time.Second),
+		)
+
+		done := make(chan error)
+		go func() {
+			_, err := loader.Load(1)
+			done <- err
+		}()
+
+		awaitClock(t, clock, f, 1, 1)
+		clock.Advance(time.Second)
+		awaitClock(t, clock, f, 1, 1)
+		clock.Advance(29 * time.Second)
+		if err := <-done; err != nil {
+			t.Fatalf("expected the retry to succeed, got %v", err)
+		}
+		if stats := loader.Stats(); stats.ThrottledBatches != 1 || stats.Retries != 1 || stats.FailedBatches != 0 {
+			t.Errorf("expected 1 throttled batch and 1 retry, got %+v", stats)
+		}
+	})
+
+	t.Run("does not trip the breaker", func(t *testing.T) {
+		clock := newFakeClock()
+		throttled := throttleError{err: errCorrupt, after: time.Second}
+		f := &scriptedFetch{script: []error{throttled, throttled}}
+		loader := NewDataLoader(f.fetch, 0, 1,
+			WithClock[int, string](clock),
+			WithClassifyError[int, string](classifyTestError),
+			WithCircuitBreaker[int, string](1, time.Minute),
+		)
+
+		if _, err := loader.Load(1); !errors.As(err, new(RetryAfterError)) {
+			t.Fatalf("expected the throttle error, got %v", err)
+		}
+		if _, err := loader.Load(2); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected a throttled batch not to open the breaker, got %v", err)
+		}
+		if n := f.calls.Load(); n != 2 {
+			t.Errorf("expected 2 fetches, got %d", n)
+		}
+		if stats := loader.Stats(); stats.ThrottledBatches != 2 {
+			t.Errorf("expected 2 throttled batches, got %d", stats.ThrottledBatches)
+		}
+	})
+
+	t.Run("waiters past their budget give up right away", func(t *testing.T) {
+		clock := newFakeClock()
+		f := &scriptedFetch{script: []error{throttleError{err: errTimeout, after: time.Minute}}}
+		loader := NewDataLoader(f.fetch, 5*time.Second, 0,
+			WithClock[int, string](clock),
+			WithClassifyError[int, string](classifyTestError),
+			WithRetry[int, string](1, 0),
+			WithLoadBudget[int, string](10*time.Second),
+		)
+
+		done := make(chan error)
+		go func() {
+			_, err := loader.Load(1)
+			done <- err
+		}()
+
+		// window and budget, then only the throttle capped by the budget once the waiter gave up
+		awaitClock(t, clock, f, 0, 2)
+		go clock.Advance(5 * time.Second) // the window timer runs the fetch, which sleeps on the clock
+		if err := <-done; !errors.Is(err, ErrBudgetExceeded) {
+			t.Fatalf("expected ErrBudgetExceeded before the budget ran out, got %v", err)
+		}
+		awaitClock(t, clock, f, 1, 1)
+		if clock.Now().Sub(time.Unix(1_700_000_000, 0)) != 5*time.Second {
+			t.Errorf("expected the waiter to give up without the clock moving, got %v", clock.Now())
+		}
+
+		// the batch carries on and caches the retry
+		clock.Advance(10 * time.Second)
+		awaitClock(t, clock, f, 2, 0)
+		var v string
+		for !loader.PeekInto(1, &v) {
+			time.Sleep(time.Millisecond)
+		}
+		if v != "v1" {
+			t.Errorf("expected v1 to be cached, got %q", v)
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package dataloaden
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrTransformPanic is wrapped by the error of a key whose value transform panicked, see WithTransformValue
+var ErrTransformPanic = errors.New("dataloaden: value transform panicked")
+
+// transformValues applies the value transform to the successful results of a fetch, see WithTransformValue.
+// A transform that panics fails its key, which turns errs into one error per key. Batches that failed as a
+// whole are returned as they are.
+func (l *Loader[K, V]) transformValues(keys []K, data []*V, errs []error, err error) ([]*V, []error, error) {
+	aligned := len(errs) == len(keys)
+	if !aligned && err != nil {
+		return data, errs, err
+	}
+
+	// the fetched slice may be shared, e.g. with the batch memo
+	data = slices.Clone(data)
+	failed := false
+	for i := range min(len(data), len(keys)) {
+		if data[i] == nil || aligned && errs[i] != nil {
+			continue
+		}
+		var terr error
+		data[i], terr = l.transformed(keys[i], data[i])
+		if terr != nil {
+			if !aligned {
+				errs, aligned = make([]error, len(keys)), true
+			}
+			errs[i], failed = terr, true
+		}
+	}
+	if failed {
+		err = joinBatchErrors(errs)
+	}
+	return data, errs, err
+}
+
+// transformed applies the value transform to a single value, turning a panic into an error
+func (l *Loader[K, V]) transformed(key K, value *V) (v *V, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			v, err = nil, l.namedError(fmt.Errorf("%w: %v", ErrTransformPanic, r))
+		}
+	}()
+	return l.valueTransform(key, value), nil
+}
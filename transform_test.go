@@ -0,0 +1,115 @@
+package dataloaden
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// paddedFetch returns values with legacy padding, and "secret" for key 0
+func paddedFetch(keys []int) ([]*string, []error) {
+	data := make([]*string, len(keys))
+	for i, key := range keys {
+		v := "  v" + string(rune('0'+key)) + "  "
+		if key == 0 {
+			v = "secret"
+		}
+		data[i] = &v
+	}
+	return data, nil
+}
+
+// trimRedact trims the padding of every value and rejects secrets
+func trimRedact(_ int, value *string) *string {
+	if *value == "secret" {
+		return nil
+	}
+	v := strings.TrimSpace(*value)
+	return &v
+}
+
+func TestTransformValue(t *testing.T) {
+	t.Run("normalizes before caching", func(t *testing.T) {
+		var calls int
+		loader := NewDataLoader(paddedFetch, time.Millisecond, 0, WithTransformValue(func(key int, value *string) *string {
+			calls++
+			return trimRedact(key, value)
+		}))
+
+		values, errs := loader.LoadAll([]int{1, 2})
+		for i, v := range values {
+			if errs[i] != nil || *v != "v"+string(rune('1'+i)) {
+				t.Errorf("expected the trimmed value, got %q, %v", *v, errs[i])
+			}
+		}
+		var cached string
+		if !loader.PeekInto(1, &cached) || cached != "v1" {
+			t.Errorf("expected the trimmed value in the cache, got %q", cached)
+		}
+		primed := "  p3  "
+		loader.Prime(3, &primed)
+		if v, _ := loader.Load(3); *v != "  p3  " {
+			t.Errorf("expected primed values not to be transformed, got %q", *v)
+		}
+		if calls != 2 {
+			t.Errorf("expected the transform to run once per fetched value, got %d", calls)
+		}
+	})
+
+	t.Run("rejected values are missing", func(t *testing.T) {
+		loader := NewDataLoader(paddedFetch, time.Millisecond, 0, WithTransformValue(trimRedact))
+		result := loader.LoadResult(0)
+		if result.Value != nil || result.Found || result.Err != nil {
+			t.Errorf("expected the rejected value to be missing, got %+v", result)
+		}
+
+		errRedacted := errors.New("redacted")
+		loader = NewDataLoader(paddedFetch, time.Millisecond, 0, WithTransformValue(trimRedact),
+			WithMissingValueError[int, string](func(int) error { return errRedacted }))
+		thunks := []func() (*string, error){loader.LoadThunk(0), loader.LoadThunk(1)}
+		if _, err := thunks[0](); !errors.Is(err, errRedacted) {
+			t.Errorf("expected the missing value policy to apply, got %v", err)
+		}
+		if v, err := thunks[1](); err != nil || *v != "v1" {
+			t.Errorf("expected the other key to be unaffected, got %v, %v", v, err)
+		}
+	})
+
+	t.Run("panics fail the key", func(t *testing.T) {
+		loader := NewDataLoader(paddedFetch, time.Millisecond, 0, WithName[int, string]("users"),
+			WithTransformValue(func(key int, value *string) *string {
+				if key == 2 {
+					panic("bad value")
+				}
+				return value
+			}))
+		_, err := loader.Load(2)
+		if !errors.Is(err, ErrTransformPanic) || !strings.Contains(err.Error(), "bad value") {
+			t.Errorf("expected ErrTransformPanic, got %v", err)
+		}
+		var cached string
+		if loader.PeekInto(2, &cached) {
+			t.Error("expected the failed key not to be cached")
+		}
+		if v, err := loader.Load(1); err != nil || *v != "  v1  " {
+			t.Errorf("unexpected result %v, %v", v, err)
+		}
+	})
+
+	t.Run("streaming", func(t *testing.T) {
+		loader := NewStreamingDataLoader(func(keys []int, emit func(int, *string, error)) {
+			data, _ := paddedFetch(keys)
+			for i := range keys {
+				emit(i, data[i], nil)
+			}
+		}, time.Millisecond, 0, WithTransformValue(trimRedact))
+		thunks := []func() (*string, error){loader.LoadThunk(0), loader.LoadThunk(1)}
+		if v, err := thunks[0](); v != nil || err != nil {
+			t.Errorf("expected the rejected value to be missing, got %v, %v", v, err)
+		}
+		if v, err := thunks[1](); err != nil || *v != "v1" {
+			t.Errorf("expected the trimmed value, got %v, %v", v, err)
+		}
+	})
+}
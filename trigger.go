@@ -0,0 +1,60 @@
+package dataloaden
+
+// TriggerReason tells why a batch was dispatched
+type TriggerReason uint8
+
+const (
+	// WaitExpired batches were dispatched because the batch window elapsed
+	WaitExpired TriggerReason = iota + 1
+
+	// MaxBatchReached batches were dispatched because they reached maxBatch keys or their maximum weight
+	MaxBatchReached
+
+	// ManualFlush batches were dispatched by Flush, Close or SetMaxBatch
+	ManualFlush
+
+	// DeadlineFlush batches were dispatched early because the deadline of one of their waiters was
+	// about to expire, see WithDeadlineFlush
+	DeadlineFlush
+
+	// SingletonFlush batches were dispatched as soon as their only key was waited for, see WithEagerSingle
+	SingletonFlush
+
+	// ReentrantFlush batches were dispatched right away because their key was loaded from within the
+	// loader's own fetch, see WithReentrantPolicy
+	ReentrantFlush
+
+	// SlideExpired batches were dispatched because no key arrived for the slide of their window, see
+	// WithSlidingWindow
+	SlideExpired
+
+	// MaxWaitExpired batches were dispatched because keys kept sliding their window until it reached
+	// maxWait, see WithSlidingWindow
+	MaxWaitExpired
+
+	// sizes the per reason counters, keep last
+	triggerReasonCount = iota + 1
+)
+
+func (r TriggerReason) String() string {
+	switch r {
+	case WaitExpired:
+		return "wait expired"
+	case MaxBatchReached:
+		return "max batch reached"
+	case ManualFlush:
+		return "manual flush"
+	case DeadlineFlush:
+		return "deadline flush"
+	case SingletonFlush:
+		return "singleton flush"
+	case ReentrantFlush:
+		return "reentrant flush"
+	case SlideExpired:
+		return "slide expired"
+	case MaxWaitExpired:
+		return "max wait expired"
+	default:
+		return "unknown"
+	}
+}
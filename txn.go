@@ -0,0 +1,157 @@
+package dataloaden
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrTxnMember is returned by Txn.Commit when a prime names a loader that is not registered with the
+// group, or that does not take keys and values of the types of the prime
+var ErrTxnMember = errors.New("dataloaden: invalid transaction member")
+
+// Txn collects primes against loaders of a Group and applies them in a single step, so a reader never
+// sees some of them cached and others missing, e.g. an order cached while its customer is not. Create it
+// with Group.Txn and add primes with TxnPrime. It is not safe for concurrent use.
+type Txn struct {
+	group  *Group
+	primes []txnPrime
+}
+
+// txnPrime is a prime of a Txn, independent of the types of its loader
+type txnPrime struct {
+	name string
+
+	// bind checks the loader registered as name, and returns its lock and the prime to apply while the
+	// lock is held. apply returns how to take the prime back, nil when it did not store anything.
+	bind func(member GroupMember) (mu *loaderMutex, apply func() (undo func()), err error)
+}
+
+// txnMember is implemented by the loaders TxnPrime can prime keys of type K with values of type V: Loader,
+// and the wrappers of one, which prime their underlying loader
+type txnMember[K any, V any] interface {
+	txnBind(key K, value *V) (mu *loaderMutex, apply func() (undo func()), err error)
+}
+
+// Txn starts a transaction that primes loaders of the group in a single step, see Txn
+func (g *Group) Txn() *Txn {
+	return &Txn{group: g}
+}
+
+// TxnPrime adds a prime of key to the loader registered as name to txn, it is applied by Txn.Commit
+// like DataLoader.Prime would: a key that is already cached keeps its value. The loader may be a Loader or
+// a wrapper of one: a HashedLoader takes its own keys, a ScopedLoader takes ScopedKeys and a CtxLoader
+// takes TenantKeys, since a transaction has no context to take the scope or tenant from.
+func TxnPrime[K any, V any](txn *Txn, name string, key K, value *V) {
+	txn.primes = append(txn.primes, txnPrime{name: name, bind: func(member GroupMember) (*loaderMutex, func() func(), error) {
+		m, ok := member.(txnMember[K, V])
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %q has other key or value types", ErrTxnMember, name)
+		}
+		return m.txnBind(key, value)
+	}})
+}
+
+// txnBind checks key and returns the lock of the loader and the prime of key to apply while it is held
+func (l *Loader[K, V]) txnBind(key K, value *V) (*loaderMutex, func() func(), error) {
+	key, err := l.checkKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	l.InvalidateBatchMemo()
+	return &l.mu, func() func() { return l.unsafeTxnPrime(key, value) }, nil
+}
+
+// Commit applies the primes of the transaction. It holds the locks of every loader involved at once,
+// acquired in the order of their names so concurrent transactions cannot deadlock, and releases them
+// once all primes are applied. Nothing is applied when a prime names an unknown loader or an invalid key.
+// When a prime panics, e.g. in the Clone of its value, the primes applied so far are taken back before
+// the panic is passed on; entries evicted to make room for them stay evicted. A loader shared by several
+// groups must have the same name in all of them.
+func (t *Txn) Commit() error {
+	type step struct {
+		name  string
+		mu    *loaderMutex
+		apply func() func()
+	}
+	steps := make([]step, 0, len(t.primes))
+	for _, p := range t.primes {
+		member, ok := t.group.member(p.name)
+		if !ok {
+			return fmt.Errorf("%w: %q is not registered", ErrTxnMember, p.name)
+		}
+		mu, apply, err := p.bind(member)
+		if err != nil {
+			return err
+		}
+		steps = append(steps, step{name: p.name, mu: mu, apply: apply})
+	}
+
+	ordered := slices.Clone(steps)
+	slices.SortStableFunc(ordered, func(a, b step) int {
+		return cmp.Compare(a.name, b.name)
+	})
+	var locked []*loaderMutex
+	for _, s := range ordered {
+		if !slices.Contains(locked, s.mu) {
+			s.mu.Lock()
+			locked = append(locked, s.mu)
+		}
+	}
+
+	var undos []func()
+	defer func() {
+		if r := recover(); r != nil {
+			for i := len(undos) - 1; i >= 0; i-- {
+				undos[i]()
+			}
+			unlockAll(locked)
+			panic(r)
+		}
+		unlockAll(locked)
+	}()
+	for _, s := range steps {
+		if undo := s.apply(); undo != nil {
+			undos = append(undos, undo)
+		}
+	}
+	return nil
+}
+
+// unsafeTxnPrime primes key like Prime and returns how to take it back, nil when nothing was stored
+func (l *Loader[K, V]) unsafeTxnPrime(key K, value *V) func() {
+	if p, pending := l.pending[key]; pending && l.primePolicy == PreferPrimed {
+		if !l.unsafePrimePending(p, key, value) {
+			return nil
+		}
+		return func() {
+			delete(p.batch.overrides, p.pos)
+			l.unsafeDelete(key)
+		}
+	}
+	if !l.unsafePrime(key, value, SourcePrime) {
+		return nil
+	}
+	return func() { l.unsafeDelete(key) }
+}
+
+// member returns the loader registered as name, constructing it when it is lazy
+func (g *Group) member(name string) (GroupMember, bool) {
+	g.mu.Lock()
+	member, ok := g.loaders[name]
+	g.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	if lazy, ok := member.(lazyMember); ok {
+		return lazy.constructed(), true
+	}
+	return member, true
+}
+
+func unlockAll(locked []*loaderMutex) {
+	for i := len(locked) - 1; i >= 0; i-- {
+		locked[i].Unlock()
+	}
+}
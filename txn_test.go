@@ -0,0 +1,251 @@
+package dataloaden
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fragile is a value whose Clone panics when it is marked bad
+type fragile struct {
+	name string
+	bad  bool
+}
+
+func (f fragile) Clone() fragile {
+	if f.bad {
+		panic("bad value")
+	}
+	return f
+}
+
+func TestTxn(t *testing.T) {
+	t.Run("primes every loader", func(t *testing.T) {
+		var g Group
+		orders, ordersRec := newStringLoader(t, 0)
+		customers, customersRec := newStringLoader(t, 0)
+		_ = g.Register("orders", orders)
+		_ = g.Register("customers", customers)
+
+		order, customer := "order", "customer"
+		txn := g.Txn()
+		TxnPrime(txn, "orders", 1, &order)
+		TxnPrime(txn, "customers", 7, &customer)
+		if err := txn.Commit(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if v, _ := orders.Load(1); *v != "order" {
+			t.Errorf("expected the primed order, got %q", *v)
+		}
+		if v, _ := customers.Load(7); *v != "customer" {
+			t.Errorf("expected the primed customer, got %q", *v)
+		}
+		if ordersRec.callCount() != 0 || customersRec.callCount() != 0 {
+			t.Error("expected no fetches")
+		}
+	})
+
+	t.Run("invalid members apply nothing", func(t *testing.T) {
+		var g Group
+		orders, _ := newStringLoader(t, 0)
+		_ = g.Register("orders", orders)
+		_ = g.Register("counts", NewDataLoader(func(keys []int) ([]*int, []error) {
+			return make([]*int, len(keys)), nil
+		}, 0, 0))
+
+		v := "order"
+		for _, name := range []string{"missing", "counts"} {
+			txn := g.Txn()
+			TxnPrime(txn, "orders", 1, &v)
+			TxnPrime(txn, name, 2, &v)
+			if err := txn.Commit(); !errors.Is(err, ErrTxnMember) {
+				t.Errorf("%s: expected ErrTxnMember, got %v", name, err)
+			}
+		}
+		var cached string
+		if orders.PeekInto(1, &cached) {
+			t.Error("expected nothing to be primed")
+		}
+	})
+
+	t.Run("rolls back on panic", func(t *testing.T) {
+		var g Group
+		orders, _ := newStringLoader(t, 0)
+		items := NewDataLoader(func(keys []int) ([]*fragile, []error) {
+			return make([]*fragile, len(keys)), nil
+		}, 0, 0, WithMaxCacheBytes[int, fragile](1024, func(int, *fragile) int { return 1 }))
+		_ = g.Register("orders", orders)
+		_ = g.Register("items", items)
+
+		order := "order"
+		txn := g.Txn()
+		TxnPrime(txn, "orders", 1, &order)
+		TxnPrime(txn, "items", 1, &fragile{name: "a"})
+		TxnPrime(txn, "items", 2, &fragile{name: "b", bad: true})
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected the panic to be passed on")
+				}
+			}()
+			_ = txn.Commit()
+		}()
+
+		var cached string
+		if orders.PeekInto(1, &cached) {
+			t.Error("expected the order to be rolled back")
+		}
+		var item fragile
+		if items.PeekInto(1, &item) {
+			t.Error("expected the item to be rolled back")
+		}
+		if stats := items.Stats(); stats.CacheBytes != 0 {
+			t.Errorf("expected the rolled back item not to count, got %d bytes", stats.CacheBytes)
+		}
+		if v, _ := orders.Load(2); *v != "v2" {
+			t.Errorf("expected the loader to be unlocked, got %q", *v)
+		}
+	})
+
+	t.Run("primes pending keys", func(t *testing.T) {
+		var g Group
+		rec := &recordingFetch{}
+		orders := NewDataLoader(rec.fetch, time.Hour, 0, WithPrimePolicy[int, string](PreferPrimed))
+		_ = g.Register("orders", orders)
+
+		thunk := orders.LoadThunk(1)
+		v := "order"
+		txn := g.Txn()
+		TxnPrime(txn, "orders", 1, &v)
+		if err := txn.Commit(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		orders.Flush()
+		if got, _ := thunk(); *got != "order" {
+			t.Errorf("expected the waiter to get the primed value, got %q", *got)
+		}
+		if n := rec.callCount(); n != 0 {
+			t.Errorf("expected the primed key to be left out of the fetch, got %d fetches", n)
+		}
+	})
+
+	t.Run("wrappers", func(t *testing.T) {
+		var g Group
+		hashed := NewHashedDataLoader(func(g grant) uint64 { return uint64(len(g.user)) }, equalGrants, (&grantFetch{}).fetch, 0, 0)
+		scoped := NewScopedDataLoader(scopeOf, (&scopedFetch{}).fetch, 0, 0)
+		tenants := WrapTenant(NewDataLoader(func(keys []TenantKey[int]) ([]*string, []error) {
+			return make([]*string, len(keys)), nil
+		}, 0, 0), scopeOf)
+		_, _ = RegisterLazy(&g, "grants", func() *HashedLoader[grant, string] { return hashed })
+		_ = g.Register("scoped", scoped)
+		_ = g.Register("tenants", tenants)
+
+		var acquisitions atomic.Uint64
+		hashed.loader.mu.acquisitions = &acquisitions
+		v := "primed"
+		txn := g.Txn()
+		TxnPrime(txn, "grants", grant{user: "ann", scopes: []string{"read"}}, &v)
+		TxnPrime(txn, "scoped", ScopedKey[int]{Scope: "alice", Key: 1}, &v)
+		TxnPrime(txn, "tenants", TenantKey[int]{Tenant: "acme", Key: 1}, &v)
+		if err := txn.Commit(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if acquisitions.Load() == 0 {
+			t.Error("expected the lock to be taken through the loader's mutex")
+		}
+
+		if got, _ := hashed.Load(grant{user: "ann", scopes: []string{"read"}}); *got != "primed" {
+			t.Errorf("expected the primed grant, got %q", *got)
+		}
+		if got, _ := scoped.Load(withScope("alice"), 1); *got != "primed" {
+			t.Errorf("expected the primed value in alice's scope, got %q", *got)
+		}
+		if got, _ := scoped.Load(withScope("bob"), 1); *got != "bob:1" {
+			t.Errorf("expected bob's own value, got %q", *got)
+		}
+		if got, _ := tenants.Load(withScope("acme"), 1); got == nil || *got != "primed" {
+			t.Errorf("expected the primed value for the tenant, got %v", got)
+		}
+
+		txn = g.Txn()
+		TxnPrime(txn, "scoped", 1, &v)
+		if err := txn.Commit(); !errors.Is(err, ErrTxnMember) {
+			t.Errorf("expected plain keys of a scoped loader to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("lazy members are constructed", func(t *testing.T) {
+		var g Group
+		rec := &recordingFetch{}
+		lazy, _ := RegisterLazy(&g, "orders", func() *Loader[int, string] {
+			return NewDataLoader(rec.fetch, 0, 0)
+		})
+		v := "order"
+		txn := g.Txn()
+		TxnPrime(txn, "orders", 1, &v)
+		if err := txn.Commit(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, _ := lazy.Get().Load(1); *got != "order" {
+			t.Errorf("expected the primed value, got %q", *got)
+		}
+	})
+}
+
+func TestTxnNoTornReads(t *testing.T) {
+	var g Group
+	names := []string{"orders", "items", "customers"}
+	loaders := make([]*Loader[int, string], len(names))
+	for i, name := range names {
+		loaders[i], _ = newStringLoader(t, 0)
+		_ = g.Register(name, loaders[i])
+	}
+
+	const txns = 200
+	var next atomic.Int32
+	var torn atomic.Int32
+	var wg sync.WaitGroup
+	for w := range 4 {
+		wg.Go(func() {
+			for {
+				key := int(next.Add(1))
+				if key > txns {
+					return
+				}
+				txn := g.Txn()
+				for i := range names {
+					// every writer lists the loaders in another order, the locks are taken by name
+					name := names[(i+w)%len(names)]
+					v := name + strconv.Itoa(key)
+					TxnPrime(txn, name, key, &v)
+				}
+				if err := txn.Commit(); err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+	for range 4 {
+		wg.Go(func() {
+			for key := 1; key <= txns; {
+				var v string
+				if !loaders[0].PeekInto(key, &v) {
+					continue
+				}
+				for _, loader := range loaders[1:] {
+					if !loader.PeekInto(key, &v) {
+						torn.Add(1)
+					}
+				}
+				key++
+			}
+		})
+	}
+	wg.Wait()
+	if n := torn.Load(); n != 0 {
+		t.Errorf("expected readers to never see part of a transaction, got %d torn reads", n)
+	}
+}
@@ -0,0 +1,120 @@
+package dataloaden
+
+import (
+	"fmt"
+	"slices"
+)
+
+// FetchRule names a part of the fetch contract, see ValidateFetch
+type FetchRule string
+
+const (
+	// the fetch must not modify the keys it is called with
+	RuleKeysUnchanged FetchRule = "keys-unchanged"
+
+	// the fetch returns one value per key, or none at all when it failed
+	RuleValueCount FetchRule = "value-count"
+
+	// the fetch returns no errors, one error for the whole batch or one error per key
+	RuleErrorCount FetchRule = "error-count"
+
+	// a key gets either a value or an error, never both
+	RuleValueOrError FetchRule = "value-or-error"
+
+	// every sample key is found: it gets a value and no error
+	RuleFound FetchRule = "found"
+
+	// the value at a position belongs to the key at that position
+	RuleOrder FetchRule = "order"
+
+	// no two keys get the same value
+	RuleUnique FetchRule = "unique"
+)
+
+// FetchViolation is a rule of the fetch contract that a fetch broke, see ValidateFetch
+type FetchViolation struct {
+	Rule   FetchRule
+	Detail string
+}
+
+func (v FetchViolation) Error() string {
+	return fmt.Sprintf("%v: %s: %s", ErrContractViolation, v.Rule, v.Detail)
+}
+
+func (v FetchViolation) Unwrap() error {
+	return ErrContractViolation
+}
+
+// ValidateFetch calls fetch once with sampleKeys and returns every rule of the fetch contract its results
+// broke, nil when it kept all of them. It is meant for unit tests with fixture keys and is never called by
+// a loader.
+//
+// The sample keys should be distinct keys that exist, a key without a value breaks RuleFound. keyOf returns
+// the key a value belongs to, which checks RuleOrder and RuleUnique, those are skipped when it is nil.
+func ValidateFetch[K comparable, V any](fetch func(keys []K) ([]*V, []error), sampleKeys []K, keyOf func(*V) K) []FetchViolation {
+	var violations []FetchViolation
+	report := func(rule FetchRule, format string, args ...any) {
+		violations = append(violations, FetchViolation{Rule: rule, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	keys := slices.Clone(sampleKeys)
+	data, errs := fetch(keys)
+
+	if !slices.Equal(keys, sampleKeys) {
+		report(RuleKeysUnchanged, "fetch was called with %v and left %v", sampleKeys, keys)
+	}
+	// a fetch that failed may return no values at all
+	if len(data) != len(sampleKeys) && !(data == nil && joinBatchErrors(errs) != nil) {
+		report(RuleValueCount, "fetch returned %d values for %d keys", len(data), len(sampleKeys))
+	}
+	if len(errs) > 1 && len(errs) != len(sampleKeys) {
+		report(RuleErrorCount, "fetch returned %d errors for %d keys, expected none, one for the batch or one per key", len(errs), len(sampleKeys))
+	}
+	batchFailed := len(errs) == 1 && len(sampleKeys) != 1 && errs[0] != nil
+	if batchFailed {
+		report(RuleFound, "fetch failed for the whole batch: %v", errs[0])
+	}
+
+	seen := map[K]int{}
+	pointers := map[*V]int{}
+	for i, key := range sampleKeys {
+		var v *V
+		if i < len(data) {
+			v = data[i]
+		}
+		var err error
+		if len(errs) == len(sampleKeys) {
+			err = errs[i]
+		}
+
+		switch {
+		case v != nil && err != nil:
+			report(RuleValueOrError, "key %v at position %d got both a value and the error %v", key, i, err)
+		case err != nil:
+			report(RuleFound, "key %v at position %d failed: %v", key, i, err)
+		case v == nil && !batchFailed:
+			report(RuleFound, "key %v at position %d got neither a value nor an error", key, i)
+		}
+		if v == nil {
+			continue
+		}
+
+		if keyOf == nil {
+			// without keyOf only values shared by pointer are detected
+			if first, ok := pointers[v]; ok {
+				report(RuleUnique, "keys %v and %v at positions %d and %d got the same value", sampleKeys[first], key, first, i)
+			}
+			pointers[v] = i
+			continue
+		}
+		owner := keyOf(v)
+		if owner != key {
+			report(RuleOrder, "key %v at position %d got the value of key %v", key, i, owner)
+		}
+		if first, ok := seen[owner]; ok {
+			report(RuleUnique, "positions %d and %d both got a value of key %v", first, i, owner)
+		}
+		seen[owner] = i
+	}
+	return violations
+}
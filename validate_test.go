@@ -0,0 +1,122 @@
+package dataloaden
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+type user struct {
+	id   int
+	name string
+}
+
+func userOf(u *user) int {
+	return u.id
+}
+
+func rules(violations []FetchViolation) []FetchRule {
+	var rules []FetchRule
+	for _, v := range violations {
+		rules = append(rules, v.Rule)
+	}
+	return rules
+}
+
+func TestValidateFetch(t *testing.T) {
+	fetchUsers := func(keys []int) []*user {
+		users := make([]*user, len(keys))
+		for i, k := range keys {
+			users[i] = &user{id: k, name: "user" + strconv.Itoa(k)}
+		}
+		return users
+	}
+	errDown := errors.New("down")
+
+	tests := []struct {
+		name  string
+		fetch func(keys []int) ([]*user, []error)
+		want  []FetchRule
+	}{
+		{
+			name: "valid",
+			fetch: func(keys []int) ([]*user, []error) {
+				return fetchUsers(keys), nil
+			},
+		},
+		{
+			name: "reordered",
+			fetch: func(keys []int) ([]*user, []error) {
+				users := fetchUsers(keys)
+				slices.Reverse(users)
+				return users, nil
+			},
+			want: []FetchRule{RuleOrder, RuleOrder},
+		},
+		{
+			name: "duplicated",
+			fetch: func(keys []int) ([]*user, []error) {
+				users := fetchUsers(keys)
+				return []*user{users[0], users[0], users[2]}, nil
+			},
+			want: []FetchRule{RuleOrder, RuleUnique},
+		},
+		{
+			name: "short with misaligned errors",
+			fetch: func(keys []int) ([]*user, []error) {
+				return fetchUsers(keys)[:2], []error{nil, nil}
+			},
+			want: []FetchRule{RuleValueCount, RuleErrorCount, RuleFound},
+		},
+		{
+			name: "value and error",
+			fetch: func(keys []int) ([]*user, []error) {
+				return fetchUsers(keys), []error{nil, errDown, nil}
+			},
+			want: []FetchRule{RuleValueOrError},
+		},
+		{
+			name: "failed batch",
+			fetch: func(keys []int) ([]*user, []error) {
+				return nil, []error{errDown}
+			},
+			want: []FetchRule{RuleFound},
+		},
+		{
+			name: "modified keys",
+			fetch: func(keys []int) ([]*user, []error) {
+				users := fetchUsers(keys)
+				keys[0] = 0
+				return users, nil
+			},
+			want: []FetchRule{RuleKeysUnchanged},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := ValidateFetch(tt.fetch, []int{1, 2, 3}, userOf)
+			if got := rules(violations); !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, violations)
+			}
+			if tt.want == nil && ValidateFetch(tt.fetch, []int{1, 2, 3}, nil) != nil {
+				t.Errorf("expected no violations without keyOf either")
+			}
+			for _, v := range violations {
+				if !errors.Is(v, ErrContractViolation) {
+					t.Errorf("expected %v to wrap ErrContractViolation", v)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateFetchWithoutKeyOf(t *testing.T) {
+	shared := &user{id: 1}
+	violations := ValidateFetch(func(keys []int) ([]*user, []error) {
+		return []*user{shared, shared}, nil
+	}, []int{1, 2}, nil)
+	if got := rules(violations); !slices.Equal(got, []FetchRule{RuleUnique}) {
+		t.Errorf("expected the shared value to be detected, got %v", violations)
+	}
+}
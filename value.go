@@ -0,0 +1,86 @@
+package dataloaden
+
+import "time"
+
+// The loaders carry values as *V, a nil pointer is a missing value whatever V is. Fetches that produce
+// values rather than pointers have no nil to express absence with, a missing int looks like a legitimate 0,
+// so the constructors below make absence explicit. LoadResult reports Found as follows:
+//
+//	fetch                                   result for the key          Found  value
+//	NewMapDataLoader                        key absent from the map     false  nil
+//	NewMapDataLoader                        key present, even zero      true   the value
+//	NewValueDataLoader                      beyond the returned values  false  nil (or Missing, see below)
+//	NewValueDataLoader                      zero value                  true   the zero value
+//	NewValueDataLoader, IsZeroMissing       zero value                  false  nil (or Missing, see below)
+//	NewValueDataLoader, Missing set         missing as above            true   what Missing returned
+//	NewValueDataLoader, Missing set         Missing returned an error   false  the error fails the key
+//
+// Absent keys are subject to WithMissingValueError like with any other fetch. The map fetch is the
+// recommended contract for value types: absence is stated by the fetch itself and not inferred from zero.
+
+// NewMapDataLoader creates a loader whose fetch returns the values of the keys it found by key. Keys that
+// are absent from the map are missing values, the error fails every key of the batch.
+func NewMapDataLoader[K comparable, V any](fetchFn func(keys []K) (map[K]V, error), waitDuration time.Duration, maxBatch int, opts ...Option[K, V]) *Loader[K, V] {
+	return NewDataLoader(func(keys []K) ([]*V, []error) {
+		found, err := fetchFn(keys)
+		if err != nil {
+			return nil, []error{err}
+		}
+		results := make([]*V, len(keys))
+		for i, key := range keys {
+			if v, ok := found[key]; ok {
+				results[i] = &v
+			}
+		}
+		return results, nil
+	}, waitDuration, maxBatch, opts...)
+}
+
+// MissingValues decides which results of a NewValueDataLoader fetch are missing values, and what loads
+// of those keys return
+type MissingValues[K comparable, V any] struct {
+	// treat zero values as missing instead of as values that were found. Builds with the minimal tag
+	// recognize zero values by comparison only, values that are not comparable are never zero.
+	IsZeroMissing bool
+
+	// when set, produces the value for missing keys, which is cached as found. An error fails the key
+	// like an error the fetch returned for it.
+	Missing func(key K) (V, error)
+}
+
+// NewValueDataLoader creates a loader whose fetch returns values aligned with the keys rather than pointers.
+// Keys beyond the returned values are missing, and zero values are too when missing says so.
+func NewValueDataLoader[K comparable, V any](fetchFn func(keys []K) ([]V, []error), missing MissingValues[K, V], waitDuration time.Duration, maxBatch int, opts ...Option[K, V]) *Loader[K, V] {
+	return NewDataLoader(func(keys []K) ([]*V, []error) {
+		values, errs := fetchFn(keys)
+		// errors that are not aligned with the keys fail the whole batch, there is nothing missing to resolve
+		resolve := missing.Missing != nil && (len(errs) == 0 || len(errs) == len(keys))
+
+		// every value gets an allocation of its own and the errors are copied before they are added to: the
+		// fetch may reuse its slices, and a cached value must not keep the values of its whole batch alive
+		results := make([]*V, len(keys))
+		ownErrs := false
+		for i, key := range keys {
+			if i < len(values) && !(missing.IsZeroMissing && isZero(values[i])) {
+				v := values[i]
+				results[i] = &v
+				continue
+			}
+			if !resolve || i < len(errs) && errs[i] != nil {
+				continue
+			}
+			v, err := missing.Missing(key)
+			if err != nil {
+				if !ownErrs {
+					aligned := make([]error, len(keys))
+					copy(aligned, errs)
+					errs, ownErrs = aligned, true
+				}
+				errs[i] = err
+				continue
+			}
+			results[i] = &v
+		}
+		return results, errs
+	}, waitDuration, maxBatch, opts...)
+}
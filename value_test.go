@@ -0,0 +1,143 @@
+package dataloaden
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMapDataLoader(t *testing.T) {
+	loader := NewMapDataLoader(func(keys []int) (map[int]int, error) {
+		found := map[int]int{}
+		for _, k := range keys {
+			if k >= 0 {
+				found[k] = k
+			}
+		}
+		return found, nil
+	}, time.Millisecond, 0)
+
+	results := loader.LoadAllResult([]int{0, 1, -1})
+	if r := results[0]; !r.Found || r.Err != nil || *r.Value != 0 {
+		t.Errorf("expected the zero value to be found, got %+v", r)
+	}
+	if r := results[1]; !r.Found || *r.Value != 1 {
+		t.Errorf("expected 1 to be found, got %+v", r)
+	}
+	if r := results[2]; r.Found || r.Err != nil || r.Value != nil {
+		t.Errorf("expected the absent key to be missing, got %+v", r)
+	}
+
+	errDown := errors.New("down")
+	failing := NewMapDataLoader(func(keys []int) (map[int]int, error) {
+		return nil, errDown
+	}, time.Millisecond, 0)
+	if r := failing.LoadResult(1); !errors.Is(r.Err, errDown) || r.Found {
+		t.Errorf("expected the error of the fetch, got %+v", r)
+	}
+}
+
+func TestValueDataLoader(t *testing.T) {
+	errGone := errors.New("gone")
+	// returns the key as the value, 0 for key 0, nothing for keys from 10 on
+	fetchFn := func(keys []int) ([]int, []error) {
+		values := make([]int, 0, len(keys))
+		for _, k := range keys {
+			if k >= 10 {
+				break
+			}
+			values = append(values, k)
+		}
+		return values, nil
+	}
+
+	tests := []struct {
+		name    string
+		missing MissingValues[int, int]
+
+		// the results of loading 0, 1 and 10
+		found  [3]bool
+		values [3]int
+
+		// the error every key fails with, the error of a key fails its whole batch
+		err error
+	}{
+		{
+			name:   "zero is a value",
+			found:  [3]bool{true, true, false},
+			values: [3]int{0, 1, 0},
+		},
+		{
+			name:    "zero is missing",
+			missing: MissingValues[int, int]{IsZeroMissing: true},
+			found:   [3]bool{false, true, false},
+			values:  [3]int{0, 1, 0},
+		},
+		{
+			name: "missing values substituted",
+			missing: MissingValues[int, int]{IsZeroMissing: true, Missing: func(key int) (int, error) {
+				return -key, nil
+			}},
+			found:  [3]bool{true, true, true},
+			values: [3]int{0, 1, -10},
+		},
+		{
+			name: "missing values fail",
+			missing: MissingValues[int, int]{Missing: func(key int) (int, error) {
+				return 0, errGone
+			}},
+			err: errGone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewValueDataLoader(fetchFn, tt.missing, time.Millisecond, 0)
+			for i, r := range loader.LoadAllResult([]int{0, 1, 10}) {
+				if tt.err != nil {
+					if !errors.Is(r.Err, tt.err) {
+						t.Errorf("expected %v for key %d, got %+v", tt.err, i, r)
+					}
+					continue
+				}
+				if r.Err != nil || r.Found != tt.found[i] {
+					t.Errorf("expected found %v for position %d, got %+v", tt.found[i], i, r)
+				}
+				if r.Found && *r.Value != tt.values[i] {
+					t.Errorf("expected %d at position %d, got %d", tt.values[i], i, *r.Value)
+				}
+				if !r.Found && r.Value != nil {
+					t.Errorf("expected no value for a missing key at position %d, got %d", i, *r.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestValueDataLoaderOwnsResults(t *testing.T) {
+	// the fetch reuses its slices for every batch, like a fetch backed by a pool
+	values, errs := make([]int, 2), make([]error, 2)
+	errOdd := errors.New("odd")
+	loader := NewValueDataLoader(func(keys []int) ([]int, []error) {
+		for i, k := range keys {
+			values[i] = k * 10
+		}
+		return values[:len(keys)], errs[:len(keys)]
+	}, MissingValues[int, int]{IsZeroMissing: true, Missing: func(key int) (int, error) {
+		return 0, errOdd
+	}}, 0, 0)
+
+	if v, _ := loader.Load(1); *v != 10 {
+		t.Fatalf("expected 10, got %d", *v)
+	}
+	_, _ = loader.Load(2)
+	if v, _ := loader.Load(1); *v != 10 {
+		t.Errorf("expected the cached value not to change with the fetch's slice, got %d", *v)
+	}
+
+	if _, err := loader.Load(0); !errors.Is(err, errOdd) {
+		t.Errorf("expected the error of the missing value, got %v", err)
+	}
+	if errs[0] != nil {
+		t.Errorf("expected the fetch's errors to be left alone, got %v", errs[0])
+	}
+}
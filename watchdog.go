@@ -0,0 +1,74 @@
+package dataloaden
+
+import (
+	"errors"
+	"runtime"
+	"time"
+)
+
+// ErrFetchStuck is delivered to the waiters of a batch that the watchdog force-completed
+var ErrFetchStuck = errors.New("dataloaden: fetch did not return in time")
+
+// Watchdog watches over dispatched batches and reports the ones whose fetch is taking too long
+type Watchdog[K comparable] struct {
+	// how long a fetch may run before the batch is considered stuck, 0 = disabled
+	After time.Duration
+
+	// called once for every stuck batch, it decides whether to log, page or ignore
+	OnStuck func(batch StuckBatch[K])
+
+	// complete stuck batches with ErrFetchStuck instead of waiting for the fetch to return,
+	// the results of a fetch that returns afterward are discarded
+	ForceComplete bool
+
+	// how long a batch may wait for the executor to run it, 0 = disabled, see WithExecutor
+	QueuedAfter time.Duration
+
+	// called once for every batch that waited QueuedAfter for the executor, Age is how long it has
+	// been queued so far
+	OnQueued func(batch StuckBatch[K])
+}
+
+// StuckBatch describes a batch whose fetch has not returned within Watchdog.After
+type StuckBatch[K comparable] struct {
+	// the name of the loader the batch belongs to
+	Loader string
+
+	// the keys that were sent to the fetch
+	Keys []K
+
+	// how long the fetch has been running
+	Age time.Duration
+}
+
+// GoroutineDump returns the stacks of all goroutines, which usually tells where the fetch is blocked.
+// It stops the world while collecting, so call it only when the dump is going to be used.
+func (s StuckBatch[K]) GoroutineDump() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// watch starts the watchdog timer for a batch that is about to be fetched, the caller must stop it
+// once the fetch returns
+func (b *genericLoaderBatch[K, V]) watch(l *Loader[K, V]) Timer {
+	started := l.clock.Now()
+	return l.clock.AfterFunc(l.watchdog.After, func() {
+		if l.watchdog.OnStuck != nil {
+			l.watchdog.OnStuck(StuckBatch[K]{
+				Loader: l.name,
+				Keys:   append([]K(nil), b.keys...),
+				Age:    l.clock.Now().Sub(started),
+			})
+		}
+		if l.watchdog.ForceComplete {
+			err := l.namedError(ErrFetchStuck)
+			b.complete(l, nil, []error{err}, err)
+		}
+	})
+}
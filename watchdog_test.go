@@ -0,0 +1,109 @@
+package dataloaden
+
+import (
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatchdogReportsStuckBatch(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	fetchFn := func(keys []int) ([]*string, []error) {
+		<-release
+		return make([]*string, len(keys)), nil
+	}
+
+	stuck := make(chan StuckBatch[int], 1)
+	loader := NewDataLoader(fetchFn, 1*time.Millisecond, 10, WithWatchdog[int, string](Watchdog[int]{
+		After: 10 * time.Millisecond,
+		OnStuck: func(batch StuckBatch[int]) {
+			stuck <- batch
+		},
+	}))
+
+	thunk := loader.LoadThunk(1)
+	loader.LoadThunk(2)
+
+	select {
+	case batch := <-stuck:
+		if !reflect.DeepEqual(batch.Keys, []int{1, 2}) {
+			t.Errorf("expected keys [1 2], got %v", batch.Keys)
+		}
+		if batch.Age < 10*time.Millisecond {
+			t.Errorf("expected age of at least 10ms, got %v", batch.Age)
+		}
+		if len(batch.GoroutineDump()) == 0 {
+			t.Errorf("expected a goroutine dump")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watchdog did not report the stuck batch")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = thunk()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("expected waiters to keep waiting without ForceComplete")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestWatchdogForceComplete(t *testing.T) {
+	release := make(chan struct{})
+	fetchFn := func(keys []int) ([]*string, []error) {
+		<-release
+		results := make([]*string, len(keys))
+		for i := range keys {
+			v := "late"
+			results[i] = &v
+		}
+		return results, nil
+	}
+
+	loader := NewDataLoader(fetchFn, 1*time.Millisecond, 10, WithWatchdog[int, string](Watchdog[int]{
+		After:         10 * time.Millisecond,
+		ForceComplete: true,
+	}))
+
+	val, err := loader.Load(1)
+	if !errors.Is(err, ErrFetchStuck) {
+		t.Fatalf("expected ErrFetchStuck, got %v", err)
+	}
+	if val != nil {
+		t.Errorf("expected nil value, got %v", *val)
+	}
+
+	// the late results are discarded instead of completing the batch a second time
+	close(release)
+	time.Sleep(10 * time.Millisecond)
+}
+
+func TestWatchdogStoppedOnCompletion(t *testing.T) {
+	fetchFn := func(keys []int) ([]*string, []error) {
+		return make([]*string, len(keys)), nil
+	}
+
+	var calls atomic.Int32
+	loader := NewDataLoader(fetchFn, 1*time.Millisecond, 10, WithWatchdog[int, string](Watchdog[int]{
+		After: 10 * time.Millisecond,
+		OnStuck: func(StuckBatch[int]) {
+			calls.Add(1)
+		},
+		ForceComplete: true,
+	}))
+
+	if _, err := loader.Load(1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if calls.Load() != 0 {
+		t.Errorf("expected the watchdog not to fire after the fetch returned, fired %d times", calls.Load())
+	}
+}
@@ -0,0 +1,89 @@
+package dataloaden
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sharedWheel schedules the batch windows of every loader created with WithLite
+var sharedWheel = &timerWheel{resolution: time.Millisecond}
+
+// timerWheel is a Clock whose timers are kept in slots of resolution width and run from a single goroutine,
+// which only ticks while there are timers. Delays are rounded up to the resolution.
+type timerWheel struct {
+	resolution time.Duration
+
+	mu      sync.Mutex
+	slots   map[int64][]*wheelTimer
+	running bool
+}
+
+const (
+	wheelTimerPending uint32 = iota
+	wheelTimerFired
+	wheelTimerStopped
+)
+
+type wheelTimer struct {
+	f     func()
+	state atomic.Uint32
+}
+
+func (t *wheelTimer) Stop() bool {
+	return t.state.CompareAndSwap(wheelTimerPending, wheelTimerStopped)
+}
+
+func (w *timerWheel) Now() time.Time {
+	return time.Now()
+}
+
+func (w *timerWheel) AfterFunc(d time.Duration, f func()) Timer {
+	t := &wheelTimer{f: f}
+	slot := (time.Now().Add(d).UnixNano() + int64(w.resolution) - 1) / int64(w.resolution)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.slots == nil {
+		w.slots = map[int64][]*wheelTimer{}
+	}
+	w.slots[slot] = append(w.slots[slot], t)
+	if !w.running {
+		w.running = true
+		go w.run()
+	}
+	return t
+}
+
+// run fires the timers of every slot that has passed, until the wheel is empty
+func (w *timerWheel) run() {
+	ticker := time.NewTicker(w.resolution)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		current := now.UnixNano() / int64(w.resolution)
+		var due []*wheelTimer
+
+		w.mu.Lock()
+		for slot, timers := range w.slots {
+			if slot <= current {
+				due = append(due, timers...)
+				delete(w.slots, slot)
+			}
+		}
+		empty := len(w.slots) == 0
+		if empty {
+			w.running = false
+		}
+		w.mu.Unlock()
+
+		for _, t := range due {
+			if t.state.CompareAndSwap(wheelTimerPending, wheelTimerFired) {
+				go t.f()
+			}
+		}
+		if empty {
+			return
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package dataloaden
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTimerWheel(t *testing.T) {
+	w := &timerWheel{resolution: time.Millisecond}
+
+	fired := make(chan struct{})
+	w.AfterFunc(2*time.Millisecond, func() { close(fired) })
+
+	var stoppedFired atomic.Bool
+	stopped := w.AfterFunc(time.Millisecond, func() { stoppedFired.Store(true) })
+	if !stopped.Stop() {
+		t.Fatal("expected Stop to report the timer as pending")
+	}
+	if stopped.Stop() {
+		t.Fatal("expected a second Stop to report nothing to stop")
+	}
+
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("timer did not fire")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if stoppedFired.Load() {
+		t.Fatal("stopped timer fired")
+	}
+
+	w.mu.Lock()
+	running := w.running
+	w.mu.Unlock()
+	if running {
+		t.Fatal("expected the wheel to stop ticking once empty")
+	}
+}
+
+func TestLite(t *testing.T) {
+	calls := 0
+	loader := NewDataLoader(func(keys []int) ([]*string, []error) {
+		calls++
+		values := make([]*string, len(keys))
+		for i := range keys {
+			v := "v"
+			values[i] = &v
+		}
+		return values, nil
+	}, time.Millisecond, 0, WithName[int, string]("lite"), WithLite[int, string]())
+
+	thunks := []func() (*string, error){loader.LoadThunk(1), loader.LoadThunk(2)}
+	for _, thunk := range thunks {
+		if v, err := thunk(); err != nil || v == nil || *v != "v" {
+			t.Fatalf("unexpected result %v %v", v, err)
+		}
+	}
+	loader.Load(1)
+
+	if calls != 1 {
+		t.Fatalf("expected one batch, got %d", calls)
+	}
+	if stats := loader.Stats(); stats.Name != "lite" || stats.Loads != 0 || stats.Batches != 0 {
+		t.Fatalf("expected no stats to be kept, got %+v", stats)
+	}
+	if info, ok := loader.Info(1); !ok || !info.StoredAt.IsZero() {
+		t.Fatalf("expected no write time to be kept, got %+v", info)
+	}
+}
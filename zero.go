@@ -0,0 +1,10 @@
+//go:build !minimal
+
+package dataloaden
+
+import "reflect"
+
+// isZero reports whether v is the zero value of V
+func isZero[V any](v V) bool {
+	return reflect.ValueOf(&v).Elem().IsZero()
+}
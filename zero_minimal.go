@@ -0,0 +1,15 @@
+//go:build minimal
+
+package dataloaden
+
+// isZero reports whether v equals the zero value of V. The minimal build avoids reflect, so values whose
+// type is not comparable, or holds something that is not, are never zero.
+func isZero[V any](v V) (zero bool) {
+	defer func() {
+		if recover() != nil {
+			zero = false
+		}
+	}()
+	var z V
+	return any(v) == any(z)
+}
@@ -0,0 +1,37 @@
+//go:build minimal
+
+package dataloaden
+
+import "testing"
+
+func TestIsZero(t *testing.T) {
+	type record struct {
+		name string
+		tags []string
+	}
+	tests := []struct {
+		name string
+		zero bool
+		got  bool
+	}{
+		{"int", true, isZero(0)},
+		{"non zero int", false, isZero(1)},
+		{"nil slice", false, isZero([]int(nil))},
+		{"struct with a slice", false, isZero(record{})},
+		{"pointer", true, isZero[*int](nil)},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.zero {
+			t.Errorf("%s: expected zero to be %v", tt.name, tt.zero)
+		}
+	}
+}
+
+func TestValueDataLoaderMinimal(t *testing.T) {
+	loader := NewValueDataLoader(func(keys []int) ([][]int, []error) {
+		return make([][]int, len(keys)), nil
+	}, MissingValues[int, []int]{IsZeroMissing: true}, 0, 0)
+	if result := loader.LoadResult(1); !result.Found {
+		t.Errorf("expected a nil slice to be found when zero values cannot be recognized, got %+v", result)
+	}
+}
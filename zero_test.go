@@ -0,0 +1,29 @@
+//go:build !minimal
+
+package dataloaden
+
+import "testing"
+
+func TestIsZero(t *testing.T) {
+	type record struct {
+		name string
+		tags []string
+	}
+	tests := []struct {
+		name string
+		zero bool
+		got  bool
+	}{
+		{"int", true, isZero(0)},
+		{"non zero int", false, isZero(1)},
+		{"nil slice", true, isZero([]int(nil))},
+		{"empty slice", false, isZero([]int{})},
+		{"struct with a slice", true, isZero(record{})},
+		{"non zero struct with a slice", false, isZero(record{name: "a"})},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.zero {
+			t.Errorf("%s: expected zero to be %v", tt.name, tt.zero)
+		}
+	}
+}